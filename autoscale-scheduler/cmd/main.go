@@ -7,6 +7,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // embed the timezone database, for VMs' scaling schedules
 
 	"github.com/tychoish/fun/srv"
 	"go.uber.org/zap"
@@ -0,0 +1,333 @@
+package main
+
+// Offline tool for auditing billing events, by comparing the events that were received against the
+// hourly summaries written by the autoscaler-agent when ".billing.summary" is configured.
+//
+// Both the events and the summaries are read from a local directory (e.g., from the local file
+// client, or synced from blob storage) or from an S3 prefix, given as "s3://bucket/prefix".
+// The format of each batch is determined from its extension: ".parquet" for Parquet, ".pb.gz" for
+// protobuf, and JSON (optionally gzipped) otherwise.
+//
+// Example usage:
+//
+//	go run ./autoscaler-agent/cmd/billing-reconcile \
+//		-events s3://billing-bucket/events/year=2024/month=10/day=31 \
+//		-summaries s3://billing-bucket/summaries/year=2024/month=10/day=31 \
+//		-cpu-metric effective_compute_seconds -active-time-metric active_time_seconds
+//
+// The tool exits with a non-zero status if any discrepancies are found.
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/parquet-go/parquet-go"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/billing"
+	"github.com/neondatabase/autoscaling/pkg/reporting"
+)
+
+var (
+	eventsSource     = flag.String("events", "", `Directory or "s3://bucket/prefix" containing the billing event batches`)
+	summariesSource  = flag.String("summaries", "", `Directory or "s3://bucket/prefix" containing the billing summaries`)
+	cpuMetric        = flag.String("cpu-metric", "", `Value of ".billing.cpuMetricName" in the autoscaler-agent config`)
+	activeTimeMetric = flag.String("active-time-metric", "", `Value of ".billing.activeTimeMetricName" in the autoscaler-agent config`)
+	s3Region         = flag.String("s3-region", "", "Region to use when reading from S3")
+	s3Endpoint       = flag.String("s3-endpoint", "", "Endpoint to use when reading from S3, if not the default")
+	maxGap           = flag.Duration("max-gap", 5*time.Minute, "Longest gap between an endpoint's events to report; longer gaps are assumed to be from the endpoint being stopped. 0 disables gap checks")
+)
+
+func main() {
+	flag.Parse()
+
+	ok, err := run(context.Background())
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(2)
+	} else if !ok {
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context) (ok bool, _ error) {
+	if *eventsSource == "" || *summariesSource == "" || *cpuMetric == "" || *activeTimeMetric == "" {
+		flag.Usage()
+		return false, errors.New("-events, -summaries, -cpu-metric, and -active-time-metric are all required")
+	}
+
+	events, err := readAll(ctx, *eventsSource, billing.IncrementalEventSchema)
+	if err != nil {
+		return false, fmt.Errorf("could not read events: %w", err)
+	}
+	summaries, err := readAll(ctx, *summariesSource, billing.HourlySummarySchema)
+	if err != nil {
+		return false, fmt.Errorf("could not read summaries: %w", err)
+	}
+
+	discrepancies := billing.Reconcile(billing.ReconcileConfig{
+		CPUMetricName:        *cpuMetric,
+		ActiveTimeMetricName: *activeTimeMetric,
+		MaxGap:               *maxGap,
+	}, summaries, events)
+
+	fmt.Fprintf(
+		os.Stderr,
+		"Compared %d events against %d summaries, found %d discrepancies\n",
+		len(events), len(summaries), len(discrepancies),
+	)
+	if len(discrepancies) == 0 {
+		return true, nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "HOUR\tENDPOINT\tKIND\tDETAIL")
+	for _, d := range discrepancies {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.HourStart.Format(time.RFC3339), d.EndpointID, d.Kind, d.Detail)
+	}
+	return false, w.Flush()
+}
+
+// object is a single batch read from the source
+type object struct {
+	key     string
+	content []byte
+}
+
+// readAll reads all of the objects from the source, returning their contents
+func readAll[T any](ctx context.Context, source string, schema *reporting.Schema[*T]) ([]T, error) {
+	var objects []object
+	var err error
+	if bucketAndPrefix, ok := strings.CutPrefix(source, "s3://"); ok {
+		bucket, prefix, _ := strings.Cut(bucketAndPrefix, "/")
+		objects, err = readS3(ctx, bucket, prefix)
+	} else {
+		objects, err = readDirectory(source)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var result []T
+	for _, obj := range objects {
+		items, err := decodeBatch(obj.key, obj.content, schema)
+		if err != nil {
+			return nil, fmt.Errorf("could not decode %q: %w", obj.key, err)
+		}
+		result = append(result, items...)
+	}
+	return result, nil
+}
+
+func readDirectory(dir string) ([]object, error) {
+	var objects []object
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		objects = append(objects, object{key: path, content: content})
+		return nil
+	})
+	return objects, err
+}
+
+func readS3(ctx context.Context, bucket, prefix string) ([]object, error) {
+	cfg, err := awsconfig.LoadDefaultConfig(ctx, awsconfig.WithRegion(*s3Region))
+	if err != nil {
+		return nil, err
+	}
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if *s3Endpoint != "" {
+			o.BaseEndpoint = s3Endpoint
+		}
+		o.UsePathStyle = true // required for minio
+	})
+
+	var objects []object
+	pages := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{ //nolint:exhaustruct // AWS SDK
+		Bucket: &bucket,
+		Prefix: &prefix,
+	})
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("could not list objects: %w", err)
+		}
+
+		for _, obj := range page.Contents {
+			resp, err := client.GetObject(ctx, &s3.GetObjectInput{ //nolint:exhaustruct // AWS SDK
+				Bucket: &bucket,
+				Key:    obj.Key,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("could not get object %q: %w", *obj.Key, err)
+			}
+			content, err := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			if err != nil {
+				return nil, fmt.Errorf("could not read object %q: %w", *obj.Key, err)
+			}
+			objects = append(objects, object{key: *obj.Key, content: content})
+		}
+	}
+
+	return objects, nil
+}
+
+// decodeBatch decodes a single batch, using its key to determine the format.
+func decodeBatch[T any](key string, content []byte, schema *reporting.Schema[*T]) ([]T, error) {
+	switch {
+	case strings.HasSuffix(key, ".parquet"):
+		records, err := decodeParquet(content, schema)
+		if err != nil {
+			return nil, err
+		}
+		return fromRecords[T](records)
+	case strings.HasSuffix(key, ".pb.gz"):
+		gz, err := gzip.NewReader(bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		data, err := io.ReadAll(gz)
+		if err != nil {
+			return nil, err
+		}
+		records, err := reporting.DecodeProtobufBatch(schema, data)
+		if err != nil {
+			return nil, err
+		}
+		return fromRecords[T](records)
+	default:
+		return decodeJSON[T](content)
+	}
+}
+
+// decodeParquet decodes a batch written by reporting.ParquetBuilder, returning each row as a map
+// from column name to value, in the same form as reporting.DecodeProtobufBatch.
+func decodeParquet[E any](content []byte, schema *reporting.Schema[E]) ([]map[string]any, error) {
+	reader := parquet.NewReader(bytes.NewReader(content))
+	defer reader.Close()
+
+	// Find the schema field for each column; columns that aren't in the schema are ignored.
+	fieldsByName := make(map[string]*reporting.Field[E])
+	for i := range schema.Fields {
+		fieldsByName[schema.Fields[i].Name] = &schema.Fields[i]
+	}
+	var columns []*reporting.Field[E]
+	for _, path := range reader.Schema().Columns() {
+		columns = append(columns, fieldsByName[strings.Join(path, ".")])
+	}
+
+	var records []map[string]any
+	rows := make([]parquet.Row, 64)
+	for {
+		n, err := reader.ReadRows(rows)
+		for _, row := range rows[:n] {
+			record := make(map[string]any)
+			for _, v := range row {
+				f := columns[v.Column()]
+				if f == nil || v.IsNull() {
+					continue
+				}
+				switch f.Type {
+				case reporting.FieldTypeString:
+					record[f.Name] = string(v.ByteArray())
+				case reporting.FieldTypeInt64:
+					record[f.Name] = v.Int64()
+				case reporting.FieldTypeTimestamp:
+					record[f.Name] = time.UnixMicro(v.Int64()).UTC()
+				case reporting.FieldTypeDouble:
+					record[f.Name] = v.Double()
+				case reporting.FieldTypeStringMap:
+					var m map[string]string
+					if err := json.Unmarshal(v.ByteArray(), &m); err != nil {
+						return nil, fmt.Errorf("could not decode column %q: %w", f.Name, err)
+					}
+					record[f.Name] = m
+				}
+			}
+			records = append(records, record)
+		}
+		if errors.Is(err, io.EOF) {
+			return records, nil
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// fromRecords converts the decoded records into T, using the JSON field names of T (which match
+// the names of the fields in its schema).
+func fromRecords[T any](records []map[string]any) ([]T, error) {
+	var items []T
+	for _, r := range records {
+		data, err := json.Marshal(r)
+		if err != nil {
+			return nil, err
+		}
+		var item T
+		if err := json.Unmarshal(data, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// decodeJSON decodes a single batch, as written by either reporting.JSONLinesBuilder or
+// reporting.JSONArrayBuilder, optionally gzipped.
+func decodeJSON[T any](content []byte) ([]T, error) {
+	var r io.Reader = bytes.NewReader(content)
+	if bytes.HasPrefix(content, []byte{0x1f, 0x8b}) {
+		gz, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	var items []T
+	dec := json.NewDecoder(r)
+	for {
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); errors.Is(err, io.EOF) {
+			return items, nil
+		} else if err != nil {
+			return nil, err
+		}
+
+		// JSON arrays are wrapped in an object, like {"events": [...]}
+		var wrapper struct {
+			Events []T `json:"events"`
+		}
+		if err := json.Unmarshal(raw, &wrapper); err == nil && wrapper.Events != nil {
+			items = append(items, wrapper.Events...)
+			continue
+		}
+
+		var item T
+		if err := json.Unmarshal(raw, &item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os/signal"
 	"syscall"
 	"time"
+	_ "time/tzdata" // embed the timezone database, for VMs' scaling schedules
 
 	"github.com/tychoish/fun/srv"
 	"go.uber.org/zap"
@@ -60,6 +61,8 @@ func main() {
 		Config:     config,
 		KubeClient: kubeClient,
 		VMClient:   vmClient,
+
+		GoalPolicies: nil, // only the default policy
 	}
 
 	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGTERM)
@@ -0,0 +1,78 @@
+package main
+
+// Offline tool for replaying a recording of a VM's scaling inputs, made by the autoscaler-agent
+// when the VM is listed in the "recording" section of its config.
+//
+// Example usage:
+//
+//	go run ./autoscaler-agent/cmd/replay -actions \
+//		default_compute-foo-2024-01-01T00-00-00.000.jsonl default_compute-foo.jsonl
+//
+// Rotated files should be given oldest first, followed by the current file.
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/recording"
+)
+
+var printActions = flag.Bool("actions", false, `Print each replayed ActionSet as a line of JSON`)
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s [-actions] <recording files...>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if err := run(flag.Args()); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(paths []string) error {
+	if len(paths) == 0 {
+		flag.Usage()
+		return errors.New("no recording files given")
+	}
+
+	var events []recording.Event
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		fileEvents, err := recording.ReadEvents(file)
+		_ = file.Close()
+		if err != nil {
+			return fmt.Errorf("could not read %q: %w", path, err)
+		}
+		events = append(events, fileEvents...)
+	}
+
+	result, err := recording.Replay(events, nil)
+	if err != nil {
+		return err
+	}
+
+	if *printActions {
+		enc := json.NewEncoder(os.Stdout)
+		for _, a := range result.Actions {
+			if err := enc.Encode(a); err != nil {
+				return err
+			}
+		}
+	}
+
+	fmt.Fprintf(
+		os.Stderr,
+		"Replayed %d events, reproducing %d ActionSets (skipped %d events before the first init)\n",
+		len(events)-result.Skipped, len(result.Actions), result.Skipped,
+	)
+	return nil
+}
@@ -0,0 +1,178 @@
+package main
+
+// Offline tool for comparing autoscaler-agent configs.
+//
+// Given a recorded time series of metrics (as JSON lines of simulate.Sample) and the VM's VmInfo,
+// this replays the metrics through the agent's scaling logic once for each config, and prints a
+// summary of the resulting scaling for each.
+//
+// Example usage:
+//
+//	go run ./autoscaler-agent/cmd/simulate -vm vminfo.json -metrics metrics.jsonl \
+//		-config current.json -config proposed.json -cu-hour-price 0.16
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/neondatabase/autoscaling/pkg/agent"
+	"github.com/neondatabase/autoscaling/pkg/agent/simulate"
+	"github.com/neondatabase/autoscaling/pkg/api"
+)
+
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+var (
+	configPaths stringList
+
+	vmPath      = flag.String("vm", "", `File containing the VM's initial VmInfo, as JSON: --vm=vminfo.json`)
+	metricsPath = flag.String("metrics", "", `File containing the recorded metrics, as JSON lines: --metrics=metrics.jsonl`)
+	step        = flag.Duration("step", time.Second, `Simulated time between scaling decisions: --step=1s`)
+	outPath     = flag.String("out", "", `Optionally write the full results, including timelines, as JSON: --out=results.json`)
+	cuHourPrice = flag.Float64("cu-hour-price", 0, `Optional price per CU-hour, to include cost in the summary: --cu-hour-price=0.16`)
+)
+
+func init() {
+	flag.Var(&configPaths, "config", `autoscaler-agent config file, may be repeated to compare configs: --config=config.json`)
+}
+
+func main() {
+	flag.Parse()
+
+	if err := run(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+type configResult struct {
+	Config string           `json:"config"`
+	Result *simulate.Result `json:"result"`
+}
+
+func run() error {
+	if *vmPath == "" || *metricsPath == "" || len(configPaths) == 0 {
+		flag.PrintDefaults()
+		return errors.New("missing required flags")
+	}
+
+	vm, err := readVmInfo(*vmPath)
+	if err != nil {
+		return fmt.Errorf("could not read VmInfo: %w", err)
+	}
+
+	samples, err := readSamples(*metricsPath)
+	if err != nil {
+		return fmt.Errorf("could not read metrics: %w", err)
+	}
+
+	var results []configResult
+	for _, path := range configPaths {
+		config, err := agent.ReadConfig(path)
+		if err != nil {
+			return fmt.Errorf("could not read config %q: %w", path, err)
+		}
+
+		result, err := simulate.Run(*vm, config.CoreConfig(), samples, *step)
+		if err != nil {
+			return fmt.Errorf("simulation with config %q failed: %w", path, err)
+		}
+
+		results = append(results, configResult{Config: path, Result: result})
+	}
+
+	printSummaries(results)
+
+	if *outPath != "" {
+		data, err := json.MarshalIndent(results, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not marshal results: %w", err)
+		}
+		if err := os.WriteFile(*outPath, data, 0o644); err != nil {
+			return fmt.Errorf("could not write results: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func readVmInfo(path string) (*api.VmInfo, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var vm api.VmInfo
+	if err := json.Unmarshal(data, &vm); err != nil {
+		return nil, err
+	}
+	return &vm, nil
+}
+
+func readSamples(path string) ([]simulate.Sample, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var samples []simulate.Sample
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+
+		var sample simulate.Sample
+		if err := json.Unmarshal(scanner.Bytes(), &sample); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		samples = append(samples, sample)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return samples, nil
+}
+
+func printSummaries(results []configResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	header := "CONFIG\tCU-HOURS\tAVG CU\tMIN CU\tMAX CU\tUPSCALES\tDOWNSCALES\tUNDER GOAL"
+	if *cuHourPrice != 0 {
+		header += "\tCOST"
+	}
+	fmt.Fprintln(w, header)
+
+	for _, r := range results {
+		s := r.Result.Summary
+		line := fmt.Sprintf(
+			"%s\t%.3f\t%.2f\t%d\t%d\t%d\t%d\t%s",
+			r.Config, s.CUHours, s.AverageCU, s.MinCU, s.MaxCU, s.Upscales, s.Downscales, s.UnderGoal,
+		)
+		if *cuHourPrice != 0 {
+			line += fmt.Sprintf("\t%.2f", s.CUHours**cuHourPrice)
+		}
+		fmt.Fprintln(w, line)
+	}
+}
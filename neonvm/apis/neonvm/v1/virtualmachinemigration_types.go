@@ -34,7 +34,7 @@ type VirtualMachineMigrationSpec struct {
 	// TODO: not implemented
 	// +optional
 	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
-	// TODO: not implemented
+	// NodeAffinity, if set, is added to the node affinity of the target pod.
 	// +optional
 	NodeAffinity *corev1.NodeAffinity `json:"nodeAffinity,omitempty"`
 
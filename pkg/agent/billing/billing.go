@@ -18,18 +18,37 @@ import (
 )
 
 type Config struct {
-	Clients                ClientsConfig `json:"clients"`
-	CPUMetricName          string        `json:"cpuMetricName"`
-	ActiveTimeMetricName   string        `json:"activeTimeMetricName"`
-	CollectEverySeconds    uint          `json:"collectEverySeconds"`
-	AccumulateEverySeconds uint          `json:"accumulateEverySeconds"`
+	Clients              ClientsConfig `json:"clients"`
+	CPUMetricName        string        `json:"cpuMetricName"`
+	ActiveTimeMetricName string        `json:"activeTimeMetricName"`
+	// MemoryMetricName, if not empty, enables reporting the VM's memory allocation over time, in
+	// GiB-seconds.
+	MemoryMetricName string `json:"memoryMetricName,omitempty"`
+	// Network, if not nil, enables reporting network usage for VMs with network monitoring
+	// enabled.
+	Network *NetworkConfig `json:"network,omitempty"`
+	// Labels, if not nil, enables copying some of each VM's labels or annotations into its billing
+	// events.
+	Labels *LabelsConfig `json:"labels,omitempty"`
+	// Summary, if not nil, enables sending hourly summaries of the billing events for each
+	// endpoint to a separate set of clients, so that the events can be audited later.
+	Summary                *SummaryConfig `json:"summary,omitempty"`
+	CollectEverySeconds    uint           `json:"collectEverySeconds"`
+	AccumulateEverySeconds uint           `json:"accumulateEverySeconds"`
 }
 
 type metricsState struct {
 	historical      map[metricsKey]vmMetricsHistory
 	present         map[metricsKey]vmMetricsInstant
+	network         map[metricsKey]networkCounters
 	lastCollectTime *time.Time
 	pushWindowStart time.Time
+
+	// labels stores the most recent labels for each VM in historical, if conf.Labels is not nil.
+	labels map[metricsKey]map[string]string
+
+	// summaries, if not nil, tracks the hourly summaries of the events we've sent.
+	summaries *summaryTracker
 }
 
 type metricsKey struct {
@@ -53,6 +72,8 @@ func (m *metricsTimeSlice) Duration() time.Duration { return m.endTime.Sub(m.sta
 type vmMetricsInstant struct {
 	// cpu stores the cpu allocation at a particular instant.
 	cpu vmv1.MilliCPU
+	// memory stores the memory allocation at a particular instant.
+	memory api.Bytes
 }
 
 // vmMetricsSeconds is like vmMetrics, but the values cover the allocation over time
@@ -60,14 +81,28 @@ type vmMetricsSeconds struct {
 	// cpu stores the CPU seconds allocated to the VM, roughly equivalent to the integral of CPU
 	// usage over time.
 	cpu float64
+	// memory stores the memory allocated to the VM over time, in GiB-seconds.
+	memory float64
 	// activeTime stores the total time that the VM was active
 	activeTime time.Duration
+	// network stores the total network bytes used by the VM. Unlike the other fields, this is
+	// not derived from the time slices, but from the increase in the runner's counters.
+	//
+	// It is nil if we didn't have network counters for the VM during this period, e.g. because it
+	// doesn't have network monitoring enabled.
+	network *networkCounters
 }
 
 type MetricsCollector struct {
 	conf    *Config
 	sink    *reporting.EventSink[*IncrementalEvent]
 	metrics PromMetrics
+
+	// summarySink is the sink for hourly summaries, if enabled. Otherwise nil.
+	summarySink *reporting.EventSink[*HourlySummary]
+
+	// network fetches the VMs' network counters in the background, if enabled. Otherwise nil.
+	network *networkFetcher
 }
 
 func NewMetricsCollector(
@@ -78,17 +113,33 @@ func NewMetricsCollector(
 ) (*MetricsCollector, error) {
 	logger := parentLogger.Named("billing")
 
-	clients, err := createClients(ctx, logger, conf.Clients)
+	clients, err := createClients[*IncrementalEvent](ctx, logger, conf.Clients, "billing events", IncrementalEventSchema)
 	if err != nil {
 		return nil, err
 	}
 
 	sink := reporting.NewEventSink(logger, metrics.reporting, clients...)
 
+	var summarySink *reporting.EventSink[*HourlySummary]
+	if conf.Summary != nil {
+		summaryClients, err := createClients[*HourlySummary](ctx, logger, conf.Summary.Clients, "billing summaries", HourlySummarySchema)
+		if err != nil {
+			return nil, fmt.Errorf("error creating billing summary clients: %w", err)
+		}
+		summarySink = reporting.NewEventSink(logger.Named("summary"), metrics.summaryReporting, summaryClients...)
+	}
+
+	var network *networkFetcher
+	if conf.Network != nil {
+		network = newNetworkFetcher(conf.Network, time.Second*time.Duration(conf.CollectEverySeconds))
+	}
+
 	return &MetricsCollector{
-		conf:    conf,
-		sink:    sink,
-		metrics: metrics,
+		conf:        conf,
+		sink:        sink,
+		metrics:     metrics,
+		summarySink: summarySink,
+		network:     network,
 	}, nil
 }
 
@@ -116,6 +167,23 @@ func (mc *MetricsCollector) Run(
 		return nil
 	})
 
+	if mc.network != nil {
+		tg.Go("network-fetch", func(logger *zap.Logger) error {
+			mc.network.run(tg.Ctx(), logger, store)
+			return nil
+		})
+	}
+
+	if mc.summarySink != nil {
+		tg.Go("summary-sink-run", func(logger *zap.Logger) error {
+			err := mc.summarySink.Run(sinkCtx) // note: NOT tg.Ctx(); see more above.
+			if err != nil {
+				return fmt.Errorf("billing summary sink failed: %w", err)
+			}
+			return nil
+		})
+	}
+
 	return tg.Wait()
 }
 
@@ -134,11 +202,17 @@ func (mc *MetricsCollector) runCollector(
 	state := metricsState{
 		historical:      make(map[metricsKey]vmMetricsHistory),
 		present:         make(map[metricsKey]vmMetricsInstant),
+		network:         make(map[metricsKey]networkCounters),
 		lastCollectTime: nil,
 		pushWindowStart: time.Now(),
+		labels:          make(map[metricsKey]map[string]string),
+		summaries:       nil,
+	}
+	if mc.summarySink != nil {
+		state.summaries = newSummaryTracker(mc.summarySink)
 	}
 
-	state.collect(logger, store, mc.metrics)
+	state.collect(logger, mc.conf, store, mc.network, mc.metrics)
 
 	for {
 		select {
@@ -149,24 +223,33 @@ func (mc *MetricsCollector) runCollector(
 				logger.Panic("Validation check failed", zap.Error(err))
 				return err
 			}
-			state.collect(logger, store, mc.metrics)
+			state.collect(logger, mc.conf, store, mc.network, mc.metrics)
 		case <-accumulateTicker.C:
 			logger.Info("Creating billing batch")
-			state.drainEnqueue(logger, mc.conf, GetHostname(), mc.sink)
+			state.drainEnqueue(logger, mc.conf, GetHostname(), mc.sink, mc.metrics)
 		case <-ctx.Done():
+			if state.summaries != nil {
+				// Send the summaries for the current hour, so that the events we've already sent
+				// are accounted for. If we restart, there'll be a second summary for this hour.
+				state.summaries.flush(time.Now(), true)
+			}
 			return nil
 		}
 	}
 }
 
-func (s *metricsState) collect(logger *zap.Logger, store VMStoreForNode, metrics PromMetrics) {
+func (s *metricsState) collect(
+	logger *zap.Logger,
+	conf *Config,
+	store VMStoreForNode,
+	network *networkFetcher,
+	metrics PromMetrics,
+) {
 	now := time.Now()
 
 	metricsBatch := metrics.forBatch()
 	defer metricsBatch.finish() // This doesn't *really* need to be deferred, but it's up here so we don't forget
 
-	old := s.present
-	s.present = make(map[metricsKey]vmMetricsInstant)
 	var vmsOnThisNode []*vmv1.VirtualMachine
 	if store.Failing() {
 		logger.Error("VM store is currently stopped. No events will be recorded")
@@ -175,6 +258,32 @@ func (s *metricsState) collect(logger *zap.Logger, store VMStoreForNode, metrics
 			return i.List()
 		})
 	}
+
+	// Use the most recent network counters that were fetched in the background, so that slow
+	// runner pods don't hold up collection.
+	var counters map[types.UID]networkCounters
+	if network != nil {
+		counters = network.latest()
+	}
+
+	s.update(conf, now, vmsOnThisNode, counters, metricsBatch)
+}
+
+// update records the VMs' current state, adding to the history of any that were also present at
+// the previous collection.
+func (s *metricsState) update(
+	conf *Config,
+	now time.Time,
+	vmsOnThisNode []*vmv1.VirtualMachine,
+	network map[types.UID]networkCounters,
+	metricsBatch batchMetrics,
+) {
+	old := s.present
+	s.present = make(map[metricsKey]vmMetricsInstant)
+
+	oldNetwork := s.network
+	s.network = make(map[metricsKey]networkCounters)
+
 	for _, vm := range vmsOnThisNode {
 		endpointID, isEndpoint := vm.Annotations[api.AnnotationBillingEndpointID]
 		metricsBatch.inc(isEndpointFlag(isEndpoint), autoscalingEnabledFlag(api.HasAutoscalingEnabled(vm)), vm.Status.Phase)
@@ -192,14 +301,28 @@ func (s *metricsState) collect(logger *zap.Logger, store VMStoreForNode, metrics
 			endpointID: endpointID,
 		}
 		presentMetrics := vmMetricsInstant{
-			cpu: *vm.Status.CPUs,
+			cpu:    *vm.Status.CPUs,
+			memory: 0,
+		}
+		if vm.Status.MemorySize != nil {
+			presentMetrics.memory = api.BytesFromResourceQuantity(*vm.Status.MemorySize)
 		}
+
+		// VMs without network monitoring (or that we haven't yet fetched from) won't have
+		// counters. Failed fetches are handled by networkFetcher keeping the previous counters.
+		prevNetwork, hadNetwork := oldNetwork[key]
+		presentNetwork, hasNetwork := network[vm.UID]
+		if hasNetwork {
+			s.network[key] = presentNetwork
+		}
+
 		if oldMetrics, ok := old[key]; ok {
 			// The VM was present from s.lastTime to now. Add a time slice to its metrics history.
 			timeSlice := metricsTimeSlice{
 				metrics: vmMetricsInstant{
 					// strategically under-bill by assigning the minimum to the entire time slice.
-					cpu: min(oldMetrics.cpu, presentMetrics.cpu),
+					cpu:    min(oldMetrics.cpu, presentMetrics.cpu),
+					memory: min(oldMetrics.memory, presentMetrics.memory),
 				},
 				// note: we know s.lastTime != nil because otherwise old would be empty.
 				startTime: *s.lastCollectTime,
@@ -210,12 +333,30 @@ func (s *metricsState) collect(logger *zap.Logger, store VMStoreForNode, metrics
 			if !ok {
 				vmHistory = vmMetricsHistory{
 					lastSlice: nil,
-					total:     vmMetricsSeconds{cpu: 0, activeTime: time.Duration(0)},
+					total: vmMetricsSeconds{
+						cpu:        0,
+						memory:     0,
+						activeTime: time.Duration(0),
+						network:    nil,
+					},
 				}
 			}
 			// append the slice, merging with the previous if the resource usage was the same
 			vmHistory.appendSlice(timeSlice)
+			if hasNetwork {
+				if vmHistory.total.network == nil {
+					vmHistory.total.network = &networkCounters{ingress: 0, egress: 0}
+				}
+				if hadNetwork {
+					delta := presentNetwork.sub(prevNetwork)
+					vmHistory.total.network.ingress += delta.ingress
+					vmHistory.total.network.egress += delta.egress
+				}
+			}
 			s.historical[key] = vmHistory
+			if labels := extractLabels(conf.Labels, vm); labels != nil {
+				s.labels[key] = labels
+			}
 		}
 
 		s.present[key] = presentMetrics
@@ -251,12 +392,10 @@ func (h *vmMetricsHistory) finalizeCurrentTimeSlice() {
 
 	// TODO: This approach is imperfect. Floating-point math is probably *fine*, but really not
 	// something we want to rely on. A "proper" solution is a lot of work, but long-term valuable.
-	metricsSeconds := vmMetricsSeconds{
-		cpu:        duration.Seconds() * h.lastSlice.metrics.cpu.AsFloat64(),
-		activeTime: duration,
-	}
-	h.total.cpu += metricsSeconds.cpu
-	h.total.activeTime += metricsSeconds.activeTime
+	const gib = float64(1 << 30)
+	h.total.cpu += duration.Seconds() * h.lastSlice.metrics.cpu.AsFloat64()
+	h.total.memory += duration.Seconds() * float64(h.lastSlice.metrics.memory) / gib
+	h.total.activeTime += duration
 
 	h.lastSlice = nil
 }
@@ -290,41 +429,80 @@ func (s *metricsState) drainEnqueue(
 	conf *Config,
 	hostname string,
 	sink *reporting.EventSink[*IncrementalEvent],
+	metrics PromMetrics,
 ) {
 	now := time.Now()
 
-	countInBatch := 0
-	batchSize := 2 * len(s.historical)
+	labels := limitLabelCardinality(logger, conf.Labels, s.labels, metrics)
+	events := s.drain(conf, now, labels)
 
 	enqueue := sink.Enqueue
+	if s.summaries != nil {
+		enqueue = func(event *IncrementalEvent) {
+			sink.Enqueue(event)
+			s.summaries.add(conf, hostname, event)
+		}
+	}
+
+	for i, event := range events {
+		enqueue(logAddedEvent(logger, enrichEvents(now, hostname, i+1, len(events), event)))
+	}
+
+	if s.summaries != nil {
+		s.summaries.flush(now, false)
+	}
+}
+
+// drain returns the events for the VMs' usage since the last push, resetting the history.
+//
+// The returned events still need to be passed through enrichEvents.
+func (s *metricsState) drain(
+	conf *Config,
+	now time.Time,
+	labels map[metricsKey]map[string]string,
+) []*IncrementalEvent {
+	var events []*IncrementalEvent
 
 	for key, history := range s.historical {
 		history.finalizeCurrentTimeSlice()
+		vmLabels := labels[key]
+
+		newEvent := func(metricName string, value int) *IncrementalEvent {
+			return &IncrementalEvent{
+				MetricName:     metricName,
+				Type:           "", // set by enrichEvents
+				IdempotencyKey: "", // set by enrichEvents
+				EndpointID:     key.endpointID,
+				// TODO: maybe we should store start/stop time in the vmMetricsHistory object itself?
+				// That way we can be aligned to collection, rather than pushing.
+				StartTime: s.pushWindowStart,
+				StopTime:  now,
+				Value:     value,
+				Labels:    vmLabels,
+			}
+		}
 
-		countInBatch += 1
-		enqueue(logAddedEvent(logger, enrichEvents(now, hostname, countInBatch, batchSize, &IncrementalEvent{
-			MetricName:     conf.CPUMetricName,
-			Type:           "", // set by enrichEvents
-			IdempotencyKey: "", // set by enrichEvents
-			EndpointID:     key.endpointID,
-			// TODO: maybe we should store start/stop time in the vmMetricsHistory object itself?
-			// That way we can be aligned to collection, rather than pushing.
-			StartTime: s.pushWindowStart,
-			StopTime:  now,
-			Value:     int(math.Round(history.total.cpu)),
-		})))
-		countInBatch += 1
-		enqueue(logAddedEvent(logger, enrichEvents(now, hostname, countInBatch, batchSize, &IncrementalEvent{
-			MetricName:     conf.ActiveTimeMetricName,
-			Type:           "", // set by enrichEvents
-			IdempotencyKey: "", // set by enrichEvents
-			EndpointID:     key.endpointID,
-			StartTime:      s.pushWindowStart,
-			StopTime:       now,
-			Value:          int(math.Round(history.total.activeTime.Seconds())),
-		})))
+		events = append(
+			events,
+			newEvent(conf.CPUMetricName, int(math.Round(history.total.cpu))),
+			newEvent(conf.ActiveTimeMetricName, int(math.Round(history.total.activeTime.Seconds()))),
+		)
+		if conf.MemoryMetricName != "" {
+			events = append(events, newEvent(conf.MemoryMetricName, int(math.Round(history.total.memory))))
+		}
+		// Only report network usage for VMs that we've actually got counters from.
+		if conf.Network != nil && history.total.network != nil {
+			events = append(
+				events,
+				newEvent(conf.Network.IngressMetricName, int(history.total.network.ingress)),
+				newEvent(conf.Network.EgressMetricName, int(history.total.network.egress)),
+			)
+		}
 	}
 
 	s.pushWindowStart = now
 	s.historical = make(map[metricsKey]vmMetricsHistory)
+	s.labels = make(map[metricsKey]map[string]string)
+
+	return events
 }
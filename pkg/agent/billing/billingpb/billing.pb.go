@@ -0,0 +1,319 @@
+// Protobuf definitions for billing events, when a client is configured with "format": "protobuf".
+//
+// Batches are a sequence of length-delimited messages, starting with a reporting.BatchHeader (see
+// pkg/reporting/batch.proto) with schema_name set to "billing.IncrementalEvent" or
+// "billing.HourlySummary".
+//
+// These must be kept in sync with the schemas in schema.go. Timestamps are microseconds since the
+// unix epoch. In Parquet files, map fields are stored as JSON-encoded strings.
+//
+// After changing this file, regenerate the Go code with 'make generate-proto'.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: pkg/agent/billing/billing.proto
+
+package billingpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Schema version 2
+type IncrementalEvent struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	IdempotencyKey string                 `protobuf:"bytes,1,opt,name=idempotency_key,json=idempotencyKey,proto3" json:"idempotency_key,omitempty"`
+	Metric         string                 `protobuf:"bytes,2,opt,name=metric,proto3" json:"metric,omitempty"`
+	Type           string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	EndpointId     string                 `protobuf:"bytes,4,opt,name=endpoint_id,json=endpointId,proto3" json:"endpoint_id,omitempty"`
+	StartTime      int64                  `protobuf:"varint,5,opt,name=start_time,json=startTime,proto3" json:"start_time,omitempty"`
+	StopTime       int64                  `protobuf:"varint,6,opt,name=stop_time,json=stopTime,proto3" json:"stop_time,omitempty"`
+	Value          int64                  `protobuf:"varint,7,opt,name=value,proto3" json:"value,omitempty"`
+	// Added in version 2.
+	Labels        map[string]string `protobuf:"bytes,8,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncrementalEvent) Reset() {
+	*x = IncrementalEvent{}
+	mi := &file_pkg_agent_billing_billing_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncrementalEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncrementalEvent) ProtoMessage() {}
+
+func (x *IncrementalEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_agent_billing_billing_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncrementalEvent.ProtoReflect.Descriptor instead.
+func (*IncrementalEvent) Descriptor() ([]byte, []int) {
+	return file_pkg_agent_billing_billing_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *IncrementalEvent) GetIdempotencyKey() string {
+	if x != nil {
+		return x.IdempotencyKey
+	}
+	return ""
+}
+
+func (x *IncrementalEvent) GetMetric() string {
+	if x != nil {
+		return x.Metric
+	}
+	return ""
+}
+
+func (x *IncrementalEvent) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *IncrementalEvent) GetEndpointId() string {
+	if x != nil {
+		return x.EndpointId
+	}
+	return ""
+}
+
+func (x *IncrementalEvent) GetStartTime() int64 {
+	if x != nil {
+		return x.StartTime
+	}
+	return 0
+}
+
+func (x *IncrementalEvent) GetStopTime() int64 {
+	if x != nil {
+		return x.StopTime
+	}
+	return 0
+}
+
+func (x *IncrementalEvent) GetValue() int64 {
+	if x != nil {
+		return x.Value
+	}
+	return 0
+}
+
+func (x *IncrementalEvent) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+// Schema version 1
+type HourlySummary struct {
+	state               protoimpl.MessageState `protogen:"open.v1"`
+	EndpointId          string                 `protobuf:"bytes,1,opt,name=endpoint_id,json=endpointId,proto3" json:"endpoint_id,omitempty"`
+	Hostname            string                 `protobuf:"bytes,2,opt,name=hostname,proto3" json:"hostname,omitempty"`
+	HourStart           int64                  `protobuf:"varint,3,opt,name=hour_start,json=hourStart,proto3" json:"hour_start,omitempty"`
+	CpuSeconds          int64                  `protobuf:"varint,4,opt,name=cpu_seconds,json=cpuSeconds,proto3" json:"cpu_seconds,omitempty"`
+	ActiveSeconds       int64                  `protobuf:"varint,5,opt,name=active_seconds,json=activeSeconds,proto3" json:"active_seconds,omitempty"`
+	Events              int64                  `protobuf:"varint,6,opt,name=events,proto3" json:"events,omitempty"`
+	FirstIdempotencyKey string                 `protobuf:"bytes,7,opt,name=first_idempotency_key,json=firstIdempotencyKey,proto3" json:"first_idempotency_key,omitempty"`
+	LastIdempotencyKey  string                 `protobuf:"bytes,8,opt,name=last_idempotency_key,json=lastIdempotencyKey,proto3" json:"last_idempotency_key,omitempty"`
+	unknownFields       protoimpl.UnknownFields
+	sizeCache           protoimpl.SizeCache
+}
+
+func (x *HourlySummary) Reset() {
+	*x = HourlySummary{}
+	mi := &file_pkg_agent_billing_billing_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *HourlySummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*HourlySummary) ProtoMessage() {}
+
+func (x *HourlySummary) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_agent_billing_billing_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use HourlySummary.ProtoReflect.Descriptor instead.
+func (*HourlySummary) Descriptor() ([]byte, []int) {
+	return file_pkg_agent_billing_billing_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *HourlySummary) GetEndpointId() string {
+	if x != nil {
+		return x.EndpointId
+	}
+	return ""
+}
+
+func (x *HourlySummary) GetHostname() string {
+	if x != nil {
+		return x.Hostname
+	}
+	return ""
+}
+
+func (x *HourlySummary) GetHourStart() int64 {
+	if x != nil {
+		return x.HourStart
+	}
+	return 0
+}
+
+func (x *HourlySummary) GetCpuSeconds() int64 {
+	if x != nil {
+		return x.CpuSeconds
+	}
+	return 0
+}
+
+func (x *HourlySummary) GetActiveSeconds() int64 {
+	if x != nil {
+		return x.ActiveSeconds
+	}
+	return 0
+}
+
+func (x *HourlySummary) GetEvents() int64 {
+	if x != nil {
+		return x.Events
+	}
+	return 0
+}
+
+func (x *HourlySummary) GetFirstIdempotencyKey() string {
+	if x != nil {
+		return x.FirstIdempotencyKey
+	}
+	return ""
+}
+
+func (x *HourlySummary) GetLastIdempotencyKey() string {
+	if x != nil {
+		return x.LastIdempotencyKey
+	}
+	return ""
+}
+
+var File_pkg_agent_billing_billing_proto protoreflect.FileDescriptor
+
+const file_pkg_agent_billing_billing_proto_rawDesc = "" +
+	"\n" +
+	"\x1fpkg/agent/billing/billing.proto\x12\abilling\"\xd4\x02\n" +
+	"\x10IncrementalEvent\x12'\n" +
+	"\x0fidempotency_key\x18\x01 \x01(\tR\x0eidempotencyKey\x12\x16\n" +
+	"\x06metric\x18\x02 \x01(\tR\x06metric\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x1f\n" +
+	"\vendpoint_id\x18\x04 \x01(\tR\n" +
+	"endpointId\x12\x1d\n" +
+	"\n" +
+	"start_time\x18\x05 \x01(\x03R\tstartTime\x12\x1b\n" +
+	"\tstop_time\x18\x06 \x01(\x03R\bstopTime\x12\x14\n" +
+	"\x05value\x18\a \x01(\x03R\x05value\x12=\n" +
+	"\x06labels\x18\b \x03(\v2%.billing.IncrementalEvent.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"\xb1\x02\n" +
+	"\rHourlySummary\x12\x1f\n" +
+	"\vendpoint_id\x18\x01 \x01(\tR\n" +
+	"endpointId\x12\x1a\n" +
+	"\bhostname\x18\x02 \x01(\tR\bhostname\x12\x1d\n" +
+	"\n" +
+	"hour_start\x18\x03 \x01(\x03R\thourStart\x12\x1f\n" +
+	"\vcpu_seconds\x18\x04 \x01(\x03R\n" +
+	"cpuSeconds\x12%\n" +
+	"\x0eactive_seconds\x18\x05 \x01(\x03R\ractiveSeconds\x12\x16\n" +
+	"\x06events\x18\x06 \x01(\x03R\x06events\x122\n" +
+	"\x15first_idempotency_key\x18\a \x01(\tR\x13firstIdempotencyKey\x120\n" +
+	"\x14last_idempotency_key\x18\b \x01(\tR\x12lastIdempotencyKeyBAZ?github.com/neondatabase/autoscaling/pkg/agent/billing/billingpbb\x06proto3"
+
+var (
+	file_pkg_agent_billing_billing_proto_rawDescOnce sync.Once
+	file_pkg_agent_billing_billing_proto_rawDescData []byte
+)
+
+func file_pkg_agent_billing_billing_proto_rawDescGZIP() []byte {
+	file_pkg_agent_billing_billing_proto_rawDescOnce.Do(func() {
+		file_pkg_agent_billing_billing_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_agent_billing_billing_proto_rawDesc), len(file_pkg_agent_billing_billing_proto_rawDesc)))
+	})
+	return file_pkg_agent_billing_billing_proto_rawDescData
+}
+
+var file_pkg_agent_billing_billing_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_pkg_agent_billing_billing_proto_goTypes = []any{
+	(*IncrementalEvent)(nil), // 0: billing.IncrementalEvent
+	(*HourlySummary)(nil),    // 1: billing.HourlySummary
+	nil,                      // 2: billing.IncrementalEvent.LabelsEntry
+}
+var file_pkg_agent_billing_billing_proto_depIdxs = []int32{
+	2, // 0: billing.IncrementalEvent.labels:type_name -> billing.IncrementalEvent.LabelsEntry
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_pkg_agent_billing_billing_proto_init() }
+func file_pkg_agent_billing_billing_proto_init() {
+	if File_pkg_agent_billing_billing_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_agent_billing_billing_proto_rawDesc), len(file_pkg_agent_billing_billing_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   3,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_pkg_agent_billing_billing_proto_goTypes,
+		DependencyIndexes: file_pkg_agent_billing_billing_proto_depIdxs,
+		MessageInfos:      file_pkg_agent_billing_billing_proto_msgTypes,
+	}.Build()
+	File_pkg_agent_billing_billing_proto = out.File
+	file_pkg_agent_billing_billing_proto_goTypes = nil
+	file_pkg_agent_billing_billing_proto_depIdxs = nil
+}
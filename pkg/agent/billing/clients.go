@@ -6,6 +6,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
 
@@ -17,7 +18,10 @@ import (
 
 type ClientsConfig struct {
 	AzureBlob *AzureBlobStorageClientConfig `json:"azureBlob"`
+	GCS       *GCSClientConfig              `json:"gcs"`
 	HTTP      *HTTPClientConfig             `json:"http"`
+	Kafka     *KafkaClientConfig            `json:"kafka"`
+	LocalFile *LocalFileClientConfig        `json:"localFile"`
 	S3        *S3ClientConfig               `json:"s3"`
 }
 
@@ -33,74 +37,160 @@ type AzureBlobStorageClientConfig struct {
 	PrefixInContainer string `json:"prefixInContainer"`
 }
 
+type GCSClientConfig struct {
+	reporting.BaseClientConfig
+	reporting.GCSClientConfig
+	PrefixInBucket string `json:"prefixInBucket"`
+}
+
+type LocalFileClientConfig struct {
+	reporting.BaseClientConfig
+	reporting.LocalFileClientConfig
+	PrefixInDirectory string `json:"prefixInDirectory"`
+}
+
 type HTTPClientConfig struct {
 	reporting.BaseClientConfig
 	URL string `json:"url"`
 }
 
-type billingClient = reporting.Client[*IncrementalEvent]
+type KafkaClientConfig struct {
+	reporting.BaseClientConfig
+	reporting.KafkaClientConfig
+}
 
-func createClients(ctx context.Context, logger *zap.Logger, cfg ClientsConfig) ([]billingClient, error) {
-	var clients []billingClient
+// createClients creates the reporting clients from the config, for the given type of event.
+//
+// The description is used for logging, e.g. "billing events". The schema is used for clients
+// configured with a non-JSON format.
+func createClients[E any](
+	ctx context.Context,
+	logger *zap.Logger,
+	cfg ClientsConfig,
+	description string,
+	schema *reporting.Schema[E],
+) ([]reporting.Client[E], error) {
+	var clients []reporting.Client[E]
 
 	if c := cfg.HTTP; c != nil {
 		client := reporting.NewHTTPClient(http.DefaultClient, reporting.HTTPClientConfig{
 			URL:    fmt.Sprintf("%s/usage_events", c.URL),
 			Method: http.MethodPost,
 		})
-		logger.Info("Created HTTP client for billing events", zap.Any("config", c))
+		logger.Info(fmt.Sprintf("Created HTTP client for %s", description), zap.Any("config", c))
 
-		clients = append(clients, billingClient{
+		clients = append(clients, reporting.Client[E]{
 			Name:            "http",
 			Base:            client,
 			BaseConfig:      c.BaseClientConfig,
-			NewBatchBuilder: jsonArrayBatch(reporting.NewByteBuffer), // note: NOT gzipped.
+			NewBatchBuilder: jsonArrayBatch[E](reporting.NewByteBuffer), // note: NOT gzipped.
+			Extension:       ".json",
+			DeadLetter:      nil,
 		})
 
 	}
 	if c := cfg.AzureBlob; c != nil {
-		generateKey := newBlobStorageKeyGenerator(c.PrefixInContainer)
+		newBatch, extension := reporting.NewBlobBatchBuilder(c.Format, schema)
+		generateKey := newBlobStorageKeyGenerator(c.PrefixInContainer, extension)
 		client, err := reporting.NewAzureBlobStorageClient(c.AzureBlobStorageClientConfig, generateKey)
 		if err != nil {
 			return nil, fmt.Errorf("error creating Azure Blob Storage client: %w", err)
 		}
-		logger.Info("Created Azure Blob Storage client for billing events", zap.Any("config", c))
+		logger.Info(fmt.Sprintf("Created Azure Blob Storage client for %s", description), zap.Any("config", c))
 
-		clients = append(clients, billingClient{
+		clients = append(clients, reporting.Client[E]{
 			Name:            "azureblob",
 			Base:            client,
 			BaseConfig:      c.BaseClientConfig,
-			NewBatchBuilder: jsonLinesBatch(reporting.NewGZIPBuffer),
+			NewBatchBuilder: newBatch,
+			Extension:       extension,
+			DeadLetter:      nil,
 		})
 	}
 	if c := cfg.S3; c != nil {
-		generateKey := newBlobStorageKeyGenerator(c.PrefixInBucket)
+		newBatch, extension := reporting.NewBlobBatchBuilder(c.Format, schema)
+		generateKey := newBlobStorageKeyGenerator(c.PrefixInBucket, extension)
 		client, err := reporting.NewS3Client(ctx, c.S3ClientConfig, generateKey)
 		if err != nil {
 			return nil, fmt.Errorf("error creating S3 client: %w", err)
 		}
-		logger.Info("Created S3 client for billing events", zap.Any("config", c))
+		logger.Info(fmt.Sprintf("Created S3 client for %s", description), zap.Any("config", c))
 
-		clients = append(clients, billingClient{
+		clients = append(clients, reporting.Client[E]{
 			Name:            "s3",
 			Base:            client,
 			BaseConfig:      c.BaseClientConfig,
-			NewBatchBuilder: jsonLinesBatch(reporting.NewGZIPBuffer),
+			NewBatchBuilder: newBatch,
+			Extension:       extension,
+			DeadLetter:      nil,
 		})
 	}
+	if c := cfg.Kafka; c != nil {
+		client, err := reporting.NewKafkaClient(c.KafkaClientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Kafka client: %w", err)
+		}
+		logger.Info(fmt.Sprintf("Created Kafka client for %s", description), zap.Any("config", c))
 
-	return clients, nil
+		clients = append(clients, reporting.Client[E]{
+			Name:            "kafka",
+			Base:            client,
+			BaseConfig:      c.BaseClientConfig,
+			NewBatchBuilder: jsonLinesBatch[E](reporting.NewByteBuffer), // note: NOT gzipped.
+			Extension:       ".ndjson",
+			DeadLetter:      nil,
+		})
+	}
+	if c := cfg.GCS; c != nil {
+		newBatch, extension := reporting.NewBlobBatchBuilder(c.Format, schema)
+		generateKey := newBlobStorageKeyGenerator(c.PrefixInBucket, extension)
+		client, err := reporting.NewGCSClient(http.DefaultClient, c.GCSClientConfig, generateKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating GCS client: %w", err)
+		}
+		logger.Info(fmt.Sprintf("Created GCS client for %s", description), zap.Any("config", c))
+
+		clients = append(clients, reporting.Client[E]{
+			Name:            "gcs",
+			Base:            client,
+			BaseConfig:      c.BaseClientConfig,
+			NewBatchBuilder: newBatch,
+			Extension:       extension,
+			DeadLetter:      nil,
+		})
+	}
+	if c := cfg.LocalFile; c != nil {
+		newBatch, extension := reporting.NewBlobBatchBuilder(c.Format, schema)
+		generateKey := newBlobStorageKeyGenerator(c.PrefixInDirectory, extension)
+		isKey := newBlobStorageKeyMatcher(c.PrefixInDirectory, extension)
+		client, err := reporting.NewLocalFileClient(c.LocalFileClientConfig, generateKey, isKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating local file client: %w", err)
+		}
+		logger.Info(fmt.Sprintf("Created local file client for %s", description), zap.Any("config", c))
+
+		clients = append(clients, reporting.Client[E]{
+			Name:            "localfile",
+			Base:            client,
+			BaseConfig:      c.BaseClientConfig,
+			NewBatchBuilder: newBatch,
+			Extension:       extension,
+			DeadLetter:      nil,
+		})
+	}
+
+	return reporting.ResolveDeadLetters(clients)
 }
 
-func jsonArrayBatch[B reporting.IOBuffer](buf func() B) func() reporting.BatchBuilder[*IncrementalEvent] {
-	return func() reporting.BatchBuilder[*IncrementalEvent] {
-		return reporting.NewJSONArrayBuilder[*IncrementalEvent](buf(), "events")
+func jsonArrayBatch[E any, B reporting.IOBuffer](buf func() B) func() reporting.BatchBuilder[E] {
+	return func() reporting.BatchBuilder[E] {
+		return reporting.NewJSONArrayBuilder[E](buf(), "events")
 	}
 }
 
-func jsonLinesBatch[B reporting.IOBuffer](buf func() B) func() reporting.BatchBuilder[*IncrementalEvent] {
-	return func() reporting.BatchBuilder[*IncrementalEvent] {
-		return reporting.NewJSONLinesBuilder[*IncrementalEvent](buf())
+func jsonLinesBatch[E any, B reporting.IOBuffer](buf func() B) func() reporting.BatchBuilder[E] {
+	return func() reporting.BatchBuilder[E] {
+		return reporting.NewJSONLinesBuilder[E](buf())
 	}
 }
 
@@ -108,9 +198,11 @@ func jsonLinesBatch[B reporting.IOBuffer](buf func() B) func() reporting.BatchBu
 //
 // Example: prefixInContainer/year=2021/month=01/day=26/hour=15/hh:mm:ssZ_{uuid}.ndjson.gz
 //
+// The extension depends on the batch format, e.g. ".ndjson.gz" for JSON.
+//
 // NOTE: This key format is different from the one we use for scaling events, but similar to the one
 // proxy/storage use.
-func newBlobStorageKeyGenerator(prefix string) func() string {
+func newBlobStorageKeyGenerator(prefix string, extension string) func() string {
 	return func() string {
 		now := time.Now()
 		id := shortuuid.New()
@@ -119,11 +211,25 @@ func newBlobStorageKeyGenerator(prefix string) func() string {
 			prefix = strings.TrimRight(prefix, "/") + "/"
 		}
 
-		return fmt.Sprintf("%syear=%d/month=%02d/day=%02d/hour=%02d/%s_%s.ndjson.gz",
+		return fmt.Sprintf("%syear=%d/month=%02d/day=%02d/hour=%02d/%s_%s%s",
 			prefix,
 			now.Year(), now.Month(), now.Day(), now.Hour(),
 			now.Format("15:04:05Z"),
 			id,
+			extension,
 		)
 	}
 }
+
+// Returns a function that checks whether a slash-separated path could have been generated by
+// newBlobStorageKeyGenerator with the same prefix and extension.
+func newBlobStorageKeyMatcher(prefix string, extension string) func(string) bool {
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	return regexp.MustCompile(
+		"^" + regexp.QuoteMeta(prefix) +
+			`year=\d{4}/month=\d{2}/day=\d{2}/hour=\d{2}/\d{2}:\d{2}:\d{2}Z_[[:alnum:]]+` +
+			regexp.QuoteMeta(extension) + "$",
+	).MatchString
+}
@@ -0,0 +1,25 @@
+package billing
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlobStorageKeyMatcher(t *testing.T) {
+	for _, prefix := range []string{"", "billing", "nested/prefix/"} {
+		generateKey := newBlobStorageKeyGenerator(prefix, ".ndjson.gz")
+		isKey := newBlobStorageKeyMatcher(prefix, ".ndjson.gz")
+
+		// Local files are found relative to the directory, with the path cleaned.
+		key := path.Clean(generateKey())
+		assert.True(t, isKey(key), "key %q with prefix %q", key, prefix)
+		assert.False(t, isKey(key+".tmp"))
+		assert.False(t, isKey("other/"+key))
+	}
+
+	isKey := newBlobStorageKeyMatcher("billing", ".ndjson.gz")
+	assert.False(t, isKey("billing/notes.txt"))
+	assert.False(t, isKey("billing/year=2024/month=01/day=02/hour=03/04:05:06Z_abc.parquet"))
+}
@@ -59,6 +59,10 @@ type IncrementalEvent struct {
 	StartTime      time.Time `json:"start_time"`
 	StopTime       time.Time `json:"stop_time"`
 	Value          int       `json:"value"`
+
+	// Labels, if not empty, are the cost attribution labels for the VM, as configured by
+	// LabelsConfig.
+	Labels map[string]string `json:"labels,omitempty"`
 }
 
 // setType implements eventMethods
@@ -0,0 +1,123 @@
+package billing
+
+// Copying VM labels and annotations into billing events, for cost attribution
+
+import (
+	"go.uber.org/zap"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+)
+
+// LabelsConfig configures copying a set of the VM's labels or annotations into each billing event,
+// so that costs can be attributed to arbitrary dimensions (e.g., project, team, or cost center).
+type LabelsConfig struct {
+	// Sources gives the labels to include in each event. There can be at most MaxLabels.
+	Sources []LabelSource `json:"sources"`
+}
+
+// LabelSource describes a single label included in billing events. Exactly one of FromLabel and
+// FromAnnotation must be set.
+type LabelSource struct {
+	// Key is the key of the label in billing events.
+	Key string `json:"key"`
+	// FromLabel, if not empty, is the VM label to take the value from.
+	FromLabel string `json:"fromLabel,omitempty"`
+	// FromAnnotation, if not empty, is the VM annotation to take the value from.
+	FromAnnotation string `json:"fromAnnotation,omitempty"`
+	// AllowedValues gives the values of the label that are reported as-is. Other values are
+	// replaced with OverflowLabelValue, to limit the cardinality of the label.
+	//
+	// There can be at most MaxValuesPerLabel.
+	AllowedValues []string `json:"allowedValues"`
+}
+
+const (
+	// MaxLabels is the maximum number of labels that can be included in billing events.
+	MaxLabels = 16
+	// MaxValuesPerLabel is the maximum number of AllowedValues for each label.
+	MaxValuesPerLabel = 1000
+	// OverflowLabelValue is the value used in place of a label's value when it's not one of the
+	// label's AllowedValues.
+	OverflowLabelValue = "__other__"
+)
+
+// extractLabels returns the configured labels for the VM, or nil if there are none.
+//
+// Labels are only included if the VM has them; if the VM doesn't have the label or annotation,
+// the key is omitted from the event.
+func extractLabels(conf *LabelsConfig, vm *vmv1.VirtualMachine) map[string]string {
+	if conf == nil {
+		return nil
+	}
+
+	var labels map[string]string
+	for _, src := range conf.Sources {
+		var value string
+		var ok bool
+		if src.FromLabel != "" {
+			value, ok = vm.Labels[src.FromLabel]
+		} else {
+			value, ok = vm.Annotations[src.FromAnnotation]
+		}
+		if !ok {
+			continue
+		}
+
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[src.Key] = value
+	}
+	return labels
+}
+
+// limitLabelCardinality replaces the values of labels that aren't in their AllowedValues,
+// returning the labels that should be used for each VM's events.
+//
+// Whether a value is replaced only depends on the config, so every autoscaler-agent reports the
+// same set of values, regardless of which VMs it has or the order it sees them in.
+func limitLabelCardinality(
+	logger *zap.Logger,
+	conf *LabelsConfig,
+	labels map[metricsKey]map[string]string,
+	metrics PromMetrics,
+) map[metricsKey]map[string]string {
+	if conf == nil || len(labels) == 0 {
+		return labels
+	}
+
+	allowed := make(map[string]map[string]struct{}, len(conf.Sources))
+	for _, src := range conf.Sources {
+		values := make(map[string]struct{}, len(src.AllowedValues))
+		for _, v := range src.AllowedValues {
+			values[v] = struct{}{}
+		}
+		allowed[src.Key] = values
+	}
+
+	overflowed := make(map[string]int)
+	result := make(map[metricsKey]map[string]string, len(labels))
+	for k, vmLabels := range labels {
+		limited := make(map[string]string, len(vmLabels))
+		for key, value := range vmLabels {
+			if _, ok := allowed[key][value]; !ok {
+				value = OverflowLabelValue
+				overflowed[key] += 1
+			}
+			limited[key] = value
+		}
+		result[k] = limited
+	}
+
+	for key, count := range overflowed {
+		logger.Warn(
+			"Billing label values not in allowed values, replacing them",
+			zap.String("label", key),
+			zap.Int("vms", count),
+			zap.String("replacement", OverflowLabelValue),
+		)
+		metrics.labelOverflowTotal.WithLabelValues(key).Add(float64(count))
+	}
+
+	return result
+}
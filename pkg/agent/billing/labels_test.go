@@ -0,0 +1,82 @@
+package billing
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+)
+
+func TestExtractLabels(t *testing.T) {
+	conf := &LabelsConfig{
+		Sources: []LabelSource{
+			{Key: "team", FromLabel: "example.com/team", FromAnnotation: "", AllowedValues: nil},
+			{Key: "project", FromLabel: "", FromAnnotation: "example.com/project", AllowedValues: nil},
+			{Key: "cost_center", FromLabel: "example.com/cost-center", FromAnnotation: "", AllowedValues: nil},
+		},
+	}
+
+	vm := &vmv1.VirtualMachine{ //nolint:exhaustruct // this is a test
+		ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustruct // this is a test
+			Labels: map[string]string{
+				"example.com/team":    "storage",
+				"example.com/project": "not-an-annotation",
+			},
+			Annotations: map[string]string{
+				"example.com/project": "proj-1",
+			},
+		},
+	}
+
+	assert.Equal(t, map[string]string{"team": "storage", "project": "proj-1"}, extractLabels(conf, vm))
+	assert.Nil(t, extractLabels(nil, vm))
+
+	vm.Labels = nil
+	vm.Annotations = nil
+	assert.Nil(t, extractLabels(conf, vm))
+}
+
+func TestLimitLabelCardinality(t *testing.T) {
+	conf := &LabelsConfig{
+		Sources: []LabelSource{
+			{Key: "team", FromLabel: "team", FromAnnotation: "", AllowedValues: []string{"a", "b"}},
+			{Key: "project", FromLabel: "project", FromAnnotation: "", AllowedValues: []string{"x"}},
+		},
+	}
+	metrics := NewPromMetrics(prometheus.NewRegistry())
+
+	key := func(endpointID string) metricsKey {
+		return metricsKey{uid: "", endpointID: endpointID}
+	}
+	expected := map[metricsKey]map[string]string{
+		key("ep-a"): {"team": "a", "project": "x"},
+		key("ep-b"): {"team": "b", "project": "x"},
+		key("ep-c"): {"team": OverflowLabelValue, "project": "x"},
+		key("ep-d"): {"team": "a"},
+		key("ep-e"): {"team": OverflowLabelValue, "project": OverflowLabelValue},
+	}
+
+	// Which values are kept must not depend on the other VMs: the result for each VM is the same
+	// whether it's reported alone or alongside others.
+	labels := map[metricsKey]map[string]string{
+		key("ep-a"): {"team": "a", "project": "x"},
+		key("ep-b"): {"team": "b", "project": "x"},
+		key("ep-c"): {"team": "c", "project": "x"},
+		key("ep-d"): {"team": "a"},
+		key("ep-e"): {"team": "d", "project": "y"},
+	}
+	assert.Equal(t, expected, limitLabelCardinality(zap.NewNop(), conf, labels, metrics))
+	for k, vmLabels := range labels {
+		single := map[metricsKey]map[string]string{k: vmLabels}
+		assert.Equal(t, expected[k], limitLabelCardinality(zap.NewNop(), conf, single, metrics)[k])
+	}
+
+	assert.Equal(t, float64(4), testutil.ToFloat64(metrics.labelOverflowTotal.WithLabelValues("team")))
+	assert.Equal(t, float64(2), testutil.ToFloat64(metrics.labelOverflowTotal.WithLabelValues("project")))
+}
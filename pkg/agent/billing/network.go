@@ -0,0 +1,233 @@
+package billing
+
+// Fetching network usage from the neonvm-runner of each VM
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"sync"
+	"time"
+
+	promtypes "github.com/prometheus/client_model/go"
+	promfmt "github.com/prometheus/common/expfmt"
+	"go.uber.org/zap"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
+)
+
+type NetworkConfig struct {
+	// IngressMetricName is the name of the billing metric for bytes received by the VM from the
+	// open internet.
+	IngressMetricName string `json:"ingressMetricName"`
+	// EgressMetricName is the name of the billing metric for bytes sent by the VM to the open
+	// internet.
+	EgressMetricName string `json:"egressMetricName"`
+	// RequestTimeoutSeconds gives the timeout, in seconds, for fetching network usage from all
+	// VMs' runner pods. This happens in the background, once every collection interval.
+	RequestTimeoutSeconds uint `json:"requestTimeoutSeconds"`
+}
+
+const (
+	// names of the counters exported by neonvm-runner, when the VM has network monitoring enabled.
+	runnerIngressBytesMetric = "runner_vm_ingress_bytes_total"
+	runnerEgressBytesMetric  = "runner_vm_egress_bytes_total"
+)
+
+// networkCounters are the cumulative network bytes reported by a VM's runner pod.
+//
+// The counters reset to zero when the runner pod restarts.
+type networkCounters struct {
+	ingress uint64
+	egress  uint64
+}
+
+// sub returns the increase in the counters from prev to c, accounting for counter resets.
+func (c networkCounters) sub(prev networkCounters) networkCounters {
+	delta := func(cur, prev uint64) uint64 {
+		if cur < prev {
+			// The counter was reset; everything since then is new.
+			return cur
+		}
+		return cur - prev
+	}
+
+	return networkCounters{
+		ingress: delta(c.ingress, prev.ingress),
+		egress:  delta(c.egress, prev.egress),
+	}
+}
+
+// hasNetworkMonitoring returns whether the VM's runner pod is expected to report network usage
+func hasNetworkMonitoring(vm *vmv1.VirtualMachine) bool {
+	enabled := vm.Spec.EnableNetworkMonitoring
+	return enabled != nil && *enabled && vm.Status.PodIP != "" && vm.Spec.RunnerPort != 0
+}
+
+// networkFetcher periodically fetches the network counters from all of the VMs on this node in the
+// background, so that slow or unreachable runner pods don't delay collecting the other metrics.
+type networkFetcher struct {
+	conf     *NetworkConfig
+	interval time.Duration
+
+	mu sync.Mutex
+	// counters stores the most recent counters for each VM that we've fetched from.
+	counters map[types.UID]networkCounters
+}
+
+func newNetworkFetcher(conf *NetworkConfig, interval time.Duration) *networkFetcher {
+	return &networkFetcher{
+		conf:     conf,
+		interval: interval,
+		mu:       sync.Mutex{},
+		counters: make(map[types.UID]networkCounters),
+	}
+}
+
+// run fetches the network counters every interval, until the context is canceled.
+func (f *networkFetcher) run(ctx context.Context, logger *zap.Logger, store VMStoreForNode) {
+	ticker := time.NewTicker(f.interval)
+	defer ticker.Stop()
+
+	for {
+		if !store.Failing() {
+			vms := store.ListIndexed(func(i *VMNodeIndex) []*vmv1.VirtualMachine {
+				return i.List()
+			})
+			f.update(vms, fetchAllNetworkCounters(ctx, logger, f.conf, vms))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// update stores the counters that were fetched from the VMs.
+//
+// If we failed to fetch from a VM, we keep its previous counters so that its usage is counted on
+// the next successful fetch. VMs that are no longer present are removed.
+func (f *networkFetcher) update(vms []*vmv1.VirtualMachine, fetched map[types.UID]networkCounters) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	counters := make(map[types.UID]networkCounters)
+	for _, vm := range vms {
+		if c, ok := fetched[vm.UID]; ok {
+			counters[vm.UID] = c
+		} else if c, ok := f.counters[vm.UID]; ok && hasNetworkMonitoring(vm) {
+			counters[vm.UID] = c
+		}
+	}
+	f.counters = counters
+}
+
+// latest returns the most recently fetched counters for each VM
+func (f *networkFetcher) latest() map[types.UID]networkCounters {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return maps.Clone(f.counters)
+}
+
+// fetchAllNetworkCounters fetches the network counters from all of the VMs that have network
+// monitoring enabled, in parallel.
+//
+// VMs that we failed to fetch from are logged and omitted from the result.
+func fetchAllNetworkCounters(
+	ctx context.Context,
+	logger *zap.Logger,
+	conf *NetworkConfig,
+	vms []*vmv1.VirtualMachine,
+) map[types.UID]networkCounters {
+	ctx, cancel := context.WithTimeout(ctx, time.Second*time.Duration(conf.RequestTimeoutSeconds))
+	defer cancel()
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	result := make(map[types.UID]networkCounters)
+
+	for _, vm := range vms {
+		// Only fetch from VMs we'd actually bill for; see (*metricsState).collect().
+		if _, isEndpoint := vm.Annotations[api.AnnotationBillingEndpointID]; !isEndpoint || !hasNetworkMonitoring(vm) {
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			counters, err := fetchNetworkCounters(ctx, vm.Status.PodIP, vm.Spec.RunnerPort)
+			if err != nil {
+				logger.Warn(
+					"Failed to fetch network usage for VM",
+					zap.String("namespace", vm.Namespace),
+					zap.String("name", vm.Name),
+					zap.Error(err),
+				)
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			result[vm.UID] = counters
+		}()
+	}
+
+	wg.Wait()
+	return result
+}
+
+func fetchNetworkCounters(ctx context.Context, podIP string, port int32) (networkCounters, error) {
+	url := fmt.Sprintf("http://%s:%d/metrics", podIP, port)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return networkCounters{}, fmt.Errorf("could not build request: %w", err)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return networkCounters{}, fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return networkCounters{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	return parseNetworkCounters(resp.Body)
+}
+
+func parseNetworkCounters(body io.Reader) (networkCounters, error) {
+	var parser promfmt.TextParser
+	mfs, err := parser.TextToMetricFamilies(body)
+	if err != nil {
+		return networkCounters{}, fmt.Errorf("could not parse metrics: %w", err)
+	}
+
+	getCounter := func(name string) (uint64, error) {
+		mf, ok := mfs[name]
+		if !ok || len(mf.GetMetric()) != 1 || mf.GetType() != promtypes.MetricType_COUNTER {
+			return 0, fmt.Errorf("missing or invalid metric %q", name)
+		}
+		return uint64(mf.GetMetric()[0].GetCounter().GetValue()), nil
+	}
+
+	ingress, err := getCounter(runnerIngressBytesMetric)
+	if err != nil {
+		return networkCounters{}, err
+	}
+	egress, err := getCounter(runnerEgressBytesMetric)
+	if err != nil {
+		return networkCounters{}, err
+	}
+
+	return networkCounters{ingress: ingress, egress: egress}, nil
+}
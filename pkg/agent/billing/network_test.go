@@ -0,0 +1,163 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
+)
+
+func networkTestVM(name string, monitoring bool, podIP string, port int32) *vmv1.VirtualMachine {
+	return &vmv1.VirtualMachine{ //nolint:exhaustruct // this is a test
+		ObjectMeta: metav1.ObjectMeta{ //nolint:exhaustruct // this is a test
+			Name:        name,
+			Namespace:   "default",
+			UID:         types.UID(name + "-uid"),
+			Annotations: map[string]string{api.AnnotationBillingEndpointID: "ep-" + name},
+		},
+		Spec: vmv1.VirtualMachineSpec{ //nolint:exhaustruct // this is a test
+			EnableNetworkMonitoring: &monitoring,
+			RunnerPort:              port,
+		},
+		Status: vmv1.VirtualMachineStatus{ //nolint:exhaustruct // this is a test
+			Phase: vmv1.VmRunning,
+			PodIP: podIP,
+			CPUs:  lo.ToPtr(vmv1.MilliCPU(1000)),
+		},
+	}
+}
+
+func runnerMetrics(ingress, egress uint64) string {
+	return fmt.Sprintf(`# TYPE runner_vm_ingress_bytes_total counter
+runner_vm_ingress_bytes_total %d
+# TYPE runner_vm_egress_bytes_total counter
+runner_vm_egress_bytes_total %d
+`, ingress, egress)
+}
+
+func TestFetchAllNetworkCounters(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(runnerMetrics(100, 200)))
+	}))
+	defer server.Close()
+
+	host, portStr, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+	port, err := strconv.Atoi(portStr)
+	require.NoError(t, err)
+
+	// Find a port with nothing listening on it, for a runner that can't be reached.
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	closedPort := closed.Addr().(*net.TCPAddr).Port
+	require.NoError(t, closed.Close())
+
+	vms := []*vmv1.VirtualMachine{
+		networkTestVM("monitored", true, host, int32(port)),
+		networkTestVM("not-monitored", false, host, int32(port)),
+		networkTestVM("unreachable", true, "127.0.0.1", int32(closedPort)),
+	}
+
+	conf := &NetworkConfig{IngressMetricName: "ingress", EgressMetricName: "egress", RequestTimeoutSeconds: 5}
+	counters := fetchAllNetworkCounters(context.Background(), zap.NewNop(), conf, vms)
+	assert.Equal(t, map[types.UID]networkCounters{
+		"monitored-uid": {ingress: 100, egress: 200},
+	}, counters)
+}
+
+func TestNetworkFetcherUpdate(t *testing.T) {
+	a := networkTestVM("a", true, "10.0.0.1", 25183)
+	b := networkTestVM("b", true, "10.0.0.2", 25183)
+
+	fetcher := newNetworkFetcher(&NetworkConfig{}, time.Second) //nolint:exhaustruct // this is a test
+	fetcher.update([]*vmv1.VirtualMachine{a, b}, map[types.UID]networkCounters{
+		a.UID: {ingress: 1, egress: 2},
+		b.UID: {ingress: 3, egress: 4},
+	})
+
+	// Failing to fetch from a VM should keep its previous counters.
+	fetcher.update([]*vmv1.VirtualMachine{a, b}, map[types.UID]networkCounters{
+		a.UID: {ingress: 5, egress: 6},
+	})
+	assert.Equal(t, map[types.UID]networkCounters{
+		a.UID: {ingress: 5, egress: 6},
+		b.UID: {ingress: 3, egress: 4},
+	}, fetcher.latest())
+
+	// ... unless the VM is gone, or no longer has network monitoring.
+	a.Spec.EnableNetworkMonitoring = lo.ToPtr(false)
+	fetcher.update([]*vmv1.VirtualMachine{a}, map[types.UID]networkCounters{})
+	assert.Empty(t, fetcher.latest())
+}
+
+func TestNetworkEventsOnlyForVMsWithCounters(t *testing.T) {
+	monitored := networkTestVM("monitored", true, "10.0.0.1", 25183)
+	unmonitored := networkTestVM("unmonitored", false, "10.0.0.2", 25183)
+	vms := []*vmv1.VirtualMachine{monitored, unmonitored}
+
+	conf := &Config{ //nolint:exhaustruct // this is a test
+		CPUMetricName:        "cpu",
+		ActiveTimeMetricName: "active_time",
+		Network:              &NetworkConfig{IngressMetricName: "ingress", EgressMetricName: "egress", RequestTimeoutSeconds: 1},
+	}
+	state := metricsState{
+		historical:      make(map[metricsKey]vmMetricsHistory),
+		present:         make(map[metricsKey]vmMetricsInstant),
+		network:         make(map[metricsKey]networkCounters),
+		lastCollectTime: nil,
+		pushWindowStart: time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC),
+		labels:          make(map[metricsKey]map[string]string),
+		summaries:       nil,
+	}
+	metricsBatch := NewPromMetrics(prometheus.NewRegistry()).forBatch()
+
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	state.update(conf, start, vms, map[types.UID]networkCounters{
+		monitored.UID: {ingress: 1000, egress: 500},
+	}, metricsBatch)
+	state.update(conf, start.Add(time.Minute), vms, map[types.UID]networkCounters{
+		monitored.UID: {ingress: 1500, egress: 700},
+	}, metricsBatch)
+	// The runner restarted, so the counters reset.
+	state.update(conf, start.Add(2*time.Minute), vms, map[types.UID]networkCounters{
+		monitored.UID: {ingress: 50, egress: 10},
+	}, metricsBatch)
+
+	events := state.drain(conf, start.Add(2*time.Minute), nil)
+
+	values := make(map[string]map[string]int)
+	for _, e := range events {
+		if values[e.EndpointID] == nil {
+			values[e.EndpointID] = make(map[string]int)
+		}
+		values[e.EndpointID][e.MetricName] = e.Value
+	}
+	assert.Equal(t, map[string]map[string]int{
+		"ep-monitored": {
+			"cpu":         120,
+			"active_time": 120,
+			"ingress":     550,
+			"egress":      210,
+		},
+		"ep-unmonitored": {
+			"cpu":         120,
+			"active_time": 120,
+		},
+	}, values)
+}
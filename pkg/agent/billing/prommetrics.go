@@ -13,15 +13,19 @@ import (
 )
 
 type PromMetrics struct {
-	reporting *reporting.EventSinkMetrics
+	reporting        *reporting.EventSinkMetrics
+	summaryReporting *reporting.EventSinkMetrics
 
 	vmsProcessedTotal *prometheus.CounterVec
 	vmsCurrent        *prometheus.GaugeVec
+
+	labelOverflowTotal *prometheus.CounterVec
 }
 
 func NewPromMetrics(reg prometheus.Registerer) PromMetrics {
 	return PromMetrics{
-		reporting: reporting.NewEventSinkMetrics("autoscaling_agent_billing", reg),
+		reporting:        reporting.NewEventSinkMetrics("autoscaling_agent_billing", reg),
+		summaryReporting: reporting.NewEventSinkMetrics("autoscaling_agent_billing_summary", reg),
 
 		vmsProcessedTotal: util.RegisterMetric(reg, prometheus.NewCounterVec(
 			prometheus.CounterOpts{
@@ -37,6 +41,14 @@ func NewPromMetrics(reg prometheus.Registerer) PromMetrics {
 			},
 			[]string{"is_endpoint", "autoscaling_enabled", "phase"},
 		)),
+
+		labelOverflowTotal: util.RegisterMetric(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "autoscaling_agent_billing_label_overflow_total",
+				Help: "Total number of times a billing label's value was replaced because it wasn't one of the allowed values",
+			},
+			[]string{"label"},
+		)),
 	}
 }
 
@@ -0,0 +1,221 @@
+package billing
+
+// Comparison of the billing events that were received against the summaries of what was sent
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"time"
+)
+
+type DiscrepancyKind string
+
+const (
+	// DiscrepancyMissing means that fewer events were found than were sent, according to the
+	// summaries.
+	DiscrepancyMissing DiscrepancyKind = "missing"
+	// DiscrepancyExtra means that more events were found than were sent, according to the
+	// summaries.
+	DiscrepancyExtra DiscrepancyKind = "extra"
+	// DiscrepancyValueMismatch means that the expected number of events were found, but their
+	// total CPU or active time differs from the summaries.
+	DiscrepancyValueMismatch DiscrepancyKind = "valueMismatch"
+	// DiscrepancyOverlap means that there are two events with different idempotency keys for the
+	// same endpoint and metric covering overlapping time ranges, i.e. the usage may be
+	// double-counted.
+	DiscrepancyOverlap DiscrepancyKind = "overlap"
+	// DiscrepancyGap means that there is a short gap between consecutive events for the same
+	// endpoint and metric, i.e. some usage may not have been reported at all.
+	DiscrepancyGap DiscrepancyKind = "gap"
+)
+
+// Discrepancy is a single problem found by Reconcile
+type Discrepancy struct {
+	Kind       DiscrepancyKind
+	EndpointID string
+	HourStart  time.Time
+	Detail     string
+}
+
+// ReconcileConfig gives the metric names that the summaries were generated with
+type ReconcileConfig struct {
+	CPUMetricName        string
+	ActiveTimeMetricName string
+
+	// MaxGap is the longest gap between consecutive events for the same endpoint and metric that
+	// is reported as a DiscrepancyGap. Longer gaps are expected whenever an endpoint is stopped, so
+	// they aren't reported. If zero, gaps are not checked.
+	MaxGap time.Duration
+}
+
+type reconcileTotals struct {
+	cpuSeconds    int
+	activeSeconds int
+	events        int
+}
+
+// Reconcile compares the billing events that were received with the hourly summaries of the events
+// that were sent, returning any discrepancies found, ordered by hour and then endpoint.
+//
+// Events with the same idempotency key are counted only once, matching the deduplication done by
+// the consumers of the events.
+func Reconcile(conf ReconcileConfig, summaries []HourlySummary, events []IncrementalEvent) []Discrepancy {
+	var discrepancies []Discrepancy
+
+	// Deduplicate events first.
+	seen := make(map[string]struct{})
+	var unique []IncrementalEvent
+	for _, e := range events {
+		if _, ok := seen[e.IdempotencyKey]; ok {
+			continue
+		}
+		seen[e.IdempotencyKey] = struct{}{}
+		unique = append(unique, e)
+	}
+
+	expected := make(map[summaryKey]*reconcileTotals)
+	for _, s := range summaries {
+		key := summaryKey{endpointID: s.EndpointID, hourStart: s.HourStart.UTC()}
+		t, ok := expected[key]
+		if !ok {
+			t = &reconcileTotals{cpuSeconds: 0, activeSeconds: 0, events: 0}
+			expected[key] = t
+		}
+		t.cpuSeconds += s.CPUSeconds
+		t.activeSeconds += s.ActiveSeconds
+		t.events += s.Events
+	}
+
+	found := make(map[summaryKey]*reconcileTotals)
+	for i := range unique {
+		e := &unique[i]
+		key := summaryKey{endpointID: e.EndpointID, hourStart: summaryHour(e)}
+		t, ok := found[key]
+		if !ok {
+			t = &reconcileTotals{cpuSeconds: 0, activeSeconds: 0, events: 0}
+			found[key] = t
+		}
+		switch e.MetricName {
+		case conf.CPUMetricName:
+			t.cpuSeconds += e.Value
+		case conf.ActiveTimeMetricName:
+			t.activeSeconds += e.Value
+		}
+		t.events += 1
+	}
+
+	zero := reconcileTotals{cpuSeconds: 0, activeSeconds: 0, events: 0}
+	for key := range merged(expected, found) {
+		exp, got := &zero, &zero
+		if t, ok := expected[key]; ok {
+			exp = t
+		}
+		if t, ok := found[key]; ok {
+			got = t
+		}
+
+		newDiscrepancy := func(kind DiscrepancyKind, format string, args ...any) Discrepancy {
+			return Discrepancy{
+				Kind:       kind,
+				EndpointID: key.endpointID,
+				HourStart:  key.hourStart,
+				Detail:     fmt.Sprintf(format, args...),
+			}
+		}
+
+		switch {
+		case got.events < exp.events:
+			discrepancies = append(discrepancies, newDiscrepancy(
+				DiscrepancyMissing, "expected %d events, found %d", exp.events, got.events,
+			))
+		case got.events > exp.events:
+			discrepancies = append(discrepancies, newDiscrepancy(
+				DiscrepancyExtra, "expected %d events, found %d", exp.events, got.events,
+			))
+		case got.cpuSeconds != exp.cpuSeconds || got.activeSeconds != exp.activeSeconds:
+			discrepancies = append(discrepancies, newDiscrepancy(
+				DiscrepancyValueMismatch,
+				"expected %d CPU-seconds and %d active seconds, found %d and %d",
+				exp.cpuSeconds, exp.activeSeconds, got.cpuSeconds, got.activeSeconds,
+			))
+		}
+	}
+
+	discrepancies = append(discrepancies, findOverlapsAndGaps(conf, unique)...)
+
+	slices.SortStableFunc(discrepancies, func(x, y Discrepancy) int {
+		return cmp.Or(
+			x.HourStart.Compare(y.HourStart),
+			cmp.Compare(x.EndpointID, y.EndpointID),
+			cmp.Compare(x.Kind, y.Kind),
+			cmp.Compare(x.Detail, y.Detail),
+		)
+	})
+	return discrepancies
+}
+
+// merged returns the set of keys in either map
+func merged[K comparable, V any](a, b map[K]V) map[K]struct{} {
+	keys := make(map[K]struct{})
+	for k := range a {
+		keys[k] = struct{}{}
+	}
+	for k := range b {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// findOverlapsAndGaps returns a discrepancy for each pair of consecutive events for the same
+// endpoint and metric with overlapping time ranges, or with a gap between them of up to
+// conf.MaxGap.
+//
+// The events must already be deduplicated.
+func findOverlapsAndGaps(conf ReconcileConfig, events []IncrementalEvent) []Discrepancy {
+	type seriesKey struct {
+		endpointID string
+		metricName string
+	}
+
+	series := make(map[seriesKey][]*IncrementalEvent)
+	for i := range events {
+		e := &events[i]
+		key := seriesKey{endpointID: e.EndpointID, metricName: e.MetricName}
+		series[key] = append(series[key], e)
+	}
+
+	var discrepancies []Discrepancy
+	for _, es := range series {
+		slices.SortFunc(es, func(x, y *IncrementalEvent) int {
+			return cmp.Or(x.StartTime.Compare(y.StartTime), x.StopTime.Compare(y.StopTime))
+		})
+
+		for i := 1; i < len(es); i++ {
+			prev, cur := es[i-1], es[i]
+			if gap := cur.StartTime.Sub(prev.StopTime); gap > 0 && gap <= conf.MaxGap {
+				discrepancies = append(discrepancies, Discrepancy{
+					Kind:       DiscrepancyGap,
+					EndpointID: cur.EndpointID,
+					HourStart:  summaryHour(cur),
+					Detail: fmt.Sprintf(
+						"no %s events between %q and %q, for %s",
+						cur.MetricName, prev.IdempotencyKey, cur.IdempotencyKey, gap,
+					),
+				})
+			} else if cur.StartTime.Before(prev.StopTime) {
+				discrepancies = append(discrepancies, Discrepancy{
+					Kind:       DiscrepancyOverlap,
+					EndpointID: cur.EndpointID,
+					HourStart:  summaryHour(cur),
+					Detail: fmt.Sprintf(
+						"%s events %q and %q overlap by %s",
+						cur.MetricName, prev.IdempotencyKey, cur.IdempotencyKey, prev.StopTime.Sub(cur.StartTime),
+					),
+				})
+			}
+		}
+	}
+
+	return discrepancies
+}
@@ -0,0 +1,141 @@
+package billing
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// summarize generates the summaries for the events, as the collector would.
+func summarize(events []IncrementalEvent) []HourlySummary {
+	tracker := &summaryTracker{sink: nil, ongoing: make(map[summaryKey]*HourlySummary)}
+	for i := range events {
+		tracker.add(&Config{CPUMetricName: "cpu", ActiveTimeMetricName: "active"}, "node-1", &events[i]) //nolint:exhaustruct // this is a test
+	}
+	var summaries []HourlySummary
+	for _, s := range tracker.ongoing {
+		summaries = append(summaries, *s)
+	}
+	return summaries
+}
+
+func TestReconcile(t *testing.T) {
+	conf := ReconcileConfig{CPUMetricName: "cpu", ActiveTimeMetricName: "active", MaxGap: 5 * time.Minute}
+	hour := time.Date(2024, 10, 31, 23, 0, 0, 0, time.UTC)
+
+	event := func(key, endpoint, metric string, start, stop time.Duration, value int) IncrementalEvent {
+		return IncrementalEvent{
+			IdempotencyKey: key,
+			MetricName:     metric,
+			Type:           "incremental",
+			EndpointID:     endpoint,
+			StartTime:      hour.Add(start),
+			StopTime:       hour.Add(stop),
+			Value:          value,
+			Labels:         nil,
+		}
+	}
+
+	// Generate the summaries from the events, as the collector would.
+	sent := []IncrementalEvent{
+		event("k1", "ep-a", "cpu", 0, time.Minute, 60),
+		event("k2", "ep-a", "active", 0, time.Minute, 60),
+		event("k3", "ep-a", "cpu", time.Minute, 2*time.Minute, 30),
+		event("k4", "ep-a", "active", time.Minute, 2*time.Minute, 60),
+		event("k5", "ep-b", "cpu", 0, time.Minute, 120),
+		event("k6", "ep-b", "active", 0, time.Minute, 60),
+	}
+	summaries := summarize(sent)
+
+	// Everything received, with a retried duplicate: no discrepancies.
+	received := append(append([]IncrementalEvent{}, sent...), sent[0])
+	assert.Empty(t, Reconcile(conf, summaries, received))
+
+	// ep-a lost an event, and ep-b has an extra event overlapping with the first.
+	received = []IncrementalEvent{
+		sent[0], sent[1], sent[2],
+		sent[4], sent[5],
+		event("k7", "ep-b", "cpu", 30*time.Second, 90*time.Second, 120),
+	}
+	assert.Equal(t, []Discrepancy{
+		{
+			Kind:       DiscrepancyMissing,
+			EndpointID: "ep-a",
+			HourStart:  hour,
+			Detail:     "expected 4 events, found 3",
+		},
+		{
+			Kind:       DiscrepancyExtra,
+			EndpointID: "ep-b",
+			HourStart:  hour,
+			Detail:     "expected 2 events, found 3",
+		},
+		{
+			Kind:       DiscrepancyOverlap,
+			EndpointID: "ep-b",
+			HourStart:  hour,
+			Detail:     `cpu events "k5" and "k7" overlap by 30s`,
+		},
+	}, Reconcile(conf, summaries, received))
+
+	// Same number of events, but a different value
+	received = append(append([]IncrementalEvent{}, sent[:4]...), sent[5], event("k5", "ep-b", "cpu", 0, time.Minute, 100))
+	assert.Equal(t, []Discrepancy{
+		{
+			Kind:       DiscrepancyValueMismatch,
+			EndpointID: "ep-b",
+			HourStart:  hour,
+			Detail:     "expected 120 CPU-seconds and 60 active seconds, found 100 and 60",
+		},
+	}, Reconcile(conf, summaries, received))
+}
+
+func TestReconcileGaps(t *testing.T) {
+	hour := time.Date(2024, 10, 31, 23, 0, 0, 0, time.UTC)
+
+	event := func(key, metric string, start, stop time.Duration) IncrementalEvent {
+		return IncrementalEvent{
+			IdempotencyKey: key,
+			MetricName:     metric,
+			Type:           "incremental",
+			EndpointID:     "ep-a",
+			StartTime:      hour.Add(start),
+			StopTime:       hour.Add(stop),
+			Value:          60,
+			Labels:         nil,
+		}
+	}
+
+	// Contiguous cpu events, then a 1 minute gap, then a 30 minute gap (e.g. because the endpoint
+	// was stopped). The active time events have a gap at a different time.
+	events := []IncrementalEvent{
+		event("k1", "cpu", 0, time.Minute),
+		event("k2", "cpu", time.Minute, 2*time.Minute),
+		event("k3", "cpu", 3*time.Minute, 4*time.Minute),
+		event("k4", "cpu", 34*time.Minute, 35*time.Minute),
+		event("k5", "active", 0, time.Minute),
+		event("k6", "active", 2*time.Minute, 3*time.Minute),
+	}
+	summaries := summarize(events)
+
+	conf := ReconcileConfig{CPUMetricName: "cpu", ActiveTimeMetricName: "active", MaxGap: 5 * time.Minute}
+	assert.Equal(t, []Discrepancy{
+		{
+			Kind:       DiscrepancyGap,
+			EndpointID: "ep-a",
+			HourStart:  hour,
+			Detail:     `no active events between "k5" and "k6", for 1m0s`,
+		},
+		{
+			Kind:       DiscrepancyGap,
+			EndpointID: "ep-a",
+			HourStart:  hour,
+			Detail:     `no cpu events between "k2" and "k3", for 1m0s`,
+		},
+	}, Reconcile(conf, summaries, events))
+
+	// With MaxGap unset, gaps aren't checked.
+	conf.MaxGap = 0
+	assert.Empty(t, Reconcile(conf, summaries, events))
+}
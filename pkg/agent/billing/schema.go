@@ -0,0 +1,69 @@
+package billing
+
+// Schemas for the non-JSON batch formats. These must be kept in sync with billing.proto.
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/billing/billingpb"
+	"github.com/neondatabase/autoscaling/pkg/reporting"
+)
+
+// IncrementalEventSchema is the schema for IncrementalEvent. It's exported so that batches can be
+// decoded by tools like billing-reconcile.
+var IncrementalEventSchema = &reporting.Schema[*IncrementalEvent]{
+	Name:    "billing.IncrementalEvent",
+	Version: 2,
+	Fields: []reporting.Field[*IncrementalEvent]{
+		reporting.StringField("idempotency_key", func(e *IncrementalEvent) string { return e.IdempotencyKey }),
+		reporting.StringField("metric", func(e *IncrementalEvent) string { return e.MetricName }),
+		reporting.StringField("type", func(e *IncrementalEvent) string { return e.Type }),
+		reporting.StringField("endpoint_id", func(e *IncrementalEvent) string { return e.EndpointID }),
+		reporting.TimestampField("start_time", func(e *IncrementalEvent) time.Time { return e.StartTime }),
+		reporting.TimestampField("stop_time", func(e *IncrementalEvent) time.Time { return e.StopTime }),
+		reporting.Int64Field("value", func(e *IncrementalEvent) int64 { return int64(e.Value) }),
+		reporting.StringMapField("labels", func(e *IncrementalEvent) map[string]string { return e.Labels }),
+	},
+	ToProto: func(e *IncrementalEvent) proto.Message {
+		return &billingpb.IncrementalEvent{
+			IdempotencyKey: e.IdempotencyKey,
+			Metric:         e.MetricName,
+			Type:           e.Type,
+			EndpointId:     e.EndpointID,
+			StartTime:      e.StartTime.UnixMicro(),
+			StopTime:       e.StopTime.UnixMicro(),
+			Value:          int64(e.Value),
+			Labels:         e.Labels,
+		}
+	},
+}
+
+// HourlySummarySchema is the schema for HourlySummary.
+var HourlySummarySchema = &reporting.Schema[*HourlySummary]{
+	Name:    "billing.HourlySummary",
+	Version: 1,
+	Fields: []reporting.Field[*HourlySummary]{
+		reporting.StringField("endpoint_id", func(s *HourlySummary) string { return s.EndpointID }),
+		reporting.StringField("hostname", func(s *HourlySummary) string { return s.Hostname }),
+		reporting.TimestampField("hour_start", func(s *HourlySummary) time.Time { return s.HourStart }),
+		reporting.Int64Field("cpu_seconds", func(s *HourlySummary) int64 { return int64(s.CPUSeconds) }),
+		reporting.Int64Field("active_seconds", func(s *HourlySummary) int64 { return int64(s.ActiveSeconds) }),
+		reporting.Int64Field("events", func(s *HourlySummary) int64 { return int64(s.Events) }),
+		reporting.StringField("first_idempotency_key", func(s *HourlySummary) string { return s.FirstIdempotencyKey }),
+		reporting.StringField("last_idempotency_key", func(s *HourlySummary) string { return s.LastIdempotencyKey }),
+	},
+	ToProto: func(s *HourlySummary) proto.Message {
+		return &billingpb.HourlySummary{
+			EndpointId:          s.EndpointID,
+			Hostname:            s.Hostname,
+			HourStart:           s.HourStart.UnixMicro(),
+			CpuSeconds:          int64(s.CPUSeconds),
+			ActiveSeconds:       int64(s.ActiveSeconds),
+			Events:              int64(s.Events),
+			FirstIdempotencyKey: s.FirstIdempotencyKey,
+			LastIdempotencyKey:  s.LastIdempotencyKey,
+		}
+	},
+}
@@ -0,0 +1,118 @@
+package billing
+
+// Hourly summaries of the billing events we've sent, so that they can later be audited.
+
+import (
+	"cmp"
+	"slices"
+	"time"
+
+	"github.com/neondatabase/autoscaling/pkg/reporting"
+)
+
+type SummaryConfig struct {
+	// Clients are the destinations for the summaries. These should be separate from the clients
+	// for the billing events themselves.
+	//
+	// The HTTP client is not supported for summaries.
+	Clients ClientsConfig `json:"clients"`
+}
+
+// HourlySummary is a compact record of the billing events that were sent for a single endpoint
+// from a single autoscaler-agent, over an hour.
+//
+// Events are assigned to the hour containing their StopTime (in UTC).
+//
+// There may be more than one summary for the same endpoint and hour, if the endpoint moved between
+// nodes or if the autoscaler-agent restarted within the hour.
+type HourlySummary struct {
+	EndpointID string    `json:"endpoint_id"`
+	Hostname   string    `json:"hostname"`
+	HourStart  time.Time `json:"hour_start"`
+
+	// CPUSeconds is the sum of the values of the CPU events
+	CPUSeconds int `json:"cpu_seconds"`
+	// ActiveSeconds is the sum of the values of the active time events
+	ActiveSeconds int `json:"active_seconds"`
+	// Events is the total number of events sent, for all metrics.
+	Events int `json:"events"`
+
+	FirstIdempotencyKey string `json:"first_idempotency_key"`
+	LastIdempotencyKey  string `json:"last_idempotency_key"`
+}
+
+// summaryHour returns the start of the hour that the event belongs to, for the purposes of
+// HourlySummary.
+func summaryHour(event *IncrementalEvent) time.Time {
+	return event.StopTime.UTC().Truncate(time.Hour)
+}
+
+type summaryKey struct {
+	endpointID string
+	hourStart  time.Time
+}
+
+// summaryTracker builds the HourlySummary for each endpoint as events are sent
+type summaryTracker struct {
+	sink *reporting.EventSink[*HourlySummary]
+
+	ongoing map[summaryKey]*HourlySummary
+}
+
+func newSummaryTracker(sink *reporting.EventSink[*HourlySummary]) *summaryTracker {
+	return &summaryTracker{
+		sink:    sink,
+		ongoing: make(map[summaryKey]*HourlySummary),
+	}
+}
+
+// add records the event in the summary for its endpoint and hour.
+//
+// The event's IdempotencyKey must already be set.
+func (t *summaryTracker) add(conf *Config, hostname string, event *IncrementalEvent) {
+	key := summaryKey{endpointID: event.EndpointID, hourStart: summaryHour(event)}
+
+	summary, ok := t.ongoing[key]
+	if !ok {
+		summary = &HourlySummary{
+			EndpointID:          event.EndpointID,
+			Hostname:            hostname,
+			HourStart:           key.hourStart,
+			CPUSeconds:          0,
+			ActiveSeconds:       0,
+			Events:              0,
+			FirstIdempotencyKey: event.IdempotencyKey,
+			LastIdempotencyKey:  "",
+		}
+		t.ongoing[key] = summary
+	}
+
+	switch event.MetricName {
+	case conf.CPUMetricName:
+		summary.CPUSeconds += event.Value
+	case conf.ActiveTimeMetricName:
+		summary.ActiveSeconds += event.Value
+	}
+	summary.Events += 1
+	summary.LastIdempotencyKey = event.IdempotencyKey
+}
+
+// flush enqueues the summaries for all hours that ended at or before now, or all summaries if
+// flushAll is true.
+func (t *summaryTracker) flush(now time.Time, flushAll bool) {
+	var done []*HourlySummary
+	for key, summary := range t.ongoing {
+		if flushAll || !key.hourStart.Add(time.Hour).After(now) {
+			done = append(done, summary)
+			delete(t.ongoing, key)
+		}
+	}
+
+	// Sort for consistent output; not strictly necessary.
+	slices.SortFunc(done, func(x, y *HourlySummary) int {
+		return cmp.Or(x.HourStart.Compare(y.HourStart), cmp.Compare(x.EndpointID, y.EndpointID))
+	})
+	for _, s := range done {
+		t.sink.Enqueue(s)
+	}
+}
@@ -4,10 +4,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/tychoish/fun/erc"
 
 	"github.com/neondatabase/autoscaling/pkg/agent/billing"
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	"github.com/neondatabase/autoscaling/pkg/agent/recording"
 	"github.com/neondatabase/autoscaling/pkg/agent/scalingevents"
 	"github.com/neondatabase/autoscaling/pkg/api"
 	"github.com/neondatabase/autoscaling/pkg/reporting"
@@ -25,6 +30,9 @@ type Config struct {
 	Monitor   MonitorConfig    `json:"monitor"`
 	NeonVM    NeonVMConfig     `json:"neonvm"`
 	DumpState *DumpStateConfig `json:"dumpState"`
+	// Recording, if not nil, enables recording all scaling inputs for particular VMs, so that
+	// their scaling decisions can be replayed later.
+	Recording *recording.Config `json:"recording"`
 }
 
 type RateThresholdConfig struct {
@@ -74,6 +82,20 @@ type DumpStateConfig struct {
 	// TimeoutSeconds gives the maximum duration, in seconds, that we allow for a request to dump
 	// internal state.
 	TimeoutSeconds uint `json:"timeoutSeconds"`
+
+	// CUOverride, if not nil, enables the endpoint to temporarily override the compute units of
+	// individual VMs.
+	CUOverride *CUOverrideConfig `json:"cuOverride,omitempty"`
+}
+
+// CUOverrideConfig configures the endpoint on the dump-state server to set temporary per-VM CU
+// overrides
+type CUOverrideConfig struct {
+	// TokenPath is the path to a file containing the bearer token that requests must provide in
+	// their Authorization header.
+	TokenPath string `json:"tokenPath"`
+	// MaxDurationSeconds gives the maximum duration, in seconds, that an override may last for.
+	MaxDurationSeconds uint `json:"maxDurationSeconds"`
 }
 
 // ScalingConfig defines the scheduling we use for scaling up and down
@@ -124,9 +146,47 @@ type SchedulerConfig struct {
 	RetryDeniedUpscaleSeconds uint `json:"retryDeniedUpscaleSeconds"`
 	// RequestPort defines the port to access the scheduler's ✨special✨ API with
 	RequestPort uint16 `json:"requestPort"`
+	// GRPCPort, if not zero, is the port to access the scheduler plugin's permit stream gRPC server
+	// with. When set, requests are sent over a long-lived stream for each VM, and the scheduler
+	// plugin pushes updated permits as soon as they're approved.
+	//
+	// If the scheduler plugin doesn't support permit streams, requests fall back to RequestPort.
+	GRPCPort uint16 `json:"grpcPort,omitempty"`
 	// MaxFailedRequestRate defines the maximum rate of failed scheduler requests, above which
 	// a VM is considered stuck.
 	MaxFailedRequestRate RateThresholdConfig `json:"maxFailedRequestRate"`
+	// LocalBudget, if not nil, enables a degraded mode where VMs on this node may be upscaled
+	// without the scheduler plugin's approval while it is unavailable, using a budget for the node
+	// that's shared by all of the VMs.
+	LocalBudget *LocalBudgetConfig `json:"localBudget,omitempty"`
+}
+
+// LocalBudgetConfig configures the budget used to approve upscaling while the scheduler plugin is
+// unavailable.
+//
+// The budget is a fraction of the node's allocatable resources, minus the resources of all the VMs
+// on the node that this autoscaler-agent is responsible for. Because other pods on the node aren't
+// counted, NodeFraction should be set conservatively.
+type LocalBudgetConfig struct {
+	// DegradedAfterSeconds gives the duration, in seconds, that requests to the scheduler plugin
+	// must have been continuously failing before we start using the local budget.
+	DegradedAfterSeconds uint `json:"degradedAfterSeconds"`
+	// NodeFraction is the fraction of the node's allocatable CPU and memory that may be used by
+	// VMs on the node while in degraded mode. It must be greater than zero and at most one.
+	NodeFraction float64 `json:"nodeFraction"`
+	// MaxUpscaleCU gives the maximum number of compute units that a VM may be upscaled by in a
+	// single approval from the local budget.
+	MaxUpscaleCU uint32 `json:"maxUpscaleCU"`
+	// RefreshNodeSeconds gives the duration, in seconds, between fetching the node's allocatable
+	// resources.
+	RefreshNodeSeconds uint `json:"refreshNodeSeconds"`
+}
+
+func (c *SchedulerConfig) pluginDegradedAfter() time.Duration {
+	if c.LocalBudget == nil {
+		return 0
+	}
+	return time.Second * time.Duration(c.LocalBudget.DegradedAfterSeconds)
 }
 
 // NeonVMConfig defines a few parameters for NeonVM requests
@@ -142,6 +202,39 @@ type NeonVMConfig struct {
 	MaxFailedRequestRate RateThresholdConfig `json:"maxFailedRequestRate"`
 }
 
+// CoreConfig returns the core.Config derived from the autoscaler-agent config.
+//
+// The fields that depend on more than just the config - GoalPolicies, Log, RevisionSource, and
+// ObservabilityCallbacks - are left empty, for the caller to fill in.
+func (c *Config) CoreConfig() core.Config {
+	return core.Config{
+		ComputeUnit:                        c.Scaling.ComputeUnit,
+		DefaultScalingConfig:               c.Scaling.DefaultConfig,
+		GoalPolicies:                       nil,
+		NeonVMRetryWait:                    time.Second * time.Duration(c.NeonVM.RetryFailedRequestSeconds),
+		PluginRequestTick:                  time.Second * time.Duration(c.Scheduler.RequestAtLeastEverySeconds),
+		PluginRetryWait:                    time.Second * time.Duration(c.Scheduler.RetryFailedRequestSeconds),
+		PluginDeniedRetryWait:              time.Second * time.Duration(c.Scheduler.RetryDeniedUpscaleSeconds),
+		PluginDegradedAfter:                c.Scheduler.pluginDegradedAfter(),
+		MonitorDeniedDownscaleCooldown:     time.Second * time.Duration(c.Monitor.RetryDeniedDownscaleSeconds),
+		MonitorRequestedUpscaleValidPeriod: time.Second * time.Duration(c.Monitor.RequestedUpscaleValidSeconds),
+		MonitorRetryWait:                   time.Second * time.Duration(c.Monitor.RetryFailedRequestSeconds),
+		Log: core.LogConfig{
+			Info: nil,
+			Warn: nil,
+		},
+		RevisionSource: nil,
+		ObservabilityCallbacks: core.ObservabilityCallbacks{
+			PluginLatency:       nil,
+			MonitorLatency:      nil,
+			NeonVMLatency:       nil,
+			ActualScaling:       nil,
+			HypotheticalScaling: nil,
+			DeniedScaling:       nil,
+		},
+	}
+}
+
 func ReadConfig(path string) (*Config, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -169,17 +262,73 @@ func (c *Config) validate() error {
 	const (
 		emptyTmpl = "field %q cannot be empty"
 		zeroTmpl  = "field %q cannot be zero"
+		// used for clients that don't support non-JSON batch formats
+		jsonOnlyTmpl = "field %q must be empty or \"json\""
 	)
 
+	// spoolDirs maps each spool directory to the client using it, because each client must have
+	// its own.
+	spoolDirs := make(map[string]string)
+
 	validateBaseReportingConfig := func(cfg *reporting.BaseClientConfig, key string) {
 		erc.Whenf(ec, cfg.PushEverySeconds == 0, zeroTmpl, fmt.Sprintf("%s.pushEverySeconds", key))
 		erc.Whenf(ec, cfg.PushRequestTimeoutSeconds == 0, zeroTmpl, fmt.Sprintf("%s.pushRequestTimeoutSeconds", key))
 		erc.Whenf(ec, cfg.MaxBatchSize == 0, zeroTmpl, fmt.Sprintf("%s.maxBatchSize", key))
+		if err := cfg.Format.Validate(); err != nil {
+			ec.Add(fmt.Errorf("field %q is invalid: %w", fmt.Sprintf("%s.format", key), err))
+		}
+		if cfg.Spool != nil {
+			dirKey := fmt.Sprintf("%s.spool.directory", key)
+			erc.Whenf(ec, cfg.Spool.Directory == "", emptyTmpl, dirKey)
+			erc.Whenf(ec, cfg.Spool.MaxSizeMB == 0, zeroTmpl, fmt.Sprintf("%s.spool.maxSizeMB", key))
+
+			if cfg.Spool.Directory != "" {
+				dir := filepath.Clean(cfg.Spool.Directory)
+				if other, ok := spoolDirs[dir]; ok {
+					ec.Add(fmt.Errorf("field %q cannot be the same as %q", dirKey, other))
+				} else {
+					spoolDirs[dir] = dirKey
+				}
+			}
+		}
+		if cfg.Retry != nil {
+			erc.Whenf(
+				ec,
+				cfg.Retry.MaxBackoffSeconds < cfg.Retry.InitialBackoffSeconds,
+				"field %q cannot be less than %q",
+				fmt.Sprintf("%s.retry.maxBackoffSeconds", key),
+				fmt.Sprintf("%s.retry.initialBackoffSeconds", key),
+			)
+		}
 	}
 	validateS3ReportingConfig := func(cfg *reporting.S3ClientConfig, key string) {
 		erc.Whenf(ec, cfg.Bucket == "", emptyTmpl, fmt.Sprintf(".%s.bucket", key))
 		erc.Whenf(ec, cfg.Region == "", emptyTmpl, fmt.Sprintf(".%s.region", key))
 	}
+	validateKafkaReportingConfig := func(cfg *reporting.KafkaClientConfig, key string) {
+		erc.Whenf(ec, len(cfg.Brokers) == 0, emptyTmpl, fmt.Sprintf("%s.brokers", key))
+		erc.Whenf(ec, cfg.Topic == "", emptyTmpl, fmt.Sprintf("%s.topic", key))
+		if cfg.SASL != nil {
+			erc.Whenf(
+				ec,
+				!slices.Contains(
+					[]string{reporting.KafkaSASLPlain, reporting.KafkaSASLScramSHA256, reporting.KafkaSASLScramSHA512},
+					cfg.SASL.Mechanism,
+				),
+				"field %q must be one of %q, %q, or %q",
+				fmt.Sprintf("%s.sasl.mechanism", key),
+				reporting.KafkaSASLPlain, reporting.KafkaSASLScramSHA256, reporting.KafkaSASLScramSHA512,
+			)
+			erc.Whenf(ec, cfg.SASL.Username == "", emptyTmpl, fmt.Sprintf("%s.sasl.username", key))
+			erc.Whenf(ec, cfg.SASL.PasswordPath == "", emptyTmpl, fmt.Sprintf("%s.sasl.passwordPath", key))
+		}
+	}
+	validateGCSReportingConfig := func(cfg *reporting.GCSClientConfig, key string) {
+		erc.Whenf(ec, cfg.Bucket == "", emptyTmpl, fmt.Sprintf("%s.bucket", key))
+	}
+	validateLocalFileReportingConfig := func(cfg *reporting.LocalFileClientConfig, key string) {
+		erc.Whenf(ec, cfg.Directory == "", emptyTmpl, fmt.Sprintf("%s.directory", key))
+	}
 	validateAzureBlobReportingConfig := func(cfg *reporting.AzureBlobStorageClientConfig, key string) {
 		erc.Whenf(ec, cfg.Endpoint == "", emptyTmpl, fmt.Sprintf(".%s.endpoint", key))
 		erc.Whenf(ec, cfg.Container == "", emptyTmpl, fmt.Sprintf("%s.container", key))
@@ -189,17 +338,74 @@ func (c *Config) validate() error {
 	erc.Whenf(ec, c.Billing.CPUMetricName == "", emptyTmpl, ".billing.cpuMetricName")
 	erc.Whenf(ec, c.Billing.CollectEverySeconds == 0, zeroTmpl, ".billing.collectEverySeconds")
 	erc.Whenf(ec, c.Billing.AccumulateEverySeconds == 0, zeroTmpl, ".billing.accumulateEverySeconds")
-	if c.Billing.Clients.AzureBlob != nil {
-		validateBaseReportingConfig(&c.Billing.Clients.AzureBlob.BaseClientConfig, ".billing.clients.azureBlob")
-		validateAzureBlobReportingConfig(&c.Billing.Clients.AzureBlob.AzureBlobStorageClientConfig, ".billing.clients.azureBlob")
+	if c.Billing.Network != nil {
+		erc.Whenf(ec, c.Billing.Network.IngressMetricName == "", emptyTmpl, ".billing.network.ingressMetricName")
+		erc.Whenf(ec, c.Billing.Network.EgressMetricName == "", emptyTmpl, ".billing.network.egressMetricName")
+		erc.Whenf(ec, c.Billing.Network.RequestTimeoutSeconds == 0, zeroTmpl, ".billing.network.requestTimeoutSeconds")
+	}
+	if c.Billing.Labels != nil {
+		erc.Whenf(ec, len(c.Billing.Labels.Sources) == 0, emptyTmpl, ".billing.labels.sources")
+		erc.Whenf(
+			ec,
+			len(c.Billing.Labels.Sources) > billing.MaxLabels,
+			"field %q cannot have more than %d entries", ".billing.labels.sources", billing.MaxLabels,
+		)
+		seenKeys := make(map[string]struct{})
+		for i, src := range c.Billing.Labels.Sources {
+			key := fmt.Sprintf(".billing.labels.sources[%d]", i)
+			erc.Whenf(ec, src.Key == "", emptyTmpl, fmt.Sprintf("%s.key", key))
+			if _, ok := seenKeys[src.Key]; ok && src.Key != "" {
+				ec.Add(fmt.Errorf("field %q has duplicate value %q", fmt.Sprintf("%s.key", key), src.Key))
+			}
+			seenKeys[src.Key] = struct{}{}
+			erc.Whenf(
+				ec,
+				(src.FromLabel == "") == (src.FromAnnotation == ""),
+				"exactly one of %q and %q must be set",
+				fmt.Sprintf("%s.fromLabel", key),
+				fmt.Sprintf("%s.fromAnnotation", key),
+			)
+			erc.Whenf(ec, len(src.AllowedValues) == 0, emptyTmpl, fmt.Sprintf("%s.allowedValues", key))
+			erc.Whenf(
+				ec,
+				len(src.AllowedValues) > billing.MaxValuesPerLabel,
+				"field %q cannot have more than %d entries",
+				fmt.Sprintf("%s.allowedValues", key), billing.MaxValuesPerLabel,
+			)
+		}
 	}
-	if c.Billing.Clients.HTTP != nil {
-		validateBaseReportingConfig(&c.Billing.Clients.HTTP.BaseClientConfig, ".billing.clients.http")
-		erc.Whenf(ec, c.Billing.Clients.HTTP.URL == "", emptyTmpl, ".billing.clients.http.url")
+	validateBillingClients := func(cfg *billing.ClientsConfig, key string) {
+		if cfg.AzureBlob != nil {
+			validateBaseReportingConfig(&cfg.AzureBlob.BaseClientConfig, fmt.Sprintf("%s.azureBlob", key))
+			validateAzureBlobReportingConfig(&cfg.AzureBlob.AzureBlobStorageClientConfig, fmt.Sprintf("%s.azureBlob", key))
+		}
+		if cfg.GCS != nil {
+			validateBaseReportingConfig(&cfg.GCS.BaseClientConfig, fmt.Sprintf("%s.gcs", key))
+			validateGCSReportingConfig(&cfg.GCS.GCSClientConfig, fmt.Sprintf("%s.gcs", key))
+		}
+		if cfg.HTTP != nil {
+			validateBaseReportingConfig(&cfg.HTTP.BaseClientConfig, fmt.Sprintf("%s.http", key))
+			erc.Whenf(ec, cfg.HTTP.URL == "", emptyTmpl, fmt.Sprintf("%s.http.url", key))
+			erc.Whenf(ec, !cfg.HTTP.Format.IsJSON(), jsonOnlyTmpl, fmt.Sprintf("%s.http.format", key))
+		}
+		if cfg.Kafka != nil {
+			validateBaseReportingConfig(&cfg.Kafka.BaseClientConfig, fmt.Sprintf("%s.kafka", key))
+			validateKafkaReportingConfig(&cfg.Kafka.KafkaClientConfig, fmt.Sprintf("%s.kafka", key))
+			erc.Whenf(ec, !cfg.Kafka.Format.IsJSON(), jsonOnlyTmpl, fmt.Sprintf("%s.kafka.format", key))
+		}
+		if cfg.LocalFile != nil {
+			validateBaseReportingConfig(&cfg.LocalFile.BaseClientConfig, fmt.Sprintf("%s.localFile", key))
+			validateLocalFileReportingConfig(&cfg.LocalFile.LocalFileClientConfig, fmt.Sprintf("%s.localFile", key))
+		}
+		if cfg.S3 != nil {
+			validateBaseReportingConfig(&cfg.S3.BaseClientConfig, fmt.Sprintf("%s.s3", key))
+			validateS3ReportingConfig(&cfg.S3.S3ClientConfig, fmt.Sprintf("%s.s3", key))
+		}
 	}
-	if c.Billing.Clients.S3 != nil {
-		validateBaseReportingConfig(&c.Billing.Clients.S3.BaseClientConfig, "billing.clients.s3")
-		validateS3ReportingConfig(&c.Billing.Clients.S3.S3ClientConfig, ".billing.clients.s3")
+	validateBillingClients(&c.Billing.Clients, ".billing.clients")
+	if c.Billing.Summary != nil {
+		validateBillingClients(&c.Billing.Summary.Clients, ".billing.summary.clients")
+		erc.Whenf(ec, c.Billing.Summary.Clients.HTTP != nil, "field %q is not supported", ".billing.summary.clients.http")
 	}
 
 	erc.Whenf(ec, c.ScalingEvents.CUMultiplier == 0, zeroTmpl, ".scalingEvents.cuMultiplier")
@@ -210,6 +416,20 @@ func (c *Config) validate() error {
 		validateAzureBlobReportingConfig(&c.ScalingEvents.Clients.AzureBlob.AzureBlobStorageClientConfig, ".scalingEvents.clients.azureBlob")
 		erc.Whenf(ec, c.ScalingEvents.Clients.AzureBlob.PrefixInContainer == "", emptyTmpl, ".scalingEvents.clients.azureBlob.prefixInContainer")
 	}
+	if c.ScalingEvents.Clients.GCS != nil {
+		validateBaseReportingConfig(&c.ScalingEvents.Clients.GCS.BaseClientConfig, ".scalingEvents.clients.gcs")
+		validateGCSReportingConfig(&c.ScalingEvents.Clients.GCS.GCSClientConfig, ".scalingEvents.clients.gcs")
+		erc.Whenf(ec, c.ScalingEvents.Clients.GCS.PrefixInBucket == "", emptyTmpl, ".scalingEvents.clients.gcs.prefixInBucket")
+	}
+	if c.ScalingEvents.Clients.Kafka != nil {
+		validateBaseReportingConfig(&c.ScalingEvents.Clients.Kafka.BaseClientConfig, ".scalingEvents.clients.kafka")
+		validateKafkaReportingConfig(&c.ScalingEvents.Clients.Kafka.KafkaClientConfig, ".scalingEvents.clients.kafka")
+		erc.Whenf(ec, !c.ScalingEvents.Clients.Kafka.Format.IsJSON(), jsonOnlyTmpl, ".scalingEvents.clients.kafka.format")
+	}
+	if c.ScalingEvents.Clients.LocalFile != nil {
+		validateBaseReportingConfig(&c.ScalingEvents.Clients.LocalFile.BaseClientConfig, ".scalingEvents.clients.localFile")
+		validateLocalFileReportingConfig(&c.ScalingEvents.Clients.LocalFile.LocalFileClientConfig, ".scalingEvents.clients.localFile")
+	}
 	if c.ScalingEvents.Clients.S3 != nil {
 		validateBaseReportingConfig(&c.ScalingEvents.Clients.S3.BaseClientConfig, "scalingEvents.clients.s3")
 		validateS3ReportingConfig(&c.ScalingEvents.Clients.S3.S3ClientConfig, ".scalingEvents.clients.s3")
@@ -218,6 +438,16 @@ func (c *Config) validate() error {
 
 	erc.Whenf(ec, c.DumpState != nil && c.DumpState.Port == 0, zeroTmpl, ".dumpState.port")
 	erc.Whenf(ec, c.DumpState != nil && c.DumpState.TimeoutSeconds == 0, zeroTmpl, ".dumpState.timeoutSeconds")
+	if c.DumpState != nil && c.DumpState.CUOverride != nil {
+		erc.Whenf(ec, c.DumpState.CUOverride.TokenPath == "", emptyTmpl, ".dumpState.cuOverride.tokenPath")
+		erc.Whenf(ec, c.DumpState.CUOverride.MaxDurationSeconds == 0, zeroTmpl, ".dumpState.cuOverride.maxDurationSeconds")
+	}
+	if c.Recording != nil {
+		erc.Whenf(ec, c.Recording.Directory == "", emptyTmpl, ".recording.directory")
+		erc.Whenf(ec, c.Recording.MaxFileSizeMB == 0, zeroTmpl, ".recording.maxFileSizeMB")
+		// Replaying needs every event since the Runner's EventInit, so no rotated files may be removed.
+		erc.Whenf(ec, c.Recording.MaxBackups != 0, "field %q must be zero", ".recording.maxBackups")
+	}
 
 	validateMetricsConfig := func(cfg MetricsSourceConfig, key string) {
 		erc.Whenf(ec, cfg.Port == 0, zeroTmpl, fmt.Sprintf(".metrics.%s.port", key))
@@ -250,6 +480,16 @@ func (c *Config) validate() error {
 	erc.Whenf(ec, c.Scheduler.RetryFailedRequestSeconds == 0, zeroTmpl, ".scheduler.retryFailedRequestSeconds")
 	erc.Whenf(ec, c.Scheduler.RetryDeniedUpscaleSeconds == 0, zeroTmpl, ".scheduler.retryDeniedUpscaleSeconds")
 	erc.Whenf(ec, c.Scheduler.SchedulerName == "", emptyTmpl, ".scheduler.schedulerName")
+	if c.Scheduler.LocalBudget != nil {
+		erc.Whenf(ec, c.Scheduler.LocalBudget.DegradedAfterSeconds == 0, zeroTmpl, ".scheduler.localBudget.degradedAfterSeconds")
+		erc.Whenf(
+			ec,
+			!(c.Scheduler.LocalBudget.NodeFraction > 0 && c.Scheduler.LocalBudget.NodeFraction <= 1),
+			"field %q must be greater than zero and at most one", ".scheduler.localBudget.nodeFraction",
+		)
+		erc.Whenf(ec, c.Scheduler.LocalBudget.MaxUpscaleCU == 0, zeroTmpl, ".scheduler.localBudget.maxUpscaleCU")
+		erc.Whenf(ec, c.Scheduler.LocalBudget.RefreshNodeSeconds == 0, zeroTmpl, ".scheduler.localBudget.refreshNodeSeconds")
+	}
 	erc.Whenf(ec, c.Scheduler.MaxFailedRequestRate.IntervalSeconds == 0, zeroTmpl, ".monitor.maxFailedRequestRate.intervalSeconds")
 
 	return ec.Resolve()
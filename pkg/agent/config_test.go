@@ -0,0 +1,38 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/billing"
+	"github.com/neondatabase/autoscaling/pkg/reporting"
+)
+
+func TestValidateSharedSpoolDirectory(t *testing.T) {
+	localFile := func(spoolDir string) *billing.LocalFileClientConfig {
+		//nolint:exhaustruct // this is a test
+		return &billing.LocalFileClientConfig{
+			BaseClientConfig: reporting.BaseClientConfig{
+				PushEverySeconds:          10,
+				PushRequestTimeoutSeconds: 10,
+				MaxBatchSize:              100,
+				Spool:                     &reporting.SpoolConfig{Directory: spoolDir, MaxSizeMB: 10},
+			},
+			LocalFileClientConfig: reporting.LocalFileClientConfig{Directory: "/data", MaxFiles: 0},
+		}
+	}
+
+	//nolint:exhaustruct // this is a test
+	c := &Config{}
+	c.Billing.Clients.LocalFile = localFile("/spool/billing")
+	c.Billing.Summary = &billing.SummaryConfig{} //nolint:exhaustruct // this is a test
+	c.Billing.Summary.Clients.LocalFile = localFile("/spool/billing/")
+
+	err := c.validate()
+	assert.ErrorContains(t, err, `field ".billing.summary.clients.localFile.spool.directory" cannot be the same as ".billing.clients.localFile.spool.directory"`)
+
+	c.Billing.Summary.Clients.LocalFile = localFile("/spool/summary")
+	err = c.validate()
+	assert.NotContains(t, err.Error(), "spool.directory")
+}
@@ -26,6 +26,9 @@ type ActionPluginRequest struct {
 	Target         api.Resources         `json:"target"`
 	Metrics        *api.Metrics          `json:"metrics"`
 	TargetRevision vmv1.RevisionWithTime `json:"targetRevision"`
+	// UseLocalBudget is true if the plugin has been unavailable for long enough that, if this
+	// request fails, the resources should instead be requested from the local budget for the node.
+	UseLocalBudget bool `json:"useLocalBudget,omitempty"`
 }
 
 type ActionNeonVMRequest struct {
@@ -75,6 +78,7 @@ func (a ActionPluginRequest) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 	_ = addObjectPtr(enc, "lastPermit", a.LastPermit)
 	_ = enc.AddObject("target", a.Target)
 	_ = enc.AddReflected("metrics", a.Metrics)
+	enc.AddBool("useLocalBudget", a.UseLocalBudget)
 	return nil
 }
 
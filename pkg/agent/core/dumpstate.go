@@ -4,6 +4,7 @@ package core
 
 import (
 	"encoding/json"
+	"slices"
 	"time"
 
 	"github.com/neondatabase/autoscaling/pkg/api"
@@ -39,20 +40,41 @@ func (s *State) Dump() StateDump {
 			Plugin:               s.internal.Plugin.deepCopy(),
 			Monitor:              s.internal.Monitor.deepCopy(),
 			NeonVM:               s.internal.NeonVM.deepCopy(),
-			Metrics:              shallowCopy[SystemMetrics](s.internal.Metrics),
+			Metrics:              s.internal.Metrics.deepCopy(),
+			LoadHistory:          slices.Clone(s.internal.LoadHistory),
 			LFCMetrics:           shallowCopy[LFCMetrics](s.internal.LFCMetrics),
 			TargetRevision:       s.internal.TargetRevision,
 			LastDesiredResources: s.internal.LastDesiredResources,
+			LastDesiredLimit:     s.internal.LastDesiredLimit,
+			ScheduledMinimum:     shallowCopy[scheduledMinimum](s.internal.ScheduledMinimum),
+
+			DownscaleStabilization: shallowCopy[downscaleStabilization](s.internal.DownscaleStabilization),
+			PSIStep:                shallowCopy[PSIStep](s.internal.PSIStep),
+			CUOverride:             shallowCopy[CUOverride](s.internal.CUOverride),
 		},
 	}
 }
 
+func (m *SystemMetrics) deepCopy() *SystemMetrics {
+	if m == nil {
+		return nil
+	}
+	c := *m
+	c.CPUSeconds = shallowCopy[CPUModeSeconds](m.CPUSeconds)
+	c.Pressure = shallowCopy[PressureMetrics](m.Pressure)
+	return &c
+}
+
 func (s *pluginState) deepCopy() pluginState {
 	return pluginState{
 		OngoingRequest:  s.OngoingRequest,
 		LastRequest:     shallowCopy[pluginRequested](s.LastRequest),
 		LastFailureAt:   shallowCopy[time.Time](s.LastFailureAt),
+		FailingSince:    shallowCopy[time.Time](s.FailingSince),
 		Permit:          shallowCopy[api.Resources](s.Permit),
+		LocalPermit:     shallowCopy[api.Resources](s.LocalPermit),
+		DeniedRetryWait: shallowCopy[time.Duration](s.DeniedRetryWait),
+		LastDenial:      shallowCopy[pluginDenial](s.LastDenial),
 		CurrentRevision: s.CurrentRevision,
 	}
 }
@@ -75,6 +97,7 @@ func (s *neonvmState) deepCopy() neonvmState {
 		OngoingRequested: shallowCopy[api.Resources](s.OngoingRequested),
 		RequestFailedAt:  shallowCopy[time.Time](s.RequestFailedAt),
 		TargetRevision:   s.TargetRevision,
+		TargetLimit:      s.TargetLimit,
 		CurrentRevision:  s.CurrentRevision,
 	}
 }
@@ -4,6 +4,7 @@ package core
 
 import (
 	"math"
+	"time"
 
 	"github.com/samber/lo"
 	"go.uber.org/zap"
@@ -22,22 +23,69 @@ type ScalingGoalParts struct {
 	CPU *float64
 	Mem *float64
 	LFC *float64
+
+	// CPUForecast, if not nil, is the goal CU based on the load average projected forward by the
+	// configured forecast horizon. It is only set when api.ScalingConfig.EnableCPUForecast is true
+	// and there is enough load history to fit a trend.
+	CPUForecast *float64
+
+	// Scheduled, if not nil, is the minimum CU required by the VM's scaling schedule at the time
+	// the goal was calculated. This is set by State, not by the GoalPolicy.
+	Scheduled *float64
+
+	// PSI, if not nil, is the goal CU based on pressure stall information. It is only set when
+	// api.ScalingConfig.EnablePSIScaling is true and the VM reports PSI metrics.
+	PSI *float64
+
+	// Override, if not nil, is the CU that the VM has been temporarily pinned to. This is set by
+	// State, not by the GoalPolicy, and is not included in GoalCU(): it replaces the goal, rather
+	// than contributing to it.
+	Override *float64
 }
 
 func (g *ScalingGoal) GoalCU() uint32 {
 	return uint32(math.Ceil(max(
-		math.Round(lo.FromPtr(g.Parts.CPU)), // for historical compatibility, use round() instead of ceil()
+		math.Round(lo.FromPtr(g.Parts.CPU)),         // for historical compatibility, use round() instead of ceil()
+		math.Round(lo.FromPtr(g.Parts.CPUForecast)), // same rounding as CPU, for consistency
 		lo.FromPtr(g.Parts.Mem),
 		lo.FromPtr(g.Parts.LFC),
+		lo.FromPtr(g.Parts.Scheduled),
+		lo.FromPtr(g.Parts.PSI),
 	)))
 }
 
+// PSIStep records the most recent goal CU from pressure stall information, so that the goal is
+// only raised one step at a time.
+type PSIStep struct {
+	// At gives the time at which the goal was raised to GoalCU.
+	At time.Time `json:"at"`
+	// GoalCU is the goal CU from PSI as of At.
+	GoalCU float64 `json:"goalCU"`
+}
+
+// defaultPSIStepCooldownSeconds is the minimum time between successive increases of the PSI goal
+// CU, if ScalingConfig.PSIStepCooldownSeconds is not set.
+//
+// Pressure is reported as a 10-second average, so it takes a while after upscaling before the
+// pressure drops -- without waiting, the goal would keep climbing while that happens.
+const defaultPSIStepCooldownSeconds = 30
+
+// LoadSample is a single load average datapoint, recorded for CPU forecasting.
+type LoadSample struct {
+	At              time.Time
+	LoadAverage1Min float64
+}
+
 func calculateGoalCU(
 	warn func(string),
 	cfg api.ScalingConfig,
 	computeUnit api.Resources,
+	current api.Resources,
 	systemMetrics *SystemMetrics,
+	loadHistory []LoadSample,
 	lfcMetrics *LFCMetrics,
+	now time.Time,
+	lastPSIStep *PSIStep,
 ) (ScalingGoal, []zap.Field) {
 	hasAllMetrics := systemMetrics != nil && (!*cfg.EnableLFCMetrics || lfcMetrics != nil)
 	if !hasAllMetrics {
@@ -67,6 +115,22 @@ func calculateGoalCU(
 		parts.Mem = lo.ToPtr(memGoalCU)
 	}
 
+	if systemMetrics != nil && lo.FromPtr(cfg.EnableCPUForecast) {
+		var forecastLogFunc func(zapcore.ObjectEncoder) error
+		parts.CPUForecast, forecastLogFunc = calculateCPUForecastGoalCU(warn, cfg, computeUnit, loadHistory)
+		if forecastLogFunc != nil {
+			logFields = append(logFields, zap.Object("cpuForecast", zapcore.ObjectMarshalerFunc(forecastLogFunc)))
+		}
+	}
+
+	if systemMetrics != nil && systemMetrics.Pressure != nil && lo.FromPtr(cfg.EnablePSIScaling) {
+		var psiLogFunc func(zapcore.ObjectEncoder) error
+		parts.PSI, psiLogFunc = calculatePSIGoalCU(warn, cfg, computeUnit, current, *systemMetrics.Pressure, now, lastPSIStep)
+		if psiLogFunc != nil {
+			logFields = append(logFields, zap.Object("psi", zapcore.ObjectMarshalerFunc(psiLogFunc)))
+		}
+	}
+
 	if systemMetrics != nil && wss != nil {
 		memTotalGoalCU := calculateMemTotalGoalCU(cfg, computeUnit, *systemMetrics, *wss)
 		parts.Mem = lo.ToPtr(max(*parts.Mem, memTotalGoalCU))
@@ -98,6 +162,132 @@ func calculateCPUGoalCU(
 	return cpuGoalCU
 }
 
+// For CPU forecasting:
+// Fit a linear trend (least squares) to the recent 1-minute load average samples, and project it
+// forward by the forecast horizon from the most recent sample. The projected load is then converted
+// to CU in the same way as for the regular CPU goal.
+//
+// Because the goal CU is the maximum of all components, a downwards trend never lowers the goal
+// below what the current load requires; forecasting only allows us to upscale earlier.
+func calculateCPUForecastGoalCU(
+	warn func(string),
+	cfg api.ScalingConfig,
+	computeUnit api.Resources,
+	loadHistory []LoadSample,
+) (*float64, func(zapcore.ObjectEncoder) error) {
+	if cfg.CPUForecastHorizonSeconds == nil {
+		warn("CPU forecasting is enabled, but the forecast horizon is not set")
+		return nil, nil
+	}
+
+	// Need at least two distinct points in time to fit a trend.
+	if len(loadHistory) < 2 || !loadHistory[len(loadHistory)-1].At.After(loadHistory[0].At) {
+		return nil, nil
+	}
+
+	horizon := time.Second * time.Duration(*cfg.CPUForecastHorizonSeconds)
+	slope, intercept := linearTrend(loadHistory)
+
+	// x values are measured in seconds relative to the first sample.
+	latest := loadHistory[len(loadHistory)-1]
+	projectAt := latest.At.Add(horizon).Sub(loadHistory[0].At).Seconds()
+	projectedLoad := max(0, intercept+slope*projectAt)
+
+	goalCPUs := projectedLoad / *cfg.LoadAverageFractionTarget
+	forecastGoalCU := goalCPUs / computeUnit.VCPU.AsFloat64()
+
+	logFunc := func(obj zapcore.ObjectEncoder) error {
+		obj.AddInt("samples", len(loadHistory))
+		obj.AddFloat64("slopePerSecond", slope)
+		obj.AddFloat64("projectedLoad", projectedLoad)
+		obj.AddFloat64("requiredCU", forecastGoalCU)
+		return nil
+	}
+
+	return &forecastGoalCU, logFunc
+}
+
+// linearTrend returns the slope and intercept of the least-squares fit of the load samples, with x
+// measured in seconds since the first sample.
+func linearTrend(samples []LoadSample) (slope float64, intercept float64) {
+	n := float64(len(samples))
+	var sumX, sumY, sumXY, sumXX float64
+	for _, s := range samples {
+		x := s.At.Sub(samples[0].At).Seconds()
+		y := s.LoadAverage1Min
+		sumX += x
+		sumY += y
+		sumXY += x * y
+		sumXX += x * x
+	}
+
+	denom := n*sumXX - sumX*sumX
+	if denom == 0 {
+		// All samples at the same time; no trend.
+		return 0, sumY / n
+	}
+
+	slope = (n*sumXY - sumX*sumY) / denom
+	intercept = (sumY - slope*sumX) / n
+	return slope, intercept
+}
+
+// For PSI:
+// If the share of time that tasks were stalled on CPU or memory is above the threshold, the VM is
+// under-provisioned in a way that may not show up in the load average or memory usage (e.g. CPU
+// steal from noisy neighbours, or heavy reclaim). We don't know by how much, so the goal is one
+// CU more than what the VM currently has. Otherwise, this component has no effect.
+//
+// While pressure stays high, the goal is held at the last step until the VM has been scaled up to
+// it and the cooldown (ScalingConfig.PSIStepCooldownSeconds) has passed, so that we don't keep adding CU before the previous step has
+// had a chance to relieve the pressure.
+//
+// I/O pressure is deliberately not used: more CPU or memory generally doesn't help with I/O
+// stalls, except through the page cache, which is already covered by the memory goal.
+func calculatePSIGoalCU(
+	warn func(string),
+	cfg api.ScalingConfig,
+	computeUnit api.Resources,
+	current api.Resources,
+	pressure PressureMetrics,
+	now time.Time,
+	lastStep *PSIStep,
+) (*float64, func(zapcore.ObjectEncoder) error) {
+	if cfg.PSIThresholdPercent == nil {
+		warn("PSI scaling is enabled, but the PSI threshold is not set")
+		return nil, nil
+	}
+
+	stall := max(pressure.CPUSome10s, pressure.MemorySome10s)
+	if stall <= *cfg.PSIThresholdPercent {
+		return nil, nil
+	}
+
+	currentCU := max(
+		current.VCPU.AsFloat64()/computeUnit.VCPU.AsFloat64(),
+		current.Mem.AsFloat64()/computeUnit.Mem.AsFloat64(),
+	)
+	psiGoalCU := math.Ceil(currentCU) + 1
+
+	cooldown := time.Second * time.Duration(lo.FromPtrOr(cfg.PSIStepCooldownSeconds, defaultPSIStepCooldownSeconds))
+
+	held := false
+	if lastStep != nil && (math.Ceil(currentCU) < lastStep.GoalCU || now.Before(lastStep.At.Add(cooldown))) {
+		psiGoalCU = lastStep.GoalCU
+		held = true
+	}
+
+	logFunc := func(obj zapcore.ObjectEncoder) error {
+		obj.AddFloat64("cpuSome10s", pressure.CPUSome10s)
+		obj.AddFloat64("memorySome10s", pressure.MemorySome10s)
+		obj.AddFloat64("requiredCU", psiGoalCU)
+		obj.AddBool("held", held)
+		return nil
+	}
+
+	return &psiGoalCU, logFunc
+}
+
 func blendingFactor[T constraints.Float](value, t1, t2 T) T {
 	if value <= t1 {
 		return 0
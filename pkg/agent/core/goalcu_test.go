@@ -2,6 +2,7 @@ package core
 
 import (
 	"testing"
+	"time"
 
 	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
@@ -24,6 +25,15 @@ func Test_calculateGoalCU(t *testing.T) {
 		LFCMinWaitBeforeDownscaleMinutes: lo.ToPtr(5),
 		CPUStableZoneRatio:               lo.ToPtr(0.0),
 		CPUMixedZoneRatio:                lo.ToPtr(0.0),
+		EnableCPUForecast:                nil,
+		CPUForecastWindowSeconds:         nil,
+		CPUForecastHorizonSeconds:        nil,
+		EnablePSIScaling:                 nil,
+		PSIThresholdPercent:              nil,
+		PSIStepCooldownSeconds:           nil,
+		DownscaleStabilizationSeconds:    nil,
+		DownscaleMaxStepCU:               nil,
+		GoalPolicy:                       nil,
 	}
 
 	warn := func(msg string) {}
@@ -43,9 +53,13 @@ func Test_calculateGoalCU(t *testing.T) {
 			want: ScalingGoal{
 				HasAllMetrics: false,
 				Parts: ScalingGoalParts{
-					CPU: nil,
-					Mem: nil,
-					LFC: nil,
+					CPU:         nil,
+					Mem:         nil,
+					LFC:         nil,
+					CPUForecast: nil,
+					Scheduled:   nil,
+					PSI:         nil,
+					Override:    nil,
 				},
 			},
 		},
@@ -60,9 +74,13 @@ func Test_calculateGoalCU(t *testing.T) {
 			want: ScalingGoal{
 				HasAllMetrics: false,
 				Parts: ScalingGoalParts{
-					CPU: lo.ToPtr(0.8),
-					Mem: lo.ToPtr(0.0),
-					LFC: nil,
+					CPU:         lo.ToPtr(0.8),
+					Mem:         lo.ToPtr(0.0),
+					LFC:         nil,
+					CPUForecast: nil,
+					Scheduled:   nil,
+					PSI:         nil,
+					Override:    nil,
 				},
 			},
 		},
@@ -77,9 +95,13 @@ func Test_calculateGoalCU(t *testing.T) {
 			want: ScalingGoal{
 				HasAllMetrics: false,
 				Parts: ScalingGoalParts{
-					CPU: lo.ToPtr(4.0),
-					Mem: lo.ToPtr(0.0),
-					LFC: nil,
+					CPU:         lo.ToPtr(4.0),
+					Mem:         lo.ToPtr(0.0),
+					LFC:         nil,
+					CPUForecast: nil,
+					Scheduled:   nil,
+					PSI:         nil,
+					Override:    nil,
 				},
 			},
 		},
@@ -97,9 +119,13 @@ func Test_calculateGoalCU(t *testing.T) {
 			want: ScalingGoal{
 				HasAllMetrics: false,
 				Parts: ScalingGoalParts{
-					CPU: lo.ToPtr(2.8),
-					Mem: lo.ToPtr(0.0),
-					LFC: nil,
+					CPU:         lo.ToPtr(2.8),
+					Mem:         lo.ToPtr(0.0),
+					LFC:         nil,
+					CPUForecast: nil,
+					Scheduled:   nil,
+					PSI:         nil,
+					Override:    nil,
 				},
 			},
 		},
@@ -113,14 +139,20 @@ func Test_calculateGoalCU(t *testing.T) {
 				LoadAverage5Min:   0.7, // equal to 3 CUs
 				MemoryUsageBytes:  0,
 				MemoryCachedBytes: 0,
+				CPUSeconds:        nil,
+				Pressure:          nil,
 			},
 			lfc: nil,
 			want: ScalingGoal{
 				HasAllMetrics: false,
 				Parts: ScalingGoalParts{
-					CPU: lo.ToPtr(2.8),
-					Mem: lo.ToPtr(0.0),
-					LFC: nil,
+					CPU:         lo.ToPtr(2.8),
+					Mem:         lo.ToPtr(0.0),
+					LFC:         nil,
+					CPUForecast: nil,
+					Scheduled:   nil,
+					PSI:         nil,
+					Override:    nil,
 				},
 			},
 		},
@@ -135,14 +167,20 @@ func Test_calculateGoalCU(t *testing.T) {
 				LoadAverage5Min:   1,    // 1*4 = 4 CUs
 				MemoryUsageBytes:  0,
 				MemoryCachedBytes: 0,
+				CPUSeconds:        nil,
+				Pressure:          nil,
 			},
 			lfc: nil,
 			want: ScalingGoal{
 				HasAllMetrics: false,
 				Parts: ScalingGoalParts{
-					CPU: lo.ToPtr(5.499997000005999),
-					Mem: lo.ToPtr(0.0),
-					LFC: nil,
+					CPU:         lo.ToPtr(5.499997000005999),
+					Mem:         lo.ToPtr(0.0),
+					LFC:         nil,
+					CPUForecast: nil,
+					Scheduled:   nil,
+					PSI:         nil,
+					Override:    nil,
 				},
 			},
 		},
@@ -155,8 +193,286 @@ func Test_calculateGoalCU(t *testing.T) {
 				c.cfgUpdater(&scalingConfig)
 			}
 
-			got, _ := calculateGoalCU(warn, scalingConfig, cu, c.sys, c.lfc)
+			got, _ := calculateGoalCU(warn, scalingConfig, cu, cu, c.sys, nil, c.lfc, time.Time{}, nil)
 			assert.InDelta(t, lo.FromPtrOr(c.want.Parts.CPU, -1), lo.FromPtrOr(got.Parts.CPU, -1), 0.000001)
 		})
 	}
 }
+
+func Test_calculateCPUForecastGoalCU(t *testing.T) {
+	cu := api.Resources{VCPU: 250, Mem: 1 << 30 /* 1 Gi */}
+
+	cfg := api.ScalingConfig{
+		LoadAverageFractionTarget:        lo.ToPtr(1.0),
+		MemoryUsageFractionTarget:        lo.ToPtr(0.5),
+		MemoryTotalFractionTarget:        lo.ToPtr(0.9),
+		EnableLFCMetrics:                 lo.ToPtr(false),
+		LFCUseLargestWindow:              lo.ToPtr(false),
+		LFCToMemoryRatio:                 lo.ToPtr(0.75),
+		LFCWindowSizeMinutes:             lo.ToPtr(5),
+		LFCMinWaitBeforeDownscaleMinutes: lo.ToPtr(5),
+		CPUStableZoneRatio:               lo.ToPtr(0.0),
+		CPUMixedZoneRatio:                lo.ToPtr(0.0),
+		EnableCPUForecast:                lo.ToPtr(true),
+		CPUForecastWindowSeconds:         lo.ToPtr(60),
+		CPUForecastHorizonSeconds:        lo.ToPtr(30),
+		EnablePSIScaling:                 nil,
+		PSIThresholdPercent:              nil,
+		PSIStepCooldownSeconds:           nil,
+		DownscaleStabilizationSeconds:    nil,
+		DownscaleMaxStepCU:               nil,
+		GoalPolicy:                       nil,
+	}
+
+	base := time.Now()
+	sample := func(seconds int, load float64) LoadSample {
+		return LoadSample{At: base.Add(time.Duration(seconds) * time.Second), LoadAverage1Min: load}
+	}
+
+	cases := []struct {
+		name    string
+		history []LoadSample
+		want    *float64
+	}{
+		{
+			name:    "no-history",
+			history: nil,
+			want:    nil,
+		},
+		{
+			name:    "single-sample",
+			history: []LoadSample{sample(0, 1.0)},
+			want:    nil,
+		},
+		{
+			name:    "flat",
+			history: []LoadSample{sample(0, 0.5), sample(10, 0.5), sample(20, 0.5)},
+			want:    lo.ToPtr(2.0), // 0.5 load / 0.25 vCPU per CU
+		},
+		{
+			// load increases by 0.1 every 10s, so in 30s past the last sample, it'll be 0.3 higher.
+			name:    "increasing",
+			history: []LoadSample{sample(0, 0.1), sample(10, 0.2), sample(20, 0.3)},
+			want:    lo.ToPtr(2.4), // (0.3 + 0.3) / 0.25
+		},
+		{
+			// projections below zero are clamped.
+			name:    "decreasing",
+			history: []LoadSample{sample(0, 0.3), sample(10, 0.2), sample(20, 0.1)},
+			want:    lo.ToPtr(0.0),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sys := &SystemMetrics{
+				LoadAverage1Min:   0,
+				LoadAverage5Min:   0,
+				MemoryUsageBytes:  0,
+				MemoryCachedBytes: 0,
+				CPUSeconds:        nil,
+				Pressure:          nil,
+			}
+			if len(c.history) != 0 {
+				sys.LoadAverage1Min = c.history[len(c.history)-1].LoadAverage1Min
+			}
+
+			got, _ := calculateGoalCU(func(string) {}, cfg, cu, cu, sys, c.history, nil, time.Time{}, nil)
+			if c.want == nil {
+				assert.Nil(t, got.Parts.CPUForecast)
+			} else {
+				assert.NotNil(t, got.Parts.CPUForecast)
+				assert.InDelta(t, *c.want, lo.FromPtr(got.Parts.CPUForecast), 0.000001)
+			}
+		})
+	}
+}
+
+func Test_calculatePSIGoalCU(t *testing.T) {
+	cu := api.Resources{VCPU: 250, Mem: 1 << 30 /* 1 Gi */}
+
+	cfg := api.ScalingConfig{
+		LoadAverageFractionTarget:        lo.ToPtr(1.0),
+		MemoryUsageFractionTarget:        lo.ToPtr(0.5),
+		MemoryTotalFractionTarget:        lo.ToPtr(0.9),
+		EnableLFCMetrics:                 lo.ToPtr(false),
+		LFCUseLargestWindow:              lo.ToPtr(false),
+		LFCToMemoryRatio:                 lo.ToPtr(0.75),
+		LFCWindowSizeMinutes:             lo.ToPtr(5),
+		LFCMinWaitBeforeDownscaleMinutes: lo.ToPtr(5),
+		CPUStableZoneRatio:               lo.ToPtr(0.0),
+		CPUMixedZoneRatio:                lo.ToPtr(0.0),
+		EnableCPUForecast:                nil,
+		CPUForecastWindowSeconds:         nil,
+		CPUForecastHorizonSeconds:        nil,
+		EnablePSIScaling:                 lo.ToPtr(true),
+		PSIThresholdPercent:              lo.ToPtr(20.0),
+		PSIStepCooldownSeconds:           nil,
+		DownscaleStabilizationSeconds:    nil,
+		DownscaleMaxStepCU:               nil,
+		GoalPolicy:                       nil,
+	}
+
+	cases := []struct {
+		name     string
+		current  api.Resources
+		pressure *PressureMetrics
+		want     *float64
+	}{
+		{
+			name:     "no-psi-metrics",
+			current:  cu.Mul(2),
+			pressure: nil,
+			want:     nil,
+		},
+		{
+			name:    "below-threshold",
+			current: cu.Mul(2),
+			//nolint:exhaustruct // this is a test
+			pressure: &PressureMetrics{CPUSome10s: 15, MemorySome10s: 5},
+			want:     nil,
+		},
+		{
+			name:    "cpu-pressure",
+			current: cu.Mul(2),
+			//nolint:exhaustruct // this is a test
+			pressure: &PressureMetrics{CPUSome10s: 30},
+			want:     lo.ToPtr(3.0),
+		},
+		{
+			name:    "memory-pressure",
+			current: cu.Mul(3),
+			//nolint:exhaustruct // this is a test
+			pressure: &PressureMetrics{MemorySome10s: 50},
+			want:     lo.ToPtr(4.0),
+		},
+		{
+			// I/O stalls alone don't trigger upscaling.
+			name:    "io-pressure-only",
+			current: cu.Mul(2),
+			//nolint:exhaustruct // this is a test
+			pressure: &PressureMetrics{IOSome10s: 90, IOFull10s: 80},
+			want:     nil,
+		},
+		{
+			// current resources that aren't a whole number of CU are rounded up first.
+			name:     "uneven-current",
+			current:  api.Resources{VCPU: 500, Mem: 3 << 30},
+			pressure: &PressureMetrics{CPUSome10s: 30}, //nolint:exhaustruct // this is a test
+			want:     lo.ToPtr(4.0),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sys := &SystemMetrics{
+				LoadAverage1Min:   0,
+				LoadAverage5Min:   0,
+				MemoryUsageBytes:  0,
+				MemoryCachedBytes: 0,
+				CPUSeconds:        nil,
+				Pressure:          c.pressure,
+			}
+
+			got, _ := calculateGoalCU(func(string) {}, cfg, cu, c.current, sys, nil, nil, time.Time{}, nil)
+			if c.want == nil {
+				assert.Nil(t, got.Parts.PSI)
+			} else {
+				assert.NotNil(t, got.Parts.PSI)
+				assert.InDelta(t, *c.want, lo.FromPtr(got.Parts.PSI), 0.000001)
+				assert.Equal(t, uint32(*c.want), got.GoalCU())
+			}
+		})
+	}
+}
+
+func Test_calculatePSIGoalCUSteps(t *testing.T) {
+	cu := api.Resources{VCPU: 250, Mem: 1 << 30 /* 1 Gi */}
+
+	//nolint:exhaustruct // this is a test
+	pressure := PressureMetrics{CPUSome10s: 30}
+
+	stepAt := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	defaultCooldown := time.Second * defaultPSIStepCooldownSeconds
+
+	cases := []struct {
+		name     string
+		current  api.Resources
+		now      time.Time
+		lastStep *PSIStep
+		cooldown *int
+		want     float64
+	}{
+		{
+			name:     "first-step",
+			current:  cu.Mul(2),
+			now:      stepAt,
+			lastStep: nil,
+			cooldown: nil,
+			want:     3,
+		},
+		{
+			name:     "not-yet-scaled",
+			current:  cu.Mul(2),
+			now:      stepAt.Add(time.Minute),
+			lastStep: &PSIStep{At: stepAt, GoalCU: 3},
+			cooldown: nil,
+			want:     3,
+		},
+		{
+			name:     "scaled-within-cooldown",
+			current:  cu.Mul(3),
+			now:      stepAt.Add(defaultCooldown - time.Second),
+			lastStep: &PSIStep{At: stepAt, GoalCU: 3},
+			cooldown: nil,
+			want:     3,
+		},
+		{
+			name:     "scaled-after-cooldown",
+			current:  cu.Mul(3),
+			now:      stepAt.Add(defaultCooldown),
+			lastStep: &PSIStep{At: stepAt, GoalCU: 3},
+			cooldown: nil,
+			want:     4,
+		},
+		{
+			// If the VM was scaled past the last step for other reasons, the next step is from
+			// where it is now.
+			name:     "scaled-past-step",
+			current:  cu.Mul(5),
+			now:      stepAt.Add(defaultCooldown),
+			lastStep: &PSIStep{At: stepAt, GoalCU: 3},
+			cooldown: nil,
+			want:     6,
+		},
+		{
+			name:     "custom-cooldown-within",
+			current:  cu.Mul(3),
+			now:      stepAt.Add(time.Minute),
+			lastStep: &PSIStep{At: stepAt, GoalCU: 3},
+			cooldown: lo.ToPtr(120),
+			want:     3,
+		},
+		{
+			name:     "custom-cooldown-after",
+			current:  cu.Mul(3),
+			now:      stepAt.Add(2 * time.Minute),
+			lastStep: &PSIStep{At: stepAt, GoalCU: 3},
+			cooldown: lo.ToPtr(120),
+			want:     4,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			//nolint:exhaustruct // this is a test
+			cfg := api.ScalingConfig{
+				PSIThresholdPercent:    lo.ToPtr(20.0),
+				PSIStepCooldownSeconds: c.cooldown,
+			}
+			got, _ := calculatePSIGoalCU(func(string) {}, cfg, cu, c.current, pressure, c.now, c.lastStep)
+			assert.NotNil(t, got)
+			assert.InDelta(t, c.want, lo.FromPtr(got), 0.000001)
+		})
+	}
+}
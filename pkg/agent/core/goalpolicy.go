@@ -0,0 +1,79 @@
+package core
+
+// Definition of the GoalPolicy interface, which allows swapping out how the "goal CU" is determined
+// for individual VMs.
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/neondatabase/autoscaling/pkg/api"
+)
+
+// DefaultGoalPolicyName is the name of the built-in GoalPolicy, used when a VM's ScalingConfig
+// doesn't specify one.
+const DefaultGoalPolicyName = "default"
+
+// GoalPolicy determines the goal CU for a VM, based on its metrics and scaling config.
+//
+// Implementations must be pure functions of their input: they are called on every recalculation of
+// the desired resources, and may be called multiple times with the same input.
+type GoalPolicy interface {
+	// CalculateGoal returns the ScalingGoal for the VM, alongside any extra fields that should be
+	// included when logging the calculated desired resources.
+	CalculateGoal(input GoalPolicyInput) (ScalingGoal, []zap.Field)
+}
+
+// GoalPolicyInput is the set of values provided to a GoalPolicy.
+type GoalPolicyInput struct {
+	// Warn logs a warning about conditions that are impeding the policy's decision.
+	Warn func(string)
+	// Config is the VM's ScalingConfig, after applying overrides to the default.
+	Config api.ScalingConfig
+	// ComputeUnit is the autoscaler-agent's configured compute unit.
+	ComputeUnit api.Resources
+	// Current is the VM's current resources.
+	Current api.Resources
+
+	// SystemMetrics is the most recent metrics from the VM, if any.
+	SystemMetrics *SystemMetrics
+	// LoadHistory is the recent load average history, oldest first. It is only non-empty when
+	// Config.EnableCPUForecast is true.
+	LoadHistory []LoadSample
+	// LFCMetrics is the most recent LFC metrics from the VM, if any.
+	LFCMetrics *LFCMetrics
+
+	// Now is the time at which the goal is being calculated.
+	Now time.Time
+	// LastPSIStep is the PSI component of the most recent goal, if any. It is only non-nil while
+	// pressure has been continuously above Config.PSIThresholdPercent.
+	LastPSIStep *PSIStep
+}
+
+// GoalPolicyFunc is an adapter to allow using an ordinary function as a GoalPolicy.
+type GoalPolicyFunc func(input GoalPolicyInput) (ScalingGoal, []zap.Field)
+
+// CalculateGoal implements GoalPolicy.
+func (f GoalPolicyFunc) CalculateGoal(input GoalPolicyInput) (ScalingGoal, []zap.Field) {
+	return f(input)
+}
+
+// DefaultGoalPolicy is the built-in GoalPolicy, taking the maximum of the CPU, memory, LFC, and
+// (if enabled) CPU forecast and PSI components.
+type DefaultGoalPolicy struct{}
+
+// CalculateGoal implements GoalPolicy.
+func (DefaultGoalPolicy) CalculateGoal(input GoalPolicyInput) (ScalingGoal, []zap.Field) {
+	return calculateGoalCU(
+		input.Warn,
+		input.Config,
+		input.ComputeUnit,
+		input.Current,
+		input.SystemMetrics,
+		input.LoadHistory,
+		input.LFCMetrics,
+		input.Now,
+		input.LastPSIStep,
+	)
+}
@@ -22,6 +22,36 @@ type SystemMetrics struct {
 	LoadAverage5Min   float64
 	MemoryUsageBytes  float64
 	MemoryCachedBytes float64
+
+	// CPUSeconds, if not nil, is the cumulative CPU time across all CPUs, broken down by the modes
+	// we're interested in. It is nil if the VM's vector doesn't export host_cpu_seconds_total.
+	CPUSeconds *CPUModeSeconds
+	// Pressure, if not nil, is the pressure stall information (PSI) from /proc/pressure. It is nil
+	// if the VM's vector doesn't export host_pressure_avg10_percent (e.g. older VM images).
+	Pressure *PressureMetrics
+}
+
+// CPUModeSeconds is the cumulative CPU time, in seconds, summed across all CPUs.
+//
+// It isn't used for scaling decisions; the agent exposes it as the autoscaling_vm_cpu_seconds
+// metric.
+type CPUModeSeconds struct {
+	Total  float64
+	IOWait float64
+	Steal  float64
+}
+
+// PressureMetrics are the 10-second averages of pressure stall information, as percentages of
+// wall-clock time (0-100).
+//
+// "some" is the share of time that at least one task was stalled on the resource, and "full" is
+// the share of time that all non-idle tasks were stalled simultaneously.
+type PressureMetrics struct {
+	CPUSome10s    float64
+	MemorySome10s float64
+	MemoryFull10s float64
+	IOSome10s     float64
+	IOFull10s     float64
 }
 
 func (m SystemMetrics) ToAPI() api.Metrics {
@@ -99,12 +129,20 @@ func (m *SystemMetrics) fromPrometheus(mfs map[string]*promtypes.MetricFamily) e
 	memAvailable := getFloat("host_memory_available_bytes")
 	memCached := getFloat("host_memory_cached_bytes")
 
+	// Optional metrics: not all VM images export these, so it's not an error if they're missing.
+	cpuSeconds, err := extractCPUModeSeconds(mfs)
+	ec.Add(err)
+	pressure, err := extractPressure(mfs)
+	ec.Add(err)
+
 	tmp := SystemMetrics{
 		LoadAverage1Min: load1,
 		LoadAverage5Min: load5,
 		// Add an extra 100 MiB to account for kernel memory usage
 		MemoryUsageBytes:  memTotal - memAvailable + 100*(1<<20),
 		MemoryCachedBytes: memCached,
+		CPUSeconds:        cpuSeconds,
+		Pressure:          pressure,
 	}
 
 	if err := ec.Resolve(); err != nil {
@@ -115,6 +153,83 @@ func (m *SystemMetrics) fromPrometheus(mfs map[string]*promtypes.MetricFamily) e
 	return nil
 }
 
+// Helper function to get the value of the label with the given name, or "" if it's not present
+func labelValue(m *promtypes.Metric, name string) string {
+	for _, l := range m.Label {
+		if l.GetName() == name {
+			return l.GetValue()
+		}
+	}
+	return ""
+}
+
+// extractCPUModeSeconds reads host_cpu_seconds_total, summing across all CPUs by mode.
+//
+// Returns (nil, nil) if the metric is not present.
+func extractCPUModeSeconds(mfs map[string]*promtypes.MetricFamily) (*CPUModeSeconds, error) {
+	metricName := "host_cpu_seconds_total"
+	mf := mfs[metricName]
+	if mf == nil {
+		return nil, nil
+	}
+
+	if mf.GetType() != promtypes.MetricType_COUNTER {
+		return nil, fmt.Errorf("wrong metric type for %s: expected %s, but got %s", metricName, promtypes.MetricType_COUNTER, mf.GetType())
+	}
+
+	var result CPUModeSeconds
+	for _, m := range mf.Metric {
+		value := m.GetCounter().GetValue()
+		result.Total += value
+		switch labelValue(m, "mode") {
+		case "iowait":
+			result.IOWait += value
+		case "steal":
+			result.Steal += value
+		}
+	}
+
+	return &result, nil
+}
+
+// extractPressure reads host_pressure_avg10_percent, which is produced from /proc/pressure by the
+// "pressure" source in vm-builder's vector.yaml.
+//
+// Returns (nil, nil) if the metric is not present.
+func extractPressure(mfs map[string]*promtypes.MetricFamily) (*PressureMetrics, error) {
+	metricName := "host_pressure_avg10_percent"
+	mf := mfs[metricName]
+	if mf == nil {
+		return nil, nil
+	}
+
+	if mf.GetType() != promtypes.MetricType_GAUGE {
+		return nil, fmt.Errorf("wrong metric type for %s: expected %s, but got %s", metricName, promtypes.MetricType_GAUGE, mf.GetType())
+	}
+
+	var result PressureMetrics
+	for _, m := range mf.Metric {
+		value := m.GetGauge().GetValue()
+		resource, kind := labelValue(m, "resource"), labelValue(m, "kind")
+		switch {
+		case resource == "cpu" && kind == "some":
+			result.CPUSome10s = value
+		case resource == "memory" && kind == "some":
+			result.MemorySome10s = value
+		case resource == "memory" && kind == "full":
+			result.MemoryFull10s = value
+		case resource == "io" && kind == "some":
+			result.IOSome10s = value
+		case resource == "io" && kind == "full":
+			result.IOFull10s = value
+		}
+		// Ignore anything else: e.g. newer kernels also report "cpu full", which is always zero
+		// at the system level.
+	}
+
+	return &result, nil
+}
+
 // fromPrometheus implements FromPrometheus, so LFCMetrics can be used with ParseMetrics.
 func (m *LFCMetrics) fromPrometheus(mfs map[string]*promtypes.MetricFamily) error {
 	ec := &erc.Collector{}
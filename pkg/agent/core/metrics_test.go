@@ -0,0 +1,118 @@
+package core
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const baseSystemMetrics = `
+# TYPE host_load1 gauge
+host_load1 0.5
+# TYPE host_load5 gauge
+host_load5 0.25
+# TYPE host_memory_total_bytes gauge
+host_memory_total_bytes 4294967296
+# TYPE host_memory_available_bytes gauge
+host_memory_available_bytes 3221225472
+# TYPE host_memory_cached_bytes gauge
+host_memory_cached_bytes 536870912
+`
+
+func TestParseSystemMetrics(t *testing.T) {
+	cases := []struct {
+		name       string
+		extra      string
+		cpuSeconds *CPUModeSeconds
+		pressure   *PressureMetrics
+		err        string
+	}{
+		{
+			name:       "base-only",
+			extra:      "",
+			cpuSeconds: nil,
+			pressure:   nil,
+			err:        "",
+		},
+		{
+			name: "cpu-seconds",
+			extra: `
+# TYPE host_cpu_seconds_total counter
+host_cpu_seconds_total{cpu="0",mode="idle"} 100
+host_cpu_seconds_total{cpu="0",mode="user"} 20
+host_cpu_seconds_total{cpu="0",mode="iowait"} 5
+host_cpu_seconds_total{cpu="0",mode="steal"} 1
+host_cpu_seconds_total{cpu="1",mode="idle"} 110
+host_cpu_seconds_total{cpu="1",mode="iowait"} 3
+host_cpu_seconds_total{cpu="1",mode="steal"} 2
+`,
+			cpuSeconds: &CPUModeSeconds{Total: 241, IOWait: 8, Steal: 3},
+			pressure:   nil,
+			err:        "",
+		},
+		{
+			name: "pressure",
+			extra: `
+# TYPE host_pressure_avg10_percent gauge
+host_pressure_avg10_percent{resource="cpu",kind="some"} 12.5
+host_pressure_avg10_percent{resource="cpu",kind="full"} 0
+host_pressure_avg10_percent{resource="memory",kind="some"} 4
+host_pressure_avg10_percent{resource="memory",kind="full"} 2
+host_pressure_avg10_percent{resource="io",kind="some"} 30
+host_pressure_avg10_percent{resource="io",kind="full"} 25
+`,
+			cpuSeconds: nil,
+			pressure: &PressureMetrics{
+				CPUSome10s:    12.5,
+				MemorySome10s: 4,
+				MemoryFull10s: 2,
+				IOSome10s:     30,
+				IOFull10s:     25,
+			},
+			err: "",
+		},
+		{
+			name: "cpu-seconds-wrong-type",
+			extra: `
+# TYPE host_cpu_seconds_total gauge
+host_cpu_seconds_total{cpu="0",mode="idle"} 100
+`,
+			cpuSeconds: nil,
+			pressure:   nil,
+			err:        "wrong metric type for host_cpu_seconds_total: expected COUNTER, but got GAUGE",
+		},
+		{
+			name: "pressure-wrong-type",
+			extra: `
+# TYPE host_pressure_avg10_percent counter
+host_pressure_avg10_percent{resource="cpu",kind="some"} 12.5
+`,
+			cpuSeconds: nil,
+			pressure:   nil,
+			err:        "wrong metric type for host_pressure_avg10_percent: expected GAUGE, but got COUNTER",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var metrics SystemMetrics
+			err := ParseMetrics(strings.NewReader(baseSystemMetrics+c.extra), &metrics)
+			if c.err != "" {
+				assert.ErrorContains(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+
+			assert.Equal(t, SystemMetrics{
+				LoadAverage1Min:   0.5,
+				LoadAverage5Min:   0.25,
+				MemoryUsageBytes:  1073741824 + 100*(1<<20),
+				MemoryCachedBytes: 536870912,
+				CPUSeconds:        c.cpuSeconds,
+				Pressure:          c.pressure,
+			}, metrics)
+		})
+	}
+}
@@ -41,11 +41,34 @@ type ObservabilityCallbacks struct {
 
 	ActualScaling       ReportActualScalingEventCallback
 	HypotheticalScaling ReportHypotheticalScalingEventCallback
+	DeniedScaling       ReportDeniedScalingEventCallback
 }
 
 type (
-	ReportActualScalingEventCallback       func(timestamp time.Time, current uint32, target uint32)
+	ReportActualScalingEventCallback       func(timestamp time.Time, current uint32, target uint32, limit ScalingLimit)
 	ReportHypotheticalScalingEventCallback func(timestamp time.Time, current uint32, target uint32, parts ScalingGoalParts)
+	ReportDeniedScalingEventCallback       func(timestamp time.Time, current uint32, target uint32, limit ScalingLimit)
+)
+
+// ScalingLimit describes what, if anything, stopped the VM from scaling to the goal CU.
+type ScalingLimit string
+
+const (
+	// ScalingLimitNone means that nothing limited scaling.
+	ScalingLimitNone ScalingLimit = ""
+	// ScalingLimitPluginCap means that upscaling was limited by the resources the scheduler plugin
+	// approved.
+	ScalingLimitPluginCap ScalingLimit = "pluginCap"
+	// ScalingLimitMonitorDenial means that downscaling was limited by the vm-monitor, either
+	// because it denied a request or hasn't yet approved it.
+	ScalingLimitMonitorDenial ScalingLimit = "monitorDenial"
+	// ScalingLimitBounds means that the goal was clamped to the VM's minimum or maximum.
+	ScalingLimitBounds ScalingLimit = "bounds"
+	// ScalingLimitRequestedUpscale means that the vm-monitor requested upscaling beyond the goal.
+	ScalingLimitRequestedUpscale ScalingLimit = "requestedUpscale"
+	// ScalingLimitLocalBudget means that upscaling was limited by the autoscaler-agent's local
+	// budget for the node, while the scheduler plugin is unavailable.
+	ScalingLimitLocalBudget ScalingLimit = "localBudget"
 )
 
 type RevisionSource interface {
@@ -63,6 +86,12 @@ type Config struct {
 	// If the VM's ScalingConfig is nil, we use this field instead.
 	DefaultScalingConfig api.ScalingConfig
 
+	// GoalPolicies provides the custom GoalPolicy implementations that may be selected by name with
+	// the GoalPolicy field of the VM's ScalingConfig.
+	//
+	// The DefaultGoalPolicy is always available as DefaultGoalPolicyName, and may not be overridden.
+	GoalPolicies map[string]GoalPolicy `json:"-"`
+
 	// NeonVMRetryWait gives the amount of time to wait to retry after a failed request
 	NeonVMRetryWait time.Duration
 
@@ -77,6 +106,13 @@ type Config struct {
 	// that were not fully granted.
 	PluginDeniedRetryWait time.Duration
 
+	// PluginDegradedAfter, if not zero, gives the amount of time that requests to the scheduler
+	// plugin must have been continuously failing before we fall back to the local budget for the
+	// node, so that upscaling isn't blocked entirely while the plugin is unavailable.
+	//
+	// If zero, the local budget is never used.
+	PluginDegradedAfter time.Duration
+
 	// MonitorDeniedDownscaleCooldown gives the time we must wait between making duplicate
 	// downscale requests to the vm-monitor where the previous failed.
 	MonitorDeniedDownscaleCooldown time.Duration
@@ -140,6 +176,12 @@ type state struct {
 
 	Metrics *SystemMetrics
 
+	// LoadHistory stores the recent load average samples used for CPU forecasting, oldest first.
+	//
+	// It is only populated when CPU forecasting is enabled, and is trimmed to the configured
+	// forecast window on each update.
+	LoadHistory []LoadSample
+
 	LFCMetrics *LFCMetrics
 
 	// TargetRevision is the revision agent works towards.
@@ -147,6 +189,53 @@ type state struct {
 
 	// LastDesiredResources is the last target agent wanted to scale to.
 	LastDesiredResources *api.Resources
+	// LastDesiredLimit is what limited LastDesiredResources, if anything.
+	LastDesiredLimit ScalingLimit
+
+	// ScheduledMinimum, if not nil, gives the minimum resources required by the VM's scaling
+	// schedule, as of the last time the desired resources were calculated.
+	ScheduledMinimum *scheduledMinimum
+
+	// DownscaleStabilization, if not nil, tracks the period during which the goal CU has been
+	// continuously below the VM's current CU. It is only used when the VM's ScalingConfig sets
+	// DownscaleStabilizationSeconds.
+	DownscaleStabilization *downscaleStabilization
+
+	// PSIStep, if not nil, gives the PSI component of the goal CU as of the last time the desired
+	// resources were calculated, and when it was last raised.
+	PSIStep *PSIStep
+
+	// CUOverride, if not nil, gives the temporary override of the VM's compute units, which is
+	// treated as both the minimum and maximum until it expires.
+	CUOverride *CUOverride
+}
+
+// CUOverride is a temporary override of a VM's size, set by an operator.
+//
+// While the override is in effect, the desired resources are exactly CU compute units, regardless
+// of the VM's metrics. They are still kept within the VM's minimum and limits, and above any
+// downscaling that the vm-monitor recently denied.
+type CUOverride struct {
+	CU uint16 `json:"cu"`
+	// Until gives the time at which the override expires.
+	Until time.Time `json:"until"`
+}
+
+type downscaleStabilization struct {
+	// Since gives the time at which the goal CU first dropped below CurrentCU.
+	Since time.Time
+	// CurrentCU is the VM's current CU (rounded up) at the start of the period. If the VM's CU
+	// changes, the period restarts.
+	CurrentCU uint32
+	// HighestGoalCU is the highest goal CU seen during the period, which is what we downscale to
+	// once the period is long enough.
+	HighestGoalCU uint32
+}
+
+type scheduledMinimum struct {
+	Resources api.Resources
+	// Until gives the time at which the earliest of the currently active windows ends.
+	Until time.Time
 }
 
 type pluginState struct {
@@ -157,9 +246,23 @@ type pluginState struct {
 	LastRequest *pluginRequested
 	// LastFailureAt, if not nil, gives the time of the most recent request failure
 	LastFailureAt *time.Time
+	// FailingSince, if not nil, gives the time of the first request failure since the last
+	// successful request.
+	FailingSince *time.Time
 	// Permit, if not nil, stores the Permit in the most recent PluginResponse. This field will be
 	// nil if we have not been able to contact *any* scheduler.
 	Permit *api.Resources
+	// LocalPermit, if not nil, stores the resources granted by the local budget for the node while
+	// the plugin is unavailable. It takes precedence over Permit, and is cleared once a request to
+	// the plugin succeeds.
+	LocalPermit *api.Resources
+	// DeniedRetryWait, if not nil, overrides Config.PluginDeniedRetryWait for the most recent
+	// request, using the plugin's estimate of when more resources may be approved.
+	DeniedRetryWait *time.Duration
+	// LastDenial, if not nil, is the most recently reported denied scaling. Periodic requests for
+	// the same resources usually get the same permit, so we only report it again once something
+	// changes.
+	LastDenial *pluginDenial
 
 	// CurrentRevision is the most recent revision the plugin has acknowledged.
 	CurrentRevision vmv1.Revision
@@ -170,6 +273,12 @@ type pluginRequested struct {
 	Resources api.Resources
 }
 
+type pluginDenial struct {
+	Target api.Resources
+	Permit api.Resources
+	Limit  ScalingLimit
+}
+
 type monitorState struct {
 	OngoingRequest *ongoingMonitorRequest
 
@@ -233,7 +342,10 @@ type neonvmState struct {
 	// TargetRevision is the revision agent works towards. Contrary to monitor/plugin, we
 	// store it not only in action, but also here. This is needed, because for NeonVM propagation
 	// happens after the changes are actually applied, when the action object is long gone.
-	TargetRevision  vmv1.RevisionWithTime
+	TargetRevision vmv1.RevisionWithTime
+	// TargetLimit is what limited the resources of the most recent request, if anything. Like
+	// TargetRevision, it's stored here so that it's available when the request is started.
+	TargetLimit     ScalingLimit
 	CurrentRevision vmv1.Revision
 }
 
@@ -251,7 +363,11 @@ func NewState(vm api.VmInfo, config Config) *State {
 				OngoingRequest:  false,
 				LastRequest:     nil,
 				LastFailureAt:   nil,
+				FailingSince:    nil,
 				Permit:          nil,
+				LocalPermit:     nil,
+				DeniedRetryWait: nil,
+				LastDenial:      nil,
 				CurrentRevision: vmv1.ZeroRevision,
 			},
 			Monitor: monitorState{
@@ -268,12 +384,20 @@ func NewState(vm api.VmInfo, config Config) *State {
 				OngoingRequested: nil,
 				RequestFailedAt:  nil,
 				TargetRevision:   vmv1.ZeroRevision.WithTime(time.Time{}),
+				TargetLimit:      ScalingLimitNone,
 				CurrentRevision:  vmv1.ZeroRevision,
 			},
 			Metrics:              nil,
+			LoadHistory:          nil,
 			LFCMetrics:           nil,
 			LastDesiredResources: nil,
+			LastDesiredLimit:     ScalingLimitNone,
 			TargetRevision:       vmv1.ZeroRevision,
+			ScheduledMinimum:     nil,
+
+			DownscaleStabilization: nil,
+			PSIStep:                nil,
+			CUOverride:             nil,
 		},
 	}
 }
@@ -406,20 +530,33 @@ func (s *state) calculatePluginAction(
 
 	timeForRequest := timeUntilNextRequestTick <= 0
 
+	// If we're using the local budget, treat its permit like one from the plugin, so that we
+	// request new resources when they change (and back off if they weren't fully granted).
+	permit := s.Plugin.Permit
+	if s.Plugin.LocalPermit != nil {
+		permit = s.Plugin.LocalPermit
+	}
+
 	var timeUntilRetryBackoffExpires time.Duration
 	requestPreviouslyDenied := !s.Plugin.OngoingRequest &&
 		s.Plugin.LastRequest != nil &&
-		s.Plugin.Permit != nil &&
-		s.Plugin.LastRequest.Resources.HasFieldGreaterThan(*s.Plugin.Permit)
+		permit != nil &&
+		s.Plugin.LastRequest.Resources.HasFieldGreaterThan(*permit)
 	if requestPreviouslyDenied {
-		timeUntilRetryBackoffExpires = s.Plugin.LastRequest.At.Add(s.Config.PluginDeniedRetryWait).Sub(now)
+		retryWait := s.Config.PluginDeniedRetryWait
+		// If the plugin told us when to retry, use that instead -- but we still have to make a
+		// request every PluginRequestTick.
+		if s.Plugin.LocalPermit == nil && s.Plugin.DeniedRetryWait != nil {
+			retryWait = min(*s.Plugin.DeniedRetryWait, s.Config.PluginRequestTick)
+		}
+		timeUntilRetryBackoffExpires = s.Plugin.LastRequest.At.Add(retryWait).Sub(now)
 	}
 
 	waitingOnRetryBackoff := timeUntilRetryBackoffExpires > 0
 
 	// changing the resources we're requesting from the plugin
-	wantToRequestNewResources := s.Plugin.LastRequest != nil && s.Plugin.Permit != nil &&
-		requestResources != *s.Plugin.Permit
+	wantToRequestNewResources := s.Plugin.LastRequest != nil && permit != nil &&
+		requestResources != *permit
 	// ... and this isn't a duplicate (or, at least it's been long enough)
 	shouldRequestNewResources := wantToRequestNewResources && !waitingOnRetryBackoff
 
@@ -451,7 +588,9 @@ func (s *state) calculatePluginAction(
 	// The rest of the complication is just around accurate logging.
 	if timeForRequest || shouldRequestNewResources {
 		return &ActionPluginRequest{
-			LastPermit: s.Plugin.Permit,
+			// If we upscaled with the local budget, tell the plugin about it: the plugin treats
+			// LastPermit as already approved, so its permit will include what we're already using.
+			LastPermit: permit,
 			Target:     permittedRequestResources,
 			// convert maybe-nil '*Metrics' to maybe-nil '*core.Metrics'
 			Metrics: func() *api.Metrics {
@@ -462,6 +601,7 @@ func (s *state) calculatePluginAction(
 				}
 			}(),
 			TargetRevision: s.TargetRevision.WithTime(now),
+			UseLocalBudget: s.pluginDegraded(now),
 		}, nil
 	} else {
 		if wantToRequestNewResources && waitingOnRetryBackoff {
@@ -475,6 +615,14 @@ func (s *state) calculatePluginAction(
 	}
 }
 
+// pluginDegraded returns whether requests to the scheduler plugin have been failing for long enough
+// that we should fall back to the local budget for the node.
+func (s *state) pluginDegraded(now time.Time) bool {
+	return s.Config.PluginDegradedAfter != 0 &&
+		s.Plugin.FailingSince != nil &&
+		now.Sub(*s.Plugin.FailingSince) >= s.Config.PluginDegradedAfter
+}
+
 func ptr[T any](t T) *T { return &t }
 
 func (s *state) calculateNeonVMAction(
@@ -499,13 +647,25 @@ func (s *state) calculateNeonVMAction(
 	if desiredResources.HasFieldGreaterThan(s.VM.Using()) {
 		neonvmUpperBound = ptr(s.pluginApprovedUpperBound())
 	}
-	desiredResources = s.clampResources(
+	clampedResources := s.clampResources(
 		s.VM.Using(),                       // current: what we're using already
 		desiredResources,                   // target: desired resources
 		ptr(s.monitorApprovedLowerBound()), // lower bound: downscaling that the monitor has approved
 		neonvmUpperBound,                   // upper bound: upscaling that the plugin has approved
 	)
 
+	limit := s.LastDesiredLimit
+	if clampedResources.HasFieldLessThan(desiredResources) {
+		if s.Plugin.LocalPermit != nil {
+			limit = ScalingLimitLocalBudget
+		} else {
+			limit = ScalingLimitPluginCap
+		}
+	} else if clampedResources.HasFieldGreaterThan(desiredResources) {
+		limit = ScalingLimitMonitorDenial
+	}
+	desiredResources = clampedResources
+
 	// If we're already using the desired resources, then no need to make a request
 	if s.VM.Using() == desiredResources {
 		return nil, nil
@@ -525,6 +685,7 @@ func (s *state) calculateNeonVMAction(
 		}
 
 		s.NeonVM.TargetRevision = targetRevision.WithTime(now)
+		s.NeonVM.TargetLimit = limit
 		return &ActionNeonVMRequest{
 			Current:        s.VM.Using(),
 			Target:         desiredResources,
@@ -709,6 +870,22 @@ func (s *state) scalingConfig() api.ScalingConfig {
 	return s.Config.DefaultScalingConfig.WithOverrides(s.VM.Config.ScalingConfig)
 }
 
+// goalPolicy returns the GoalPolicy selected by the scaling config, falling back to the default if
+// it's not known.
+func (s *state) goalPolicy(cfg api.ScalingConfig) GoalPolicy {
+	name := lo.FromPtr(cfg.GoalPolicy)
+	if name == "" || name == DefaultGoalPolicyName {
+		return DefaultGoalPolicy{}
+	}
+
+	if policy, ok := s.Config.GoalPolicies[name]; ok {
+		return policy
+	}
+
+	s.warnf("Unknown goal policy %q, falling back to %q", name, DefaultGoalPolicyName)
+	return DefaultGoalPolicy{}
+}
+
 // public version, for testing.
 func (s *State) DesiredResourcesFromMetricsOrRequestedUpscaling(now time.Time) (api.Resources, func(ActionSet) *time.Duration) {
 	return s.internal.desiredResourcesFromMetricsOrRequestedUpscaling(now)
@@ -750,20 +927,45 @@ func (s *state) desiredResourcesFromMetricsOrRequestedUpscaling(now time.Time) (
 		}
 	}
 
-	sg, goalCULogFields := calculateGoalCU(
-		s.warn,
-		s.scalingConfig(),
-		s.Config.ComputeUnit,
-		s.Metrics,
-		s.LFCMetrics,
-	)
+	scalingConfig := s.scalingConfig()
+	sg, goalCULogFields := s.goalPolicy(scalingConfig).CalculateGoal(GoalPolicyInput{
+		Warn:          s.warn,
+		Config:        scalingConfig,
+		ComputeUnit:   s.Config.ComputeUnit,
+		Current:       s.VM.Using(),
+		SystemMetrics: s.Metrics,
+		LoadHistory:   s.LoadHistory,
+		LFCMetrics:    s.LFCMetrics,
+		Now:           now,
+		LastPSIStep:   s.PSIStep,
+	})
+	s.updatePSIStep(now, sg.Parts.PSI)
+
+	// Raise the goal to any minimum required by the VM's scaling schedule. This is done as part of
+	// the goal, rather than afterwards, so that it's included in the reported goal components.
+	scheduledMinimumInEffect := s.updateScheduledMinimum(now)
+	var timeUntilScheduledMinimumExpired time.Duration
+	if scheduledMinimumInEffect {
+		sg.Parts.Scheduled = lo.ToPtr(s.requiredCUForScheduledMinimum(s.Config.ComputeUnit, s.ScheduledMinimum.Resources))
+		timeUntilScheduledMinimumExpired = s.ScheduledMinimum.Until.Sub(now)
+	}
+
 	goalCU := sg.GoalCU()
 	// If we don't have all the metrics we need, we'll later prevent downscaling to avoid flushing
 	// the VM's cache on autoscaler-agent restart if we have SystemMetrics but not LFCMetrics.
 	hasAllMetrics := sg.HasAllMetrics
 
+	// Any CU override replaces the goal entirely. We still calculate the rest of the goal so that
+	// the components can be reported alongside the override.
+	timeUntilCUOverrideExpired := s.timeUntilCUOverrideExpired(now)
+	cuOverrideInEffect := timeUntilCUOverrideExpired > 0
 	if hasAllMetrics {
-		reportGoals(goalCU, sg.Parts)
+		reportedGoalCU := goalCU
+		if cuOverrideInEffect {
+			sg.Parts.Override = lo.ToPtr(float64(s.CUOverride.CU))
+			reportedGoalCU = uint32(s.CUOverride.CU)
+		}
+		reportGoals(reportedGoalCU, sg.Parts)
 	}
 
 	// Copy the initial value of the goal CU so that we can accurately track whether either
@@ -800,9 +1002,17 @@ func (s *state) desiredResourcesFromMetricsOrRequestedUpscaling(now time.Time) (
 		}
 	}
 
+	// Hold back or limit downscaling, if the VM's ScalingConfig requires it.
+	goalCU, downscaleHeld, timeUntilDownscaleStabilized := s.limitDownscale(now, scalingConfig, goalCU)
+
 	// resources for the desired "goal" compute units
 	goalResources := s.Config.ComputeUnit.Mul(uint16(goalCU))
 
+	// If the downscale stabilization window hasn't yet passed, don't scale down at all.
+	if downscaleHeld {
+		goalResources = goalResources.Max(s.VM.Using())
+	}
+
 	// If we don't have all the metrics we need to make a proper decision, make sure that we aren't
 	// going to scale down below the current resources.
 	// Otherwise, we can make an under-informed decision that has undesirable impacts (e.g., scaling
@@ -815,6 +1025,14 @@ func (s *state) desiredResourcesFromMetricsOrRequestedUpscaling(now time.Time) (
 	upperBound := s.VM.Limiting()
 	result := goalResources.Min(upperBound).Max(s.VM.Min())
 
+	limit := ScalingLimitNone
+	if requestedUpscalingAffectedResult {
+		limit = ScalingLimitRequestedUpscale
+	}
+	if result != goalResources {
+		limit = ScalingLimitBounds
+	}
+
 	// ... but if we aren't allowed to downscale, then we *must* make sure that the VM's usage value
 	// won't decrease to the previously denied amount, even if it's greater than the maximum.
 	//
@@ -839,6 +1057,9 @@ func (s *state) desiredResourcesFromMetricsOrRequestedUpscaling(now time.Time) (
 	if result.HasFieldGreaterThan(upperBound) {
 		result = result.Min(upperBound)
 	}
+	if deniedDownscaleAffectedResult {
+		limit = ScalingLimitMonitorDenial
+	}
 
 	// Check that the result is sound.
 	//
@@ -854,6 +1075,32 @@ func (s *state) desiredResourcesFromMetricsOrRequestedUpscaling(now time.Time) (
 		))
 	}
 
+	// If there's a CU override, it acts as both the minimum and maximum. We can't go beyond the
+	// VM's bounds though, and we must still respect any recently denied downscaling, otherwise
+	// we'd immediately make the same request to the vm-monitor again.
+	if cuOverrideInEffect {
+		override := s.Config.ComputeUnit.Mul(s.CUOverride.CU)
+		result = override.Max(s.VM.Min())
+		limit = ScalingLimitNone
+		if result.HasFieldGreaterThan(upperBound) {
+			s.warnf("Can't increase desired resources to CU override of %d because of VM limits", s.CUOverride.CU)
+			result = result.Min(upperBound)
+		}
+		if result != override {
+			limit = ScalingLimitBounds
+		}
+
+		deniedDownscaleAffectedResult = false
+		if deniedDownscaleInEffect {
+			preMaxResult := result
+			result = result.Max(s.minRequiredResourcesForDeniedDownscale(s.Config.ComputeUnit, *s.Monitor.DeniedDownscale))
+			if result != preMaxResult {
+				deniedDownscaleAffectedResult = true
+				limit = ScalingLimitMonitorDenial
+			}
+		}
+	}
+
 	calculateWaitTime := func(actions ActionSet) *time.Duration {
 		var waiting bool
 		waitTime := time.Duration(int64(1<<63 - 1)) // time.Duration is an int64. As an "unset" value, use the maximum.
@@ -866,6 +1113,18 @@ func (s *state) desiredResourcesFromMetricsOrRequestedUpscaling(now time.Time) (
 			waitTime = min(waitTime, timeUntilRequestedUpscalingExpired)
 			waiting = true
 		}
+		if scheduledMinimumInEffect {
+			waitTime = min(waitTime, timeUntilScheduledMinimumExpired)
+			waiting = true
+		}
+		if downscaleHeld {
+			waitTime = min(waitTime, timeUntilDownscaleStabilized)
+			waiting = true
+		}
+		if cuOverrideInEffect {
+			waitTime = min(waitTime, timeUntilCUOverrideExpired)
+			waiting = true
+		}
 
 		if waiting {
 			return &waitTime
@@ -878,6 +1137,7 @@ func (s *state) desiredResourcesFromMetricsOrRequestedUpscaling(now time.Time) (
 	// TODO: we are both saving the result into LastDesiredResources and returning it. This is
 	// redundant, and we should remove one of the two.
 	s.LastDesiredResources = &result
+	s.LastDesiredLimit = limit
 
 	logFields := []zap.Field{
 		zap.Object("current", s.VM.Using()),
@@ -928,6 +1188,21 @@ func (s *state) updateNeonVMCurrentRevision(currentRevision vmv1.RevisionWithTim
 	s.LastDesiredResources = nil
 }
 
+// reportDeniedScaling calls the DeniedScaling callback, if there is one, for a request to scale to
+// the target resources that was denied.
+func (s *state) reportDeniedScaling(now time.Time, target api.Resources, limit ScalingLimit) {
+	report := s.Config.ObservabilityCallbacks.DeniedScaling
+	if report == nil {
+		return
+	}
+
+	currentCU, currentOk := s.VM.Using().DivResources(s.Config.ComputeUnit)
+	targetCU, targetOk := target.DivResources(s.Config.ComputeUnit)
+	if currentOk && targetOk {
+		report(now, uint32(currentCU), uint32(targetCU), limit)
+	}
+}
+
 func (s *state) timeUntilRequestedUpscalingExpired(now time.Time) time.Duration {
 	if s.Monitor.RequestedUpscale != nil {
 		return s.Monitor.RequestedUpscale.At.Add(s.Config.MonitorRequestedUpscaleValidPeriod).Sub(now)
@@ -956,6 +1231,108 @@ func (s *state) requiredCUForRequestedUpscaling(computeUnit api.Resources, reque
 	return required
 }
 
+// updateScheduledMinimum sets s.ScheduledMinimum from the VM's scaling schedule, returning whether
+// there is any scheduled minimum in effect.
+func (s *state) updateScheduledMinimum(now time.Time) bool {
+	s.ScheduledMinimum = nil
+	if s.VM.Config.ScalingSchedule == nil {
+		return false
+	}
+
+	minimum, until := s.VM.Config.ScalingSchedule.ActiveMinimum(now)
+	if minimum == nil {
+		return false
+	}
+
+	s.ScheduledMinimum = &scheduledMinimum{Resources: *minimum, Until: until}
+	return true
+}
+
+// requiredCUForScheduledMinimum returns the (fractional) number of compute units required to
+// provide the scheduled minimum resources. The goal CU is later rounded up.
+func (s *state) requiredCUForScheduledMinimum(computeUnit, minimum api.Resources) float64 {
+	return max(
+		minimum.VCPU.AsFloat64()/computeUnit.VCPU.AsFloat64(),
+		minimum.Mem.AsFloat64()/computeUnit.Mem.AsFloat64(),
+	)
+}
+
+// timeUntilCUOverrideExpired returns the remaining duration of the CU override, clearing it if it
+// has already expired.
+func (s *state) timeUntilCUOverrideExpired(now time.Time) time.Duration {
+	if s.CUOverride == nil {
+		return 0
+	}
+
+	remaining := s.CUOverride.Until.Sub(now)
+	if remaining <= 0 {
+		s.CUOverride = nil
+		return 0
+	}
+	return remaining
+}
+
+// limitDownscale applies the downscale stabilization window and maximum downscale step from the
+// ScalingConfig to goalCU, returning the new goal CU.
+//
+// If the stabilization window is still preventing downscaling, limitDownscale also returns true,
+// along with the time until the window will have passed.
+func (s *state) limitDownscale(now time.Time, cfg api.ScalingConfig, goalCU uint32) (uint32, bool, time.Duration) {
+	currentCU := s.currentCURoundedUp()
+	if goalCU >= currentCU {
+		s.DownscaleStabilization = nil
+		return goalCU, false, 0
+	}
+
+	if window := lo.FromPtr(cfg.DownscaleStabilizationSeconds); window > 0 {
+		if s.DownscaleStabilization == nil || s.DownscaleStabilization.CurrentCU != currentCU {
+			s.DownscaleStabilization = &downscaleStabilization{
+				Since:         now,
+				CurrentCU:     currentCU,
+				HighestGoalCU: goalCU,
+			}
+		} else {
+			s.DownscaleStabilization.HighestGoalCU = max(s.DownscaleStabilization.HighestGoalCU, goalCU)
+		}
+
+		stableAt := s.DownscaleStabilization.Since.Add(time.Second * time.Duration(window))
+		if now.Before(stableAt) {
+			return goalCU, true, stableAt.Sub(now)
+		}
+		goalCU = s.DownscaleStabilization.HighestGoalCU
+	} else {
+		s.DownscaleStabilization = nil
+	}
+
+	if step := lo.FromPtr(cfg.DownscaleMaxStepCU); step > 0 && currentCU-goalCU > uint32(step) {
+		goalCU = currentCU - uint32(step)
+	}
+
+	return goalCU, false, 0
+}
+
+// updatePSIStep records the PSI component of the latest goal, restarting the cooldown if it changed.
+func (s *state) updatePSIStep(now time.Time, psiGoalCU *float64) {
+	if psiGoalCU == nil {
+		s.PSIStep = nil
+	} else if s.PSIStep == nil || s.PSIStep.GoalCU != *psiGoalCU {
+		s.PSIStep = &PSIStep{At: now, GoalCU: *psiGoalCU}
+	}
+}
+
+// currentCURoundedUp returns the smallest number of compute units that is at least the VM's
+// current resources.
+func (s *state) currentCURoundedUp() uint32 {
+	using := s.VM.Using()
+	cu := s.Config.ComputeUnit
+
+	// note: (x + M - 1) / M gives ceil(x / M) for integers.
+	return max(
+		uint32((using.VCPU+cu.VCPU-1)/cu.VCPU),
+		uint32((using.Mem+cu.Mem-1)/cu.Mem),
+	)
+}
+
 func (s *state) timeUntilDeniedDownscaleExpired(now time.Time) time.Duration {
 	if s.Monitor.DeniedDownscale != nil {
 		return s.Monitor.DeniedDownscale.At.Add(s.Config.MonitorDeniedDownscaleCooldown).Sub(now)
@@ -1035,7 +1412,9 @@ func (s *state) monitorApprovedLowerBound() api.Resources {
 }
 
 func (s *state) pluginApprovedUpperBound() api.Resources {
-	if s.Plugin.Permit != nil {
+	if s.Plugin.LocalPermit != nil {
+		return *s.Plugin.LocalPermit
+	} else if s.Plugin.Permit != nil {
 		return *s.Plugin.Permit
 	} else {
 		using := s.VM.Using()
@@ -1076,10 +1455,43 @@ func (s *State) UpdatedVM(vm api.VmInfo) {
 	if !*s.internal.scalingConfig().EnableLFCMetrics {
 		s.internal.LFCMetrics = nil
 	}
+	// ... and likewise for CPU forecasting.
+	if !lo.FromPtr(s.internal.scalingConfig().EnableCPUForecast) {
+		s.internal.LoadHistory = nil
+	}
 }
 
-func (s *State) UpdateSystemMetrics(metrics SystemMetrics) {
+// UpdateCUOverride sets or (if override is nil) clears the temporary override of the VM's compute
+// units.
+func (s *State) UpdateCUOverride(override *CUOverride) {
+	s.internal.CUOverride = shallowCopy[CUOverride](override)
+}
+
+func (s *State) UpdateSystemMetrics(now time.Time, metrics SystemMetrics) {
 	s.internal.Metrics = &metrics
+	s.internal.recordLoadSample(now, metrics)
+}
+
+// recordLoadSample adds the metrics to the load history if CPU forecasting is enabled, dropping any
+// samples that have fallen outside of the forecast window.
+func (s *state) recordLoadSample(now time.Time, metrics SystemMetrics) {
+	cfg := s.scalingConfig()
+	if !lo.FromPtr(cfg.EnableCPUForecast) || cfg.CPUForecastWindowSeconds == nil {
+		s.LoadHistory = nil
+		return
+	}
+
+	s.LoadHistory = append(s.LoadHistory, LoadSample{
+		At:              now,
+		LoadAverage1Min: metrics.LoadAverage1Min,
+	})
+
+	cutoff := now.Add(-time.Second * time.Duration(*cfg.CPUForecastWindowSeconds))
+	firstKept := 0
+	for firstKept < len(s.LoadHistory) && s.LoadHistory[firstKept].At.Before(cutoff) {
+		firstKept++
+	}
+	s.LoadHistory = s.LoadHistory[firstKept:]
 }
 
 func (s *State) UpdateLFCMetrics(metrics LFCMetrics) {
@@ -1106,6 +1518,43 @@ func (h PluginHandle) StartingRequest(now time.Time, resources api.Resources) {
 func (h PluginHandle) RequestFailed(now time.Time) {
 	h.s.Plugin.OngoingRequest = false
 	h.s.Plugin.LastFailureAt = &now
+	if h.s.Plugin.FailingSince == nil {
+		h.s.Plugin.FailingSince = &now
+	}
+}
+
+// LocalPermitGranted records the resources granted by the local budget for the node, after a failed
+// request to the scheduler plugin where ActionPluginRequest.UseLocalBudget was true.
+func (h PluginHandle) LocalPermitGranted(now time.Time, permit api.Resources) error {
+	if err := permit.ValidateNonZero(); err != nil {
+		return fmt.Errorf("invalid local permit: %w", err)
+	}
+	if vmUsing := h.s.VM.Using(); permit.HasFieldLessThan(vmUsing) {
+		return fmt.Errorf("local permit has resources less than VM (%+v vs %+v)", permit, vmUsing)
+	}
+
+	h.s.Plugin.LocalPermit = &permit
+	if h.s.Plugin.LastRequest != nil {
+		h.reportPermit(now, permit, ScalingLimitLocalBudget)
+	}
+	return nil
+}
+
+// reportPermit reports denied scaling if the permit for the most recent request was less than what
+// was requested, unless it's the same as the last denial that was reported.
+func (h PluginHandle) reportPermit(now time.Time, permit api.Resources, limit ScalingLimit) {
+	target := h.s.Plugin.LastRequest.Resources
+	if !permit.HasFieldLessThan(target) {
+		h.s.Plugin.LastDenial = nil
+		return
+	}
+
+	denial := pluginDenial{Target: target, Permit: permit, Limit: limit}
+	if h.s.Plugin.LastDenial != nil && *h.s.Plugin.LastDenial == denial {
+		return
+	}
+	h.s.Plugin.LastDenial = &denial
+	h.s.reportDeniedScaling(now, target, limit)
 }
 
 func (h PluginHandle) RequestSuccessful(
@@ -1144,6 +1593,18 @@ func (h PluginHandle) RequestSuccessful(
 	// the process of moving the source of truth for ComputeUnit from the scheduler plugin to the
 	// autoscaler-agent.
 	h.s.Plugin.Permit = &resp.Permit
+	// The plugin is available again, so it's now responsible for any resources we were granted by
+	// the local budget.
+	h.s.Plugin.FailingSince = nil
+	h.s.Plugin.LocalPermit = nil
+	h.s.Plugin.DeniedRetryWait = nil
+	h.reportPermit(now, resp.Permit, ScalingLimitPluginCap)
+	if resp.Permit.HasFieldLessThan(h.s.Plugin.LastRequest.Resources) {
+		if resp.Partial != nil && resp.Partial.RetryAfterSeconds != 0 {
+			wait := time.Second * time.Duration(resp.Partial.RetryAfterSeconds)
+			h.s.Plugin.DeniedRetryWait = &wait
+		}
+	}
 	revsource.Propagate(now,
 		targetRevision,
 		&h.s.Plugin.CurrentRevision,
@@ -1152,6 +1613,36 @@ func (h PluginHandle) RequestSuccessful(
 	return nil
 }
 
+// PermitPushed records an updated permit for the most recent request, pushed by the scheduler
+// plugin without us making a new request.
+//
+// Pushed permits are ignored while a request is ongoing, because the response to that request will
+// supersede them.
+func (h PluginHandle) PermitPushed(now time.Time, permit api.Resources) error {
+	if h.s.Plugin.OngoingRequest {
+		return nil
+	}
+
+	if h.s.Plugin.LastRequest == nil || h.s.Plugin.Permit == nil || h.s.Plugin.FailingSince != nil {
+		return errors.New("pushed permit without a prior successful request")
+	}
+	if err := permit.ValidateNonZero(); err != nil {
+		return fmt.Errorf("invalid pushed permit: %w", err)
+	}
+	if permit.HasFieldGreaterThan(h.s.Plugin.LastRequest.Resources) {
+		return fmt.Errorf(
+			"pushed permit has resources greater than request (%+v vs. %+v)",
+			permit, h.s.Plugin.LastRequest.Resources,
+		)
+	}
+	if vmUsing := h.s.VM.Using(); permit.HasFieldLessThan(vmUsing) {
+		return fmt.Errorf("pushed permit has resources less than VM (%+v vs %+v)", permit, vmUsing)
+	}
+
+	h.s.Plugin.Permit = &permit
+	return nil
+}
+
 // MonitorHandle provides write access to the vm-monitor pieces of an UpdateState
 type MonitorHandle struct {
 	s *state
@@ -1233,6 +1724,7 @@ func (h MonitorHandle) DownscaleRequestDenied(now time.Time, targetRevision vmv1
 		Current:   *h.s.Monitor.Approved,
 		Requested: h.s.Monitor.OngoingRequest.Requested,
 	}
+	h.s.reportDeniedScaling(now, h.s.Monitor.OngoingRequest.Requested, ScalingLimitMonitorDenial)
 	h.s.Monitor.OngoingRequest = nil
 	revsource.Propagate(now,
 		targetRevision,
@@ -1260,7 +1752,7 @@ func (h NeonVMHandle) StartingRequest(now time.Time, resources api.Resources) {
 		targetCU, targetOk := resources.DivResources(h.s.Config.ComputeUnit)
 
 		if currentOk && targetOk {
-			report(now, uint32(currentCU), uint32(targetCU))
+			report(now, uint32(currentCU), uint32(targetCU), h.s.NeonVM.TargetLimit)
 		}
 	}
 
@@ -1,6 +1,7 @@
 package core_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"slices"
 	"testing"
@@ -11,6 +12,8 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
+	"k8s.io/apimachinery/pkg/api/resource"
+
 	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
 	"github.com/neondatabase/autoscaling/pkg/agent/core"
 	"github.com/neondatabase/autoscaling/pkg/agent/core/revsource"
@@ -44,6 +47,8 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 				LoadAverage5Min:   0.0,
 				MemoryUsageBytes:  0.0,
 				MemoryCachedBytes: 0.0,
+				CPUSeconds:        nil,
+				Pressure:          nil,
 			},
 			lfcMetrics:        nil,
 			enableLFCMetrics:  false,
@@ -62,6 +67,8 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 				LoadAverage5Min:   0.0,
 				MemoryUsageBytes:  0.0,
 				MemoryCachedBytes: 0.0,
+				CPUSeconds:        nil,
+				Pressure:          nil,
 			},
 			lfcMetrics:        nil,
 			enableLFCMetrics:  false,
@@ -82,6 +89,8 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 				LoadAverage5Min:   0.0,
 				MemoryUsageBytes:  0.0,
 				MemoryCachedBytes: 0.0,
+				CPUSeconds:        nil,
+				Pressure:          nil,
 			},
 			lfcMetrics:        nil,
 			enableLFCMetrics:  false,
@@ -102,6 +111,8 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 				LoadAverage5Min:   0.0,
 				MemoryUsageBytes:  0.0,
 				MemoryCachedBytes: 0.0,
+				CPUSeconds:        nil,
+				Pressure:          nil,
 			},
 			lfcMetrics: &core.LFCMetrics{
 				CacheHitsTotal:   0.0, // unused
@@ -138,6 +149,8 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 				LoadAverage5Min:   0.0,
 				MemoryUsageBytes:  0.0,
 				MemoryCachedBytes: 0.0,
+				CPUSeconds:        nil,
+				Pressure:          nil,
 			},
 			lfcMetrics:        nil,
 			enableLFCMetrics:  true,
@@ -160,6 +173,8 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 				LoadAverage5Min:   0.0,
 				MemoryUsageBytes:  0.0,
 				MemoryCachedBytes: 0.0,
+				CPUSeconds:        nil,
+				Pressure:          nil,
 			},
 			lfcMetrics:        nil,
 			enableLFCMetrics:  true,
@@ -230,6 +245,7 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 					AlwaysMigrate:        false,
 					ScalingEnabled:       true,
 					ScalingConfig:        nil,
+					ScalingSchedule:      nil,
 				},
 				CurrentRevision: nil,
 			}
@@ -248,12 +264,23 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 					LFCMinWaitBeforeDownscaleMinutes: lo.ToPtr(5),
 					CPUStableZoneRatio:               lo.ToPtr(0.0),
 					CPUMixedZoneRatio:                lo.ToPtr(0.0),
+					EnableCPUForecast:                nil,
+					CPUForecastWindowSeconds:         nil,
+					CPUForecastHorizonSeconds:        nil,
+					EnablePSIScaling:                 nil,
+					PSIThresholdPercent:              nil,
+					PSIStepCooldownSeconds:           nil,
+					DownscaleStabilizationSeconds:    nil,
+					DownscaleMaxStepCU:               nil,
+					GoalPolicy:                       nil,
 				},
+				GoalPolicies: nil,
 				// these don't really matter, because we're not using (*State).NextActions()
 				NeonVMRetryWait:                    time.Second,
 				PluginRequestTick:                  time.Second,
 				PluginRetryWait:                    time.Second,
 				PluginDeniedRetryWait:              time.Second,
+				PluginDegradedAfter:                0,
 				MonitorDeniedDownscaleCooldown:     time.Second,
 				MonitorRequestedUpscaleValidPeriod: time.Second,
 				MonitorRetryWait:                   time.Second,
@@ -270,6 +297,7 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 					NeonVMLatency:       nil,
 					ActualScaling:       nil,
 					HypotheticalScaling: nil,
+					DeniedScaling:       nil,
 				},
 			}
 		}
@@ -277,21 +305,22 @@ func Test_DesiredResourcesFromMetricsOrRequestedUpscaling(t *testing.T) {
 		t.Run(c.name, func(t *testing.T) {
 			state := core.NewState(makeVM(), makeStateConfig(c.enableLFCMetrics))
 
+			now := time.Now()
+
 			// set the metrics
 			if c.systemMetrics != nil {
-				state.UpdateSystemMetrics(*c.systemMetrics)
+				state.UpdateSystemMetrics(now, *c.systemMetrics)
 			}
 			if c.lfcMetrics != nil {
 				state.UpdateLFCMetrics(*c.lfcMetrics)
 			}
 
-			now := time.Now()
-
 			// set lastApproved by simulating a scheduler request/response
 			state.Plugin().StartingRequest(now, c.schedulerApproved)
 			err := state.Plugin().RequestSuccessful(now, vmv1.ZeroRevision.WithTime(now), api.PluginResponse{
 				Permit:  c.schedulerApproved,
 				Migrate: nil,
+				Partial: nil,
 			})
 			if err != nil {
 				t.Errorf("state.Plugin().RequestSuccessful() failed: %s", err)
@@ -341,11 +370,22 @@ var DefaultInitialStateConfig = helpers.InitialStateConfig{
 			LFCMinWaitBeforeDownscaleMinutes: lo.ToPtr(15),
 			CPUStableZoneRatio:               lo.ToPtr(0.0),
 			CPUMixedZoneRatio:                lo.ToPtr(0.0),
+			EnableCPUForecast:                nil,
+			CPUForecastWindowSeconds:         nil,
+			CPUForecastHorizonSeconds:        nil,
+			EnablePSIScaling:                 nil,
+			PSIThresholdPercent:              nil,
+			PSIStepCooldownSeconds:           nil,
+			DownscaleStabilizationSeconds:    nil,
+			DownscaleMaxStepCU:               nil,
+			GoalPolicy:                       nil,
 		},
+		GoalPolicies:                       nil,
 		NeonVMRetryWait:                    5 * time.Second,
 		PluginRequestTick:                  5 * time.Second,
 		PluginRetryWait:                    3 * time.Second,
 		PluginDeniedRetryWait:              2 * time.Second,
+		PluginDegradedAfter:                0,
 		MonitorDeniedDownscaleCooldown:     5 * time.Second,
 		MonitorRequestedUpscaleValidPeriod: 10 * time.Second,
 		MonitorRetryWait:                   3 * time.Second,
@@ -360,6 +400,7 @@ var DefaultInitialStateConfig = helpers.InitialStateConfig{
 			NeonVMLatency:       nil,
 			ActualScaling:       nil,
 			HypotheticalScaling: nil,
+			DeniedScaling:       nil,
 		},
 	},
 }
@@ -389,6 +430,7 @@ func doInitialPluginRequest(
 			Target:         resources,
 			Metrics:        metrics,
 			TargetRevision: rev,
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resources)
@@ -396,6 +438,7 @@ func doInitialPluginRequest(
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), rev, api.PluginResponse{
 		Permit:  resources,
 		Migrate: nil,
+		Partial: nil,
 	})
 }
 
@@ -471,8 +514,10 @@ func TestBasicScaleUpAndDownFlow(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
-	a.Do(state.UpdateSystemMetrics, lastMetrics)
+	a.Do(state.UpdateSystemMetrics, clock.Now(), lastMetrics)
 	// double-check that we agree about the desired resources
 	a.Call(getDesiredResources, state, clock.Now()).
 		Equals(resForCU(2))
@@ -489,6 +534,7 @@ func TestBasicScaleUpAndDownFlow(t *testing.T) {
 			Target:         resForCU(2),
 			Metrics:        lo.ToPtr(lastMetrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	// start the request:
@@ -499,6 +545,7 @@ func TestBasicScaleUpAndDownFlow(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(2),
 		Migrate: nil,
+		Partial: nil,
 	})
 
 	// Scheduler approval is done, now we should be making the request to NeonVM
@@ -571,8 +618,10 @@ func TestBasicScaleUpAndDownFlow(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
-	a.Do(state.UpdateSystemMetrics, lastMetrics)
+	a.Do(state.UpdateSystemMetrics, clock.Now(), lastMetrics)
 	// double-check that we agree about the new desired resources
 	a.Call(getDesiredResources, state, clock.Now()).
 		Equals(resForCU(1))
@@ -631,6 +680,7 @@ func TestBasicScaleUpAndDownFlow(t *testing.T) {
 			Target:         resForCU(1),
 			Metrics:        lo.ToPtr(lastMetrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 		// shouldn't have anything to say to the other components
 	})
@@ -641,6 +691,7 @@ func TestBasicScaleUpAndDownFlow(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(1),
 		Migrate: nil,
+		Partial: nil,
 	})
 
 	// Finally, check there's no leftover actions:
@@ -675,10 +726,12 @@ func TestPeriodicPluginRequest(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
 	resources := DefaultComputeUnit
 
-	a.Do(state.UpdateSystemMetrics, metrics)
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
 
 	base := duration("0s")
 	clock.Elapsed().AssertEquals(base)
@@ -707,6 +760,7 @@ func TestPeriodicPluginRequest(t *testing.T) {
 					Target:         resources,
 					Metrics:        lo.ToPtr(metrics.ToAPI()),
 					TargetRevision: target,
+					UseLocalBudget: false,
 				},
 			})
 			a.Do(state.Plugin().StartingRequest, clock.Now(), resources)
@@ -716,6 +770,7 @@ func TestPeriodicPluginRequest(t *testing.T) {
 			a.NoError(state.Plugin().RequestSuccessful, clock.Now(), target, api.PluginResponse{
 				Permit:  resources,
 				Migrate: nil,
+				Partial: nil,
 			})
 			clock.Inc(clockTick - reqDuration)
 		}
@@ -740,6 +795,12 @@ func TestPartialUpscaleThenFull(t *testing.T) {
 
 	resForCU := DefaultComputeUnit.Mul
 
+	type scalingEvent struct {
+		current, target uint32
+		limit           core.ScalingLimit
+	}
+	var actualEvents, deniedEvents []scalingEvent
+
 	state := helpers.CreateInitialState(
 		DefaultInitialStateConfig,
 		helpers.WithStoredWarnings(a.StoredWarnings()),
@@ -748,6 +809,12 @@ func TestPartialUpscaleThenFull(t *testing.T) {
 		helpers.WithConfigSetting(func(c *core.Config) {
 			c.RevisionSource = revsource.NewRevisionSource(0, scalingLatencyObserver.observe)
 			c.ObservabilityCallbacks.PluginLatency = pluginLatencyObserver.observe
+			c.ObservabilityCallbacks.ActualScaling = func(_ time.Time, current, target uint32, limit core.ScalingLimit) {
+				actualEvents = append(actualEvents, scalingEvent{current: current, target: target, limit: limit})
+			}
+			c.ObservabilityCallbacks.DeniedScaling = func(_ time.Time, current, target uint32, limit core.ScalingLimit) {
+				deniedEvents = append(deniedEvents, scalingEvent{current: current, target: target, limit: limit})
+			}
 		}),
 	)
 
@@ -766,8 +833,10 @@ func TestPartialUpscaleThenFull(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  12345678,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
-	a.Do(state.UpdateSystemMetrics, metrics)
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
 
 	// double-check that we agree about the desired resources
 	a.Call(getDesiredResources, state, clock.Now()).
@@ -783,6 +852,7 @@ func TestPartialUpscaleThenFull(t *testing.T) {
 			Target:         resForCU(4),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: targetRevision,
+			UseLocalBudget: false,
 		},
 	})
 
@@ -792,9 +862,11 @@ func TestPartialUpscaleThenFull(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), targetRevision, api.PluginResponse{
 		Permit:  resForCU(3),
 		Migrate: nil,
+		Partial: nil,
 	})
 
 	pluginLatencyObserver.assert(duration("0.1s"), revsource.Upscale)
+	assert.Equal(t, []scalingEvent{{current: 1, target: 4, limit: core.ScalingLimitPluginCap}}, deniedEvents)
 
 	// NeonVM request
 	a.
@@ -810,6 +882,7 @@ func TestPartialUpscaleThenFull(t *testing.T) {
 		})
 
 	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(3))
+	assert.Equal(t, []scalingEvent{{current: 1, target: 3, limit: core.ScalingLimitPluginCap}}, actualEvents)
 	clockTick()
 	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
 	clockTick()
@@ -836,6 +909,7 @@ func TestPartialUpscaleThenFull(t *testing.T) {
 			Target:         resForCU(4),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 
@@ -846,6 +920,7 @@ func TestPartialUpscaleThenFull(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), targetRevision, api.PluginResponse{
 		Permit:  resForCU(4),
 		Migrate: nil,
+		Partial: nil,
 	})
 	pluginLatencyObserver.assert(duration("0.1s"), revsource.Upscale)
 	a.Call(nextActions).Equals(core.ActionSet{
@@ -857,6 +932,9 @@ func TestPartialUpscaleThenFull(t *testing.T) {
 		},
 	})
 	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(4))
+	// The goal is above the VM's maximum, so it's the bounds that are limiting us now.
+	assert.Equal(t, scalingEvent{current: 3, target: 4, limit: core.ScalingLimitBounds}, actualEvents[1])
+	assert.Len(t, deniedEvents, 1) // no more denials
 	clockTick()
 	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
 	vmInfo := helpers.CreateVmInfo(
@@ -884,6 +962,8 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 	defer latencyObserver.assertEmpty()
 	resForCU := DefaultComputeUnit.Mul
 
+	var deniedLimits []core.ScalingLimit
+
 	state := helpers.CreateInitialState(
 		DefaultInitialStateConfig,
 		helpers.WithStoredWarnings(a.StoredWarnings()),
@@ -893,6 +973,9 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 			// values close to the default, so request timing works out a little better.
 			c.PluginRequestTick = duration("7s")
 			c.MonitorDeniedDownscaleCooldown = duration("4s")
+			c.ObservabilityCallbacks.DeniedScaling = func(_ time.Time, _, _ uint32, limit core.ScalingLimit) {
+				deniedLimits = append(deniedLimits, limit)
+			}
 		}),
 	)
 
@@ -911,8 +994,10 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
-	a.Do(state.UpdateSystemMetrics, metrics)
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
 	// double-check that we agree about the desired resources
 	a.Call(getDesiredResources, state, clock.Now()).
 		Equals(resForCU(1))
@@ -949,6 +1034,7 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 	a.Do(state.Monitor().StartingDownscaleRequest, clock.Now(), resForCU(5))
 	clockTick()
 	a.Do(state.Monitor().DownscaleRequestDenied, clock.Now(), expectedRevision.WithTime())
+	assert.Equal(t, []core.ScalingLimit{core.ScalingLimitMonitorDenial}, deniedLimits)
 
 	// At the end, we should be waiting to retry downscaling:
 	a.Call(nextActions).Equals(core.ActionSet{
@@ -1019,6 +1105,7 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 			Target:         resForCU(3),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
@@ -1029,6 +1116,7 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(3),
 		Migrate: nil,
+		Partial: nil,
 	})
 	// ... And *now* there's nothing left to do but wait until downscale wait expires:
 	a.Call(nextActions).Equals(core.ActionSet{
@@ -1064,6 +1152,7 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 			Target:         resForCU(3),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
@@ -1074,6 +1163,7 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(3),
 		Migrate: nil,
+		Partial: nil,
 	})
 	a.Call(nextActions).Equals(core.ActionSet{
 		Wait: &core.ActionWait{Duration: duration("0.9s")}, // yep, still waiting on retrying vm-monitor downscaling
@@ -1134,6 +1224,7 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 			Target:         resForCU(1),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(1))
@@ -1144,6 +1235,7 @@ func TestDeniedDownscalingIncreaseAndRetry(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(1),
 		Migrate: nil,
+		Partial: nil,
 	})
 	// And now there's truly nothing left to do. Back to waiting on plugin request tick :)
 	a.Call(nextActions).Equals(core.ActionSet{
@@ -1188,8 +1280,10 @@ func TestRequestedUpscale(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
-	a.Do(state.UpdateSystemMetrics, lastMetrics)
+	a.Do(state.UpdateSystemMetrics, clock.Now(), lastMetrics)
 
 	// Check we're not supposed to do anything
 	a.Call(nextActions).Equals(core.ActionSet{
@@ -1210,6 +1304,7 @@ func TestRequestedUpscale(t *testing.T) {
 			Target:         resForCU(2),
 			Metrics:        lo.ToPtr(lastMetrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(2))
@@ -1220,6 +1315,7 @@ func TestRequestedUpscale(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(2),
 		Migrate: nil,
+		Partial: nil,
 	})
 
 	// After approval from the scheduler plugin, now need to make NeonVM request:
@@ -1272,6 +1368,7 @@ func TestRequestedUpscale(t *testing.T) {
 			Target:         resForCU(2),
 			Metrics:        lo.ToPtr(lastMetrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(2))
@@ -1282,6 +1379,7 @@ func TestRequestedUpscale(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(2),
 		Migrate: nil,
+		Partial: nil,
 	})
 
 	// Still should just be waiting on vm-monitor upscale expiring
@@ -1332,12 +1430,16 @@ func TestDownscalePivotBack(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
 	newMetrics := core.SystemMetrics{
 		LoadAverage1Min:   0.3,
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
 
 	steps := []struct {
@@ -1445,6 +1547,7 @@ func TestDownscalePivotBack(t *testing.T) {
 						Target:         resForCU(1),
 						Metrics:        lo.ToPtr(initialMetrics.ToAPI()),
 						TargetRevision: expectedRevision.WithTime(),
+						UseLocalBudget: false,
 					},
 				})
 				a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(1))
@@ -1456,6 +1559,7 @@ func TestDownscalePivotBack(t *testing.T) {
 				a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 					Permit:  resForCU(1),
 					Migrate: nil,
+					Partial: nil,
 				})
 			},
 			post: func(pluginWait *time.Duration) {
@@ -1466,6 +1570,7 @@ func TestDownscalePivotBack(t *testing.T) {
 						Target:         resForCU(2),
 						Metrics:        lo.ToPtr(newMetrics.ToAPI()),
 						TargetRevision: expectedRevision.WithTime(),
+						UseLocalBudget: false,
 					},
 				})
 				a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(2))
@@ -1475,6 +1580,7 @@ func TestDownscalePivotBack(t *testing.T) {
 				a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 					Permit:  resForCU(2),
 					Migrate: nil,
+					Partial: nil,
 				})
 			},
 		},
@@ -1503,7 +1609,7 @@ func TestDownscalePivotBack(t *testing.T) {
 		clockTick().AssertEquals(duration("0.2s"))
 		pluginWait := duration("4.8s")
 
-		a.Do(state.UpdateSystemMetrics, initialMetrics)
+		a.Do(state.UpdateSystemMetrics, clock.Now(), initialMetrics)
 		// double-check that we agree about the desired resources
 		a.Call(getDesiredResources, state, clock.Now()).
 			Equals(resForCU(1))
@@ -1518,7 +1624,7 @@ func TestDownscalePivotBack(t *testing.T) {
 				// at the midpoint, start backtracking by setting the metrics
 				midRequest = func() {
 					t.Log(" > > updating metrics mid-request")
-					a.Do(state.UpdateSystemMetrics, newMetrics)
+					a.Do(state.UpdateSystemMetrics, clock.Now(), newMetrics)
 					a.Call(getDesiredResources, state, clock.Now()).
 						Equals(resForCU(2))
 				}
@@ -1576,8 +1682,10 @@ func TestBoundsChangeRequiresDownsale(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
-	a.Do(state.UpdateSystemMetrics, metrics)
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
 	// Check that we agree about desired resources
 	a.Call(getDesiredResources, state, clock.Now()).
 		Equals(resForCU(2))
@@ -1632,6 +1740,7 @@ func TestBoundsChangeRequiresDownsale(t *testing.T) {
 			Target:         resForCU(1),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(1))
@@ -1639,6 +1748,7 @@ func TestBoundsChangeRequiresDownsale(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(1),
 		Migrate: nil,
+		Partial: nil,
 	})
 
 	// Update the VM to set currentCU==1 CU
@@ -1692,8 +1802,10 @@ func TestBoundsChangeRequiresUpscale(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
-	a.Do(state.UpdateSystemMetrics, metrics)
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
 	// Check that we agree about desired resources
 	a.Call(getDesiredResources, state, clock.Now()).
 		Equals(resForCU(2))
@@ -1718,6 +1830,7 @@ func TestBoundsChangeRequiresUpscale(t *testing.T) {
 			Target:         resForCU(3),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
@@ -1725,6 +1838,7 @@ func TestBoundsChangeRequiresUpscale(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(3),
 		Migrate: nil,
+		Partial: nil,
 	})
 	// Do NeonVM request for the upscaling
 	a.Call(nextActions).Equals(core.ActionSet{
@@ -1793,8 +1907,10 @@ func TestFailedRequestRetry(t *testing.T) {
 		LoadAverage5Min:   0.0,
 		MemoryUsageBytes:  0.0,
 		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
 	}
-	a.Do(state.UpdateSystemMetrics, metrics)
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
 
 	// We should be asking the scheduler for upscaling
 	a.Call(nextActions).Equals(core.ActionSet{
@@ -1803,6 +1919,7 @@ func TestFailedRequestRetry(t *testing.T) {
 			Target:         resForCU(2),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(2))
@@ -1823,6 +1940,7 @@ func TestFailedRequestRetry(t *testing.T) {
 			Target:         resForCU(2),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
 		},
 	})
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(2))
@@ -1830,6 +1948,7 @@ func TestFailedRequestRetry(t *testing.T) {
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
 		Permit:  resForCU(2),
 		Migrate: nil,
+		Partial: nil,
 	})
 
 	// Now, after plugin request is successful, we should be making a request to NeonVM.
@@ -1876,11 +1995,9 @@ func TestFailedRequestRetry(t *testing.T) {
 	})
 }
 
-// Checks that when metrics are updated during the downscaling process, between the NeonVM request
-// and plugin request, we keep those processes mostly separate, without interference between them.
-//
-// This is distilled from a bug found on staging that resulted in faulty requests to the plugin.
-func TestMetricsConcurrentUpdatedDuringDownscale(t *testing.T) {
+// Checks that when the scheduler plugin has been unavailable for long enough, we fall back to the
+// local budget, and then stop using it once the plugin is available again.
+func TestLocalBudgetWhilePluginUnavailable(t *testing.T) {
 	a := helpers.NewAssert(t)
 	clock := helpers.NewFakeClock(t)
 	clockTick := func() {
@@ -1889,171 +2006,1086 @@ func TestMetricsConcurrentUpdatedDuringDownscale(t *testing.T) {
 	expectedRevision := helpers.NewExpectedRevision(clock.Now)
 	resForCU := DefaultComputeUnit.Mul
 
+	var deniedLimits []core.ScalingLimit
 	state := helpers.CreateInitialState(
 		DefaultInitialStateConfig,
 		helpers.WithStoredWarnings(a.StoredWarnings()),
-		// NOTE: current CU is greater than max CU. This is in line with what happens when
-		// unassigned pooled VMs created by the control plane are first assigned and endpoint and
-		// must immediately scale down.
-		helpers.WithMinMaxCU(1, 2),
-		helpers.WithCurrentCU(3),
+		helpers.WithMinMaxCU(1, 3),
+		helpers.WithCurrentCU(1),
+		helpers.WithConfigSetting(func(c *core.Config) {
+			c.PluginRetryWait = duration("2s")
+			c.PluginDegradedAfter = duration("3s")
+			c.ObservabilityCallbacks.DeniedScaling = func(_ time.Time, _, _ uint32, limit core.ScalingLimit) {
+				deniedLimits = append(deniedLimits, limit)
+			}
+		}),
 	)
 	nextActions := func() core.ActionSet {
 		return state.NextActions(clock.Now())
 	}
 
-	// Send initial scheduler request - without the monitor active, so we're stuck at 4 CU for now
-	a.
-		WithWarnings(
-			"Making scaling decision without all required metrics available",
-			"Wanted to send vm-monitor downscale request, but there's no active connection",
-		).
-		Call(state.NextActions, clock.Now()).
-		Equals(core.ActionSet{
+	state.Monitor().Active(true)
+
+	doInitialPluginRequest(a, state, clock, duration("0.1s"), nil, resForCU(1))
+
+	// Set metrics so that we should be trying to upscale to 3 CU
+	clockTick()
+	metrics := core.SystemMetrics{
+		LoadAverage1Min:   0.35,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	}
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
+
+	pluginRequest := func(useLocalBudget bool) core.ActionSet {
+		return core.ActionSet{
 			PluginRequest: &core.ActionPluginRequest{
-				LastPermit:     nil,
+				LastPermit:     lo.ToPtr(resForCU(1)),
 				Target:         resForCU(3),
-				Metrics:        nil,
+				Metrics:        lo.ToPtr(metrics.ToAPI()),
 				TargetRevision: expectedRevision.WithTime(),
+				UseLocalBudget: useLocalBudget,
 			},
-		})
+		}
+	}
+	failureWait := core.ActionSet{Wait: &core.ActionWait{Duration: duration("2s")}}
+
+	// The first couple of failures are within PluginDegradedAfter, so we don't use the local budget
+	a.Call(nextActions).Equals(pluginRequest(false))
 	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
 	clockTick()
-	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
-		Permit:  resForCU(3),
-		Migrate: nil,
-	})
+	a.Do(state.Plugin().RequestFailed, clock.Now())
+	a.
+		WithWarnings("Wanted to make a request to the scheduler plugin, but previous request failed too recently").
+		Call(nextActions).
+		Equals(failureWait)
+	clock.Inc(duration("2s"))
+	a.Call(nextActions).Equals(pluginRequest(false))
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
+	clockTick()
+	a.Do(state.Plugin().RequestFailed, clock.Now())
+	clock.Inc(duration("2s"))
 
+	// ... but now it's been more than PluginDegradedAfter since the first failure:
+	a.Call(nextActions).Equals(pluginRequest(true))
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
 	clockTick()
+	a.Do(state.Plugin().RequestFailed, clock.Now())
+	// The local budget only grants part of the request
+	a.NoError(state.Plugin().LocalPermitGranted, clock.Now(), resForCU(2))
+	assert.Equal(t, []core.ScalingLimit{core.ScalingLimitLocalBudget}, deniedLimits)
 
-	// Monitor's now active, so we should be asking it for downscaling.
-	// We don't yet have metrics though, so we only want to downscale as much as is required.
-	state.Monitor().Active(true)
+	// We should be able to upscale using the local permit
 	a.
-		WithWarnings("Making scaling decision without all required metrics available").
+		WithWarnings("Wanted to make a request to the scheduler plugin, but previous request failed too recently").
 		Call(nextActions).
 		Equals(core.ActionSet{
-			Wait: &core.ActionWait{Duration: duration("4.8s")},
-			MonitorDownscale: &core.ActionMonitorDownscale{
-				Current:        resForCU(3),
+			Wait: &core.ActionWait{Duration: duration("2s")},
+			NeonVMRequest: &core.ActionNeonVMRequest{
+				Current:        resForCU(1),
 				Target:         resForCU(2),
 				TargetRevision: expectedRevision.WithTime(),
 			},
 		})
-	a.Do(state.Monitor().StartingDownscaleRequest, clock.Now(), resForCU(2))
-
-	// In the middle of the vm-monitor request, update the metrics so that now the desired resource
-	// usage is actually 1 CU
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(2))
 	clockTick()
-	// the actual metrics we got in the actual logs
-	metrics := core.SystemMetrics{
-		LoadAverage1Min:   0.0,
-		LoadAverage5Min:   0.0,
-		MemoryUsageBytes:  150589570, // 143.6 MiB
-		MemoryCachedBytes: 0.0,
-	}
-	a.Do(state.UpdateSystemMetrics, metrics)
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
 
-	// nothing to do yet, until the existing vm-monitor request finishes
+	// Once the plugin is available again, its permit replaces the local one. The request includes
+	// the local permit, so that the plugin takes into account what we're already using.
+	clock.Inc(duration("2s"))
 	a.Call(nextActions).Equals(core.ActionSet{
-		Wait: &core.ActionWait{Duration: duration("4.7s")}, // plugin request tick wait
+		PluginRequest: &core.ActionPluginRequest{
+			LastPermit:     lo.ToPtr(resForCU(2)),
+			Target:         resForCU(3),
+			Metrics:        lo.ToPtr(metrics.ToAPI()),
+			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: true,
+		},
+		MonitorUpscale: &core.ActionMonitorUpscale{
+			Current:        resForCU(1),
+			Target:         resForCU(2),
+			TargetRevision: expectedRevision.WithTime(),
+		},
 	})
-
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
 	clockTick()
-
-	// When the vm-monitor request finishes, we want to both
-	// (a) request additional downscaling from vm-monitor, and
-	// (b) make a NeonVM request for the initially approved downscaling
-	a.Do(state.Monitor().DownscaleRequestAllowed, clock.Now(), expectedRevision.WithTime())
+	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
+		Permit:  resForCU(3),
+		Migrate: nil,
+		Partial: nil,
+	})
 	a.Call(nextActions).Equals(core.ActionSet{
-		Wait: &core.ActionWait{Duration: duration("4.6s")}, // plugin request tick wait
+		Wait: &core.ActionWait{Duration: duration("4.9s")}, // plugin request tick
 		NeonVMRequest: &core.ActionNeonVMRequest{
-			Current:        resForCU(3),
-			Target:         resForCU(2),
+			Current:        resForCU(2),
+			Target:         resForCU(3),
 			TargetRevision: expectedRevision.WithTime(),
 		},
-		MonitorDownscale: &core.ActionMonitorDownscale{
-			Current:        resForCU(2),
-			Target:         resForCU(1),
+		MonitorUpscale: &core.ActionMonitorUpscale{
+			Current:        resForCU(1),
+			Target:         resForCU(2),
 			TargetRevision: expectedRevision.WithTime(),
 		},
 	})
-	// Start both requests. The vm-monitor request will finish first, but after that we'll just be
-	// waiting on the NeonVM request (and then redoing a follow-up for more downscaling).
-	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(2))
-	a.Do(state.Monitor().StartingDownscaleRequest, clock.Now(), resForCU(1))
+}
 
-	clockTick()
+// Checks that after upscaling with the local budget, a permit from the plugin that's less than we
+// requested (but includes what was granted locally) is accepted, and replaces the local permit.
+func TestLocalBudgetThenSmallerPluginPermit(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	clockTick := func() {
+		clock.Inc(100 * time.Millisecond)
+	}
+	expectedRevision := helpers.NewExpectedRevision(clock.Now)
+	resForCU := DefaultComputeUnit.Mul
 
-	a.Do(state.Monitor().DownscaleRequestAllowed, clock.Now(), expectedRevision.WithTime())
-	a.
-		WithWarnings(
-			"Wanted to make a request to NeonVM API, but there's already NeonVM request (for different resources) ongoing",
-		).
-		Call(nextActions).
-		Equals(core.ActionSet{
-			Wait: &core.ActionWait{Duration: duration("4.5s")}, // plugin request tick wait
-		})
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithMinMaxCU(1, 3),
+		helpers.WithCurrentCU(1),
+		helpers.WithConfigSetting(func(c *core.Config) {
+			c.PluginRetryWait = duration("2s")
+			c.PluginDegradedAfter = duration("1s")
+		}),
+	)
+	nextActions := func() core.ActionSet {
+		return state.NextActions(clock.Now())
+	}
+
+	state.Monitor().Active(true)
+
+	doInitialPluginRequest(a, state, clock, duration("0.1s"), nil, resForCU(1))
 
+	// Set metrics so that we should be trying to upscale to 3 CU
 	clockTick()
+	metrics := core.SystemMetrics{
+		LoadAverage1Min:   0.35,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	}
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
 
-	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
-	state.Debug(true)
+	// Plugin outage: the first request fails, and after PluginDegradedAfter we use the local budget.
+	a.Call(nextActions).Equals(core.ActionSet{
+		PluginRequest: &core.ActionPluginRequest{
+			LastPermit:     lo.ToPtr(resForCU(1)),
+			Target:         resForCU(3),
+			Metrics:        lo.ToPtr(metrics.ToAPI()),
+			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
+		},
+	})
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
+	clockTick()
+	a.Do(state.Plugin().RequestFailed, clock.Now())
+	clock.Inc(duration("2s"))
+	a.Call(nextActions).Equals(core.ActionSet{
+		PluginRequest: &core.ActionPluginRequest{
+			LastPermit:     lo.ToPtr(resForCU(1)),
+			Target:         resForCU(3),
+			Metrics:        lo.ToPtr(metrics.ToAPI()),
+			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: true,
+		},
+	})
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
+	clockTick()
+	a.Do(state.Plugin().RequestFailed, clock.Now())
+	a.NoError(state.Plugin().LocalPermitGranted, clock.Now(), resForCU(2))
+
+	// Upscale to the locally granted 2 CU
 	a.
+		WithWarnings("Wanted to make a request to the scheduler plugin, but previous request failed too recently").
 		Call(nextActions).
 		Equals(core.ActionSet{
-			// At this point in the original logs from staging, the intended request to the plugin was
-			// incorrectly for 1 CU, rather than 2 CU. So, the rest of this test case is mostly just
-			// rounding out the rest of the scale-down routine.
-			PluginRequest: &core.ActionPluginRequest{
-				LastPermit:     lo.ToPtr(resForCU(3)),
-				Target:         resForCU(2),
-				Metrics:        lo.ToPtr(metrics.ToAPI()),
-				TargetRevision: expectedRevision.WithTime(),
-			},
+			Wait: &core.ActionWait{Duration: duration("2s")},
 			NeonVMRequest: &core.ActionNeonVMRequest{
-				Current:        resForCU(2),
-				Target:         resForCU(1),
+				Current:        resForCU(1),
+				Target:         resForCU(2),
 				TargetRevision: expectedRevision.WithTime(),
 			},
 		})
-	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(2))
-	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(1))
-
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(2))
 	clockTick()
-
-	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
-		Permit:  resForCU(2),
-		Migrate: nil,
-	})
-	// Still waiting for NeonVM request to complete
-	a.Call(nextActions).Equals(core.ActionSet{
-		Wait: &core.ActionWait{Duration: duration("4.9s")}, // plugin request tick wait
-	})
-
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+	a.Do(state.Monitor().StartingUpscaleRequest, clock.Now(), resForCU(2))
 	clockTick()
+	a.Do(state.Monitor().UpscaleRequestSuccessful, clock.Now())
 
-	// After the NeonVM request finishes, all that we have left to do is inform the plugin of the
-	// final downscaling.
-	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+	// The plugin returns. The request sends the local permit as the last permit...
+	clock.Inc(duration("2s"))
 	a.Call(nextActions).Equals(core.ActionSet{
 		PluginRequest: &core.ActionPluginRequest{
 			LastPermit:     lo.ToPtr(resForCU(2)),
-			Target:         resForCU(1),
+			Target:         resForCU(3),
 			Metrics:        lo.ToPtr(metrics.ToAPI()),
 			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: true,
 		},
 	})
-	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(1))
-
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
 	clockTick()
-
+	// ... so the plugin's permit, even though it's less than requested, covers what we're using.
 	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
-		Permit:  resForCU(1),
+		Permit:  resForCU(2),
 		Migrate: nil,
+		Partial: nil,
 	})
-	// Nothing left to do
+
+	// The local permit is gone: the next request is a normal one, based on the plugin's permit.
+	a.
+		WithWarnings("Wanted to make a request to the scheduler plugin, but previous request for more resources was denied too recently").
+		Call(nextActions).
+		Equals(core.ActionSet{
+			Wait: &core.ActionWait{Duration: duration("1.9s")}, // denied retry wait
+		})
+	clock.Inc(duration("1.9s"))
 	a.Call(nextActions).Equals(core.ActionSet{
-		Wait: &core.ActionWait{Duration: duration("4.9s")}, // plugin request tick wait
+		PluginRequest: &core.ActionPluginRequest{
+			LastPermit:     lo.ToPtr(resForCU(2)),
+			Target:         resForCU(3),
+			Metrics:        lo.ToPtr(metrics.ToAPI()),
+			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
+		},
+	})
+}
+
+// Checks that permits pushed by the scheduler plugin are used without making a new request, and that
+// invalid pushed permits are rejected.
+func TestPluginPermitPushed(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	clockTick := func() {
+		clock.Inc(100 * time.Millisecond)
+	}
+	expectedRevision := helpers.NewExpectedRevision(clock.Now)
+	resForCU := DefaultComputeUnit.Mul
+
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithMinMaxCU(1, 3),
+		helpers.WithCurrentCU(1),
+	)
+	nextActions := func() core.ActionSet {
+		return state.NextActions(clock.Now())
+	}
+
+	state.Monitor().Active(true)
+
+	// Pushed permits aren't accepted before there's been a successful request
+	assert.Error(t, state.Plugin().PermitPushed(clock.Now(), resForCU(1)))
+
+	doInitialPluginRequest(a, state, clock, duration("0.1s"), nil, resForCU(1))
+
+	// Set metrics so that we should be trying to upscale to 3 CU
+	clockTick()
+	metrics := core.SystemMetrics{
+		LoadAverage1Min:   0.35,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	}
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
+
+	a.Call(nextActions).Equals(core.ActionSet{
+		PluginRequest: &core.ActionPluginRequest{
+			LastPermit:     lo.ToPtr(resForCU(1)),
+			Target:         resForCU(3),
+			Metrics:        lo.ToPtr(metrics.ToAPI()),
+			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
+		},
+	})
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
+	// Permits pushed while a request is ongoing are ignored
+	a.NoError(state.Plugin().PermitPushed, clock.Now(), resForCU(3))
+	clockTick()
+	// The plugin only approves part of the request
+	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
+		Permit:  resForCU(2),
+		Migrate: nil,
+		Partial: nil,
+	})
+	clockTick()
+	a.
+		WithWarnings("Wanted to make a request to the scheduler plugin, but previous request for more resources was denied too recently").
+		Call(nextActions).
+		Equals(core.ActionSet{
+			Wait: &core.ActionWait{Duration: duration("1.8s")}, // denied retry wait
+			NeonVMRequest: &core.ActionNeonVMRequest{
+				Current:        resForCU(1),
+				Target:         resForCU(2),
+				TargetRevision: expectedRevision.WithTime(),
+			},
+		})
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(2))
+	clockTick()
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+
+	// Pushed permits can't be greater than the last request, or less than the VM's resources
+	assert.Error(t, state.Plugin().PermitPushed(clock.Now(), resForCU(4)))
+	assert.Error(t, state.Plugin().PermitPushed(clock.Now(), resForCU(1)))
+
+	// Once the rest of the request is approved, we can upscale without making another request
+	clockTick()
+	a.NoError(state.Plugin().PermitPushed, clock.Now(), resForCU(3))
+	a.Call(nextActions).Equals(core.ActionSet{
+		Wait: &core.ActionWait{Duration: duration("4.6s")}, // plugin request tick
+		NeonVMRequest: &core.ActionNeonVMRequest{
+			Current:        resForCU(2),
+			Target:         resForCU(3),
+			TargetRevision: expectedRevision.WithTime(),
+		},
+		MonitorUpscale: &core.ActionMonitorUpscale{
+			Current:        resForCU(1),
+			Target:         resForCU(2),
+			TargetRevision: expectedRevision.WithTime(),
+		},
+	})
+}
+
+// Checks that when the plugin gives an estimate of when to retry a partially approved request, we
+// use that instead of the configured wait before retrying.
+func TestPartialPermitRetryAfter(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	clockTick := func() {
+		clock.Inc(100 * time.Millisecond)
+	}
+	expectedRevision := helpers.NewExpectedRevision(clock.Now)
+	resForCU := DefaultComputeUnit.Mul
+
+	var deniedLimits []core.ScalingLimit
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithMinMaxCU(1, 3),
+		helpers.WithCurrentCU(1),
+		helpers.WithConfigSetting(func(c *core.Config) {
+			c.ObservabilityCallbacks.DeniedScaling = func(_ time.Time, _, _ uint32, limit core.ScalingLimit) {
+				deniedLimits = append(deniedLimits, limit)
+			}
+		}),
+	)
+	nextActions := func() core.ActionSet {
+		return state.NextActions(clock.Now())
+	}
+
+	state.Monitor().Active(true)
+
+	doInitialPluginRequest(a, state, clock, duration("0.1s"), nil, resForCU(1))
+
+	// Set metrics so that we should be trying to upscale to 3 CU
+	clockTick()
+	metrics := core.SystemMetrics{
+		LoadAverage1Min:   0.35,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	}
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
+
+	a.Call(nextActions).Equals(core.ActionSet{
+		PluginRequest: &core.ActionPluginRequest{
+			LastPermit:     lo.ToPtr(resForCU(1)),
+			Target:         resForCU(3),
+			Metrics:        lo.ToPtr(metrics.ToAPI()),
+			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
+		},
+	})
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
+	clockTick()
+	// The plugin only approves part of the request, and expects more to be available in 3s
+	partialResp := api.PluginResponse{
+		Permit:  resForCU(2),
+		Migrate: nil,
+		Partial: &api.PartialPermit{
+			Reason:            api.PartialPermitReasonMigrationInProgress,
+			Message:           "",
+			RetryAfterSeconds: 3,
+		},
+	}
+	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), partialResp)
+	assert.Equal(t, []core.ScalingLimit{core.ScalingLimitPluginCap}, deniedLimits)
+	clockTick()
+	a.
+		WithWarnings("Wanted to make a request to the scheduler plugin, but previous request for more resources was denied too recently").
+		Call(nextActions).
+		Equals(core.ActionSet{
+			Wait: &core.ActionWait{Duration: duration("2.8s")}, // plugin's retry estimate
+			NeonVMRequest: &core.ActionNeonVMRequest{
+				Current:        resForCU(1),
+				Target:         resForCU(2),
+				TargetRevision: expectedRevision.WithTime(),
+			},
+		})
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(2))
+	clockTick()
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+
+	// Once the estimate has passed, we retry the request
+	clock.Inc(duration("2.7s"))
+	a.Call(nextActions).Equals(core.ActionSet{
+		PluginRequest: &core.ActionPluginRequest{
+			LastPermit:     lo.ToPtr(resForCU(2)),
+			Target:         resForCU(3),
+			Metrics:        lo.ToPtr(metrics.ToAPI()),
+			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
+		},
+		MonitorUpscale: &core.ActionMonitorUpscale{
+			Current:        resForCU(1),
+			Target:         resForCU(2),
+			TargetRevision: expectedRevision.WithTime(),
+		},
+	})
+
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
+	clockTick()
+
+	// The plugin gives the same partial permit again. That was already reported, so there's no new
+	// denied scaling event.
+	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), partialResp)
+	assert.Equal(t, []core.ScalingLimit{core.ScalingLimitPluginCap}, deniedLimits)
+}
+
+// Checks that when metrics are updated during the downscaling process, between the NeonVM request
+// and plugin request, we keep those processes mostly separate, without interference between them.
+//
+// This is distilled from a bug found on staging that resulted in faulty requests to the plugin.
+func TestMetricsConcurrentUpdatedDuringDownscale(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	clockTick := func() {
+		clock.Inc(100 * time.Millisecond)
+	}
+	expectedRevision := helpers.NewExpectedRevision(clock.Now)
+	resForCU := DefaultComputeUnit.Mul
+
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		// NOTE: current CU is greater than max CU. This is in line with what happens when
+		// unassigned pooled VMs created by the control plane are first assigned and endpoint and
+		// must immediately scale down.
+		helpers.WithMinMaxCU(1, 2),
+		helpers.WithCurrentCU(3),
+	)
+	nextActions := func() core.ActionSet {
+		return state.NextActions(clock.Now())
+	}
+
+	// Send initial scheduler request - without the monitor active, so we're stuck at 4 CU for now
+	a.
+		WithWarnings(
+			"Making scaling decision without all required metrics available",
+			"Wanted to send vm-monitor downscale request, but there's no active connection",
+		).
+		Call(state.NextActions, clock.Now()).
+		Equals(core.ActionSet{
+			PluginRequest: &core.ActionPluginRequest{
+				LastPermit:     nil,
+				Target:         resForCU(3),
+				Metrics:        nil,
+				TargetRevision: expectedRevision.WithTime(),
+				UseLocalBudget: false,
+			},
+		})
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(3))
+	clockTick()
+	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
+		Permit:  resForCU(3),
+		Migrate: nil,
+		Partial: nil,
+	})
+
+	clockTick()
+
+	// Monitor's now active, so we should be asking it for downscaling.
+	// We don't yet have metrics though, so we only want to downscale as much as is required.
+	state.Monitor().Active(true)
+	a.
+		WithWarnings("Making scaling decision without all required metrics available").
+		Call(nextActions).
+		Equals(core.ActionSet{
+			Wait: &core.ActionWait{Duration: duration("4.8s")},
+			MonitorDownscale: &core.ActionMonitorDownscale{
+				Current:        resForCU(3),
+				Target:         resForCU(2),
+				TargetRevision: expectedRevision.WithTime(),
+			},
+		})
+	a.Do(state.Monitor().StartingDownscaleRequest, clock.Now(), resForCU(2))
+
+	// In the middle of the vm-monitor request, update the metrics so that now the desired resource
+	// usage is actually 1 CU
+	clockTick()
+	// the actual metrics we got in the actual logs
+	metrics := core.SystemMetrics{
+		LoadAverage1Min:   0.0,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  150589570, // 143.6 MiB
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	}
+	a.Do(state.UpdateSystemMetrics, clock.Now(), metrics)
+
+	// nothing to do yet, until the existing vm-monitor request finishes
+	a.Call(nextActions).Equals(core.ActionSet{
+		Wait: &core.ActionWait{Duration: duration("4.7s")}, // plugin request tick wait
+	})
+
+	clockTick()
+
+	// When the vm-monitor request finishes, we want to both
+	// (a) request additional downscaling from vm-monitor, and
+	// (b) make a NeonVM request for the initially approved downscaling
+	a.Do(state.Monitor().DownscaleRequestAllowed, clock.Now(), expectedRevision.WithTime())
+	a.Call(nextActions).Equals(core.ActionSet{
+		Wait: &core.ActionWait{Duration: duration("4.6s")}, // plugin request tick wait
+		NeonVMRequest: &core.ActionNeonVMRequest{
+			Current:        resForCU(3),
+			Target:         resForCU(2),
+			TargetRevision: expectedRevision.WithTime(),
+		},
+		MonitorDownscale: &core.ActionMonitorDownscale{
+			Current:        resForCU(2),
+			Target:         resForCU(1),
+			TargetRevision: expectedRevision.WithTime(),
+		},
+	})
+	// Start both requests. The vm-monitor request will finish first, but after that we'll just be
+	// waiting on the NeonVM request (and then redoing a follow-up for more downscaling).
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(2))
+	a.Do(state.Monitor().StartingDownscaleRequest, clock.Now(), resForCU(1))
+
+	clockTick()
+
+	a.Do(state.Monitor().DownscaleRequestAllowed, clock.Now(), expectedRevision.WithTime())
+	a.
+		WithWarnings(
+			"Wanted to make a request to NeonVM API, but there's already NeonVM request (for different resources) ongoing",
+		).
+		Call(nextActions).
+		Equals(core.ActionSet{
+			Wait: &core.ActionWait{Duration: duration("4.5s")}, // plugin request tick wait
+		})
+
+	clockTick()
+
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+	state.Debug(true)
+	a.
+		Call(nextActions).
+		Equals(core.ActionSet{
+			// At this point in the original logs from staging, the intended request to the plugin was
+			// incorrectly for 1 CU, rather than 2 CU. So, the rest of this test case is mostly just
+			// rounding out the rest of the scale-down routine.
+			PluginRequest: &core.ActionPluginRequest{
+				LastPermit:     lo.ToPtr(resForCU(3)),
+				Target:         resForCU(2),
+				Metrics:        lo.ToPtr(metrics.ToAPI()),
+				TargetRevision: expectedRevision.WithTime(),
+				UseLocalBudget: false,
+			},
+			NeonVMRequest: &core.ActionNeonVMRequest{
+				Current:        resForCU(2),
+				Target:         resForCU(1),
+				TargetRevision: expectedRevision.WithTime(),
+			},
+		})
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(2))
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(1))
+
+	clockTick()
+
+	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
+		Permit:  resForCU(2),
+		Migrate: nil,
+		Partial: nil,
+	})
+	// Still waiting for NeonVM request to complete
+	a.Call(nextActions).Equals(core.ActionSet{
+		Wait: &core.ActionWait{Duration: duration("4.9s")}, // plugin request tick wait
+	})
+
+	clockTick()
+
+	// After the NeonVM request finishes, all that we have left to do is inform the plugin of the
+	// final downscaling.
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+	a.Call(nextActions).Equals(core.ActionSet{
+		PluginRequest: &core.ActionPluginRequest{
+			LastPermit:     lo.ToPtr(resForCU(2)),
+			Target:         resForCU(1),
+			Metrics:        lo.ToPtr(metrics.ToAPI()),
+			TargetRevision: expectedRevision.WithTime(),
+			UseLocalBudget: false,
+		},
+	})
+	a.Do(state.Plugin().StartingRequest, clock.Now(), resForCU(1))
+
+	clockTick()
+
+	a.NoError(state.Plugin().RequestSuccessful, clock.Now(), expectedRevision.WithTime(), api.PluginResponse{
+		Permit:  resForCU(1),
+		Migrate: nil,
+		Partial: nil,
+	})
+	// Nothing left to do
+	a.Call(nextActions).Equals(core.ActionSet{
+		Wait: &core.ActionWait{Duration: duration("4.9s")}, // plugin request tick wait
+	})
+}
+
+// Checks that a custom GoalPolicy selected via the VM's scaling config is used in place of the
+// default, and that unknown policies fall back to the default.
+func TestCustomGoalPolicy(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	resForCU := DefaultComputeUnit.Mul
+
+	// Metrics that, with the default policy, would keep us at 1 CU.
+	metrics := core.SystemMetrics{
+		LoadAverage1Min:   0.0,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	}
+
+	var policyInputs []core.GoalPolicyInput
+	alwaysThree := core.GoalPolicyFunc(func(input core.GoalPolicyInput) (core.ScalingGoal, []zap.Field) {
+		policyInputs = append(policyInputs, input)
+		return core.ScalingGoal{
+			HasAllMetrics: input.SystemMetrics != nil,
+			Parts: core.ScalingGoalParts{
+				CPU:         lo.ToPtr(3.0),
+				Mem:         nil,
+				LFC:         nil,
+				CPUForecast: nil,
+				Scheduled:   nil,
+				PSI:         nil,
+				Override:    nil,
+			},
+		}, nil
+	})
+
+	//nolint:exhaustruct // this is a test; only overriding the one field
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithGoalPolicy("always-three", alwaysThree),
+		helpers.WithScalingConfig(&api.ScalingConfig{GoalPolicy: lo.ToPtr("always-three")}),
+	)
+	state.UpdateSystemMetrics(clock.Now(), metrics)
+
+	a.Call(getDesiredResources, state, clock.Now()).Equals(resForCU(3))
+	require.Len(t, policyInputs, 1)
+	assert.Equal(t, &metrics, policyInputs[0].SystemMetrics)
+	assert.Equal(t, DefaultComputeUnit, policyInputs[0].ComputeUnit)
+
+	// Switching to an unknown policy falls back to the default, with a warning.
+	//nolint:exhaustruct // this is a test; only overriding the one field
+	a.Do(state.UpdatedVM, helpers.CreateVmInfo(
+		DefaultInitialStateConfig.VM,
+		helpers.WithScalingConfig(&api.ScalingConfig{GoalPolicy: lo.ToPtr("does-not-exist")}),
+	))
+	a.WithWarnings(`Unknown goal policy "does-not-exist", falling back to "default"`).
+		Call(getDesiredResources, state, clock.Now()).
+		Equals(resForCU(1))
+	require.Len(t, policyInputs, 1)
+}
+
+// Checks that the VM's scaling schedule is used as a lower bound on desired resources while a
+// window is active, and not otherwise.
+func TestScheduledMinimum(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t) // starts at 2000-01-01T00:00:00Z, a Saturday
+	resForCU := DefaultComputeUnit.Mul
+
+	schedule := &api.ScalingSchedule{
+		Windows: []api.ScheduledWindow{
+			{
+				Days:     []string{"sat"},
+				Start:    "00:00",
+				End:      "00:10",
+				Timezone: "UTC",
+				Min: api.ResourceBounds{
+					CPU: *resource.NewMilliQuantity(750, resource.DecimalSI),
+					Mem: *resource.NewQuantity(3<<30 /* 3 Gi */, resource.BinarySI),
+				},
+			},
+		},
+	}
+
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithScalingSchedule(schedule),
+	)
+	state.UpdateSystemMetrics(clock.Now(), core.SystemMetrics{
+		LoadAverage1Min:   0.0,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	})
+
+	// Inside the window: we should be held at 3 CU, and woken up when the window ends.
+	clock.Inc(duration("1m"))
+	desired, waitFn := state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(3), desired)
+	assert.Equal(t, lo.ToPtr(duration("9m")), waitFn(core.ActionSet{}))
+
+	// After the window: back to what the metrics say.
+	clock.Inc(duration("9m"))
+	desired, waitFn = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(1), desired)
+	assert.Nil(t, waitFn(core.ActionSet{}))
+}
+
+// Checks that with a downscale stabilization window, we only downscale once the goal CU has been
+// lower for the whole window - and then only to the highest goal CU seen during it.
+func TestDownscaleStabilization(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	resForCU := DefaultComputeUnit.Mul
+
+	metricsForLoad := func(load float64) core.SystemMetrics {
+		return core.SystemMetrics{
+			LoadAverage1Min:   load,
+			LoadAverage5Min:   load,
+			MemoryUsageBytes:  0.0,
+			MemoryCachedBytes: 0.0,
+			CPUSeconds:        nil,
+			Pressure:          nil,
+		}
+	}
+
+	//nolint:exhaustruct // this is a test; only overriding the one field
+	scalingConfig := &api.ScalingConfig{DownscaleStabilizationSeconds: lo.ToPtr(60)}
+
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithCurrentCU(4),
+		helpers.WithScalingConfig(scalingConfig),
+	)
+	state.UpdateSystemMetrics(clock.Now(), metricsForLoad(0.0))
+
+	// Goal is 1 CU, but we should stay at 4 CU until the window has passed.
+	desired, waitFn := state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(4), desired)
+	assert.Equal(t, lo.ToPtr(duration("60s")), waitFn(core.ActionSet{}))
+
+	// A brief increase in load to 2 CU during the window doesn't restart it...
+	clock.Inc(duration("30s"))
+	state.UpdateSystemMetrics(clock.Now(), metricsForLoad(0.25))
+	desired, waitFn = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(4), desired)
+	assert.Equal(t, lo.ToPtr(duration("30s")), waitFn(core.ActionSet{}))
+
+	// ... but does mean that we only downscale to 2 CU once the window has passed.
+	clock.Inc(duration("30s"))
+	state.UpdateSystemMetrics(clock.Now(), metricsForLoad(0.0))
+	desired, waitFn = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(2), desired)
+	assert.Nil(t, waitFn(core.ActionSet{}))
+
+	// The window should be visible in the state dump
+	dump, err := json.Marshal(state.Dump())
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `"DownscaleStabilization":{"Since":"2000-01-01T00:00:00Z","CurrentCU":4,"HighestGoalCU":2}`)
+	assert.Contains(t, string(dump), `"downscaleStabilizationSeconds":60`)
+
+	// Once the VM is at 2 CU, the window restarts, acting as a cooldown between downscaling.
+	clock.Inc(duration("1s"))
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(2))
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+	desired, waitFn = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(2), desired)
+	assert.Equal(t, lo.ToPtr(duration("60s")), waitFn(core.ActionSet{}))
+
+	// If the goal goes back up to the current CU, the window is reset.
+	clock.Inc(duration("30s"))
+	state.UpdateSystemMetrics(clock.Now(), metricsForLoad(0.25))
+	desired, waitFn = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(2), desired)
+	assert.Nil(t, waitFn(core.ActionSet{}))
+
+	clock.Inc(duration("1s"))
+	state.UpdateSystemMetrics(clock.Now(), metricsForLoad(0.0))
+	desired, waitFn = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(2), desired)
+	assert.Equal(t, lo.ToPtr(duration("60s")), waitFn(core.ActionSet{}))
+
+	// Upscaling is never held back.
+	state.UpdateSystemMetrics(clock.Now(), metricsForLoad(0.5))
+	desired, waitFn = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(4), desired)
+	assert.Nil(t, waitFn(core.ActionSet{}))
+}
+
+func TestPSIScalingSteps(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	resForCU := DefaultComputeUnit.Mul
+
+	metricsForPressure := func(cpuSome float64) core.SystemMetrics {
+		return core.SystemMetrics{
+			LoadAverage1Min:   0.0,
+			LoadAverage5Min:   0.0,
+			MemoryUsageBytes:  0.0,
+			MemoryCachedBytes: 0.0,
+			CPUSeconds:        nil,
+			//nolint:exhaustruct // this is a test
+			Pressure: &core.PressureMetrics{CPUSome10s: cpuSome},
+		}
+	}
+
+	//nolint:exhaustruct // this is a test; only overriding the relevant fields
+	scalingConfig := &api.ScalingConfig{
+		EnablePSIScaling:    lo.ToPtr(true),
+		PSIThresholdPercent: lo.ToPtr(20.0),
+	}
+
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithMinMaxCU(1, 8),
+		helpers.WithCurrentCU(2),
+		helpers.WithScalingConfig(scalingConfig),
+	)
+
+	// Pressure is above the threshold, so the goal is one more than the current CU.
+	state.UpdateSystemMetrics(clock.Now(), metricsForPressure(50))
+	desired, _ := state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(3), desired)
+
+	// While the pressure remains, the goal shouldn't keep climbing before we've scaled up...
+	clock.Inc(duration("10s"))
+	state.UpdateSystemMetrics(clock.Now(), metricsForPressure(50))
+	desired, _ = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(3), desired)
+
+	// ... or before the cooldown has passed, even once the VM is at the new size.
+	clock.Inc(duration("10s"))
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(3))
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+	state.UpdateSystemMetrics(clock.Now(), metricsForPressure(50))
+	desired, _ = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(3), desired)
+
+	dump, err := json.Marshal(state.Dump())
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `"PSIStep":{"at":"2000-01-01T00:00:00Z","goalCU":3}`)
+
+	// Once the cooldown has passed, we take another step.
+	clock.Inc(duration("10s"))
+	state.UpdateSystemMetrics(clock.Now(), metricsForPressure(50))
+	desired, _ = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(4), desired)
+
+	// When the pressure drops, the PSI component no longer has any effect.
+	clock.Inc(duration("1s"))
+	state.UpdateSystemMetrics(clock.Now(), metricsForPressure(5))
+	desired, _ = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(1), desired)
+
+	dump, err = json.Marshal(state.Dump())
+	require.NoError(t, err)
+	assert.Contains(t, string(dump), `"PSIStep":null`)
+}
+
+// Checks that the maximum downscale step limits how far we downscale in a single decision, and
+// doesn't affect upscaling.
+func TestDownscaleMaxStep(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	resForCU := DefaultComputeUnit.Mul
+
+	//nolint:exhaustruct // this is a test; only overriding the one field
+	scalingConfig := &api.ScalingConfig{DownscaleMaxStepCU: lo.ToPtr(1)}
+
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithCurrentCU(4),
+		helpers.WithScalingConfig(scalingConfig),
+	)
+	state.UpdateSystemMetrics(clock.Now(), core.SystemMetrics{
+		LoadAverage1Min:   0.0,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	})
+
+	a.Call(getDesiredResources, state, clock.Now()).Equals(resForCU(3))
+
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(3))
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+	a.Call(getDesiredResources, state, clock.Now()).Equals(resForCU(2))
+
+	// Upscaling from 1 CU to 4 CU happens in one step.
+	a.Do(state.NeonVM().StartingRequest, clock.Now(), resForCU(1))
+	a.Do(state.NeonVM().RequestSuccessful, clock.Now())
+	state.UpdateSystemMetrics(clock.Now(), core.SystemMetrics{
+		LoadAverage1Min:   0.5,
+		LoadAverage5Min:   0.5,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	})
+	a.Call(getDesiredResources, state, clock.Now()).Equals(resForCU(4))
+}
+
+// Checks that a CU override is treated as both the minimum and maximum until it expires, and that
+// it's included in the reported goal.
+func TestCUOverride(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	resForCU := DefaultComputeUnit.Mul
+
+	var reportedParts []core.ScalingGoalParts
+	var reportedTargets []uint32
+
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithMinMaxCU(2, 4),
+		helpers.WithCurrentCU(2),
+		helpers.WithConfigSetting(func(c *core.Config) {
+			c.ObservabilityCallbacks.HypotheticalScaling = func(_ time.Time, _, target uint32, parts core.ScalingGoalParts) {
+				reportedTargets = append(reportedTargets, target)
+				reportedParts = append(reportedParts, parts)
+			}
+		}),
+	)
+	state.UpdateSystemMetrics(clock.Now(), core.SystemMetrics{
+		LoadAverage1Min:   0.0,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	})
+
+	// An override below the VM's minimum is raised to the minimum.
+	state.UpdateCUOverride(&core.CUOverride{CU: 1, Until: clock.Now().Add(duration("60s"))})
+	desired, waitFn := state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(2), desired)
+	assert.Equal(t, lo.ToPtr(duration("60s")), waitFn(core.ActionSet{}))
+	require.Len(t, reportedParts, 1)
+	assert.Equal(t, lo.ToPtr(1.0), reportedParts[0].Override)
+	assert.Equal(t, uint32(1), reportedTargets[0])
+
+	// An override above the VM's limit is capped.
+	clock.Inc(duration("10s"))
+	state.UpdateCUOverride(&core.CUOverride{CU: 6, Until: clock.Now().Add(duration("60s"))})
+	a.WithWarnings("Can't increase desired resources to CU override of 6 because of VM limits").
+		Call(getDesiredResources, state, clock.Now()).
+		Equals(resForCU(4))
+
+	// Once the override has expired, we go back to the usual bounds.
+	clock.Inc(duration("60s"))
+	desired, waitFn = state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(2), desired)
+	assert.Nil(t, waitFn(core.ActionSet{}))
+	assert.Nil(t, reportedParts[len(reportedParts)-1].Override)
+
+	// ... and likewise if it's cleared before then.
+	state.UpdateCUOverride(&core.CUOverride{CU: 3, Until: clock.Now().Add(duration("60s"))})
+	a.Call(getDesiredResources, state, clock.Now()).Equals(resForCU(3))
+	state.UpdateCUOverride(nil)
+	a.Call(getDesiredResources, state, clock.Now()).Equals(resForCU(2))
+}
+
+// Checks that a CU override doesn't cause us to re-request downscaling that the vm-monitor
+// recently denied.
+func TestCUOverrideWithDeniedDownscale(t *testing.T) {
+	a := helpers.NewAssert(t)
+	clock := helpers.NewFakeClock(t)
+	expectedRevision := helpers.NewExpectedRevision(clock.Now)
+	resForCU := DefaultComputeUnit.Mul
+
+	state := helpers.CreateInitialState(
+		DefaultInitialStateConfig,
+		helpers.WithStoredWarnings(a.StoredWarnings()),
+		helpers.WithMinMaxCU(1, 8),
+		helpers.WithCurrentCU(6),
+		helpers.WithConfigSetting(func(c *core.Config) {
+			c.PluginRequestTick = duration("7s")
+			c.MonitorDeniedDownscaleCooldown = duration("4s")
+		}),
+	)
+	state.Monitor().Active(true)
+
+	doInitialPluginRequest(a, state, clock, duration("0.1s"), nil, resForCU(6))
+
+	clock.Inc(duration("0.1s"))
+	a.Do(state.UpdateSystemMetrics, clock.Now(), core.SystemMetrics{
+		LoadAverage1Min:   0.0,
+		LoadAverage5Min:   0.0,
+		MemoryUsageBytes:  0.0,
+		MemoryCachedBytes: 0.0,
+		CPUSeconds:        nil,
+		Pressure:          nil,
+	})
+
+	// The vm-monitor denies downscaling to 5 CU
+	a.Call(state.NextActions, clock.Now()).Equals(core.ActionSet{
+		Wait: &core.ActionWait{Duration: duration("6.8s")},
+		MonitorDownscale: &core.ActionMonitorDownscale{
+			Current:        resForCU(6),
+			Target:         resForCU(5),
+			TargetRevision: expectedRevision.WithTime(),
+		},
+	})
+	a.Do(state.Monitor().StartingDownscaleRequest, clock.Now(), resForCU(5))
+	clock.Inc(duration("0.1s"))
+	a.Do(state.Monitor().DownscaleRequestDenied, clock.Now(), expectedRevision.WithTime())
+
+	// An override below the denied amount shouldn't result in another downscale request until
+	// the denial has expired.
+	state.UpdateCUOverride(&core.CUOverride{CU: 2, Until: clock.Now().Add(duration("60s"))})
+	desired, waitFn := state.DesiredResourcesFromMetricsOrRequestedUpscaling(clock.Now())
+	assert.Equal(t, resForCU(6), desired)
+	assert.Equal(t, lo.ToPtr(duration("4s")), waitFn(core.ActionSet{}))
+	a.Call(state.NextActions, clock.Now()).Equals(core.ActionSet{
+		Wait: &core.ActionWait{Duration: duration("4s")},
+	})
+
+	// Once the denial expires, we start downscaling towards the override again.
+	clock.Inc(duration("4s"))
+	a.Call(state.NextActions, clock.Now()).Equals(core.ActionSet{
+		Wait: &core.ActionWait{Duration: duration("2.7s")},
+		MonitorDownscale: &core.ActionMonitorDownscale{
+			Current:        resForCU(6),
+			Target:         resForCU(5),
+			TargetRevision: expectedRevision.WithTime(),
+		},
 	})
 }
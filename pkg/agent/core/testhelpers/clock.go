@@ -23,6 +23,11 @@ func NewFakeClock(t *testing.T) *FakeClock {
 		panic(err)
 	}
 
+	return NewFakeClockAt(t, base)
+}
+
+// NewFakeClockAt creates a new fake clock, with the initial time set to base.
+func NewFakeClockAt(t *testing.T, base time.Time) *FakeClock {
 	return &FakeClock{t: t, base: base, now: base}
 }
 
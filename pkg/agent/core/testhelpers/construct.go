@@ -87,6 +87,7 @@ func CreateVmInfo(config InitialVmInfoConfig, opts ...VmInfoOpt) api.VmInfo {
 			AlwaysMigrate:        false,
 			ScalingConfig:        nil,
 			ScalingEnabled:       true,
+			ScalingSchedule:      nil,
 		},
 		CurrentRevision: nil,
 	}
@@ -169,3 +170,26 @@ func WithCurrentRevision(rev vmv1.RevisionWithTime) VmInfoOpt {
 		vm.CurrentRevision = &rev
 	})
 }
+
+func WithScalingConfig(cfg *api.ScalingConfig) VmInfoOpt {
+	return vmInfoModifier(func(c InitialVmInfoConfig, vm *api.VmInfo) {
+		vm.Config.ScalingConfig = cfg
+	})
+}
+
+func WithGoalPolicy(name string, policy core.GoalPolicy) InitialStateOpt {
+	return WithConfigSetting(func(c *core.Config) {
+		policies := make(map[string]core.GoalPolicy)
+		for n, p := range c.GoalPolicies {
+			policies[n] = p
+		}
+		policies[name] = policy
+		c.GoalPolicies = policies
+	})
+}
+
+func WithScalingSchedule(schedule *api.ScalingSchedule) VmInfoOpt {
+	return vmInfoModifier(func(c InitialVmInfoConfig, vm *api.VmInfo) {
+		vm.Config.ScalingSchedule = schedule
+	})
+}
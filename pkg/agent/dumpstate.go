@@ -1,13 +1,15 @@
 package agent
 
-// Utilities for dumping internal state
+// Utilities for dumping internal state, and for temporarily overriding it
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"os"
 	"runtime"
 	"slices"
 	"strings"
@@ -16,15 +18,47 @@ import (
 
 	"go.uber.org/zap"
 
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
 	"github.com/neondatabase/autoscaling/pkg/util"
 )
 
 type StateDump struct {
 	Stopped bool           `json:"stopped"`
 	Pods    []podStateDump `json:"pods"`
+	// LocalBudget is set if the local budget for the node is enabled.
+	LocalBudget *localBudgetDump `json:"localBudget,omitempty"`
+}
+
+// CUOverrideRequest is the body of requests to the dump-state server's /cu-override endpoint
+type CUOverrideRequest struct {
+	VM util.NamespacedName `json:"vm"`
+	// CU gives the number of compute units to pin the VM to.
+	CU uint16 `json:"cu"`
+	// DurationSeconds gives how long the override should last. If zero, any existing override for
+	// the VM is removed.
+	DurationSeconds uint `json:"durationSeconds"`
+}
+
+// CUOverrideResponse is the response to a successful CUOverrideRequest
+type CUOverrideResponse struct {
+	VM util.NamespacedName `json:"vm"`
+	// CUOverride is the override now in effect, or nil if it was removed.
+	CUOverride *core.CUOverride `json:"cuOverride"`
 }
 
 func (s *agentState) StartDumpStateServer(shutdownCtx context.Context, logger *zap.Logger, config *DumpStateConfig) error {
+	var overrideToken string
+	if config.CUOverride != nil {
+		token, err := os.ReadFile(config.CUOverride.TokenPath)
+		if err != nil {
+			return fmt.Errorf("error reading CU override token: %w", err)
+		}
+		overrideToken = strings.TrimSpace(string(token))
+		if overrideToken == "" {
+			return fmt.Errorf("CU override token file %q is empty", config.CUOverride.TokenPath)
+		}
+	}
+
 	// Manually start the TCP listener so we can minimize errors in the background thread.
 	addr := net.TCPAddr{IP: net.IPv4zero, Port: int(config.Port)}
 	listener, err := net.ListenTCP("tcp", &addr)
@@ -55,6 +89,14 @@ func (s *agentState) StartDumpStateServer(shutdownCtx context.Context, logger *z
 
 			return state, 200, nil
 		})
+		if config.CUOverride != nil {
+			// Wrap the handler in a separate mux so that we can check authentication first.
+			overrideMux := http.NewServeMux()
+			util.AddHandler(logger, overrideMux, "/cu-override", http.MethodPost, "CUOverrideRequest", func(ctx context.Context, logger *zap.Logger, req *CUOverrideRequest) (*CUOverrideResponse, int, error) {
+				return s.handleCUOverride(ctx, logger, config, req)
+			})
+			mux.Handle("/cu-override", requireBearerToken(overrideToken, overrideMux))
+		}
 		// note: we don't shut down this server. It should be possible to continue fetching the
 		// internal state after shutdown has started.
 		server := &http.Server{Handler: mux}
@@ -66,6 +108,64 @@ func (s *agentState) StartDumpStateServer(shutdownCtx context.Context, logger *z
 	return nil
 }
 
+func (s *agentState) handleCUOverride(
+	ctx context.Context,
+	logger *zap.Logger,
+	config *DumpStateConfig,
+	req *CUOverrideRequest,
+) (*CUOverrideResponse, int, error) {
+	maxDuration := config.CUOverride.MaxDurationSeconds
+	if req.VM.Namespace == "" || req.VM.Name == "" {
+		return nil, 400, errors.New("VM namespace and name must be set")
+	} else if req.DurationSeconds > maxDuration {
+		return nil, 400, fmt.Errorf("duration must be at most %d seconds", maxDuration)
+	} else if req.DurationSeconds != 0 && req.CU == 0 {
+		return nil, 400, errors.New("CU must be greater than zero")
+	}
+
+	var override *core.CUOverride
+	if req.DurationSeconds != 0 {
+		override = &core.CUOverride{
+			CU:    req.CU,
+			Until: time.Now().Add(time.Second * time.Duration(req.DurationSeconds)),
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(config.TimeoutSeconds)*time.Second)
+	defer cancel()
+
+	if err := s.SetCUOverride(ctx, req.VM, override); err != nil {
+		if errors.Is(err, errVMNotFound) {
+			return nil, 404, err
+		}
+		return nil, 500, fmt.Errorf("error while setting CU override: %w", err)
+	}
+
+	if override != nil {
+		logger.Warn("Set CU override for VM", zap.Object("virtualmachine", req.VM), zap.Any("cuOverride", override))
+	} else {
+		logger.Warn("Removed CU override for VM", zap.Object("virtualmachine", req.VM))
+	}
+
+	return &CUOverrideResponse{VM: req.VM, CUOverride: override}, 200, nil
+}
+
+// requireBearerToken wraps the handler so that requests without the token in their Authorization
+// header are rejected.
+func requireBearerToken(token string, handler http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		provided := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(provided, expected) != 1 {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte("missing or invalid bearer token"))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
 func (s *agentState) DumpState(ctx context.Context, stopped bool) (*StateDump, error) {
 	// Copy the high-level state, then process it
 	podList, err := func() ([]*podState, error) {
@@ -85,8 +185,12 @@ func (s *agentState) DumpState(ctx context.Context, stopped bool) (*StateDump, e
 	}
 
 	state := StateDump{
-		Stopped: stopped,
-		Pods:    make([]podStateDump, len(podList)),
+		Stopped:     stopped,
+		Pods:        make([]podStateDump, len(podList)),
+		LocalBudget: nil,
+	}
+	if s.localBudget != nil {
+		state.LocalBudget = s.localBudget.dump()
 	}
 
 	wg := sync.WaitGroup{}
@@ -11,6 +11,7 @@ import (
 
 	vmclient "github.com/neondatabase/autoscaling/neonvm/client/clientset/versioned"
 	"github.com/neondatabase/autoscaling/pkg/agent/billing"
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
 	"github.com/neondatabase/autoscaling/pkg/agent/scalingevents"
 	"github.com/neondatabase/autoscaling/pkg/agent/schedwatch"
 	"github.com/neondatabase/autoscaling/pkg/util"
@@ -23,9 +24,17 @@ type MainRunner struct {
 	Config     *Config
 	KubeClient *kubernetes.Clientset
 	VMClient   *vmclient.Clientset
+
+	// GoalPolicies provides any custom goal CU policies, which VMs may select by name with the
+	// goalPolicy field of their scaling config. May be nil.
+	GoalPolicies map[string]core.GoalPolicy
 }
 
 func (r MainRunner) Run(logger *zap.Logger, ctx context.Context) error {
+	if err := r.validateGoalPolicies(); err != nil {
+		return fmt.Errorf("invalid goal policies: %w", err)
+	}
+
 	vmEventQueue := pubsub.NewUnlimitedQueue[vmEvent]()
 	defer vmEventQueue.Close()
 	pushToQueue := func(ev vmEvent) {
@@ -100,6 +109,11 @@ func (r MainRunner) Run(logger *zap.Logger, ctx context.Context) error {
 	tg.Go("billing", func(logger *zap.Logger) error {
 		return mc.Run(tg.Ctx(), logger, storeForNode)
 	})
+	if globalState.localBudget != nil {
+		tg.Go("local-budget", func(logger *zap.Logger) error {
+			return globalState.localBudget.Run(tg.Ctx(), logger)
+		})
+	}
 	tg.Go("main-loop", func(logger *zap.Logger) error {
 		logger.Info("Entering main loop")
 		for {
@@ -119,3 +133,21 @@ func (r MainRunner) Run(logger *zap.Logger, ctx context.Context) error {
 
 	return tg.Wait()
 }
+
+// validateGoalPolicies checks that the custom goal policies don't conflict with the built-in one,
+// and that the default scaling config refers to a policy that exists.
+func (r MainRunner) validateGoalPolicies() error {
+	for name := range r.GoalPolicies {
+		if name == "" || name == core.DefaultGoalPolicyName {
+			return fmt.Errorf("goal policy name %q is reserved", name)
+		}
+	}
+
+	if name := r.Config.Scaling.DefaultConfig.GoalPolicy; name != nil && *name != core.DefaultGoalPolicyName {
+		if _, ok := r.GoalPolicies[*name]; !ok {
+			return fmt.Errorf("default scaling config uses unknown goal policy %q", *name)
+		}
+	}
+
+	return nil
+}
@@ -17,9 +17,10 @@ import (
 )
 
 var (
-	_ executor.PluginInterface  = (*execPluginInterface)(nil)
-	_ executor.NeonVMInterface  = (*execNeonVMInterface)(nil)
-	_ executor.MonitorInterface = (*execMonitorInterface)(nil)
+	_ executor.PluginInterface      = (*execPluginInterface)(nil)
+	_ executor.LocalBudgetInterface = (*execLocalBudgetInterface)(nil)
+	_ executor.NeonVMInterface      = (*execNeonVMInterface)(nil)
+	_ executor.MonitorInterface     = (*execMonitorInterface)(nil)
 )
 
 /////////////////////////////////////////////////////////////
@@ -28,10 +29,11 @@ var (
 
 type execPluginInterface struct {
 	runner *Runner
+	core   *executor.ExecutorCore
 }
 
-func makePluginInterface(r *Runner) *execPluginInterface {
-	return &execPluginInterface{runner: r}
+func makePluginInterface(r *Runner, core *executor.ExecutorCore) *execPluginInterface {
+	return &execPluginInterface{runner: r, core: core}
 }
 
 // scalingResponseType indicates type of scaling response from the scheduler plugin
@@ -56,7 +58,7 @@ func (iface *execPluginInterface) Request(
 		iface.runner.recordResourceChange(*lastPermit, target, iface.runner.global.metrics.schedulerRequestedChange)
 	}
 
-	resp, err := iface.runner.DoSchedulerRequest(ctx, logger, target, lastPermit, metrics)
+	resp, err := iface.runner.DoSchedulerRequest(ctx, logger, target, lastPermit, metrics, iface.permitPushed)
 
 	if err == nil && lastPermit != nil {
 		iface.runner.recordResourceChange(*lastPermit, resp.Permit, iface.runner.global.metrics.schedulerApprovedChange)
@@ -97,6 +99,38 @@ func (iface *execPluginInterface) Request(
 	return resp, err
 }
 
+// permitPushed handles an updated permit pushed by the scheduler plugin over a permit stream
+func (iface *execPluginInterface) permitPushed(logger *zap.Logger, resp api.PluginResponse) {
+	iface.core.Updater().PluginPermitPushed(resp, func(err error) {
+		if err != nil {
+			iface.runner.global.metrics.schedulerPushedPermits.WithLabelValues("rejected").Inc()
+			logger.Warn("Ignoring permit pushed by scheduler plugin", zap.Any("response", resp), zap.Error(err))
+		} else {
+			iface.runner.global.metrics.schedulerPushedPermits.WithLabelValues("accepted").Inc()
+			logger.Info("Received permit pushed by scheduler plugin", zap.Any("response", resp))
+		}
+	})
+}
+
+type execLocalBudgetInterface struct {
+	runner *Runner
+	budget *localBudget
+}
+
+func makeLocalBudgetInterface(r *Runner, budget *localBudget) *execLocalBudgetInterface {
+	return &execLocalBudgetInterface{runner: r, budget: budget}
+}
+
+// Request implements executor.LocalBudgetInterface
+func (iface *execLocalBudgetInterface) Request(logger *zap.Logger, target api.Resources) (api.Resources, bool) {
+	return iface.budget.Request(logger, iface.runner.vmName, target)
+}
+
+// Release implements executor.LocalBudgetInterface
+func (iface *execLocalBudgetInterface) Release() {
+	iface.budget.release(iface.runner.vmName)
+}
+
 /////////////////////////////////////////////////
 // NeonVM-related interface and implementation //
 /////////////////////////////////////////////////
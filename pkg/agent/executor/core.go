@@ -19,6 +19,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	"github.com/neondatabase/autoscaling/pkg/agent/recording"
 	"github.com/neondatabase/autoscaling/pkg/api"
 	"github.com/neondatabase/autoscaling/pkg/util"
 )
@@ -30,6 +31,10 @@ type Config struct {
 	// In practice, this value is set to a callback that increments a metric.
 	OnNextActions func()
 
+	// Recorder, if not nil, is given every input to the inner core.State, so that the sequence
+	// of decisions can be replayed later.
+	Recorder *recording.Recorder
+
 	Core core.Config
 }
 
@@ -44,6 +49,8 @@ type ExecutorCore struct {
 	lastActionsID timedActionsID
 	onNextActions func()
 
+	recorder *recording.Recorder
+
 	updates *util.Broadcaster
 }
 
@@ -51,9 +58,18 @@ type ClientSet struct {
 	Plugin  PluginInterface
 	NeonVM  NeonVMInterface
 	Monitor MonitorInterface
+	// LocalBudget, if not nil, is used when the scheduler plugin is unavailable.
+	LocalBudget LocalBudgetInterface
 }
 
 func NewExecutorCore(stateLogger *zap.Logger, vm api.VmInfo, config Config) *ExecutorCore {
+	config.Recorder.Record(recording.Event{
+		Kind:   recording.EventInit,
+		At:     time.Now(),
+		VM:     &vm,
+		Config: &config.Core,
+	})
+
 	return &ExecutorCore{
 		mu:            sync.Mutex{},
 		stateLogger:   stateLogger,
@@ -61,6 +77,7 @@ func NewExecutorCore(stateLogger *zap.Logger, vm api.VmInfo, config Config) *Exe
 		actions:       nil, // (*ExecutorCore).getActions() checks if this is nil
 		lastActionsID: -1,
 		onNextActions: config.OnNextActions,
+		recorder:      config.Recorder,
 		updates:       util.NewBroadcaster(),
 	}
 }
@@ -106,6 +123,7 @@ func (c *ExecutorCore) getActions() timedActions {
 		c.stateLogger.Debug("Recalculating ActionSet", zap.Time("now", now), zap.Any("state", c.core.Dump()))
 		c.actions = &timedActions{id: id, actions: c.core.NextActions(now)}
 		c.lastActionsID = id
+		c.record(recording.Event{Kind: recording.EventNextActions, At: now, Actions: &c.actions.actions})
 		c.stateLogger.Debug("New ActionSet", zap.Time("now", now), zap.Any("actions", c.actions.actions))
 	}
 
@@ -121,6 +139,12 @@ func (c *ExecutorCore) update(with func(*core.State)) {
 	with(c.core)
 }
 
+// record passes the event to the recorder, if there is one. It must be called while holding c.mu,
+// so that the recorded events are in the same order as they were applied to the core.State.
+func (c *ExecutorCore) record(event recording.Event) {
+	c.recorder.Record(event)
+}
+
 // updateIfActionsUnchanged is like update, but if the actions have been changed, then the function
 // is not called and this returns false.
 //
@@ -164,7 +188,9 @@ type ExecutorCoreUpdater struct {
 // withLock while holding the lock.
 func (c ExecutorCoreUpdater) UpdateSystemMetrics(metrics core.SystemMetrics, withLock func()) {
 	c.core.update(func(state *core.State) {
-		state.UpdateSystemMetrics(metrics)
+		now := time.Now()
+		c.core.record(recording.Event{Kind: recording.EventSystemMetrics, At: now, SystemMetrics: &metrics})
+		state.UpdateSystemMetrics(now, metrics)
 		withLock()
 	})
 }
@@ -173,6 +199,7 @@ func (c ExecutorCoreUpdater) UpdateSystemMetrics(metrics core.SystemMetrics, wit
 // while holding the lock.
 func (c ExecutorCoreUpdater) UpdateLFCMetrics(metrics core.LFCMetrics, withLock func()) {
 	c.core.update(func(state *core.State) {
+		c.core.record(recording.Event{Kind: recording.EventLFCMetrics, At: time.Now(), LFCMetrics: &metrics})
 		state.UpdateLFCMetrics(metrics)
 		withLock()
 	})
@@ -182,15 +209,27 @@ func (c ExecutorCoreUpdater) UpdateLFCMetrics(metrics core.LFCMetrics, withLock
 // holding the lock.
 func (c ExecutorCoreUpdater) UpdatedVM(vm api.VmInfo, withLock func()) {
 	c.core.update(func(state *core.State) {
+		c.core.record(recording.Event{Kind: recording.EventUpdatedVM, At: time.Now(), VM: &vm})
 		state.UpdatedVM(vm)
 		withLock()
 	})
 }
 
+// UpdateCUOverride calls (*core.State).UpdateCUOverride() on the inner core.State and runs
+// withLock while holding the lock.
+func (c ExecutorCoreUpdater) UpdateCUOverride(override *core.CUOverride, withLock func()) {
+	c.core.update(func(state *core.State) {
+		c.core.record(recording.Event{Kind: recording.EventCUOverride, At: time.Now(), CUOverride: override})
+		state.UpdateCUOverride(override)
+		withLock()
+	})
+}
+
 // ResetMonitor calls (*core.State).Monitor().Reset() on the inner core.State and runs withLock
 // while holding the lock.
 func (c ExecutorCoreUpdater) ResetMonitor(withLock func()) {
 	c.core.update(func(state *core.State) {
+		c.core.record(recording.Event{Kind: recording.EventMonitorReset, At: time.Now()})
 		state.Monitor().Reset()
 		withLock()
 	})
@@ -200,15 +239,29 @@ func (c ExecutorCoreUpdater) ResetMonitor(withLock func()) {
 // runs withLock while holding the lock.
 func (c ExecutorCoreUpdater) UpscaleRequested(resources api.MoreResources, withLock func()) {
 	c.core.update(func(state *core.State) {
-		state.Monitor().UpscaleRequested(time.Now(), resources)
+		now := time.Now()
+		c.core.record(recording.Event{Kind: recording.EventMonitorUpscaleRequested, At: now, Requested: &resources})
+		state.Monitor().UpscaleRequested(now, resources)
 		withLock()
 	})
 }
 
+// PluginPermitPushed calls (*core.State).Plugin().PermitPushed(...) on the inner core.State and
+// runs withLock while holding the lock, passing any validation error from the core.State.
+func (c ExecutorCoreUpdater) PluginPermitPushed(resp api.PluginResponse, withLock func(error)) {
+	c.core.update(func(state *core.State) {
+		now := time.Now()
+		c.core.record(recording.Event{Kind: recording.EventPluginPermitPushed, At: now, PluginResponse: &resp})
+		err := state.Plugin().PermitPushed(now, resp.Permit)
+		withLock(err)
+	})
+}
+
 // MonitorActive calls (*core.State).Monitor().Active(...) on the inner core.State and runs withLock
 // while holding the lock.
 func (c ExecutorCoreUpdater) MonitorActive(active bool, withLock func()) {
 	c.core.update(func(state *core.State) {
+		c.core.record(recording.Event{Kind: recording.EventMonitorActive, At: time.Now(), Active: &active})
 		state.Monitor().Active(active)
 		withLock()
 	})
@@ -8,6 +8,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	"github.com/neondatabase/autoscaling/pkg/agent/recording"
 	"github.com/neondatabase/autoscaling/pkg/api"
 )
 
@@ -57,6 +58,7 @@ func (c *ExecutorCoreWithClients) DoMonitorDownscales(ctx context.Context, logge
 			logger.Info("Starting vm-monitor downscale request", zap.Object("action", action))
 			startTime = time.Now()
 			monitorIface = c.clients.Monitor.GetHandle()
+			c.record(recording.Event{Kind: recording.EventMonitorDownscaleStarting, At: startTime, Target: &action.Target})
 			state.Monitor().StartingDownscaleRequest(startTime, action.Target)
 
 			if monitorIface == nil {
@@ -86,6 +88,7 @@ func (c *ExecutorCoreWithClients) DoMonitorDownscales(ctx context.Context, logge
 			if err != nil {
 				logger.Error("vm-monitor downscale request failed", append(logFields, zap.Error(err))...)
 				if unchanged {
+					c.record(recording.Event{Kind: recording.EventMonitorDownscaleFailed, At: endTime})
 					state.Monitor().DownscaleRequestFailed(endTime)
 				} else {
 					warnSkipBecauseChanged()
@@ -98,6 +101,11 @@ func (c *ExecutorCoreWithClients) DoMonitorDownscales(ctx context.Context, logge
 			if !result.Ok {
 				logger.Warn("vm-monitor denied downscale", logFields...)
 				if unchanged {
+					c.record(recording.Event{
+						Kind:     recording.EventMonitorDownscaleDenied,
+						At:       endTime,
+						Revision: &action.TargetRevision,
+					})
 					state.Monitor().DownscaleRequestDenied(endTime, action.TargetRevision)
 				} else {
 					warnSkipBecauseChanged()
@@ -105,6 +113,11 @@ func (c *ExecutorCoreWithClients) DoMonitorDownscales(ctx context.Context, logge
 			} else {
 				logger.Info("vm-monitor approved downscale", logFields...)
 				if unchanged {
+					c.record(recording.Event{
+						Kind:     recording.EventMonitorDownscaleAllowed,
+						At:       endTime,
+						Revision: &action.TargetRevision,
+					})
 					state.Monitor().DownscaleRequestAllowed(endTime, action.TargetRevision)
 				} else {
 					warnSkipBecauseChanged()
@@ -147,6 +160,7 @@ func (c *ExecutorCoreWithClients) DoMonitorUpscales(ctx context.Context, logger
 			logger.Info("Starting vm-monitor upscale request", zap.Object("action", action))
 			startTime = time.Now()
 			monitorIface = c.clients.Monitor.GetHandle()
+			c.record(recording.Event{Kind: recording.EventMonitorUpscaleStarting, At: startTime, Target: &action.Target})
 			state.Monitor().StartingUpscaleRequest(startTime, action.Target)
 
 			if monitorIface == nil {
@@ -176,6 +190,7 @@ func (c *ExecutorCoreWithClients) DoMonitorUpscales(ctx context.Context, logger
 			if err != nil {
 				logger.Error("vm-monitor upscale request failed", append(logFields, zap.Error(err))...)
 				if unchanged {
+					c.record(recording.Event{Kind: recording.EventMonitorUpscaleFailed, At: endTime})
 					state.Monitor().UpscaleRequestFailed(endTime)
 				} else {
 					warnSkipBecauseChanged()
@@ -185,6 +200,7 @@ func (c *ExecutorCoreWithClients) DoMonitorUpscales(ctx context.Context, logger
 
 			logger.Info("vm-monitor upscale request successful", logFields...)
 			if unchanged {
+				c.record(recording.Event{Kind: recording.EventMonitorUpscaleSuccess, At: endTime})
 				state.Monitor().UpscaleRequestSuccessful(endTime)
 			} else {
 				warnSkipBecauseChanged()
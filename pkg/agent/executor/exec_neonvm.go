@@ -8,6 +8,7 @@ import (
 
 	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
 	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	"github.com/neondatabase/autoscaling/pkg/agent/recording"
 	"github.com/neondatabase/autoscaling/pkg/api"
 )
 
@@ -46,6 +47,7 @@ func (c *ExecutorCoreWithClients) DoNeonVMRequests(ctx context.Context, logger *
 		if updated := c.updateIfActionsUnchanged(last, func(state *core.State) {
 			logger.Info("Starting NeonVM request", zap.Object("action", action))
 			startTime = time.Now()
+			c.record(recording.Event{Kind: recording.EventNeonVMStarting, At: startTime, Target: &action.Target})
 			state.NeonVM().StartingRequest(startTime, action.Target)
 		}); !updated {
 			continue // state has changed, retry.
@@ -60,9 +62,11 @@ func (c *ExecutorCoreWithClients) DoNeonVMRequests(ctx context.Context, logger *
 		c.update(func(state *core.State) {
 			if err != nil {
 				logger.Error("NeonVM request failed", append(logFields, zap.Error(err))...)
+				c.record(recording.Event{Kind: recording.EventNeonVMFailed, At: endTime})
 				state.NeonVM().RequestFailed(endTime)
 			} else /* err == nil */ {
 				logger.Info("NeonVM request successful", logFields...)
+				c.record(recording.Event{Kind: recording.EventNeonVMSuccess, At: endTime})
 				state.NeonVM().RequestSuccessful(endTime)
 			}
 		})
@@ -7,6 +7,7 @@ import (
 	"go.uber.org/zap"
 
 	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	"github.com/neondatabase/autoscaling/pkg/agent/recording"
 	"github.com/neondatabase/autoscaling/pkg/api"
 )
 
@@ -14,6 +15,16 @@ type PluginInterface interface {
 	Request(_ context.Context, _ *zap.Logger, lastPermit *api.Resources, target api.Resources, _ *api.Metrics) (*api.PluginResponse, error)
 }
 
+// LocalBudgetInterface is the local budget for the node, used to approve upscaling while the
+// scheduler plugin is unavailable.
+type LocalBudgetInterface interface {
+	// Request returns the resources granted by the local budget, or false if none could be.
+	Request(_ *zap.Logger, target api.Resources) (api.Resources, bool)
+	// Release is called after a successful request to the scheduler plugin, which is then
+	// responsible for any resources granted by the local budget.
+	Release()
+}
+
 func (c *ExecutorCoreWithClients) DoPluginRequests(ctx context.Context, logger *zap.Logger) {
 	var (
 		updates     = c.updates.NewReceiver()
@@ -40,13 +51,24 @@ func (c *ExecutorCoreWithClients) DoPluginRequests(ctx context.Context, logger *
 		if updated := c.updateIfActionsUnchanged(last, func(state *core.State) {
 			logger.Info("Starting plugin request", zap.Object("action", action))
 			startTime = time.Now()
+			c.record(recording.Event{Kind: recording.EventPluginStarting, At: startTime, Target: &action.Target})
 			state.Plugin().StartingRequest(startTime, action.Target)
 		}); !updated {
 			continue // state has changed, retry.
 		}
 
 		resp, err := c.clients.Plugin.Request(ctx, ifaceLogger, action.LastPermit, action.Target, action.Metrics)
+
+		// If the plugin has been unavailable for long enough, fall back to the local budget.
+		var localPermit *api.Resources
+		if err != nil && action.UseLocalBudget && c.clients.LocalBudget != nil {
+			if permit, ok := c.clients.LocalBudget.Request(ifaceLogger, action.Target); ok {
+				localPermit = &permit
+			}
+		}
+
 		endTime := time.Now()
+		var successful bool
 
 		c.update(func(state *core.State) {
 			logFields := []zap.Field{
@@ -56,14 +78,35 @@ func (c *ExecutorCoreWithClients) DoPluginRequests(ctx context.Context, logger *
 
 			if err != nil {
 				logger.Error("Plugin request failed", append(logFields, zap.Error(err))...)
+				c.record(recording.Event{Kind: recording.EventPluginFailed, At: endTime})
 				state.Plugin().RequestFailed(endTime)
+
+				if localPermit != nil {
+					logger.Warn("Using permit from local budget", append(logFields, zap.Object("permit", *localPermit))...)
+					c.record(recording.Event{Kind: recording.EventPluginLocalPermit, At: endTime, LocalPermit: localPermit})
+					if err := state.Plugin().LocalPermitGranted(endTime, *localPermit); err != nil {
+						logger.Error("Local budget permit validation failed", append(logFields, zap.Error(err))...)
+					}
+				}
 			} else {
 				logFields = append(logFields, zap.Any("response", resp))
 				logger.Info("Plugin request successful", logFields...)
+				c.record(recording.Event{
+					Kind:           recording.EventPluginSuccess,
+					At:             endTime,
+					Revision:       &action.TargetRevision,
+					PluginResponse: resp,
+				})
 				if err := state.Plugin().RequestSuccessful(endTime, action.TargetRevision, *resp); err != nil {
 					logger.Error("Plugin response validation failed", append(logFields, zap.Error(err))...)
+				} else {
+					successful = true
 				}
 			}
 		})
+
+		if successful && c.clients.LocalBudget != nil {
+			c.clients.LocalBudget.Release()
+		}
 	}
 }
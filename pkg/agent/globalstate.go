@@ -16,6 +16,7 @@ import (
 
 	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
 	vmclient "github.com/neondatabase/autoscaling/neonvm/client/clientset/versioned"
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
 	"github.com/neondatabase/autoscaling/pkg/agent/scalingevents"
 	"github.com/neondatabase/autoscaling/pkg/agent/schedwatch"
 	"github.com/neondatabase/autoscaling/pkg/api"
@@ -41,8 +42,17 @@ type agentState struct {
 	schedTracker *schedwatch.SchedulerTracker
 	metrics      GlobalMetrics
 	vmMetrics    *PerVMMetrics
+	goalPolicies map[string]core.GoalPolicy
 
 	scalingReporter *scalingevents.Reporter
+
+	// localBudget, if not nil, is used to approve upscaling while the scheduler plugin is
+	// unavailable.
+	localBudget *localBudget
+
+	// permitStreams, if not nil, provides the connection to the scheduler plugin for Runners'
+	// permit streams. It is nil if scheduler.grpcPort is not set.
+	permitStreams *permitStreamConns
 }
 
 func (r MainRunner) newAgentState(
@@ -53,7 +63,7 @@ func (r MainRunner) newAgentState(
 	globalMetrics GlobalMetrics,
 	perVMMetrics *PerVMMetrics,
 ) *agentState {
-	return &agentState{
+	state := &agentState{
 		lock:         util.NewChanMutex(),
 		pods:         make(map[util.NamespacedName]*podState),
 		baseLogger:   baseLogger,
@@ -64,9 +74,25 @@ func (r MainRunner) newAgentState(
 		schedTracker: schedTracker,
 		metrics:      globalMetrics,
 		vmMetrics:    perVMMetrics,
+		goalPolicies: r.GoalPolicies,
 
 		scalingReporter: scalingReporter,
+		localBudget:     nil, // set below
+		permitStreams:   nil, // set below
+	}
+	if r.Config.Scheduler.LocalBudget != nil {
+		state.localBudget = newLocalBudget(
+			state,
+			r.Config.Scheduler.LocalBudget,
+			r.Config.Scaling.ComputeUnit,
+			r.EnvArgs.K8sNodeName,
+			r.KubeClient,
+		)
 	}
+	if r.Config.Scheduler.GRPCPort != 0 {
+		state.permitStreams = newPermitStreamConns(r.Config.Scheduler.GRPCPort)
+	}
+	return state
 }
 
 func vmIsOurResponsibility(vm *vmv1.VirtualMachine, config *Config, nodeName string) bool {
@@ -115,6 +141,9 @@ func (s *agentState) handleEvent(ctx context.Context, logger *zap.Logger, event
 	switch event.kind {
 	case vmEventDeleted:
 		state.stop()
+		if s.localBudget != nil {
+			s.localBudget.release(event.vmInfo.NamespacedName())
+		}
 		// mark the status as deleted, so that it gets removed from metrics.
 		state.status.update(s, func(stat podStatus) podStatus {
 			stat.deleted = true
@@ -156,6 +185,7 @@ func (s *agentState) handleVMEventAdded(
 			vmInfo:             event.vmInfo,
 			endpointID:         event.endpointID,
 			endpointAssignedAt: &now,
+			cuOverride:         nil,
 			state:              "", // Explicitly set state to empty so that the initial state update does no decrement
 			stateUpdatedAt:     now,
 
@@ -188,6 +218,50 @@ func (s *agentState) handleVMEventAdded(
 	runner.Spawn(runnerCtx, logger, rxVMUpdate)
 }
 
+// errVMNotFound is returned by SetCUOverride if there's no VM with the given name.
+var errVMNotFound = errors.New("VM not found")
+
+// SetCUOverride sets or (if override is nil) clears the temporary override of the compute units for
+// the VM, passing it on to the VM's Runner.
+func (s *agentState) SetCUOverride(ctx context.Context, vmName util.NamespacedName, override *core.CUOverride) error {
+	if err := s.lock.TryLock(ctx); err != nil {
+		return err
+	}
+	defer s.lock.Unlock()
+
+	for _, pod := range s.pods {
+		if pod.runner.vmName != vmName {
+			continue
+		}
+
+		pod.status.update(s, func(stat podStatus) podStatus {
+			stat.cuOverride = override
+			pod.vmInfoUpdated.Send()
+			return stat
+		})
+		return nil
+	}
+
+	return errVMNotFound
+}
+
+// vmResources returns the current resources of each VM that this autoscaler-agent is responsible
+// for.
+func (s *agentState) vmResources() map[util.NamespacedName]api.Resources {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	resources := make(map[util.NamespacedName]api.Resources, len(s.pods))
+	for _, pod := range s.pods {
+		pod.status.mu.Lock()
+		vmInfo := pod.status.vmInfo
+		pod.status.mu.Unlock()
+
+		resources[vmInfo.NamespacedName()] = vmInfo.Using()
+	}
+	return resources
+}
+
 // FIXME: make these timings configurable.
 const (
 	RunnerRestartMinWaitSeconds = 5
@@ -360,7 +434,8 @@ func (s *agentState) newRunner(vmInfo api.VmInfo, podName util.NamespacedName, p
 
 		executorStateDump: nil, // set by (*Runner).Run
 
-		monitor: nil,
+		monitor:      nil,
+		permitStream: nil,
 
 		backgroundWorkerCount: atomic.Int64{},
 		backgroundPanic:       make(chan error),
@@ -432,6 +507,12 @@ type podStatus struct {
 	// NB: this value, once non-nil, is never changed.
 	endpointAssignedAt *time.Time
 
+	// cuOverride, if not nil, is the temporary override of the VM's compute units, set via the
+	// dump-state server. It's stored here so that it persists across Runner restarts.
+	//
+	// NB: the contents are never modified; any change replaces the pointer.
+	cuOverride *core.CUOverride
+
 	state          runnerMetricState
 	stateUpdatedAt time.Time
 }
@@ -452,6 +533,8 @@ type podStatusDump struct {
 	EndpointID         string     `json:"endpointID"`
 	EndpointAssignedAt *time.Time `json:"endpointAssignedAt"`
 
+	CUOverride *core.CUOverride `json:"cuOverride"`
+
 	State          runnerMetricState `json:"state"`
 	StateUpdatedAt time.Time         `json:"stateUpdatedAt"`
 }
@@ -605,6 +688,7 @@ func (s *lockedPodStatus) dump() podStatusDump {
 		VMInfo:             s.vmInfo,
 		EndpointID:         s.endpointID,
 		EndpointAssignedAt: s.endpointAssignedAt, // ok to share the pointer, because it's not updated
+		CUOverride:         s.cuOverride,         // likewise, the contents are never modified.
 		StartTime:          s.startTime,
 
 		State:          s.state,
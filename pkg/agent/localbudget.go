@@ -0,0 +1,230 @@
+package agent
+
+// Implementation of the local budget for the node, used to approve upscaling while the scheduler
+// plugin is unavailable.
+
+import (
+	"context"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+// localBudget tracks the resources that VMs on this node have been granted without the scheduler
+// plugin's approval.
+//
+// Each VM is counted as using the maximum of its current resources and any resources granted by the
+// local budget, until the grant is released once the plugin is available again.
+type localBudget struct {
+	global      *agentState
+	config      *LocalBudgetConfig
+	computeUnit api.Resources
+	nodeName    string
+	kubeClient  kubernetes.Interface
+
+	mu sync.Mutex
+	// allocatable is the node's allocatable resources, as of the last successful fetch. If nil, we
+	// haven't been able to fetch them yet, and nothing can be granted.
+	//
+	// NB: the contents are never modified; any change replaces the pointer.
+	allocatable *api.Resources
+	// grants stores the resources granted to each VM that haven't yet been released.
+	grants map[util.NamespacedName]api.Resources
+}
+
+func newLocalBudget(
+	global *agentState,
+	config *LocalBudgetConfig,
+	computeUnit api.Resources,
+	nodeName string,
+	kubeClient kubernetes.Interface,
+) *localBudget {
+	return &localBudget{
+		global:      global,
+		config:      config,
+		computeUnit: computeUnit,
+		nodeName:    nodeName,
+		kubeClient:  kubeClient,
+		mu:          sync.Mutex{},
+		allocatable: nil,
+		grants:      make(map[util.NamespacedName]api.Resources),
+	}
+}
+
+// Run periodically fetches the node's allocatable resources, until the context is canceled.
+func (b *localBudget) Run(ctx context.Context, logger *zap.Logger) error {
+	ticker := time.NewTicker(time.Second * time.Duration(b.config.RefreshNodeSeconds))
+	defer ticker.Stop()
+
+	for {
+		b.refreshNode(ctx, logger)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *localBudget) refreshNode(ctx context.Context, logger *zap.Logger) {
+	node, err := b.kubeClient.CoreV1().Nodes().Get(ctx, b.nodeName, metav1.GetOptions{})
+	if err != nil {
+		if ctx.Err() == nil {
+			logger.Error("Failed to fetch node for local budget", zap.String("node", b.nodeName), zap.Error(err))
+		}
+		return
+	}
+
+	allocatable := api.Resources{
+		VCPU: vmv1.MilliCPUFromResourceQuantity(*node.Status.Allocatable.Cpu()),
+		Mem:  api.BytesFromResourceQuantity(*node.Status.Allocatable.Memory()),
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.allocatable = &allocatable
+
+	total := b.total()
+	b.global.metrics.localBudgetTotal.WithLabelValues("cpu").Set(total.VCPU.AsFloat64())
+	b.global.metrics.localBudgetTotal.WithLabelValues("memory").Set(float64(total.Mem))
+}
+
+// total returns the total resources available to VMs on the node while in degraded mode.
+//
+// NB: b.mu must be held, and b.allocatable must not be nil.
+func (b *localBudget) total() api.Resources {
+	return api.Resources{
+		VCPU: vmv1.MilliCPU(float64(b.allocatable.VCPU) * b.config.NodeFraction),
+		Mem:  api.Bytes(float64(b.allocatable.Mem) * b.config.NodeFraction),
+	}
+}
+
+// Request asks for the VM's resources to be increased to target, returning the resources that were
+// granted, or false if the VM isn't known.
+//
+// Upscaling is granted in whole compute units, up to config.MaxUpscaleCU at a time, as long as the
+// total resources of all VMs on the node remain within the budget.
+func (b *localBudget) Request(
+	logger *zap.Logger,
+	vmName util.NamespacedName,
+	target api.Resources,
+) (api.Resources, bool) {
+	// note: fetch the VMs' resources before locking b.mu; see release() for the lock ordering.
+	using := b.global.vmResources()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	vmUsing, ok := using[vmName]
+	if !ok {
+		logger.Warn("VM missing from local budget, denying request")
+		return api.Resources{}, false
+	}
+	current := vmUsing
+	if grant, ok := b.grants[vmName]; ok {
+		current = current.Max(grant)
+	}
+
+	granted := target.Min(current).Max(vmUsing)
+	if b.allocatable != nil && target.HasFieldGreaterThan(current) {
+		var used api.Resources
+		for name, res := range using {
+			if name == vmName {
+				continue
+			}
+			if grant, ok := b.grants[name]; ok {
+				res = res.Max(grant)
+			}
+			used = used.Add(res)
+		}
+		available := b.total().SaturatingSub(used)
+
+		// number of whole compute units that fit in the remaining budget
+		remaining := available.SaturatingSub(current)
+		steps := min(
+			uint64(b.config.MaxUpscaleCU),
+			uint64(remaining.VCPU/b.computeUnit.VCPU),
+			uint64(remaining.Mem/b.computeUnit.Mem),
+			math.MaxUint16,
+		)
+
+		granted = target.Min(current.Add(b.computeUnit.Mul(uint16(steps)))).Max(vmUsing)
+	}
+
+	b.grants[vmName] = granted
+	b.global.metrics.localBudgetVMs.Set(float64(len(b.grants)))
+
+	var result string
+	if !granted.HasFieldLessThan(target) {
+		result = "approved"
+	} else if granted.HasFieldGreaterThan(current) {
+		result = "partial"
+	} else {
+		result = "denied"
+	}
+	b.global.metrics.localBudgetRequestsTotal.WithLabelValues(result).Inc()
+	logger.Info(
+		"Handled request to local budget",
+		zap.String("result", result),
+		zap.Object("target", target),
+		zap.Object("granted", granted),
+	)
+
+	return granted, true
+}
+
+// release removes any resources granted to the VM, either because the scheduler plugin is now
+// responsible for them or because the VM is gone.
+//
+// NB: This may be called while holding the agentState's lock, so b.mu must never be held while
+// acquiring that lock.
+func (b *localBudget) release(vmName util.NamespacedName) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.grants, vmName)
+	b.global.metrics.localBudgetVMs.Set(float64(len(b.grants)))
+}
+
+type localBudgetDump struct {
+	Allocatable *api.Resources         `json:"allocatable"`
+	Grants      []localBudgetGrantDump `json:"grants"`
+}
+
+type localBudgetGrantDump struct {
+	VM      util.NamespacedName `json:"vm"`
+	Granted api.Resources       `json:"granted"`
+}
+
+func (b *localBudget) dump() *localBudgetDump {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	grants := make([]localBudgetGrantDump, 0, len(b.grants))
+	for vm, res := range b.grants {
+		grants = append(grants, localBudgetGrantDump{VM: vm, Granted: res})
+	}
+	slices.SortFunc(grants, func(a, b localBudgetGrantDump) int {
+		if n := strings.Compare(a.VM.Namespace, b.VM.Namespace); n != 0 {
+			return n
+		}
+		return strings.Compare(a.VM.Name, b.VM.Name)
+	})
+
+	return &localBudgetDump{
+		Allocatable: b.allocatable, // never modified, only replaced
+		Grants:      grants,
+	}
+}
@@ -0,0 +1,186 @@
+package agent
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"go.uber.org/zap"
+
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+func TestLocalBudgetRequest(t *testing.T) {
+	cu := api.Resources{VCPU: 250, Mem: 1 << 30 /* 1 Gi */}
+
+	// With NodeFraction = 0.5, VMs on the node can use 8 CU in total.
+	allocatable := cu.Mul(16)
+
+	vmName := util.NamespacedName{Namespace: "default", Name: "vm"}
+	otherName := util.NamespacedName{Namespace: "default", Name: "other"}
+
+	type vmState struct {
+		usingCU uint16
+		// grantCU, if not nil, gives the resources previously granted to the VM by the local budget
+		grantCU *uint16
+	}
+
+	cases := []struct {
+		name        string
+		allocatable *api.Resources
+		vm          *vmState
+		other       *vmState
+		targetCU    uint16
+
+		wantOk     bool
+		wantCU     uint16
+		wantResult string
+	}{
+		{
+			name:        "approved",
+			allocatable: &allocatable,
+			vm:          &vmState{usingCU: 1, grantCU: nil},
+			other:       nil,
+			targetCU:    3,
+			wantOk:      true,
+			wantCU:      3,
+			wantResult:  "approved",
+		},
+		{
+			name:        "partial-max-upscale",
+			allocatable: &allocatable,
+			vm:          &vmState{usingCU: 1, grantCU: nil},
+			other:       nil,
+			targetCU:    6,
+			wantOk:      true,
+			wantCU:      3, // MaxUpscaleCU = 2
+			wantResult:  "partial",
+		},
+		{
+			name:        "partial-budget",
+			allocatable: &allocatable,
+			vm:          &vmState{usingCU: 1, grantCU: nil},
+			other:       &vmState{usingCU: 6, grantCU: nil},
+			targetCU:    3,
+			wantOk:      true,
+			wantCU:      2,
+			wantResult:  "partial",
+		},
+		{
+			name:        "denied",
+			allocatable: &allocatable,
+			vm:          &vmState{usingCU: 1, grantCU: nil},
+			other:       &vmState{usingCU: 7, grantCU: nil},
+			targetCU:    2,
+			wantOk:      true,
+			wantCU:      1,
+			wantResult:  "denied",
+		},
+		{
+			// The other VM is only using 2 CU, but it's already been granted 7 CU, so there's no
+			// room left.
+			name:        "denied-other-grant",
+			allocatable: &allocatable,
+			vm:          &vmState{usingCU: 1, grantCU: nil},
+			other:       &vmState{usingCU: 2, grantCU: lo.ToPtr[uint16](7)},
+			targetCU:    2,
+			wantOk:      true,
+			wantCU:      1,
+			wantResult:  "denied",
+		},
+		{
+			// Before we've fetched the node, nothing can be granted.
+			name:        "denied-no-allocatable",
+			allocatable: nil,
+			vm:          &vmState{usingCU: 1, grantCU: nil},
+			other:       nil,
+			targetCU:    2,
+			wantOk:      true,
+			wantCU:      1,
+			wantResult:  "denied",
+		},
+		{
+			// Without the node, the VM can still use what it was granted before.
+			name:        "existing-grant-no-allocatable",
+			allocatable: nil,
+			vm:          &vmState{usingCU: 1, grantCU: lo.ToPtr[uint16](3)},
+			other:       nil,
+			targetCU:    2,
+			wantOk:      true,
+			wantCU:      2,
+			wantResult:  "approved",
+		},
+		{
+			name:        "unknown-vm",
+			allocatable: &allocatable,
+			vm:          nil,
+			other:       &vmState{usingCU: 1, grantCU: nil},
+			targetCU:    2,
+			wantOk:      false,
+			wantCU:      0,
+			wantResult:  "",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			metrics, _ := makeGlobalMetrics()
+			//nolint:exhaustruct // this is a test
+			global := &agentState{
+				lock:    util.NewChanMutex(),
+				pods:    make(map[util.NamespacedName]*podState),
+				metrics: metrics,
+			}
+			budget := newLocalBudget(
+				global,
+				&LocalBudgetConfig{
+					DegradedAfterSeconds: 10,
+					NodeFraction:         0.5,
+					MaxUpscaleCU:         2,
+					RefreshNodeSeconds:   10,
+				},
+				cu,
+				"node",
+				nil,
+			)
+			budget.allocatable = c.allocatable
+
+			addVM := func(name util.NamespacedName, state *vmState) {
+				if state == nil {
+					return
+				}
+				//nolint:exhaustruct // this is a test
+				vmInfo := api.VmInfo{
+					Name:      name.Name,
+					Namespace: name.Namespace,
+					Mem:       api.VmMemInfo{SlotSize: cu.Mem}, //nolint:exhaustruct // this is a test
+				}
+				vmInfo.SetUsing(cu.Mul(state.usingCU))
+				//nolint:exhaustruct // this is a test
+				global.pods[name] = &podState{
+					podName: name,
+					status:  &lockedPodStatus{podStatus: podStatus{vmInfo: vmInfo}},
+				}
+				if state.grantCU != nil {
+					budget.grants[name] = cu.Mul(*state.grantCU)
+				}
+			}
+			addVM(vmName, c.vm)
+			addVM(otherName, c.other)
+
+			granted, ok := budget.Request(zap.NewNop(), vmName, cu.Mul(c.targetCU))
+			assert.Equal(t, c.wantOk, ok)
+			if !ok {
+				assert.Equal(t, 0, testutil.CollectAndCount(metrics.localBudgetRequestsTotal))
+				assert.NotContains(t, budget.grants, vmName)
+				return
+			}
+
+			assert.Equal(t, cu.Mul(c.wantCU), granted)
+			assert.Equal(t, 1.0, testutil.ToFloat64(metrics.localBudgetRequestsTotal.WithLabelValues(c.wantResult)))
+			assert.Equal(t, granted, budget.grants[vmName])
+		})
+	}
+}
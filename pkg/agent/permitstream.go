@@ -0,0 +1,345 @@
+package agent
+
+// Client side of the streaming gRPC transport for requests to the scheduler plugin, used instead of
+// HTTP when scheduler.grpcPort is set and the scheduler plugin supports it.
+//
+// See package permitstream for more.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/schedwatch"
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/api/permitstream"
+)
+
+// errPermitStreamUnsupported is returned by (*Runner).doStreamRequest when the scheduler plugin
+// doesn't support permit streams, and the request should be made over HTTP instead.
+var errPermitStreamUnsupported = errors.New("scheduler plugin does not support permit streams")
+
+// permitStreamConns manages the gRPC connection to the current scheduler plugin, shared by all
+// Runners' permit streams.
+type permitStreamConns struct {
+	port uint16
+
+	mu sync.Mutex
+	// current is the connection to the most recently used scheduler, or nil if there hasn't been
+	// one yet.
+	current *permitStreamConn
+}
+
+type permitStreamConn struct {
+	schedUID types.UID
+	conn     *grpc.ClientConn
+	// confirmed is true once any stream on the connection has received a response, after which
+	// failures are no longer taken to mean that the scheduler plugin doesn't support streams.
+	confirmed bool
+	// unsupported is true if the scheduler plugin doesn't support permit streams.
+	unsupported bool
+	// unavailableUntil, if in the future, is when we'll next try to open a permit stream, after
+	// the scheduler plugin was unavailable before any stream was confirmed.
+	unavailableUntil time.Time
+	// unavailableCount is the number of times the scheduler plugin has been unavailable before any
+	// stream was confirmed, used to back off retries.
+	unavailableCount int
+}
+
+const (
+	// permitStreamMinRetryWait is the initial wait before trying permit streams again, after the
+	// scheduler plugin was unavailable.
+	permitStreamMinRetryWait = 10 * time.Second
+	// permitStreamMaxRetryWait is the maximum wait before trying permit streams again, after the
+	// scheduler plugin was unavailable.
+	permitStreamMaxRetryWait = 5 * time.Minute
+)
+
+func newPermitStreamConns(port uint16) *permitStreamConns {
+	return &permitStreamConns{
+		port:    port,
+		mu:      sync.Mutex{},
+		current: nil,
+	}
+}
+
+// get returns the connection to the scheduler, or false if it doesn't support permit streams.
+func (c *permitStreamConns) get(logger *zap.Logger, sched *schedwatch.SchedulerInfo) (*grpc.ClientConn, bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil && c.current.schedUID == sched.UID {
+		usable := !c.current.unsupported && !time.Now().Before(c.current.unavailableUntil)
+		return c.current.conn, usable, nil
+	}
+
+	// The scheduler has changed. Close the old connection, if there was one -- any streams using it
+	// are already unusable.
+	if c.current != nil {
+		if err := c.current.conn.Close(); err != nil {
+			logger.Warn("Failed to close connection to previous scheduler", zap.Error(err))
+		}
+		c.current = nil
+	}
+
+	conn, err := grpc.NewClient(
+		fmt.Sprintf("%s:%d", sched.IP, c.port),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating gRPC client: %w", err)
+	}
+
+	c.current = &permitStreamConn{
+		schedUID:         sched.UID,
+		conn:             conn,
+		confirmed:        false,
+		unsupported:      false,
+		unavailableUntil: time.Time{},
+		unavailableCount: 0,
+	}
+	return conn, true, nil
+}
+
+// confirm records that the scheduler supports permit streams
+func (c *permitStreamConns) confirm(sched *schedwatch.SchedulerInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current != nil && c.current.schedUID == sched.UID {
+		c.current.confirmed = true
+	}
+}
+
+// checkUnsupported returns whether the error from a permit stream means that we should make
+// requests to the scheduler plugin over HTTP instead.
+//
+// If the scheduler plugin doesn't implement permit streams, we use HTTP from now on. Older
+// scheduler plugins don't listen on the gRPC port at all, but we can't distinguish them from a
+// scheduler that's temporarily unavailable -- so until one of our streams has been successful, we
+// fall back to HTTP when the scheduler is unavailable, and try permit streams again after an
+// exponential backoff.
+func (c *permitStreamConns) checkUnsupported(logger *zap.Logger, sched *schedwatch.SchedulerInfo, err error) bool {
+	code := status.Code(err)
+	if code != codes.Unimplemented && code != codes.Unavailable {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.current == nil || c.current.schedUID != sched.UID || c.current.confirmed {
+		return false
+	}
+
+	now := time.Now()
+	switch {
+	case code == codes.Unimplemented && !c.current.unsupported:
+		logger.Warn(
+			"Scheduler plugin does not support permit streams, falling back to HTTP",
+			zap.Object("scheduler", sched),
+			zap.Error(err),
+		)
+		c.current.unsupported = true
+	case code == codes.Unavailable && !now.Before(c.current.unavailableUntil):
+		// Only back off further if we weren't already waiting; other streams may have failed at the
+		// same time.
+		wait := permitStreamMinRetryWait
+		for i := 0; i < c.current.unavailableCount && wait < permitStreamMaxRetryWait; i++ {
+			wait *= 2
+		}
+		wait = min(wait, permitStreamMaxRetryWait)
+
+		c.current.unavailableCount += 1
+		c.current.unavailableUntil = now.Add(wait)
+		logger.Warn(
+			"Scheduler plugin permit streams are unavailable, falling back to HTTP until retry",
+			zap.Object("scheduler", sched),
+			zap.Duration("retryAfter", wait),
+			zap.Error(err),
+		)
+	}
+	return true
+}
+
+// runnerPermitStream is an open permit stream for a single Runner
+type runnerPermitStream struct {
+	sched  schedwatch.SchedulerInfo
+	stream permitstream.ClientStream
+	cancel context.CancelFunc
+
+	// seq is the Seq of the most recent request sent on the stream.
+	seq uint64
+	// responses receives each response to a request (i.e., with non-zero Seq).
+	responses chan *permitstream.Response
+	// done is closed once the stream has ended, after err is set.
+	done chan struct{}
+	err  error
+}
+
+// getPermitStream returns the Runner's permit stream to the scheduler, opening a new one if
+// necessary.
+//
+// NB: This must only be called by doStreamRequest, which is not called concurrently.
+func (r *Runner) getPermitStream(
+	ctx context.Context,
+	logger *zap.Logger,
+	sched *schedwatch.SchedulerInfo,
+	onPush func(*zap.Logger, api.PluginResponse),
+) (*runnerPermitStream, error) {
+	if s := r.permitStream; s != nil {
+		select {
+		case <-s.done:
+		default:
+			if s.sched.UID == sched.UID {
+				return s, nil
+			}
+		}
+		r.closePermitStream()
+	}
+
+	conn, ok, err := r.global.permitStreams.get(logger, sched)
+	if err != nil {
+		return nil, err
+	} else if !ok {
+		return nil, errPermitStreamUnsupported
+	}
+
+	streamCtx, cancel := context.WithCancel(ctx)
+	stream, err := permitstream.Open(streamCtx, conn)
+	if err != nil {
+		cancel()
+		if r.global.permitStreams.checkUnsupported(logger, sched, err) {
+			return nil, errPermitStreamUnsupported
+		}
+		return nil, fmt.Errorf("error opening permit stream: %w", err)
+	}
+
+	s := &runnerPermitStream{
+		sched:     *sched,
+		stream:    stream,
+		cancel:    cancel,
+		seq:       0,
+		responses: make(chan *permitstream.Response, 1),
+		done:      make(chan struct{}),
+		err:       nil,
+	}
+	r.permitStream = s
+
+	r.spawnBackgroundWorker(streamCtx, logger, "permit stream receiver", func(ctx context.Context, logger *zap.Logger) {
+		defer close(s.done)
+		for {
+			resp, err := stream.Recv()
+			if err != nil {
+				s.err = err
+				return
+			}
+
+			if resp.Seq == 0 {
+				onPush(logger, resp.Response)
+				continue
+			}
+
+			select {
+			case s.responses <- resp:
+			case <-ctx.Done():
+				s.err = ctx.Err()
+				return
+			}
+		}
+	})
+
+	return s, nil
+}
+
+// closePermitStream closes the Runner's permit stream, if there is one.
+func (r *Runner) closePermitStream() {
+	if r.permitStream != nil {
+		r.permitStream.cancel()
+		r.permitStream = nil
+	}
+}
+
+// doStreamRequest sends the request to the scheduler plugin over the Runner's permit stream, and
+// waits for the response.
+//
+// If the scheduler plugin doesn't support permit streams, this returns errPermitStreamUnsupported.
+func (r *Runner) doStreamRequest(
+	ctx context.Context,
+	logger *zap.Logger,
+	sched *schedwatch.SchedulerInfo,
+	timeout time.Duration,
+	req api.AgentRequest,
+	onPush func(*zap.Logger, api.PluginResponse),
+) (*api.PluginResponse, error) {
+	s, err := r.getPermitStream(ctx, logger, sched, onPush)
+	if err != nil {
+		if !errors.Is(err, errPermitStreamUnsupported) {
+			description := fmt.Sprintf("[error opening stream: %s]", status.Code(err))
+			r.global.metrics.schedulerRequests.WithLabelValues(description).Inc()
+		}
+		return nil, err
+	}
+
+	// streamFailed handles an error from the stream, closing it so that the next request opens a
+	// new one.
+	streamFailed := func(err error) error {
+		r.closePermitStream()
+		if r.global.permitStreams.checkUnsupported(logger, sched, err) {
+			return errPermitStreamUnsupported
+		}
+		r.global.metrics.schedulerRequests.WithLabelValues(fmt.Sprintf("grpc:%s", status.Code(err))).Inc()
+		return fmt.Errorf("permit stream failed: %w", err)
+	}
+
+	s.seq += 1
+	msg := permitstream.Request{Seq: s.seq, Request: req}
+
+	logger.Debug("Sending request to scheduler over permit stream", zap.Uint64("seq", msg.Seq), zap.Any("request", req))
+
+	if err := s.stream.Send(&msg); err != nil {
+		// If the stream has been closed, Send returns io.EOF and the actual error is returned by
+		// Recv.
+		if errors.Is(err, io.EOF) {
+			<-s.done
+			err = s.err
+		}
+		return nil, streamFailed(err)
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case resp := <-s.responses:
+		if resp.Seq != msg.Seq {
+			r.closePermitStream()
+			r.global.metrics.schedulerRequests.WithLabelValues("[bad response seq]").Inc()
+			return nil, fmt.Errorf("received response with seq %d, expected %d", resp.Seq, msg.Seq)
+		}
+		r.global.permitStreams.confirm(sched)
+		r.global.metrics.schedulerRequests.WithLabelValues(fmt.Sprintf("grpc:%s", codes.OK)).Inc()
+		return &resp.Response, nil
+	case <-s.done:
+		return nil, streamFailed(s.err)
+	case <-timer.C:
+		// We don't know if the scheduler will still respond, so we can't keep using the stream.
+		r.closePermitStream()
+		r.global.metrics.schedulerRequests.WithLabelValues("[timed out]").Inc()
+		return nil, fmt.Errorf("timed out after %s waiting for response on permit stream", timeout)
+	case <-ctx.Done():
+		r.closePermitStream()
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,298 @@
+package agent
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/schedwatch"
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/api/permitstream"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+// permitStreamServerFunc is a permitstream.Server using the function to handle each stream
+type permitStreamServerFunc func(permitstream.ServerStream) error
+
+func (f permitStreamServerFunc) Stream(stream permitstream.ServerStream) error {
+	return f(stream)
+}
+
+// echoPermits responds to each request with a permit equal to the requested resources, after first
+// pushing a permit with the resources from the request's LastPermit, if there is one.
+func echoPermits(stream permitstream.ServerStream) error {
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if req.Request.LastPermit != nil {
+			//nolint:exhaustruct // this is a test
+			if err := stream.Send(&permitstream.Response{
+				Seq:      0,
+				Response: api.PluginResponse{Permit: *req.Request.LastPermit},
+			}); err != nil {
+				return err
+			}
+		}
+		//nolint:exhaustruct // this is a test
+		if err := stream.Send(&permitstream.Response{
+			Seq:      req.Seq,
+			Response: api.PluginResponse{Permit: req.Request.Resources},
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+// startPermitStreamServer starts a gRPC server on localhost, returning it and its port. If srv is
+// nil, the server doesn't implement permit streams.
+func startPermitStreamServer(t *testing.T, srv permitstream.Server) (*grpc.Server, uint16) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := grpc.NewServer()
+	if srv != nil {
+		permitstream.RegisterServer(server, srv)
+	}
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	return server, uint16(listener.Addr().(*net.TCPAddr).Port)
+}
+
+// unusedPort returns a port on localhost that nothing is listening on.
+func unusedPort(t *testing.T) uint16 {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := uint16(listener.Addr().(*net.TCPAddr).Port)
+	require.NoError(t, listener.Close())
+	return port
+}
+
+type permitStreamTest struct {
+	runner  *Runner
+	metrics GlobalMetrics
+	sched   schedwatch.SchedulerInfo
+	pushed  chan api.PluginResponse
+}
+
+func newPermitStreamTest(t *testing.T, port uint16) *permitStreamTest {
+	metrics, _ := makeGlobalMetrics()
+
+	//nolint:exhaustruct // this is a test
+	global := &agentState{
+		metrics:       metrics,
+		permitStreams: newPermitStreamConns(port),
+	}
+	t.Cleanup(func() {
+		if c := global.permitStreams.current; c != nil {
+			_ = c.conn.Close()
+		}
+	})
+
+	//nolint:exhaustruct // this is a test
+	runner := &Runner{
+		global:          global,
+		podName:         util.NamespacedName{Namespace: "default", Name: "pod"},
+		backgroundPanic: make(chan error, 1),
+	}
+	t.Cleanup(runner.closePermitStream)
+
+	return &permitStreamTest{
+		runner:  runner,
+		metrics: metrics,
+		//nolint:exhaustruct // this is a test
+		sched: schedwatch.SchedulerInfo{
+			UID: "scheduler",
+			IP:  "127.0.0.1",
+		},
+		pushed: make(chan api.PluginResponse, 10),
+	}
+}
+
+func (p *permitStreamTest) request(timeout time.Duration, resources api.Resources, lastPermit *api.Resources) (*api.PluginResponse, error) {
+	req := api.AgentRequest{
+		ProtoVersion: PluginProtocolVersion,
+		Pod:          p.runner.podName,
+		ComputeUnit:  api.Resources{VCPU: 250, Mem: 1 << 30},
+		Resources:    resources,
+		LastPermit:   lastPermit,
+		Metrics:      nil,
+	}
+	onPush := func(_ *zap.Logger, resp api.PluginResponse) {
+		p.pushed <- resp
+	}
+	return p.runner.doStreamRequest(context.Background(), zap.NewNop(), &p.sched, timeout, req, onPush)
+}
+
+func (p *permitStreamTest) requestCount(description string) float64 {
+	return testutil.ToFloat64(p.metrics.schedulerRequests.WithLabelValues(description))
+}
+
+func TestPermitStreamRequest(t *testing.T) {
+	_, port := startPermitStreamServer(t, permitStreamServerFunc(echoPermits))
+	p := newPermitStreamTest(t, port)
+
+	resources := api.Resources{VCPU: 500, Mem: 2 << 30}
+	for i := 1; i <= 2; i++ {
+		resp, err := p.request(time.Second, resources, nil)
+		require.NoError(t, err)
+		assert.Equal(t, resources, resp.Permit)
+		assert.Equal(t, uint64(i), p.runner.permitStream.seq)
+	}
+	assert.Equal(t, 2.0, p.requestCount("grpc:OK"))
+	assert.True(t, p.runner.global.permitStreams.current.confirmed)
+}
+
+func TestPermitStreamPushedPermits(t *testing.T) {
+	_, port := startPermitStreamServer(t, permitStreamServerFunc(echoPermits))
+	p := newPermitStreamTest(t, port)
+
+	lastPermit := api.Resources{VCPU: 250, Mem: 1 << 30}
+	resources := api.Resources{VCPU: 500, Mem: 2 << 30}
+	resp, err := p.request(time.Second, resources, &lastPermit)
+	require.NoError(t, err)
+	assert.Equal(t, resources, resp.Permit)
+
+	// The pushed permit was sent before the response, so it should already have been handled.
+	select {
+	case pushed := <-p.pushed:
+		assert.Equal(t, lastPermit, pushed.Permit)
+	default:
+		t.Fatal("pushed permit was not passed to onPush")
+	}
+}
+
+func TestPermitStreamBadSeq(t *testing.T) {
+	_, port := startPermitStreamServer(t, permitStreamServerFunc(func(stream permitstream.ServerStream) error {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				return err
+			}
+			//nolint:exhaustruct // this is a test
+			if err := stream.Send(&permitstream.Response{
+				Seq:      req.Seq + 1,
+				Response: api.PluginResponse{Permit: req.Request.Resources},
+			}); err != nil {
+				return err
+			}
+		}
+	}))
+	p := newPermitStreamTest(t, port)
+
+	_, err := p.request(time.Second, api.Resources{VCPU: 250, Mem: 1 << 30}, nil)
+	assert.EqualError(t, err, "received response with seq 2, expected 1")
+	assert.Nil(t, p.runner.permitStream)
+	assert.Equal(t, 1.0, p.requestCount("[bad response seq]"))
+}
+
+func TestPermitStreamTimeout(t *testing.T) {
+	streamEnded := make(chan error, 1)
+	_, port := startPermitStreamServer(t, permitStreamServerFunc(func(stream permitstream.ServerStream) error {
+		// Receive requests, but never respond.
+		for {
+			if _, err := stream.Recv(); err != nil {
+				streamEnded <- err
+				return err
+			}
+		}
+	}))
+	p := newPermitStreamTest(t, port)
+
+	_, err := p.request(100*time.Millisecond, api.Resources{VCPU: 250, Mem: 1 << 30}, nil)
+	assert.EqualError(t, err, "timed out after 100ms waiting for response on permit stream")
+	assert.Nil(t, p.runner.permitStream)
+	assert.Equal(t, 1.0, p.requestCount("[timed out]"))
+
+	// The stream should be closed, so that a late response isn't taken as the response to the next
+	// request.
+	select {
+	case <-streamEnded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for the stream to be closed")
+	}
+}
+
+func TestPermitStreamUnimplemented(t *testing.T) {
+	_, port := startPermitStreamServer(t, nil)
+	p := newPermitStreamTest(t, port)
+
+	resources := api.Resources{VCPU: 250, Mem: 1 << 30}
+	_, err := p.request(time.Second, resources, nil)
+	assert.ErrorIs(t, err, errPermitStreamUnsupported)
+	assert.True(t, p.runner.global.permitStreams.current.unsupported)
+
+	// From now on, requests should go straight to HTTP, without trying the stream.
+	_, err = p.request(time.Second, resources, nil)
+	assert.ErrorIs(t, err, errPermitStreamUnsupported)
+	assert.Nil(t, p.runner.permitStream)
+}
+
+func TestPermitStreamUnavailableBackoff(t *testing.T) {
+	p := newPermitStreamTest(t, unusedPort(t))
+	conns := p.runner.global.permitStreams
+
+	resources := api.Resources{VCPU: 250, Mem: 1 << 30}
+
+	start := time.Now()
+	_, err := p.request(time.Second, resources, nil)
+	assert.ErrorIs(t, err, errPermitStreamUnsupported)
+	assert.False(t, conns.current.unsupported)
+	assert.Equal(t, 1, conns.current.unavailableCount)
+	assert.WithinRange(t, conns.current.unavailableUntil, start.Add(permitStreamMinRetryWait), time.Now().Add(permitStreamMinRetryWait))
+
+	// Until the backoff has passed, requests go straight to HTTP without backing off further.
+	_, err = p.request(time.Second, resources, nil)
+	assert.ErrorIs(t, err, errPermitStreamUnsupported)
+	assert.Equal(t, 1, conns.current.unavailableCount)
+
+	// After the backoff, we try the stream again, and wait twice as long if it's still unavailable.
+	conns.current.unavailableUntil = time.Time{}
+	start = time.Now()
+	_, err = p.request(time.Second, resources, nil)
+	assert.ErrorIs(t, err, errPermitStreamUnsupported)
+	assert.Equal(t, 2, conns.current.unavailableCount)
+	assert.WithinRange(t, conns.current.unavailableUntil, start.Add(2*permitStreamMinRetryWait), time.Now().Add(2*permitStreamMinRetryWait))
+
+	// ... up to the maximum wait.
+	conns.current.unavailableCount = 100
+	conns.current.unavailableUntil = time.Time{}
+	start = time.Now()
+	_, err = p.request(time.Second, resources, nil)
+	assert.ErrorIs(t, err, errPermitStreamUnsupported)
+	assert.WithinRange(t, conns.current.unavailableUntil, start.Add(permitStreamMaxRetryWait), time.Now().Add(permitStreamMaxRetryWait))
+
+	// None of these should be counted as failed requests, because they'll be retried over HTTP.
+	assert.Equal(t, 0, testutil.CollectAndCount(p.metrics.schedulerRequests))
+}
+
+func TestPermitStreamUnavailableAfterConfirmed(t *testing.T) {
+	server, port := startPermitStreamServer(t, permitStreamServerFunc(echoPermits))
+	p := newPermitStreamTest(t, port)
+
+	resources := api.Resources{VCPU: 250, Mem: 1 << 30}
+	_, err := p.request(time.Second, resources, nil)
+	require.NoError(t, err)
+
+	// Once the scheduler plugin has been seen to support permit streams, it being unavailable is
+	// just an error, not a reason to fall back to HTTP.
+	server.Stop()
+	_, err = p.request(time.Second, resources, nil)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errPermitStreamUnsupported)
+	assert.Nil(t, p.runner.permitStream)
+
+	_, err = p.request(time.Second, resources, nil)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, errPermitStreamUnsupported)
+	assert.Zero(t, p.runner.global.permitStreams.current.unavailableCount)
+}
@@ -9,6 +9,7 @@ import (
 	"github.com/samber/lo"
 
 	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
 	"github.com/neondatabase/autoscaling/pkg/agent/core/revsource"
 	"github.com/neondatabase/autoscaling/pkg/agent/scalingevents"
 	"github.com/neondatabase/autoscaling/pkg/util"
@@ -18,6 +19,7 @@ type GlobalMetrics struct {
 	schedulerRequests        *prometheus.CounterVec
 	schedulerRequestedChange resourceChangePair
 	schedulerApprovedChange  resourceChangePair
+	schedulerPushedPermits   *prometheus.CounterVec
 
 	scalingFullDeniesTotal       *prometheus.CounterVec
 	scalingPartialApprovalsTotal *prometheus.CounterVec
@@ -36,6 +38,10 @@ type GlobalMetrics struct {
 	runnerRestarts     prometheus.Counter
 	runnerNextActions  prometheus.Counter
 
+	localBudgetTotal         *prometheus.GaugeVec
+	localBudgetVMs           prometheus.Gauge
+	localBudgetRequestsTotal *prometheus.CounterVec
+
 	scalingLatency prometheus.HistogramVec
 	pluginLatency  prometheus.HistogramVec
 	monitorLatency prometheus.HistogramVec
@@ -100,10 +106,17 @@ func makeGlobalMetrics() (GlobalMetrics, *prometheus.Registry) {
 		schedulerRequests: util.RegisterMetric(reg, prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "autoscaling_agent_scheduler_plugin_requests_total",
-				Help: "Number of attempted HTTP requests to the scheduler plugin by autoscaler-agents",
+				Help: "Number of attempted requests to the scheduler plugin by autoscaler-agents, over HTTP or a permit stream",
 			},
 			[]string{"code"},
 		)),
+		schedulerPushedPermits: util.RegisterMetric(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "autoscaling_agent_scheduler_plugin_pushed_permits_total",
+				Help: "Number of permits pushed by the scheduler plugin over permit streams, by whether they were accepted",
+			},
+			[]string{"result"},
+		)),
 		schedulerRequestedChange: resourceChangePair{
 			cpu: util.RegisterMetric(reg, prometheus.NewCounterVec(
 				prometheus.CounterOpts{
@@ -260,6 +273,28 @@ func makeGlobalMetrics() (GlobalMetrics, *prometheus.Registry) {
 			},
 		)),
 
+		// ---- LOCAL BUDGET ----
+		localBudgetTotal: util.RegisterMetric(reg, prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "autoscaling_agent_local_budget_total",
+				Help: "Total resources available to VMs on the node while the scheduler plugin is unavailable",
+			},
+			[]string{"resource"},
+		)),
+		localBudgetVMs: util.RegisterMetric(reg, prometheus.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "autoscaling_agent_local_budget_vms",
+				Help: "Number of VMs currently in degraded mode, using resources granted by the local budget",
+			},
+		)),
+		localBudgetRequestsTotal: util.RegisterMetric(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "autoscaling_agent_local_budget_requests_total",
+				Help: "Number of requests to the local budget while the scheduler plugin is unavailable",
+			},
+			[]string{"result"},
+		)),
+
 		scalingLatency: *util.RegisterMetric(reg, prometheus.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "autoscaling_agent_scaling_latency_seconds",
@@ -358,6 +393,7 @@ type PerVMMetrics struct {
 	memory       *prometheus.GaugeVec
 	restartCount *prometheus.GaugeVec
 	desiredCU    *prometheus.GaugeVec
+	cpuSeconds   *prometheus.GaugeVec
 	extraIP      *prometheus.GaugeVec
 }
 
@@ -424,7 +460,16 @@ func makePerVMMetrics() (*PerVMMetrics, *prometheus.Registry) {
 				Help: "Amount of Compute Units desired for a VM: the total, and the components for cpu, memory, and LFC",
 			},
 			makeLabels(
-				"component", // desired CU component: total, cpu, mem, lfc
+				"component", // desired CU component: total, cpu, mem, lfc, cpu_forecast, scheduled, psi, override
+			),
+		)),
+		cpuSeconds: util.RegisterMetric(reg, prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "autoscaling_vm_cpu_seconds",
+				Help: "Cumulative CPU time across all of a VM's CPUs, as last reported by the VM: the total, and the time in iowait and steal",
+			},
+			makeLabels(
+				"mode", // cpu mode: total, iowait, steal
 			),
 		)),
 		extraIP: util.RegisterMetric(reg, prometheus.NewGaugeVec(
@@ -472,6 +517,10 @@ func (m *PerVMMetrics) deleteActive(vm *vmv1.VirtualMachine) {
 		"vm_namespace": vm.Namespace,
 		"vm_name":      vm.Name,
 	})
+	m.cpuSeconds.DeletePartialMatch(prometheus.Labels{
+		"vm_namespace": vm.Namespace,
+		"vm_name":      vm.Name,
+	})
 }
 
 // vmMetric is a data object that represents a single metric
@@ -506,6 +555,10 @@ func (m *PerVMMetrics) updateDesiredCU(
 		{"cpu", parts.CPU},
 		{"mem", parts.Mem},
 		{"lfc", parts.LFC},
+		{"cpu_forecast", parts.CPUForecast},
+		{"scheduled", parts.Scheduled},
+		{"psi", parts.PSI},
+		{"override", parts.Override},
 	}
 
 	for _, p := range pairs {
@@ -523,3 +576,39 @@ func (m *PerVMMetrics) updateDesiredCU(
 		}
 	}
 }
+
+// updateCPUSeconds sets the per-mode CPU time for the VM, or removes it if the VM didn't report any
+func (m *PerVMMetrics) updateCPUSeconds(vm util.NamespacedName, cpu *core.CPUModeSeconds) {
+	m.activeMu.Lock()
+	defer m.activeMu.Unlock()
+
+	// Same as in updateDesiredCU: don't leak metrics for VMs that are gone.
+	info, ok := m.activeVMs[vm]
+	if !ok {
+		return
+	}
+
+	labels := prometheus.Labels{
+		"vm_namespace": vm.Namespace,
+		"vm_name":      vm.Name,
+		"endpoint_id":  info.endpointID,
+		"project_id":   info.projectID,
+	}
+	if cpu == nil {
+		m.cpuSeconds.DeletePartialMatch(labels)
+		return
+	}
+
+	pairs := []struct {
+		mode  string
+		value float64
+	}{
+		{"total", cpu.Total},
+		{"iowait", cpu.IOWait},
+		{"steal", cpu.Steal},
+	}
+	for _, p := range pairs {
+		labels["mode"] = p.mode
+		m.cpuSeconds.With(labels).Set(p.value)
+	}
+}
@@ -0,0 +1,221 @@
+package recording
+
+// Recording of every input to a VM's core.State, so that its decisions can be replayed later.
+//
+// The executor calls (*Recorder).Record() while holding its lock, so the order of events in the
+// recording exactly matches the order in which they were applied to the core.State.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/natefinch/lumberjack.v2"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+type Config struct {
+	// Directory is the directory to write recordings into. Each VM's recording is written to
+	// "<namespace>_<name>.jsonl" within this directory.
+	Directory string `json:"directory"`
+	// MaxFileSizeMB is the size, in megabytes, at which the recording file is rotated.
+	MaxFileSizeMB uint `json:"maxFileSizeMB"`
+	// MaxBackups is the maximum number of rotated files to keep for each VM. Zero means that all
+	// rotated files are kept.
+	//
+	// This must currently be zero: EventInit is only recorded when the Runner starts, so replaying
+	// any part of a recording requires all of the files since then.
+	MaxBackups uint `json:"maxBackups"`
+	// VMs are the VMs whose inputs should be recorded.
+	VMs []util.NamespacedName `json:"vms"`
+}
+
+// ShouldRecord returns whether the VM is one of the ones listed in the config
+func (c *Config) ShouldRecord(vm util.NamespacedName) bool {
+	if c == nil {
+		return false
+	}
+	for _, v := range c.VMs {
+		if v == vm {
+			return true
+		}
+	}
+	return false
+}
+
+type EventKind string
+
+const (
+	// EventInit is recorded when the core.State is created, with VM and Config set.
+	//
+	// The core.State uses a revsource.RevisionSource starting from VM.CurrentRevision.
+	EventInit EventKind = "init"
+	// EventNextActions is recorded each time NextActions is called, with Actions set to the result.
+	EventNextActions EventKind = "nextActions"
+
+	EventSystemMetrics EventKind = "systemMetrics"
+	EventLFCMetrics    EventKind = "lfcMetrics"
+	EventUpdatedVM     EventKind = "updatedVM"
+	// EventCUOverride is recorded with CUOverride set to the new override, or nil if it was cleared.
+	EventCUOverride EventKind = "cuOverride"
+
+	EventMonitorReset            EventKind = "monitorReset"
+	EventMonitorActive           EventKind = "monitorActive"
+	EventMonitorUpscaleRequested EventKind = "monitorUpscaleRequested"
+
+	EventPluginStarting EventKind = "pluginStarting"
+	EventPluginSuccess  EventKind = "pluginSuccess"
+	EventPluginFailed   EventKind = "pluginFailed"
+	// EventPluginLocalPermit is recorded with LocalPermit set, after EventPluginFailed if the
+	// resources were granted by the local budget instead.
+	EventPluginLocalPermit EventKind = "pluginLocalPermit"
+	// EventPluginPermitPushed is recorded with PluginResponse set, when the scheduler plugin pushes
+	// an updated permit over a permit stream.
+	EventPluginPermitPushed EventKind = "pluginPermitPushed"
+
+	EventMonitorDownscaleStarting EventKind = "monitorDownscaleStarting"
+	EventMonitorDownscaleAllowed  EventKind = "monitorDownscaleAllowed"
+	EventMonitorDownscaleDenied   EventKind = "monitorDownscaleDenied"
+	EventMonitorDownscaleFailed   EventKind = "monitorDownscaleFailed"
+
+	EventMonitorUpscaleStarting EventKind = "monitorUpscaleStarting"
+	EventMonitorUpscaleSuccess  EventKind = "monitorUpscaleSuccess"
+	EventMonitorUpscaleFailed   EventKind = "monitorUpscaleFailed"
+
+	EventNeonVMStarting EventKind = "neonvmStarting"
+	EventNeonVMSuccess  EventKind = "neonvmSuccess"
+	EventNeonVMFailed   EventKind = "neonvmFailed"
+)
+
+// Event is a single input to the core.State
+//
+// Which of the optional fields are set depends on the Kind.
+type Event struct {
+	Kind EventKind `json:"kind"`
+	// At is the time passed to the core.State for this event. For events where the core.State
+	// doesn't take a timestamp, it's the time that the event happened.
+	At time.Time `json:"at"`
+
+	// VM is set for EventInit and EventUpdatedVM
+	VM *api.VmInfo `json:"vm,omitempty"`
+	// Config is set for EventInit
+	Config *core.Config `json:"config,omitempty"`
+	// Actions is set for EventNextActions
+	Actions *core.ActionSet `json:"actions,omitempty"`
+
+	SystemMetrics *core.SystemMetrics `json:"systemMetrics,omitempty"`
+	LFCMetrics    *core.LFCMetrics    `json:"lfcMetrics,omitempty"`
+	CUOverride    *core.CUOverride    `json:"cuOverride,omitempty"`
+	// Active is set for EventMonitorActive
+	Active *bool `json:"active,omitempty"`
+	// Requested is set for EventMonitorUpscaleRequested
+	Requested *api.MoreResources `json:"requested,omitempty"`
+
+	// Target is set for each of the "*Starting" events
+	Target *api.Resources `json:"target,omitempty"`
+	// Revision is set for EventPluginSuccess, EventMonitorDownscaleAllowed, and
+	// EventMonitorDownscaleDenied
+	Revision *vmv1.RevisionWithTime `json:"revision,omitempty"`
+	// PluginResponse is set for EventPluginSuccess and EventPluginPermitPushed
+	PluginResponse *api.PluginResponse `json:"pluginResponse,omitempty"`
+	// LocalPermit is set for EventPluginLocalPermit
+	LocalPermit *api.Resources `json:"localPermit,omitempty"`
+}
+
+// Recorder writes Events for a single VM to a rotating file
+//
+// A nil *Recorder is valid, and discards all events.
+type Recorder struct {
+	mu sync.Mutex
+
+	logger *zap.Logger
+	file   io.WriteCloser
+	// failed is set after the first failure to write, so that we only log it once.
+	failed bool
+}
+
+// NewRecorder creates a new Recorder writing to the VM's file in the configured directory
+func NewRecorder(logger *zap.Logger, config Config, vm util.NamespacedName) (*Recorder, error) {
+	if err := os.MkdirAll(config.Directory, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create directory: %w", err)
+	}
+
+	return &Recorder{
+		mu:     sync.Mutex{},
+		logger: logger,
+		file: &lumberjack.Logger{
+			Filename:   filepath.Join(config.Directory, fmt.Sprintf("%s_%s.jsonl", vm.Namespace, vm.Name)),
+			MaxSize:    int(config.MaxFileSizeMB),
+			MaxAge:     0, // don't remove old files based on age; only MaxBackups.
+			MaxBackups: int(config.MaxBackups),
+			LocalTime:  false,
+			Compress:   false,
+		},
+		failed: false,
+	}, nil
+}
+
+// Record writes the event to the recording
+//
+// Failures to write are logged, but otherwise ignored; recording is best-effort and should not
+// interfere with scaling.
+func (r *Recorder) Record(event Event) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	data, err := json.Marshal(event)
+	if err == nil {
+		_, err = r.file.Write(append(data, '\n'))
+	}
+	if err != nil && !r.failed {
+		r.failed = true
+		r.logger.Error("Failed to write event to recording, further errors will not be logged", zap.Error(err))
+	}
+}
+
+// Close closes the underlying file
+func (r *Recorder) Close() error {
+	if r == nil {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.file.Close()
+}
+
+// ReadEvents reads all of the events from a recording
+func ReadEvents(reader io.Reader) ([]Event, error) {
+	var events []Event
+
+	scanner := bufio.NewScanner(reader)
+	// Events including the VmInfo and Config can be larger than the default 64KiB limit.
+	scanner.Buffer(nil, 4<<20)
+	for line := 1; scanner.Scan(); line++ {
+		var event Event
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		events = append(events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return events, nil
+}
@@ -0,0 +1,241 @@
+package recording
+
+// Replaying a recording through a fresh core.State
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	"github.com/neondatabase/autoscaling/pkg/agent/core/revsource"
+)
+
+// ReplayResult is the output of Replay
+type ReplayResult struct {
+	// Actions are the ActionSets produced by the replayed core.State, in order. They are identical
+	// to the ones in the recording.
+	Actions []ReplayedActions
+	// Skipped is the number of events at the start of the recording that were skipped because
+	// they came before the first EventInit.
+	Skipped int
+}
+
+type ReplayedActions struct {
+	At      time.Time      `json:"at"`
+	Actions core.ActionSet `json:"actions"`
+}
+
+// MismatchError is returned by Replay when the replayed core.State produces a different ActionSet
+// than what was recorded
+type MismatchError struct {
+	// Index is the index of the EventNextActions in the recording
+	Index    int
+	At       time.Time
+	Recorded core.ActionSet
+	Replayed core.ActionSet
+}
+
+func (e *MismatchError) Error() string {
+	recorded, _ := json.Marshal(e.Recorded)
+	replayed, _ := json.Marshal(e.Replayed)
+	return fmt.Sprintf(
+		"event %d at %s: recorded actions %s do not match replayed actions %s",
+		e.Index, e.At.Format(time.RFC3339Nano), recorded, replayed,
+	)
+}
+
+// Replay feeds the events into a new core.State, checking that each call to NextActions produces
+// the same ActionSet as was recorded.
+//
+// Each EventInit starts over with a new core.State, because the agent creates a new one each time
+// the per-VM Runner restarts. Events before the first EventInit (e.g., because only the later
+// rotated files were given) are skipped, because there is no state to apply them to.
+//
+// goalPolicies should be the same custom GoalPolicy implementations that the agent was using.
+//
+// Replay is deterministic. However, the agent's durations are measured with the monotonic clock,
+// and the recording only has wall-clock times, so a recording that spans a wall clock adjustment
+// may not be reproduced exactly.
+func Replay(events []Event, goalPolicies map[string]core.GoalPolicy) (*ReplayResult, error) {
+	result := &ReplayResult{Actions: nil, Skipped: 0}
+
+	var state *core.State
+
+	for i, event := range events {
+		if event.Kind == EventInit {
+			if event.VM == nil || event.Config == nil {
+				return nil, fmt.Errorf("event %d: %q event missing VM or Config", i, event.Kind)
+			}
+
+			var initialRevision int64
+			if event.VM.CurrentRevision != nil {
+				initialRevision = event.VM.CurrentRevision.Value
+			}
+
+			config := *event.Config
+			config.GoalPolicies = goalPolicies
+			config.Log = core.LogConfig{Info: nil, Warn: nil}
+			config.RevisionSource = revsource.NewRevisionSource(initialRevision, nil)
+			config.ObservabilityCallbacks = core.ObservabilityCallbacks{
+				PluginLatency:       nil,
+				MonitorLatency:      nil,
+				NeonVMLatency:       nil,
+				ActualScaling:       nil,
+				HypotheticalScaling: nil,
+				DeniedScaling:       nil,
+			}
+
+			state = core.NewState(*event.VM, config)
+			continue
+		}
+
+		if state == nil {
+			result.Skipped++
+			continue
+		}
+
+		if err := applyEvent(state, event); err != nil {
+			return nil, fmt.Errorf("event %d: %w", i, err)
+		}
+
+		if event.Kind == EventNextActions {
+			actions := state.NextActions(event.At)
+			if !sameActions(*event.Actions, actions) {
+				return nil, &MismatchError{
+					Index:    i,
+					At:       event.At,
+					Recorded: *event.Actions,
+					Replayed: actions,
+				}
+			}
+			result.Actions = append(result.Actions, ReplayedActions{At: event.At, Actions: actions})
+		}
+	}
+
+	return result, nil
+}
+
+// sameActions returns whether the two ActionSets are equal, comparing by their JSON encoding so
+// that a recorded ActionSet can be compared with one that hasn't been through JSON.
+func sameActions(recorded, replayed core.ActionSet) bool {
+	r1, err1 := json.Marshal(recorded)
+	r2, err2 := json.Marshal(replayed)
+	return err1 == nil && err2 == nil && bytes.Equal(r1, r2)
+}
+
+// applyEvent applies any non-init event to the state. EventNextActions is only checked for
+// validity; the caller is responsible for calling NextActions.
+func applyEvent(state *core.State, event Event) error {
+	missing := func(field string) error {
+		return fmt.Errorf("%q event missing %s", event.Kind, field)
+	}
+
+	switch event.Kind {
+	case EventNextActions:
+		if event.Actions == nil {
+			return missing("Actions")
+		}
+
+	case EventSystemMetrics:
+		if event.SystemMetrics == nil {
+			return missing("SystemMetrics")
+		}
+		state.UpdateSystemMetrics(event.At, *event.SystemMetrics)
+	case EventLFCMetrics:
+		if event.LFCMetrics == nil {
+			return missing("LFCMetrics")
+		}
+		state.UpdateLFCMetrics(*event.LFCMetrics)
+	case EventUpdatedVM:
+		if event.VM == nil {
+			return missing("VM")
+		}
+		state.UpdatedVM(*event.VM)
+	case EventCUOverride:
+		state.UpdateCUOverride(event.CUOverride)
+
+	case EventMonitorReset:
+		state.Monitor().Reset()
+	case EventMonitorActive:
+		if event.Active == nil {
+			return missing("Active")
+		}
+		state.Monitor().Active(*event.Active)
+	case EventMonitorUpscaleRequested:
+		if event.Requested == nil {
+			return missing("Requested")
+		}
+		state.Monitor().UpscaleRequested(event.At, *event.Requested)
+
+	case EventPluginStarting:
+		if event.Target == nil {
+			return missing("Target")
+		}
+		state.Plugin().StartingRequest(event.At, *event.Target)
+	case EventPluginSuccess:
+		if event.Revision == nil || event.PluginResponse == nil {
+			return missing("Revision or PluginResponse")
+		}
+		// Response validation errors are part of the state's normal behavior, and would also
+		// have happened when the events were recorded.
+		_ = state.Plugin().RequestSuccessful(event.At, *event.Revision, *event.PluginResponse)
+	case EventPluginFailed:
+		state.Plugin().RequestFailed(event.At)
+	case EventPluginLocalPermit:
+		if event.LocalPermit == nil {
+			return missing("LocalPermit")
+		}
+		// As with EventPluginSuccess, validation errors would also have happened when recorded.
+		_ = state.Plugin().LocalPermitGranted(event.At, *event.LocalPermit)
+	case EventPluginPermitPushed:
+		if event.PluginResponse == nil {
+			return missing("PluginResponse")
+		}
+		_ = state.Plugin().PermitPushed(event.At, event.PluginResponse.Permit)
+
+	case EventMonitorDownscaleStarting:
+		if event.Target == nil {
+			return missing("Target")
+		}
+		state.Monitor().StartingDownscaleRequest(event.At, *event.Target)
+	case EventMonitorDownscaleAllowed:
+		if event.Revision == nil {
+			return missing("Revision")
+		}
+		state.Monitor().DownscaleRequestAllowed(event.At, *event.Revision)
+	case EventMonitorDownscaleDenied:
+		if event.Revision == nil {
+			return missing("Revision")
+		}
+		state.Monitor().DownscaleRequestDenied(event.At, *event.Revision)
+	case EventMonitorDownscaleFailed:
+		state.Monitor().DownscaleRequestFailed(event.At)
+
+	case EventMonitorUpscaleStarting:
+		if event.Target == nil {
+			return missing("Target")
+		}
+		state.Monitor().StartingUpscaleRequest(event.At, *event.Target)
+	case EventMonitorUpscaleSuccess:
+		state.Monitor().UpscaleRequestSuccessful(event.At)
+	case EventMonitorUpscaleFailed:
+		state.Monitor().UpscaleRequestFailed(event.At)
+
+	case EventNeonVMStarting:
+		if event.Target == nil {
+			return missing("Target")
+		}
+		state.NeonVM().StartingRequest(event.At, *event.Target)
+	case EventNeonVMSuccess:
+		state.NeonVM().RequestSuccessful(event.At)
+	case EventNeonVMFailed:
+		state.NeonVM().RequestFailed(event.At)
+
+	default:
+		return fmt.Errorf("unknown event kind %q", event.Kind)
+	}
+
+	return nil
+}
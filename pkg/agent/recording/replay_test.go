@@ -0,0 +1,232 @@
+package recording_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	"github.com/neondatabase/autoscaling/pkg/agent/core/revsource"
+	helpers "github.com/neondatabase/autoscaling/pkg/agent/core/testhelpers"
+	"github.com/neondatabase/autoscaling/pkg/agent/recording"
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+var computeUnit = api.Resources{VCPU: 250, Mem: 1 << 30 /* 1 Gi */}
+
+//nolint:exhaustruct // this is a test
+var coreConfig = core.Config{
+	ComputeUnit: computeUnit,
+	DefaultScalingConfig: api.ScalingConfig{
+		LoadAverageFractionTarget:        lo.ToPtr(0.5),
+		MemoryUsageFractionTarget:        lo.ToPtr(0.5),
+		MemoryTotalFractionTarget:        lo.ToPtr(0.9),
+		EnableLFCMetrics:                 lo.ToPtr(false),
+		LFCUseLargestWindow:              lo.ToPtr(false),
+		LFCToMemoryRatio:                 lo.ToPtr(0.75),
+		LFCWindowSizeMinutes:             lo.ToPtr(5),
+		LFCMinWaitBeforeDownscaleMinutes: lo.ToPtr(15),
+		CPUStableZoneRatio:               lo.ToPtr(0.0),
+		CPUMixedZoneRatio:                lo.ToPtr(0.0),
+	},
+	NeonVMRetryWait:                    5 * time.Second,
+	PluginRequestTick:                  5 * time.Second,
+	PluginRetryWait:                    3 * time.Second,
+	PluginDeniedRetryWait:              2 * time.Second,
+	PluginDegradedAfter:                0,
+	MonitorDeniedDownscaleCooldown:     5 * time.Second,
+	MonitorRequestedUpscaleValidPeriod: 10 * time.Second,
+	MonitorRetryWait:                   3 * time.Second,
+}
+
+// recordScaling drives a core.State through scaling up and back down the given number of times, in
+// the same way as the executor, and records all of the inputs and resulting actions.
+func recordScaling(t *testing.T, recorder *recording.Recorder, cycles int) {
+	vm := helpers.CreateVmInfo(helpers.InitialVmInfoConfig{
+		ComputeUnit:    computeUnit,
+		MemorySlotSize: 1 << 30,
+		MinCU:          1,
+		MaxCU:          4,
+	})
+
+	config := coreConfig
+	config.RevisionSource = revsource.NewRevisionSource(0, nil)
+
+	// Use real times, so that we check that they're preserved exactly by the recording.
+	now := time.Now()
+	recorder.Record(recording.Event{Kind: recording.EventInit, At: now, VM: &vm, Config: &config})
+	state := core.NewState(vm, config)
+
+	record := func(event recording.Event) {
+		event.At = now
+		recorder.Record(event)
+	}
+
+	active := true
+	record(recording.Event{Kind: recording.EventMonitorActive, Active: &active})
+	state.Monitor().Active(active)
+
+	var loads []float64
+	for range cycles {
+		loads = append(loads, 0.0, 0.6, 0.0)
+	}
+
+	for _, load := range loads {
+		now = now.Add(time.Minute + 123*time.Nanosecond)
+
+		//nolint:exhaustruct // this is a test
+		metrics := core.SystemMetrics{LoadAverage1Min: load, LoadAverage5Min: load}
+		record(recording.Event{Kind: recording.EventSystemMetrics, SystemMetrics: &metrics})
+		state.UpdateSystemMetrics(now, metrics)
+
+		for i := 0; ; i++ {
+			require.Less(t, i, 10, "no steady state")
+
+			actions := state.NextActions(now)
+			record(recording.Event{Kind: recording.EventNextActions, Actions: &actions})
+
+			if a := actions.PluginRequest; a != nil {
+				record(recording.Event{Kind: recording.EventPluginStarting, Target: &a.Target})
+				state.Plugin().StartingRequest(now, a.Target)
+				resp := api.PluginResponse{Permit: a.Target, Migrate: nil, Partial: nil}
+				record(recording.Event{
+					Kind:           recording.EventPluginSuccess,
+					Revision:       &a.TargetRevision,
+					PluginResponse: &resp,
+				})
+				require.NoError(t, state.Plugin().RequestSuccessful(now, a.TargetRevision, resp))
+			} else if a := actions.MonitorDownscale; a != nil {
+				record(recording.Event{Kind: recording.EventMonitorDownscaleStarting, Target: &a.Target})
+				state.Monitor().StartingDownscaleRequest(now, a.Target)
+				record(recording.Event{Kind: recording.EventMonitorDownscaleAllowed, Revision: &a.TargetRevision})
+				state.Monitor().DownscaleRequestAllowed(now, a.TargetRevision)
+			} else if a := actions.MonitorUpscale; a != nil {
+				record(recording.Event{Kind: recording.EventMonitorUpscaleStarting, Target: &a.Target})
+				state.Monitor().StartingUpscaleRequest(now, a.Target)
+				record(recording.Event{Kind: recording.EventMonitorUpscaleSuccess})
+				state.Monitor().UpscaleRequestSuccessful(now)
+			} else if a := actions.NeonVMRequest; a != nil {
+				record(recording.Event{Kind: recording.EventNeonVMStarting, Target: &a.Target})
+				state.NeonVM().StartingRequest(now, a.Target)
+				record(recording.Event{Kind: recording.EventNeonVMSuccess})
+				state.NeonVM().RequestSuccessful(now)
+			} else {
+				break
+			}
+		}
+	}
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	dir := t.TempDir()
+	vmName := util.NamespacedName{Namespace: "default", Name: "test"}
+
+	//nolint:exhaustruct // this is a test
+	recorder, err := recording.NewRecorder(zap.NewNop(), recording.Config{
+		Directory:     dir,
+		MaxFileSizeMB: 1,
+	}, vmName)
+	require.NoError(t, err)
+
+	recordScaling(t, recorder, 1)
+	require.NoError(t, recorder.Close())
+
+	file, err := os.Open(filepath.Join(dir, "default_test.jsonl"))
+	require.NoError(t, err)
+	defer file.Close()
+
+	events, err := recording.ReadEvents(file)
+	require.NoError(t, err)
+
+	result, err := recording.Replay(events, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Skipped)
+
+	var recordedActions int
+	var sawNeonVMRequest bool
+	for _, e := range events {
+		if e.Kind == recording.EventNextActions {
+			recordedActions++
+			sawNeonVMRequest = sawNeonVMRequest || e.Actions.NeonVMRequest != nil
+		}
+	}
+	assert.Len(t, result.Actions, recordedActions)
+	assert.True(t, sawNeonVMRequest, "expected recording to include scaling")
+
+	// Events before the first init are skipped
+	result, err = recording.Replay(append(events[1:3:3], events...), nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Skipped)
+	assert.Len(t, result.Actions, recordedActions)
+
+	// Changing one of the inputs should cause a mismatch
+	for i, e := range events {
+		if e.Kind == recording.EventSystemMetrics && e.SystemMetrics.LoadAverage1Min != 0 {
+			metrics := *e.SystemMetrics
+			metrics.LoadAverage1Min = 0.3
+			events[i].SystemMetrics = &metrics
+			break
+		}
+	}
+	_, err = recording.Replay(events, nil)
+	var mismatch *recording.MismatchError
+	require.True(t, errors.As(err, &mismatch), "expected MismatchError, got %v", err)
+}
+
+func TestReplayRotatedFiles(t *testing.T) {
+	dir := t.TempDir()
+	vmName := util.NamespacedName{Namespace: "default", Name: "test"}
+
+	//nolint:exhaustruct // this is a test
+	recorder, err := recording.NewRecorder(zap.NewNop(), recording.Config{
+		Directory:     dir,
+		MaxFileSizeMB: 1,
+		MaxBackups:    0,
+	}, vmName)
+	require.NoError(t, err)
+
+	// Record enough from a single core.State that the file is rotated at least once.
+	recordScaling(t, recorder, 200)
+	require.NoError(t, recorder.Close())
+
+	// Rotated files have a timestamp after the name, so they sort before the current file.
+	paths, err := filepath.Glob(filepath.Join(dir, "default_test*.jsonl"))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(paths), 2, "expected the recording to be rotated")
+	slices.Sort(paths)
+	require.Equal(t, filepath.Join(dir, "default_test.jsonl"), paths[len(paths)-1])
+
+	var events []recording.Event
+	var lastFileEvents []recording.Event
+	for _, path := range paths {
+		file, err := os.Open(path)
+		require.NoError(t, err)
+		lastFileEvents, err = recording.ReadEvents(file)
+		require.NoError(t, file.Close())
+		require.NoError(t, err)
+		events = append(events, lastFileEvents...)
+	}
+
+	// With all of the files, everything is replayed.
+	result, err := recording.Replay(events, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Skipped)
+	assert.NotEmpty(t, result.Actions)
+
+	// The later files don't have an EventInit, so they can't be replayed on their own. This is why
+	// the recording config requires keeping all rotated files.
+	require.NotEqual(t, recording.EventInit, lastFileEvents[0].Kind)
+	result, err = recording.Replay(lastFileEvents, nil)
+	require.NoError(t, err)
+	assert.Equal(t, len(lastFileEvents), result.Skipped)
+	assert.Empty(t, result.Actions)
+}
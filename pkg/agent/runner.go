@@ -37,6 +37,7 @@ import (
 	"github.com/neondatabase/autoscaling/pkg/agent/core"
 	"github.com/neondatabase/autoscaling/pkg/agent/core/revsource"
 	"github.com/neondatabase/autoscaling/pkg/agent/executor"
+	"github.com/neondatabase/autoscaling/pkg/agent/recording"
 	"github.com/neondatabase/autoscaling/pkg/agent/scalingevents"
 	"github.com/neondatabase/autoscaling/pkg/agent/schedwatch"
 	"github.com/neondatabase/autoscaling/pkg/api"
@@ -45,11 +46,11 @@ import (
 )
 
 // PluginProtocolVersion is the current version of the agent<->scheduler plugin in use by this
-// autoscaler-agent.
+// autoscaler-agent, for requests over both HTTP and permit streams.
 //
 // Currently, each autoscaler-agent supports only one version at a time. In the future, this may
 // change.
-const PluginProtocolVersion api.PluginProtoVersion = api.PluginProtoV5_0
+const PluginProtocolVersion api.PluginProtoVersion = api.PluginProtoV6_1
 
 // Runner is per-VM Pod god object responsible for handling everything
 //
@@ -86,6 +87,11 @@ type Runner struct {
 	// which means that it may be read when EITHER holding lock OR the executor's lock.
 	monitor *monitorInfo
 
+	// permitStream, if not nil, is the current permit stream to the scheduler plugin. It is
+	// exclusively accessed by (*Runner).doStreamRequest, which is only called by the executor's
+	// plugin requests worker.
+	permitStream *runnerPermitStream
+
 	// backgroundWorkerCount tracks the current number of background workers. It is exclusively
 	// updated by r.spawnBackgroundWorker
 	backgroundWorkerCount atomic.Int64
@@ -183,6 +189,11 @@ func (r *Runner) Run(ctx context.Context, logger *zap.Logger, vmInfoUpdated util
 		defer r.status.mu.Unlock()
 		return r.status.vmInfo
 	}
+	getCUOverride := func() *core.CUOverride {
+		r.status.mu.Lock()
+		defer r.status.mu.Unlock()
+		return r.status.cuOverride
+	}
 
 	execLogger := logger.Named("exec")
 
@@ -199,55 +210,89 @@ func (r *Runner) Run(ctx context.Context, logger *zap.Logger, vmInfoUpdated util
 	}
 	// "dsrl" stands for "desired scaling report limiter" -- helper to avoid spamming events.
 	dsrl := &desiredScalingReportLimiter{lastEvent: nil}
-	revisionSource := revsource.NewRevisionSource(initialRevision, WrapHistogramVec(&r.global.metrics.scalingLatency))
+	coreConfig := r.global.config.CoreConfig()
+	observeScalingLatency := WrapHistogramVec(&r.global.metrics.scalingLatency)
+	revisionSource := revsource.NewRevisionSource(initialRevision, func(dur time.Duration, flags vmv1.Flag) {
+		observeScalingLatency(dur, flags)
+		r.reportScalingCompleted(time.Now(), coreConfig.ComputeUnit, getVmInfo(), dur)
+	})
+	coreConfig.GoalPolicies = r.global.goalPolicies
+	coreConfig.PluginRequestTick -= pluginRequestJitter
+	coreConfig.Log = core.LogConfig{
+		Info: coreExecLogger.Info,
+		Warn: coreExecLogger.Warn,
+	}
+	coreConfig.RevisionSource = revisionSource
+	coreConfig.ObservabilityCallbacks = core.ObservabilityCallbacks{
+		PluginLatency:  WrapHistogramVec(&r.global.metrics.pluginLatency),
+		MonitorLatency: WrapHistogramVec(&r.global.metrics.monitorLatency),
+		NeonVMLatency:  WrapHistogramVec(&r.global.metrics.neonvmLatency),
+		ActualScaling:  r.reportScalingEvent,
+		DeniedScaling:  r.reportDeniedScaling,
+		HypotheticalScaling: func(ts time.Time, current, target uint32, parts core.ScalingGoalParts) {
+			r.reportDesiredScaling(dsrl, ts, current, target, scalingevents.GoalCUComponents{
+				CPU:         parts.CPU,
+				Mem:         parts.Mem,
+				LFC:         parts.LFC,
+				CPUForecast: parts.CPUForecast,
+				Scheduled:   parts.Scheduled,
+				PSI:         parts.PSI,
+				Override:    parts.Override,
+			})
+		},
+	}
+
+	var recorder *recording.Recorder
+	if r.global.config.Recording.ShouldRecord(r.vmName) {
+		var err error
+		recorder, err = recording.NewRecorder(logger.Named("recording"), *r.global.config.Recording, r.vmName)
+		if err != nil {
+			// Recording is only for debugging, so we shouldn't stop scaling the VM if it fails.
+			logger.Error("Failed to start recording scaling inputs", zap.Error(err))
+		} else {
+			logger.Info("Recording scaling inputs", zap.String("directory", r.global.config.Recording.Directory))
+			defer func() {
+				if err := recorder.Close(); err != nil {
+					logger.Warn("Failed to close recording", zap.Error(err))
+				}
+			}()
+		}
+	}
+
 	executorCore := executor.NewExecutorCore(coreExecLogger, vmInfo, executor.Config{
 		OnNextActions: r.global.metrics.runnerNextActions.Inc,
-		Core: core.Config{
-			ComputeUnit:                        r.global.config.Scaling.ComputeUnit,
-			DefaultScalingConfig:               r.global.config.Scaling.DefaultConfig,
-			NeonVMRetryWait:                    time.Second * time.Duration(r.global.config.NeonVM.RetryFailedRequestSeconds),
-			PluginRequestTick:                  time.Second*time.Duration(r.global.config.Scheduler.RequestAtLeastEverySeconds) - pluginRequestJitter,
-			PluginRetryWait:                    time.Second * time.Duration(r.global.config.Scheduler.RetryFailedRequestSeconds),
-			PluginDeniedRetryWait:              time.Second * time.Duration(r.global.config.Scheduler.RetryDeniedUpscaleSeconds),
-			MonitorDeniedDownscaleCooldown:     time.Second * time.Duration(r.global.config.Monitor.RetryDeniedDownscaleSeconds),
-			MonitorRequestedUpscaleValidPeriod: time.Second * time.Duration(r.global.config.Monitor.RequestedUpscaleValidSeconds),
-			MonitorRetryWait:                   time.Second * time.Duration(r.global.config.Monitor.RetryFailedRequestSeconds),
-			Log: core.LogConfig{
-				Info: coreExecLogger.Info,
-				Warn: coreExecLogger.Warn,
-			},
-			RevisionSource: revisionSource,
-			ObservabilityCallbacks: core.ObservabilityCallbacks{
-				PluginLatency:  WrapHistogramVec(&r.global.metrics.pluginLatency),
-				MonitorLatency: WrapHistogramVec(&r.global.metrics.monitorLatency),
-				NeonVMLatency:  WrapHistogramVec(&r.global.metrics.neonvmLatency),
-				ActualScaling:  r.reportScalingEvent,
-				HypotheticalScaling: func(ts time.Time, current, target uint32, parts core.ScalingGoalParts) {
-					r.reportDesiredScaling(dsrl, ts, current, target, scalingevents.GoalCUComponents{
-						CPU: parts.CPU,
-						Mem: parts.Mem,
-						LFC: parts.LFC,
-					})
-				},
-			},
-		},
+		Recorder:      recorder,
+		Core:          coreConfig,
 	})
 
 	r.executorStateDump = executorCore.StateDump
 
 	monitorGeneration := executor.NewStoredGenerationNumber()
 
-	pluginIface := makePluginInterface(r)
+	pluginIface := makePluginInterface(r, executorCore)
 	neonvmIface := makeNeonVMInterface(r)
 	monitorIface := makeMonitorInterface(r, executorCore, monitorGeneration)
+	// note: must be a nil interface (not a nil pointer) if the local budget is disabled.
+	var localBudgetIface executor.LocalBudgetInterface
+	if r.global.localBudget != nil {
+		localBudgetIface = makeLocalBudgetInterface(r, r.global.localBudget)
+	}
 
 	// "ecwc" stands for "ExecutorCoreWithClients"
 	ecwc := executorCore.WithClients(executor.ClientSet{
-		Plugin:  pluginIface,
-		NeonVM:  neonvmIface,
-		Monitor: monitorIface,
+		Plugin:      pluginIface,
+		NeonVM:      neonvmIface,
+		Monitor:     monitorIface,
+		LocalBudget: localBudgetIface,
 	})
 
+	// Carry over any CU override from before the Runner (re)started.
+	if override := getCUOverride(); override != nil {
+		ecwc.Updater().UpdateCUOverride(override, func() {
+			logger.Info("Restored CU override", zap.Any("cuOverride", override))
+		})
+	}
+
 	logger.Info("Starting background workers")
 
 	// FIXME: make this timeout/delay a separately defined constant, or configurable
@@ -267,6 +312,10 @@ func (r *Runner) Run(ctx context.Context, logger *zap.Logger, vmInfoUpdated util
 				ecwc.Updater().UpdatedVM(vm, func() {
 					logger2.Info("VmInfo updated", zap.Any("vmInfo", vm))
 				})
+				// CU overrides are delivered through the same channel; see
+				// (*agentState).SetCUOverride().
+				// Changes are logged by the HTTP handler, so no need to log here.
+				ecwc.Updater().UpdateCUOverride(getCUOverride(), func() {})
 			}
 		}
 	})
@@ -282,6 +331,7 @@ func (r *Runner) Run(ctx context.Context, logger *zap.Logger, vmInfoUpdated util
 				isActive:     func() bool { return true },
 				updateMetrics: func(metrics *core.SystemMetrics, withLock func()) {
 					ecwc.Updater().UpdateSystemMetrics(*metrics, withLock)
+					r.global.vmMetrics.updateCPUSeconds(r.vmName, metrics.CPUSeconds)
 				},
 			},
 		)
@@ -334,7 +384,7 @@ func (r *Runner) Run(ctx context.Context, logger *zap.Logger, vmInfoUpdated util
 	}
 }
 
-func (r *Runner) reportScalingEvent(timestamp time.Time, currentCU, targetCU uint32) {
+func (r *Runner) reportScalingEvent(timestamp time.Time, currentCU, targetCU uint32, limit core.ScalingLimit) {
 	endpointID := func() string {
 		return r.status.endpointID
 	}()
@@ -345,9 +395,46 @@ func (r *Runner) reportScalingEvent(timestamp time.Time, currentCU, targetCU uin
 		endpointID,
 		currentCU,
 		targetCU,
+		string(limit),
+	))
+}
+
+func (r *Runner) reportDeniedScaling(timestamp time.Time, currentCU, targetCU uint32, limit core.ScalingLimit) {
+	endpointID := func() string {
+		return r.status.endpointID
+	}()
+
+	reporter := r.global.scalingReporter
+	reporter.Submit(reporter.NewDeniedEvent(
+		timestamp,
+		endpointID,
+		currentCU,
+		targetCU,
+		string(limit),
 	))
 }
 
+// reportScalingCompleted is called when NeonVM has finished applying scaling that was decided on
+// latency ago, with vmInfo as the new state of the VM.
+func (r *Runner) reportScalingCompleted(
+	timestamp time.Time,
+	computeUnit api.Resources,
+	vmInfo api.VmInfo,
+	latency time.Duration,
+) {
+	currentCU, ok := vmInfo.Using().DivResources(computeUnit)
+	if !ok {
+		return // skip reporting if the current CU is not right.
+	}
+
+	endpointID := func() string {
+		return r.status.endpointID
+	}()
+
+	reporter := r.global.scalingReporter
+	reporter.Submit(reporter.NewCompletedEvent(timestamp, endpointID, uint32(currentCU), latency))
+}
+
 func (r *Runner) reportDesiredScaling(
 	rl *desiredScalingReportLimiter,
 	timestamp time.Time,
@@ -399,7 +486,11 @@ func (rl *desiredScalingReportLimiter) report(
 		skip := rl.lastEvent.TargetMilliCU == event.TargetMilliCU &&
 			closeEnough(rl.lastEvent.GoalComponents.CPU, event.GoalComponents.CPU) &&
 			closeEnough(rl.lastEvent.GoalComponents.Mem, event.GoalComponents.Mem) &&
-			closeEnough(rl.lastEvent.GoalComponents.LFC, event.GoalComponents.LFC)
+			closeEnough(rl.lastEvent.GoalComponents.LFC, event.GoalComponents.LFC) &&
+			closeEnough(rl.lastEvent.GoalComponents.CPUForecast, event.GoalComponents.CPUForecast) &&
+			closeEnough(rl.lastEvent.GoalComponents.Scheduled, event.GoalComponents.Scheduled) &&
+			closeEnough(rl.lastEvent.GoalComponents.PSI, event.GoalComponents.PSI) &&
+			closeEnough(rl.lastEvent.GoalComponents.Override, event.GoalComponents.Override)
 		if skip {
 			return
 		}
@@ -837,14 +928,18 @@ func doMonitorUpscale(
 }
 
 // DoSchedulerRequest sends a request to the scheduler and does not validate the response.
+//
+// If scheduler.grpcPort is set, the request is sent over the Runner's permit stream where possible,
+// and onPush is called with any updated permits pushed by the scheduler plugin.
 func (r *Runner) DoSchedulerRequest(
 	ctx context.Context,
 	logger *zap.Logger,
 	resources api.Resources,
 	lastPermit *api.Resources,
 	metrics *api.Metrics,
+	onPush func(*zap.Logger, api.PluginResponse),
 ) (_ *api.PluginResponse, err error) {
-	reqData := &api.AgentRequest{
+	reqData := api.AgentRequest{
 		ProtoVersion: PluginProtocolVersion,
 		Pod:          r.podName,
 		ComputeUnit:  r.global.config.Scaling.ComputeUnit,
@@ -868,12 +963,43 @@ func (r *Runner) DoSchedulerRequest(
 		return nil, err
 	}
 
+	timeout := time.Second * time.Duration(r.global.config.NeonVM.RequestTimeoutSeconds)
+
+	var respData *api.PluginResponse
+	if r.global.permitStreams != nil {
+		respData, err = r.doStreamRequest(ctx, logger, sched, timeout, reqData, onPush)
+		if errors.Is(err, errPermitStreamUnsupported) {
+			respData, err = r.doHTTPRequest(ctx, logger, sched, timeout, reqData)
+		}
+	} else {
+		respData, err = r.doHTTPRequest(ctx, logger, sched, timeout, reqData)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	level := zap.DebugLevel
+	if respData.Permit.HasFieldLessThan(resources) {
+		level = zap.WarnLevel
+	}
+	logger.Log(level, "Received response from scheduler", zap.Any("response", respData), zap.Any("requested", resources))
+
+	return respData, nil
+}
+
+// doHTTPRequest sends the request to the scheduler plugin over HTTP
+func (r *Runner) doHTTPRequest(
+	ctx context.Context,
+	logger *zap.Logger,
+	sched *schedwatch.SchedulerInfo,
+	timeout time.Duration,
+	reqData api.AgentRequest,
+) (*api.PluginResponse, error) {
 	reqBody, err := json.Marshal(reqData)
 	if err != nil {
 		return nil, fmt.Errorf("error encoding request JSON: %w", err)
 	}
 
-	timeout := time.Second * time.Duration(r.global.config.NeonVM.RequestTimeoutSeconds)
 	reqCtx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
@@ -913,11 +1039,5 @@ func (r *Runner) DoSchedulerRequest(
 		// Fatal because invalid JSON might also be semantically invalid
 		return nil, fmt.Errorf("bad JSON response: %w", err)
 	}
-	level := zap.DebugLevel
-	if respData.Permit.HasFieldLessThan(resources) {
-		level = zap.WarnLevel
-	}
-	logger.Log(level, "Received response from scheduler", zap.Any("response", respData), zap.Any("requested", resources))
-
 	return &respData, nil
 }
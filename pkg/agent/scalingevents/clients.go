@@ -3,6 +3,9 @@ package scalingevents
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/lithammer/shortuuid"
@@ -13,6 +16,9 @@ import (
 
 type ClientsConfig struct {
 	AzureBlob *AzureBlobStorageClientConfig `json:"azureBlob"`
+	GCS       *GCSClientConfig              `json:"gcs"`
+	Kafka     *KafkaClientConfig            `json:"kafka"`
+	LocalFile *LocalFileClientConfig        `json:"localFile"`
 	S3        *S3ClientConfig               `json:"s3"`
 }
 
@@ -28,13 +34,31 @@ type AzureBlobStorageClientConfig struct {
 	PrefixInContainer string `json:"prefixInContainer"`
 }
 
+type GCSClientConfig struct {
+	reporting.BaseClientConfig
+	reporting.GCSClientConfig
+	PrefixInBucket string `json:"prefixInBucket"`
+}
+
+type LocalFileClientConfig struct {
+	reporting.BaseClientConfig
+	reporting.LocalFileClientConfig
+	PrefixInDirectory string `json:"prefixInDirectory"`
+}
+
+type KafkaClientConfig struct {
+	reporting.BaseClientConfig
+	reporting.KafkaClientConfig
+}
+
 type eventsClient = reporting.Client[ScalingEvent]
 
 func createClients(ctx context.Context, logger *zap.Logger, cfg ClientsConfig) ([]eventsClient, error) {
 	var clients []eventsClient
 
 	if c := cfg.AzureBlob; c != nil {
-		generateKey := newBlobStorageKeyGenerator(c.PrefixInContainer)
+		newBatch, extension := reporting.NewBlobBatchBuilder(c.Format, scalingEventSchema)
+		generateKey := newBlobStorageKeyGenerator(c.PrefixInContainer, extension)
 		client, err := reporting.NewAzureBlobStorageClient(c.AzureBlobStorageClientConfig, generateKey)
 		if err != nil {
 			return nil, fmt.Errorf("error creating Azure Blob Storage client: %w", err)
@@ -45,11 +69,14 @@ func createClients(ctx context.Context, logger *zap.Logger, cfg ClientsConfig) (
 			Name:            "azureblob",
 			Base:            client,
 			BaseConfig:      c.BaseClientConfig,
-			NewBatchBuilder: jsonLinesBatch(reporting.NewGZIPBuffer),
+			NewBatchBuilder: newBatch,
+			Extension:       extension,
+			DeadLetter:      nil,
 		})
 	}
 	if c := cfg.S3; c != nil {
-		generateKey := newBlobStorageKeyGenerator(c.PrefixInBucket)
+		newBatch, extension := reporting.NewBlobBatchBuilder(c.Format, scalingEventSchema)
+		generateKey := newBlobStorageKeyGenerator(c.PrefixInBucket, extension)
 		client, err := reporting.NewS3Client(ctx, c.S3ClientConfig, generateKey)
 		if err != nil {
 			return nil, fmt.Errorf("error creating S3 client: %w", err)
@@ -60,11 +87,66 @@ func createClients(ctx context.Context, logger *zap.Logger, cfg ClientsConfig) (
 			Name:            "s3",
 			Base:            client,
 			BaseConfig:      c.BaseClientConfig,
-			NewBatchBuilder: jsonLinesBatch(reporting.NewGZIPBuffer),
+			NewBatchBuilder: newBatch,
+			Extension:       extension,
+			DeadLetter:      nil,
+		})
+	}
+	if c := cfg.Kafka; c != nil {
+		client, err := reporting.NewKafkaClient(c.KafkaClientConfig)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Kafka client: %w", err)
+		}
+		logger.Info("Created Kafka client for scaling events", zap.Any("config", c))
+
+		clients = append(clients, eventsClient{
+			Name:            "kafka",
+			Base:            client,
+			BaseConfig:      c.BaseClientConfig,
+			NewBatchBuilder: jsonLinesBatch(reporting.NewByteBuffer), // note: NOT gzipped.
+			Extension:       ".ndjson",
+			DeadLetter:      nil,
+		})
+	}
+	if c := cfg.GCS; c != nil {
+		newBatch, extension := reporting.NewBlobBatchBuilder(c.Format, scalingEventSchema)
+		generateKey := newBlobStorageKeyGenerator(c.PrefixInBucket, extension)
+		client, err := reporting.NewGCSClient(http.DefaultClient, c.GCSClientConfig, generateKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating GCS client: %w", err)
+		}
+		logger.Info("Created GCS client for scaling events", zap.Any("config", c))
+
+		clients = append(clients, eventsClient{
+			Name:            "gcs",
+			Base:            client,
+			BaseConfig:      c.BaseClientConfig,
+			NewBatchBuilder: newBatch,
+			Extension:       extension,
+			DeadLetter:      nil,
 		})
 	}
+	if c := cfg.LocalFile; c != nil {
+		newBatch, extension := reporting.NewBlobBatchBuilder(c.Format, scalingEventSchema)
+		generateKey := newBlobStorageKeyGenerator(c.PrefixInDirectory, extension)
+		isKey := newBlobStorageKeyMatcher(c.PrefixInDirectory, extension)
+		client, err := reporting.NewLocalFileClient(c.LocalFileClientConfig, generateKey, isKey)
+		if err != nil {
+			return nil, fmt.Errorf("error creating local file client: %w", err)
+		}
+		logger.Info("Created local file client for scaling events", zap.Any("config", c))
 
-	return clients, nil
+		clients = append(clients, eventsClient{
+			Name:            "localfile",
+			Base:            client,
+			BaseConfig:      c.BaseClientConfig,
+			NewBatchBuilder: newBatch,
+			Extension:       extension,
+			DeadLetter:      nil,
+		})
+	}
+
+	return reporting.ResolveDeadLetters(clients)
 }
 
 func jsonLinesBatch[B reporting.IOBuffer](buf func() B) func() reporting.BatchBuilder[ScalingEvent] {
@@ -77,18 +159,34 @@ func jsonLinesBatch[B reporting.IOBuffer](buf func() B) func() reporting.BatchBu
 //
 // Example: prefix/2024/10/31/23/events_{uuid}.ndjson.gz (11pm on halloween, UTC)
 //
+// The extension depends on the batch format, e.g. ".ndjson.gz" for JSON.
+//
 // NOTE: This key format is different from the one we use for billing, but similar to the one proxy
 // uses for its reporting.
-func newBlobStorageKeyGenerator(prefix string) func() string {
+func newBlobStorageKeyGenerator(prefix string, extension string) func() string {
 	return func() string {
 		now := time.Now().UTC()
 		id := shortuuid.New()
 
 		return fmt.Sprintf(
-			"%s/%d/%02d/%02d/%02d/events_%s.ndjson.gz",
+			"%s/%d/%02d/%02d/%02d/events_%s%s",
 			prefix,
 			now.Year(), now.Month(), now.Day(), now.Hour(),
 			id,
+			extension,
 		)
 	}
 }
+
+// Returns a function that checks whether a slash-separated path could have been generated by
+// newBlobStorageKeyGenerator with the same prefix and extension.
+func newBlobStorageKeyMatcher(prefix string, extension string) func(string) bool {
+	if prefix = strings.Trim(prefix, "/"); prefix != "" {
+		prefix += "/"
+	}
+	return regexp.MustCompile(
+		"^" + regexp.QuoteMeta(prefix) +
+			`\d{4}/\d{2}/\d{2}/\d{2}/events_[[:alnum:]]+` +
+			regexp.QuoteMeta(extension) + "$",
+	).MatchString
+}
@@ -30,7 +30,7 @@ func NewPromMetrics(reg prometheus.Registerer) PromMetrics {
 func (m PromMetrics) recordSubmitted(event ScalingEvent) {
 	var eventKind string
 	switch event.Kind {
-	case scalingEventActual, scalingEventHypothetical:
+	case scalingEventActual, scalingEventHypothetical, scalingEventDenied, scalingEventCompleted:
 		eventKind = string(event.Kind)
 	default:
 		eventKind = "unknown"
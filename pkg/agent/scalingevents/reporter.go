@@ -44,12 +44,24 @@ type ScalingEvent struct {
 	CurrentMilliCU uint32            `json:"current_cu"`
 	TargetMilliCU  uint32            `json:"target_cu"`
 	GoalComponents *GoalCUComponents `json:"goalComponents,omitempty"`
+
+	// Limit, for actual and denied events, gives what stopped the VM from scaling to the goal CU,
+	// if anything. For example: "pluginCap", "monitorDenial", "bounds", or "requestedUpscale".
+	Limit string `json:"limit,omitempty"`
+	// LatencySeconds, for completed events, is the time from the scaling decision until NeonVM
+	// finished applying it.
+	LatencySeconds *float64 `json:"latency_seconds,omitempty"`
 }
 
 type GoalCUComponents struct {
-	CPU *float64 `json:"cpu,omitempty"`
-	Mem *float64 `json:"mem,omitempty"`
-	LFC *float64 `json:"lfc,omitempty"`
+	CPU         *float64 `json:"cpu,omitempty"`
+	Mem         *float64 `json:"mem,omitempty"`
+	LFC         *float64 `json:"lfc,omitempty"`
+	CPUForecast *float64 `json:"cpuForecast,omitempty"`
+	Scheduled   *float64 `json:"scheduled,omitempty"`
+	PSI         *float64 `json:"psi,omitempty"`
+	// Override, if not nil, is the CU that the VM has been temporarily pinned to by an operator.
+	Override *float64 `json:"override,omitempty"`
 }
 
 type scalingEventKind string
@@ -57,6 +69,11 @@ type scalingEventKind string
 const (
 	scalingEventActual       = "actual"
 	scalingEventHypothetical = "hypothetical"
+	// scalingEventDenied is for requests to scale that were denied by the scheduler plugin or the
+	// vm-monitor, either wholly or in part.
+	scalingEventDenied = "denied"
+	// scalingEventCompleted is for scaling that NeonVM has finished applying.
+	scalingEventCompleted = "completed"
 )
 
 func NewReporter(
@@ -111,6 +128,7 @@ func (r *Reporter) NewActualEvent(
 	endpointID string,
 	currentCU uint32,
 	targetCU uint32,
+	limit string,
 ) ScalingEvent {
 	return ScalingEvent{
 		Timestamp:      timestamp,
@@ -120,6 +138,52 @@ func (r *Reporter) NewActualEvent(
 		CurrentMilliCU: convertToMilliCU(currentCU, r.conf.CUMultiplier),
 		TargetMilliCU:  convertToMilliCU(targetCU, r.conf.CUMultiplier),
 		GoalComponents: nil,
+		Limit:          limit,
+		LatencySeconds: nil,
+	}
+}
+
+// NewDeniedEvent is a helper function to create a ScalingEvent for a request to scale to targetCU
+// that was denied, with limit giving what denied it.
+func (r *Reporter) NewDeniedEvent(
+	timestamp time.Time,
+	endpointID string,
+	currentCU uint32,
+	targetCU uint32,
+	limit string,
+) ScalingEvent {
+	return ScalingEvent{
+		Timestamp:      timestamp,
+		Region:         r.conf.RegionName,
+		EndpointID:     endpointID,
+		Kind:           scalingEventDenied,
+		CurrentMilliCU: convertToMilliCU(currentCU, r.conf.CUMultiplier),
+		TargetMilliCU:  convertToMilliCU(targetCU, r.conf.CUMultiplier),
+		GoalComponents: nil,
+		Limit:          limit,
+		LatencySeconds: nil,
+	}
+}
+
+// NewCompletedEvent is a helper function to create a ScalingEvent for scaling to currentCU that
+// NeonVM has finished applying, latency after it was decided on.
+func (r *Reporter) NewCompletedEvent(
+	timestamp time.Time,
+	endpointID string,
+	currentCU uint32,
+	latency time.Duration,
+) ScalingEvent {
+	milliCU := convertToMilliCU(currentCU, r.conf.CUMultiplier)
+	return ScalingEvent{
+		Timestamp:      timestamp,
+		Region:         r.conf.RegionName,
+		EndpointID:     endpointID,
+		Kind:           scalingEventCompleted,
+		CurrentMilliCU: milliCU,
+		TargetMilliCU:  milliCU,
+		GoalComponents: nil,
+		Limit:          "",
+		LatencySeconds: lo.ToPtr(latency.Seconds()),
 	}
 }
 
@@ -145,9 +209,15 @@ func (r *Reporter) NewHypotheticalEvent(
 		CurrentMilliCU: convertToMilliCU(currentCU, r.conf.CUMultiplier),
 		TargetMilliCU:  convertToMilliCU(targetCU, r.conf.CUMultiplier),
 		GoalComponents: &GoalCUComponents{
-			CPU: convertFloat(goalCUs.CPU),
-			Mem: convertFloat(goalCUs.Mem),
-			LFC: convertFloat(goalCUs.LFC),
+			CPU:         convertFloat(goalCUs.CPU),
+			Mem:         convertFloat(goalCUs.Mem),
+			LFC:         convertFloat(goalCUs.LFC),
+			CPUForecast: convertFloat(goalCUs.CPUForecast),
+			Scheduled:   convertFloat(goalCUs.Scheduled),
+			PSI:         convertFloat(goalCUs.PSI),
+			Override:    convertFloat(goalCUs.Override),
 		},
+		Limit:          "",
+		LatencySeconds: nil,
 	}
 }
@@ -0,0 +1,278 @@
+// Protobuf definition for scaling events, when a client is configured with "format": "protobuf".
+//
+// Batches are a sequence of length-delimited messages, starting with a reporting.BatchHeader (see
+// pkg/reporting/batch.proto) with schema_name set to "scalingevents.ScalingEvent".
+//
+// This must be kept in sync with the schema in schema.go. Timestamps are microseconds since the
+// unix epoch.
+//
+// After changing this file, regenerate the Go code with 'make generate-proto'.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: pkg/agent/scalingevents/scalingevents.proto
+
+package scalingeventspb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Schema version 2
+type ScalingEvent struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Timestamp  int64                  `protobuf:"varint,1,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Region     string                 `protobuf:"bytes,2,opt,name=region,proto3" json:"region,omitempty"`
+	EndpointId string                 `protobuf:"bytes,3,opt,name=endpoint_id,json=endpointId,proto3" json:"endpoint_id,omitempty"`
+	// One of "actual", "hypothetical", "denied", or "completed"
+	Kind string `protobuf:"bytes,4,opt,name=kind,proto3" json:"kind,omitempty"`
+	// current_cu and target_cu are in thousandths of a CU
+	CurrentCu int64 `protobuf:"varint,5,opt,name=current_cu,json=currentCu,proto3" json:"current_cu,omitempty"`
+	TargetCu  int64 `protobuf:"varint,6,opt,name=target_cu,json=targetCu,proto3" json:"target_cu,omitempty"`
+	// Components of the goal CU, if available. Each one is absent if it was not used.
+	GoalCpu         *float64 `protobuf:"fixed64,7,opt,name=goal_cpu,json=goalCpu,proto3,oneof" json:"goal_cpu,omitempty"`
+	GoalMem         *float64 `protobuf:"fixed64,8,opt,name=goal_mem,json=goalMem,proto3,oneof" json:"goal_mem,omitempty"`
+	GoalLfc         *float64 `protobuf:"fixed64,9,opt,name=goal_lfc,json=goalLfc,proto3,oneof" json:"goal_lfc,omitempty"`
+	GoalCpuForecast *float64 `protobuf:"fixed64,10,opt,name=goal_cpu_forecast,json=goalCpuForecast,proto3,oneof" json:"goal_cpu_forecast,omitempty"`
+	GoalScheduled   *float64 `protobuf:"fixed64,11,opt,name=goal_scheduled,json=goalScheduled,proto3,oneof" json:"goal_scheduled,omitempty"`
+	GoalPsi         *float64 `protobuf:"fixed64,12,opt,name=goal_psi,json=goalPsi,proto3,oneof" json:"goal_psi,omitempty"`
+	GoalOverride    *float64 `protobuf:"fixed64,13,opt,name=goal_override,json=goalOverride,proto3,oneof" json:"goal_override,omitempty"`
+	// limit, for "actual" and "denied" events, is what stopped the VM from scaling to the goal CU,
+	// or empty if nothing did.
+	Limit string `protobuf:"bytes,14,opt,name=limit,proto3" json:"limit,omitempty"`
+	// latency_seconds, for "completed" events, is the time from the scaling decision until NeonVM
+	// finished applying it.
+	LatencySeconds *float64 `protobuf:"fixed64,15,opt,name=latency_seconds,json=latencySeconds,proto3,oneof" json:"latency_seconds,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ScalingEvent) Reset() {
+	*x = ScalingEvent{}
+	mi := &file_pkg_agent_scalingevents_scalingevents_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScalingEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScalingEvent) ProtoMessage() {}
+
+func (x *ScalingEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_agent_scalingevents_scalingevents_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScalingEvent.ProtoReflect.Descriptor instead.
+func (*ScalingEvent) Descriptor() ([]byte, []int) {
+	return file_pkg_agent_scalingevents_scalingevents_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ScalingEvent) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetRegion() string {
+	if x != nil {
+		return x.Region
+	}
+	return ""
+}
+
+func (x *ScalingEvent) GetEndpointId() string {
+	if x != nil {
+		return x.EndpointId
+	}
+	return ""
+}
+
+func (x *ScalingEvent) GetKind() string {
+	if x != nil {
+		return x.Kind
+	}
+	return ""
+}
+
+func (x *ScalingEvent) GetCurrentCu() int64 {
+	if x != nil {
+		return x.CurrentCu
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetTargetCu() int64 {
+	if x != nil {
+		return x.TargetCu
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetGoalCpu() float64 {
+	if x != nil && x.GoalCpu != nil {
+		return *x.GoalCpu
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetGoalMem() float64 {
+	if x != nil && x.GoalMem != nil {
+		return *x.GoalMem
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetGoalLfc() float64 {
+	if x != nil && x.GoalLfc != nil {
+		return *x.GoalLfc
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetGoalCpuForecast() float64 {
+	if x != nil && x.GoalCpuForecast != nil {
+		return *x.GoalCpuForecast
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetGoalScheduled() float64 {
+	if x != nil && x.GoalScheduled != nil {
+		return *x.GoalScheduled
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetGoalPsi() float64 {
+	if x != nil && x.GoalPsi != nil {
+		return *x.GoalPsi
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetGoalOverride() float64 {
+	if x != nil && x.GoalOverride != nil {
+		return *x.GoalOverride
+	}
+	return 0
+}
+
+func (x *ScalingEvent) GetLimit() string {
+	if x != nil {
+		return x.Limit
+	}
+	return ""
+}
+
+func (x *ScalingEvent) GetLatencySeconds() float64 {
+	if x != nil && x.LatencySeconds != nil {
+		return *x.LatencySeconds
+	}
+	return 0
+}
+
+var File_pkg_agent_scalingevents_scalingevents_proto protoreflect.FileDescriptor
+
+const file_pkg_agent_scalingevents_scalingevents_proto_rawDesc = "" +
+	"\n" +
+	"+pkg/agent/scalingevents/scalingevents.proto\x12\rscalingevents\"\x83\x05\n" +
+	"\fScalingEvent\x12\x1c\n" +
+	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\x12\x16\n" +
+	"\x06region\x18\x02 \x01(\tR\x06region\x12\x1f\n" +
+	"\vendpoint_id\x18\x03 \x01(\tR\n" +
+	"endpointId\x12\x12\n" +
+	"\x04kind\x18\x04 \x01(\tR\x04kind\x12\x1d\n" +
+	"\n" +
+	"current_cu\x18\x05 \x01(\x03R\tcurrentCu\x12\x1b\n" +
+	"\ttarget_cu\x18\x06 \x01(\x03R\btargetCu\x12\x1e\n" +
+	"\bgoal_cpu\x18\a \x01(\x01H\x00R\agoalCpu\x88\x01\x01\x12\x1e\n" +
+	"\bgoal_mem\x18\b \x01(\x01H\x01R\agoalMem\x88\x01\x01\x12\x1e\n" +
+	"\bgoal_lfc\x18\t \x01(\x01H\x02R\agoalLfc\x88\x01\x01\x12/\n" +
+	"\x11goal_cpu_forecast\x18\n" +
+	" \x01(\x01H\x03R\x0fgoalCpuForecast\x88\x01\x01\x12*\n" +
+	"\x0egoal_scheduled\x18\v \x01(\x01H\x04R\rgoalScheduled\x88\x01\x01\x12\x1e\n" +
+	"\bgoal_psi\x18\f \x01(\x01H\x05R\agoalPsi\x88\x01\x01\x12(\n" +
+	"\rgoal_override\x18\r \x01(\x01H\x06R\fgoalOverride\x88\x01\x01\x12\x14\n" +
+	"\x05limit\x18\x0e \x01(\tR\x05limit\x12,\n" +
+	"\x0flatency_seconds\x18\x0f \x01(\x01H\aR\x0elatencySeconds\x88\x01\x01B\v\n" +
+	"\t_goal_cpuB\v\n" +
+	"\t_goal_memB\v\n" +
+	"\t_goal_lfcB\x14\n" +
+	"\x12_goal_cpu_forecastB\x11\n" +
+	"\x0f_goal_scheduledB\v\n" +
+	"\t_goal_psiB\x10\n" +
+	"\x0e_goal_overrideB\x12\n" +
+	"\x10_latency_secondsBMZKgithub.com/neondatabase/autoscaling/pkg/agent/scalingevents/scalingeventspbb\x06proto3"
+
+var (
+	file_pkg_agent_scalingevents_scalingevents_proto_rawDescOnce sync.Once
+	file_pkg_agent_scalingevents_scalingevents_proto_rawDescData []byte
+)
+
+func file_pkg_agent_scalingevents_scalingevents_proto_rawDescGZIP() []byte {
+	file_pkg_agent_scalingevents_scalingevents_proto_rawDescOnce.Do(func() {
+		file_pkg_agent_scalingevents_scalingevents_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_agent_scalingevents_scalingevents_proto_rawDesc), len(file_pkg_agent_scalingevents_scalingevents_proto_rawDesc)))
+	})
+	return file_pkg_agent_scalingevents_scalingevents_proto_rawDescData
+}
+
+var file_pkg_agent_scalingevents_scalingevents_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_pkg_agent_scalingevents_scalingevents_proto_goTypes = []any{
+	(*ScalingEvent)(nil), // 0: scalingevents.ScalingEvent
+}
+var file_pkg_agent_scalingevents_scalingevents_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_pkg_agent_scalingevents_scalingevents_proto_init() }
+func file_pkg_agent_scalingevents_scalingevents_proto_init() {
+	if File_pkg_agent_scalingevents_scalingevents_proto != nil {
+		return
+	}
+	file_pkg_agent_scalingevents_scalingevents_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_agent_scalingevents_scalingevents_proto_rawDesc), len(file_pkg_agent_scalingevents_scalingevents_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_pkg_agent_scalingevents_scalingevents_proto_goTypes,
+		DependencyIndexes: file_pkg_agent_scalingevents_scalingevents_proto_depIdxs,
+		MessageInfos:      file_pkg_agent_scalingevents_scalingevents_proto_msgTypes,
+	}.Build()
+	File_pkg_agent_scalingevents_scalingevents_proto = out.File
+	file_pkg_agent_scalingevents_scalingevents_proto_goTypes = nil
+	file_pkg_agent_scalingevents_scalingevents_proto_depIdxs = nil
+}
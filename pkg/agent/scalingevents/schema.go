@@ -0,0 +1,69 @@
+package scalingevents
+
+// Schema for the non-JSON batch formats. This must be kept in sync with scalingevents.proto.
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/scalingevents/scalingeventspb"
+	"github.com/neondatabase/autoscaling/pkg/reporting"
+)
+
+var scalingEventSchema = &reporting.Schema[ScalingEvent]{
+	Name:    "scalingevents.ScalingEvent",
+	Version: 2,
+	Fields: []reporting.Field[ScalingEvent]{
+		reporting.TimestampField("timestamp", func(e ScalingEvent) time.Time { return e.Timestamp }),
+		reporting.StringField("region", func(e ScalingEvent) string { return e.Region }),
+		reporting.StringField("endpoint_id", func(e ScalingEvent) string { return e.EndpointID }),
+		reporting.StringField("kind", func(e ScalingEvent) string { return string(e.Kind) }),
+		reporting.Int64Field("current_cu", func(e ScalingEvent) int64 { return int64(e.CurrentMilliCU) }),
+		reporting.Int64Field("target_cu", func(e ScalingEvent) int64 { return int64(e.TargetMilliCU) }),
+		reporting.OptionalDoubleField("goal_cpu", goalComponent(func(g *GoalCUComponents) *float64 { return g.CPU })),
+		reporting.OptionalDoubleField("goal_mem", goalComponent(func(g *GoalCUComponents) *float64 { return g.Mem })),
+		reporting.OptionalDoubleField("goal_lfc", goalComponent(func(g *GoalCUComponents) *float64 { return g.LFC })),
+		reporting.OptionalDoubleField("goal_cpu_forecast", goalComponent(func(g *GoalCUComponents) *float64 { return g.CPUForecast })),
+		reporting.OptionalDoubleField("goal_scheduled", goalComponent(func(g *GoalCUComponents) *float64 { return g.Scheduled })),
+		reporting.OptionalDoubleField("goal_psi", goalComponent(func(g *GoalCUComponents) *float64 { return g.PSI })),
+		reporting.OptionalDoubleField("goal_override", goalComponent(func(g *GoalCUComponents) *float64 { return g.Override })),
+		// Added in version 2:
+		reporting.StringField("limit", func(e ScalingEvent) string { return e.Limit }),
+		reporting.OptionalDoubleField("latency_seconds", func(e ScalingEvent) *float64 { return e.LatencySeconds }),
+	},
+	ToProto: func(e ScalingEvent) proto.Message {
+		g := e.GoalComponents
+		if g == nil {
+			g = &GoalCUComponents{} //nolint:exhaustruct // all components are absent
+		}
+		return &scalingeventspb.ScalingEvent{
+			Timestamp:       e.Timestamp.UnixMicro(),
+			Region:          e.Region,
+			EndpointId:      e.EndpointID,
+			Kind:            string(e.Kind),
+			CurrentCu:       int64(e.CurrentMilliCU),
+			TargetCu:        int64(e.TargetMilliCU),
+			GoalCpu:         g.CPU,
+			GoalMem:         g.Mem,
+			GoalLfc:         g.LFC,
+			GoalCpuForecast: g.CPUForecast,
+			GoalScheduled:   g.Scheduled,
+			GoalPsi:         g.PSI,
+			GoalOverride:    g.Override,
+			Limit:           e.Limit,
+			LatencySeconds:  e.LatencySeconds,
+		}
+	},
+}
+
+// goalComponent returns a function to get a single component from the event's GoalComponents, which
+// may be nil.
+func goalComponent(get func(*GoalCUComponents) *float64) func(ScalingEvent) *float64 {
+	return func(e ScalingEvent) *float64 {
+		if e.GoalComponents == nil {
+			return nil
+		}
+		return get(e.GoalComponents)
+	}
+}
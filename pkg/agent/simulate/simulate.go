@@ -0,0 +1,219 @@
+package simulate
+
+// Offline simulation of the autoscaler-agent's scaling decisions for a single VM.
+//
+// We drive a core.State with a fake clock, feeding it a recorded time series of metrics and
+// immediately granting every request it makes to the scheduler plugin, vm-monitor, and NeonVM.
+// This gives an upper bound on how quickly the VM would be scaled with a particular config, which is
+// useful for comparing configs against each other.
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	helpers "github.com/neondatabase/autoscaling/pkg/agent/core/testhelpers"
+	"github.com/neondatabase/autoscaling/pkg/api"
+)
+
+// Sample is a single point in the recorded time series of metrics.
+//
+// Either or both of System and LFC may be nil, if they weren't fetched at that time.
+type Sample struct {
+	At     time.Time           `json:"at"`
+	System *core.SystemMetrics `json:"system,omitempty"`
+	LFC    *core.LFCMetrics    `json:"lfc,omitempty"`
+}
+
+// TimelinePoint records the VM's current and goal CU, whenever either of them changes.
+type TimelinePoint struct {
+	// Offset is the time since the first sample.
+	Offset time.Duration `json:"offset"`
+	// CurrentCU is the number of compute units the VM has, rounded up.
+	CurrentCU uint32 `json:"currentCU"`
+	// GoalCU is the most recent goal CU, before it's bounded by the VM's min and max.
+	GoalCU uint32 `json:"goalCU"`
+}
+
+// Summary gives the aggregate results of a simulation.
+type Summary struct {
+	Duration time.Duration `json:"duration"`
+
+	// CUHours is the total of the VM's compute units over time, in CU × hours.
+	CUHours float64 `json:"cuHours"`
+	// AverageCU is CUHours divided by the duration.
+	AverageCU float64 `json:"averageCU"`
+	MinCU     uint32  `json:"minCU"`
+	MaxCU     uint32  `json:"maxCU"`
+
+	Upscales   int `json:"upscales"`
+	Downscales int `json:"downscales"`
+
+	// UnderGoal is the total time during which the VM had fewer compute units than its goal, after
+	// bounding the goal by the VM's max.
+	UnderGoal time.Duration `json:"underGoal"`
+}
+
+// Result is the output of Run.
+type Result struct {
+	Timeline []TimelinePoint `json:"timeline"`
+	Summary  Summary         `json:"summary"`
+}
+
+// Run simulates scaling the VM according to the metrics in samples, calling NextActions every step.
+//
+// The Log, RevisionSource, and ObservabilityCallbacks fields of config are overwritten.
+func Run(vm api.VmInfo, config core.Config, samples []Sample, step time.Duration) (*Result, error) {
+	if len(samples) == 0 {
+		return nil, errors.New("no samples")
+	} else if step <= 0 {
+		return nil, errors.New("step must be greater than zero")
+	}
+	for i := 1; i < len(samples); i++ {
+		if samples[i].At.Before(samples[i-1].At) {
+			return nil, fmt.Errorf("samples out of order: sample %d is before sample %d", i, i-1)
+		}
+	}
+
+	// Start the clock at the first sample, so that the times given to the State match the samples.
+	start := samples[0].At
+	clock := helpers.NewFakeClockAt(nil, start)
+	end := samples[len(samples)-1].At.Sub(start)
+
+	var goalCU uint32
+	config.Log = core.LogConfig{Info: nil, Warn: nil}
+	config.RevisionSource = &helpers.NilRevisionSource{}
+	config.ObservabilityCallbacks = core.ObservabilityCallbacks{
+		PluginLatency:  nil,
+		MonitorLatency: nil,
+		NeonVMLatency:  nil,
+		ActualScaling:  nil,
+		HypotheticalScaling: func(_ time.Time, _ uint32, target uint32, _ core.ScalingGoalParts) {
+			goalCU = target
+		},
+		DeniedScaling: nil,
+	}
+
+	state := core.NewState(vm, config)
+	state.Monitor().Active(true)
+
+	cuOf := func(r api.Resources) uint32 {
+		// note: (x + M - 1) / M gives ceil(x / M) for integers.
+		return max(
+			uint32((r.VCPU+config.ComputeUnit.VCPU-1)/config.ComputeUnit.VCPU),
+			uint32((r.Mem+config.ComputeUnit.Mem-1)/config.ComputeUnit.Mem),
+		)
+	}
+	currentCU := func() uint32 { return cuOf(vm.Using()) }
+	maxCU := cuOf(vm.Max())
+
+	var result Result
+	result.Summary.MinCU = currentCU()
+	result.Summary.MaxCU = currentCU()
+
+	record := func(offset time.Duration) {
+		point := TimelinePoint{Offset: offset, CurrentCU: currentCU(), GoalCU: goalCU}
+		if n := len(result.Timeline); n != 0 {
+			last := result.Timeline[n-1]
+			if last.CurrentCU == point.CurrentCU && last.GoalCU == point.GoalCU {
+				return
+			}
+		}
+		result.Timeline = append(result.Timeline, point)
+	}
+
+	nextSample := 0
+	for offset := time.Duration(0); offset <= end; offset += step {
+		if offset != 0 {
+			clock.Inc(step)
+		}
+		now := clock.Now()
+
+		for ; nextSample < len(samples) && samples[nextSample].At.Sub(start) <= offset; nextSample++ {
+			if m := samples[nextSample].System; m != nil {
+				state.UpdateSystemMetrics(now, *m)
+			}
+			if m := samples[nextSample].LFC; m != nil {
+				state.UpdateLFCMetrics(*m)
+			}
+		}
+
+		before := currentCU()
+		if err := respondToActions(state, &vm, now); err != nil {
+			return nil, fmt.Errorf("at offset %s: %w", offset, err)
+		}
+		after := currentCU()
+
+		if after > before {
+			result.Summary.Upscales++
+		} else if after < before {
+			result.Summary.Downscales++
+		}
+		result.Summary.MinCU = min(result.Summary.MinCU, after)
+		result.Summary.MaxCU = max(result.Summary.MaxCU, after)
+
+		record(offset)
+
+		// Account for the time until the next step
+		if offset+step <= end {
+			result.Summary.CUHours += float64(after) * step.Hours()
+			if min(goalCU, maxCU) > after {
+				result.Summary.UnderGoal += step
+			}
+		}
+	}
+
+	result.Summary.Duration = end
+	if end > 0 {
+		result.Summary.AverageCU = result.Summary.CUHours / end.Hours()
+	}
+
+	return &result, nil
+}
+
+// maxActionsPerStep is the maximum number of times we'll call NextActions at a single point in
+// time. Each call should make progress, so reaching this limit indicates a bug in core.State.
+const maxActionsPerStep = 16
+
+// respondToActions repeatedly calls NextActions, immediately granting any requests, until there is
+// nothing left to do at this point in time.
+//
+// Any changes to the VM's resources are also applied to vm.
+func respondToActions(state *core.State, vm *api.VmInfo, now time.Time) error {
+	for i := 0; i < maxActionsPerStep; i++ {
+		actions := state.NextActions(now)
+
+		if actions.PluginRequest == nil && actions.MonitorDownscale == nil &&
+			actions.MonitorUpscale == nil && actions.NeonVMRequest == nil {
+			return nil
+		}
+
+		if a := actions.PluginRequest; a != nil {
+			state.Plugin().StartingRequest(now, a.Target)
+			err := state.Plugin().RequestSuccessful(now, a.TargetRevision, api.PluginResponse{
+				Permit:  a.Target,
+				Migrate: nil,
+				Partial: nil,
+			})
+			if err != nil {
+				return fmt.Errorf("plugin request failed: %w", err)
+			}
+		}
+		if a := actions.MonitorDownscale; a != nil {
+			state.Monitor().StartingDownscaleRequest(now, a.Target)
+			state.Monitor().DownscaleRequestAllowed(now, a.TargetRevision)
+		}
+		if a := actions.MonitorUpscale; a != nil {
+			state.Monitor().StartingUpscaleRequest(now, a.Target)
+			state.Monitor().UpscaleRequestSuccessful(now)
+		}
+		if a := actions.NeonVMRequest; a != nil {
+			state.NeonVM().StartingRequest(now, a.Target)
+			state.NeonVM().RequestSuccessful(now)
+			vm.SetUsing(a.Target)
+		}
+	}
+
+	return fmt.Errorf("no steady state after %d calls to NextActions", maxActionsPerStep)
+}
@@ -0,0 +1,83 @@
+package simulate_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/neondatabase/autoscaling/pkg/agent/core"
+	helpers "github.com/neondatabase/autoscaling/pkg/agent/core/testhelpers"
+	"github.com/neondatabase/autoscaling/pkg/agent/simulate"
+	"github.com/neondatabase/autoscaling/pkg/api"
+)
+
+func TestRunUpscaleThenDownscale(t *testing.T) {
+	computeUnit := api.Resources{VCPU: 250, Mem: 1 << 30}
+
+	vm := helpers.CreateVmInfo(helpers.InitialVmInfoConfig{
+		ComputeUnit:    computeUnit,
+		MemorySlotSize: 1 << 30,
+		MinCU:          1,
+		MaxCU:          4,
+	})
+
+	//nolint:exhaustruct // this is a test
+	config := core.Config{
+		ComputeUnit: computeUnit,
+		DefaultScalingConfig: api.ScalingConfig{
+			LoadAverageFractionTarget:        lo.ToPtr(0.5),
+			MemoryUsageFractionTarget:        lo.ToPtr(0.5),
+			MemoryTotalFractionTarget:        lo.ToPtr(0.9),
+			EnableLFCMetrics:                 lo.ToPtr(false),
+			LFCUseLargestWindow:              lo.ToPtr(false),
+			LFCToMemoryRatio:                 lo.ToPtr(0.75),
+			LFCWindowSizeMinutes:             lo.ToPtr(5),
+			LFCMinWaitBeforeDownscaleMinutes: lo.ToPtr(15),
+			CPUStableZoneRatio:               lo.ToPtr(0.0),
+			CPUMixedZoneRatio:                lo.ToPtr(0.0),
+		},
+		NeonVMRetryWait:                    5 * time.Second,
+		PluginRequestTick:                  5 * time.Second,
+		PluginRetryWait:                    3 * time.Second,
+		PluginDeniedRetryWait:              2 * time.Second,
+		PluginDegradedAfter:                0,
+		MonitorDeniedDownscaleCooldown:     5 * time.Second,
+		MonitorRequestedUpscaleValidPeriod: 10 * time.Second,
+		MonitorRetryWait:                   3 * time.Second,
+	}
+
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	metrics := func(load float64) *core.SystemMetrics {
+		//nolint:exhaustruct // this is a test
+		return &core.SystemMetrics{LoadAverage1Min: load, LoadAverage5Min: load}
+	}
+	samples := []simulate.Sample{
+		{At: start, System: metrics(0.0), LFC: nil},
+		// 0.6 load at 0.5 target -> 1.2 vCPU -> 4.8 CU -> goal of 5 CU, bounded to 4 CU
+		{At: start.Add(time.Minute), System: metrics(0.6), LFC: nil},
+		{At: start.Add(2 * time.Minute), System: metrics(0.0), LFC: nil},
+		{At: start.Add(time.Hour), System: metrics(0.0), LFC: nil},
+	}
+
+	result, err := simulate.Run(vm, config, samples, time.Second)
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Hour, result.Summary.Duration)
+	assert.Equal(t, uint32(1), result.Summary.MinCU)
+	assert.Equal(t, uint32(4), result.Summary.MaxCU)
+	assert.Equal(t, 1, result.Summary.Upscales)
+	assert.Equal(t, 1, result.Summary.Downscales)
+	// 1 CU for 59 minutes, 4 CU for 1 minute
+	assert.InDelta(t, (59.0+4.0)/60.0, result.Summary.CUHours, 1e-9)
+	assert.InDelta(t, (59.0+4.0)/60.0, result.Summary.AverageCU, 1e-9)
+	assert.Equal(t, time.Duration(0), result.Summary.UnderGoal)
+
+	assert.Equal(t, []simulate.TimelinePoint{
+		{Offset: 0, CurrentCU: 1, GoalCU: 0},
+		{Offset: time.Minute, CurrentCU: 4, GoalCU: 5},
+		{Offset: 2 * time.Minute, CurrentCU: 1, GoalCU: 0},
+	}, result.Timeline)
+}
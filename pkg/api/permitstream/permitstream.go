@@ -0,0 +1,117 @@
+// Package permitstream implements the streaming gRPC transport for the agent<->scheduler plugin
+// protocol, available from api.PluginProtoV6_0.
+//
+// Each autoscaler-agent Runner opens a single bidirectional stream to the scheduler plugin for its
+// VM. The agent sends a Request in place of each HTTP request, and the plugin sends a Response for
+// each one. Afterwards, the plugin continues to push a Response (with Seq = 0) whenever the permit
+// for the latest request changes, so that the agent doesn't need to poll for updates.
+//
+// Messages are encoded as JSON, using the same types as the HTTP transport.
+package permitstream
+
+import (
+	"context"
+	"encoding/json"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/encoding"
+
+	"github.com/neondatabase/autoscaling/pkg/api"
+)
+
+// Request is sent by the autoscaler-agent for each request to the scheduler plugin.
+type Request struct {
+	// Seq identifies the request, so that the agent can match the plugin's response to it.
+	//
+	// Seq is never zero, and is strictly increasing within a single stream.
+	Seq uint64 `json:"seq"`
+	// Request is the request itself. All requests in a stream must be for the same Pod.
+	Request api.AgentRequest `json:"request"`
+}
+
+// Response is sent by the scheduler plugin, either in response to a Request or when the permit for
+// the latest Request has changed.
+type Response struct {
+	// Seq is the Seq of the Request this is in response to, or zero if the plugin is pushing an
+	// updated permit for the latest request.
+	Seq uint64 `json:"seq"`
+	// Response is the response itself.
+	Response api.PluginResponse `json:"response"`
+}
+
+// ClientStream is the autoscaler-agent's side of a permit stream.
+type ClientStream = grpc.BidiStreamingClient[Request, Response]
+
+// ServerStream is the scheduler plugin's side of a permit stream.
+type ServerStream = grpc.BidiStreamingServer[Request, Response]
+
+// Server is implemented by the scheduler plugin to handle permit streams.
+type Server interface {
+	// Stream handles a single permit stream, returning when the stream is finished.
+	//
+	// Errors should be created with the google.golang.org/grpc/status package, so that the agent
+	// receives a meaningful status code.
+	Stream(ServerStream) error
+}
+
+const (
+	serviceName = "autoscaling.plugin.Permits"
+	streamName  = "Stream"
+	// codecName is the content-subtype used for messages, which are always JSON.
+	codecName = "json"
+)
+
+var serviceDesc = grpc.ServiceDesc{
+	ServiceName: serviceName,
+	HandlerType: (*Server)(nil),
+	Methods:     nil,
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName: streamName,
+			Handler: func(srv any, stream grpc.ServerStream) error {
+				return srv.(Server).Stream(&grpc.GenericServerStream[Request, Response]{ServerStream: stream})
+			},
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "permitstream",
+}
+
+func init() {
+	encoding.RegisterCodec(jsonCodec{})
+}
+
+// RegisterServer registers the permit stream service with the gRPC server.
+func RegisterServer(s grpc.ServiceRegistrar, srv Server) {
+	s.RegisterService(&serviceDesc, srv)
+}
+
+// Open starts a new permit stream on the connection. The stream is closed when ctx is canceled.
+func Open(ctx context.Context, conn grpc.ClientConnInterface) (ClientStream, error) {
+	stream, err := conn.NewStream(
+		ctx,
+		&serviceDesc.Streams[0],
+		"/"+serviceName+"/"+streamName,
+		grpc.CallContentSubtype(codecName),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &grpc.GenericClientStream[Request, Response]{ClientStream: stream}, nil
+}
+
+// jsonCodec implements encoding.Codec for the messages in a permit stream
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+func (jsonCodec) Name() string {
+	return codecName
+}
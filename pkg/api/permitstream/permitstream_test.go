@@ -0,0 +1,104 @@
+package permitstream_test
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/api/permitstream"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+// echoServer responds to each request with a permit equal to the requested resources, followed by
+// a pushed permit with double the resources, and then fails the stream once the pod changes.
+type echoServer struct{}
+
+func (echoServer) Stream(stream permitstream.ServerStream) error {
+	var pod *util.NamespacedName
+	for {
+		req, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+		if pod != nil && *pod != req.Request.Pod {
+			return status.Error(codes.InvalidArgument, "pod changed")
+		}
+		pod = &req.Request.Pod
+
+		//nolint:exhaustruct // this is a test
+		if err := stream.Send(&permitstream.Response{
+			Seq:      req.Seq,
+			Response: api.PluginResponse{Permit: req.Request.Resources},
+		}); err != nil {
+			return err
+		}
+		//nolint:exhaustruct // this is a test
+		if err := stream.Send(&permitstream.Response{
+			Seq:      0,
+			Response: api.PluginResponse{Permit: req.Request.Resources.Mul(2)},
+		}); err != nil {
+			return err
+		}
+	}
+}
+
+func TestStream(t *testing.T) {
+	listener := bufconn.Listen(1 << 16)
+	server := grpc.NewServer()
+	permitstream.RegisterServer(server, echoServer{})
+	go func() { _ = server.Serve(listener) }()
+	defer server.Stop()
+
+	conn, err := grpc.NewClient(
+		"passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := permitstream.Open(ctx, conn)
+	require.NoError(t, err)
+
+	pod := util.NamespacedName{Namespace: "default", Name: "foo"}
+	resources := api.Resources{VCPU: 250, Mem: 1 << 30}
+
+	//nolint:exhaustruct // this is a test
+	require.NoError(t, stream.Send(&permitstream.Request{
+		Seq:     1,
+		Request: api.AgentRequest{ProtoVersion: api.PluginProtoV6_0, Pod: pod, Resources: resources},
+	}))
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), resp.Seq)
+	assert.Equal(t, resources, resp.Response.Permit)
+
+	resp, err = stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), resp.Seq)
+	assert.Equal(t, resources.Mul(2), resp.Response.Permit)
+
+	// Errors from the server are returned with their status code
+	//nolint:exhaustruct // this is a test
+	require.NoError(t, stream.Send(&permitstream.Request{
+		Seq:     2,
+		Request: api.AgentRequest{ProtoVersion: api.PluginProtoV6_0, Pod: util.NamespacedName{Namespace: "default", Name: "bar"}},
+	}))
+	_, err = stream.Recv()
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
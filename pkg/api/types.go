@@ -84,9 +84,26 @@ const (
 	// Changes from v4.0:
 	//
 	// * Removed AgentRequest.metrics fields loadAvg5M and memoryUsageBytes
+	PluginProtoV5_0
+
+	// PluginProtoV6_0 represents v6.0 of the agent<->scheduler plugin protocol.
+	//
+	// Changes from v5.0:
+	//
+	// * Adds the streaming gRPC transport (see package permitstream), where the scheduler plugin
+	//   pushes updated permits to the autoscaler-agent as soon as they're approved.
+	//
+	// Messages are otherwise unchanged, so the HTTP transport may still be used with this version.
+	PluginProtoV6_0
+
+	// PluginProtoV6_1 represents v6.1 of the agent<->scheduler plugin protocol.
+	//
+	// Changes from v6.0:
+	//
+	// * Adds PluginResponse.Partial
 	//
 	// Currently the latest version.
-	PluginProtoV5_0
+	PluginProtoV6_1
 
 	// latestPluginProtoVersion represents the latest version of the agent<->scheduler plugin
 	// protocol
@@ -117,6 +134,10 @@ func (v PluginProtoVersion) String() string {
 		return "v4.0"
 	case PluginProtoV5_0:
 		return "v5.0"
+	case PluginProtoV6_0:
+		return "v6.0"
+	case PluginProtoV6_1:
+		return "v6.1"
 	default:
 		diff := v - latestPluginProtoVersion
 		return fmt.Sprintf("<unknown = %v + %d>", latestPluginProtoVersion, diff)
@@ -172,6 +193,23 @@ func (v PluginProtoVersion) IncludesExtendedMetrics() bool {
 	return v < PluginProtoV5_0
 }
 
+// SupportsPermitStreaming returns whether this version of the protocol may be used with the
+// streaming gRPC transport, where the scheduler plugin pushes updated permits to the
+// autoscaler-agent.
+//
+// This is true for version v6.0 and greater.
+func (v PluginProtoVersion) SupportsPermitStreaming() bool {
+	return v >= PluginProtoV6_0
+}
+
+// IncludesPartialPermits returns whether this version of the protocol allows the scheduler plugin
+// to explain partially approved requests with PluginResponse.Partial.
+//
+// This is true for version v6.1 and greater.
+func (v PluginProtoVersion) IncludesPartialPermits() bool {
+	return v >= PluginProtoV6_1
+}
+
 // AgentRequest is the type of message sent from an autoscaler-agent to the scheduler plugin on
 // behalf of a Pod on the agent's node.
 //
@@ -436,8 +474,44 @@ type PluginResponse struct {
 	// Migrate, if present, notifies the autoscaler-agent that its VM will be migrated away,
 	// alongside whatever other information may be useful.
 	Migrate *MigrateResponse `json:"migrate,omitempty"`
+
+	// Partial, if present, explains why the Permit is less than the requested resources.
+	//
+	// It is only set in protocol versions that support it, and only if the scheduler plugin is
+	// configured to do so.
+	Partial *PartialPermit `json:"partial,omitempty"`
 }
 
+// PartialPermit explains why a request was only partially approved, so that the autoscaler-agent
+// can decide when to retry.
+type PartialPermit struct {
+	// Reason is the reason that the rest of the request could not be approved.
+	Reason PartialPermitReason `json:"reason"`
+	// Message gives additional human-readable detail, for logging.
+	Message string `json:"message"`
+	// RetryAfterSeconds, if not zero, is the scheduler plugin's estimate of how long it will be
+	// until more resources may be approved.
+	RetryAfterSeconds uint `json:"retryAfterSeconds,omitempty"`
+}
+
+// PartialPermitReason is the reason that a request was only partially approved
+type PartialPermitReason string
+
+const (
+	// PartialPermitReasonPending means that the scheduler plugin hasn't finished processing the
+	// request yet, and the Permit is the last one the autoscaler-agent received.
+	PartialPermitReasonPending PartialPermitReason = "pending"
+	// PartialPermitReasonPodMigrating means that the VM is currently being migrated, and won't be
+	// granted more resources until the migration is complete.
+	PartialPermitReasonPodMigrating PartialPermitReason = "podMigrating"
+	// PartialPermitReasonMigrationInProgress means that the node is over its watermark, and more
+	// resources may be approved once ongoing migrations away from the node have finished.
+	PartialPermitReasonMigrationInProgress PartialPermitReason = "migrationInProgress"
+	// PartialPermitReasonNodeFull means that the node doesn't have enough resources, and no
+	// migrations are in progress to free up more.
+	PartialPermitReasonNodeFull PartialPermitReason = "nodeFull"
+)
+
 // MigrateResponse, when provided, is a notification to the autsocaler-agent that it will migrate
 //
 // After receiving a MigrateResponse, the autoscaler-agent MUST NOT change its resource allocation.
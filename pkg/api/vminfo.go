@@ -6,6 +6,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/samber/lo"
 	"github.com/tychoish/fun/erc"
@@ -26,6 +30,7 @@ const (
 	AnnotationAutoscalingBounds   = "autoscaling.neon.tech/bounds"
 	AnnotationAutoscalingConfig   = "autoscaling.neon.tech/config"
 	AnnotationAutoscalingUnit     = "autoscaling.neon.tech/scaling-unit"
+	AnnotationAutoscalingSchedule = "autoscaling.neon.tech/scaling-schedule"
 	AnnotationBillingEndpointID   = "autoscaling.neon.tech/billing-endpoint-id"
 
 	// For internal use only, between the autoscaler-agent and scheduler plugin:
@@ -150,6 +155,9 @@ type VmConfig struct {
 	AlwaysMigrate  bool           `json:"alwaysMigrate"`
 	ScalingEnabled bool           `json:"scalingEnabled"`
 	ScalingConfig  *ScalingConfig `json:"scalingConfig,omitempty"`
+	// ScalingSchedule, if not nil, gives the time windows during which the VM must be kept at or
+	// above some minimum amount of resources, regardless of metrics.
+	ScalingSchedule *ScalingSchedule `json:"scalingSchedule,omitempty"`
 }
 
 // Using returns the Resources that this VmInfo says the VM is using
@@ -248,6 +256,7 @@ func extractVmInfoGeneric(
 			AlwaysMigrate:        alwaysMigrate,
 			ScalingEnabled:       scalingEnabled,
 			ScalingConfig:        nil, // set below, maybe
+			ScalingSchedule:      nil, // set below, maybe
 		},
 		CurrentRevision: nil, // set later, maybe
 	}
@@ -264,6 +273,18 @@ func extractVmInfoGeneric(
 		info.applyBounds(bounds)
 	}
 
+	if scheduleJSON, ok := obj.GetObjectMeta().GetAnnotations()[AnnotationAutoscalingSchedule]; ok {
+		var schedule ScalingSchedule
+		if err := json.Unmarshal([]byte(scheduleJSON), &schedule); err != nil {
+			return nil, fmt.Errorf("error unmarshaling annotation %q: %w", AnnotationAutoscalingSchedule, err)
+		}
+
+		if err := schedule.Validate(&resources.MemorySlotSize); err != nil {
+			return nil, fmt.Errorf("bad scaling schedule in annotation %q: %w", AnnotationAutoscalingSchedule, err)
+		}
+		info.Config.ScalingSchedule = &schedule
+	}
+
 	if configJSON, ok := obj.GetObjectMeta().GetAnnotations()[AnnotationAutoscalingConfig]; ok {
 		var config ScalingConfig
 		if err := json.Unmarshal([]byte(configJSON), &config); err != nil {
@@ -369,6 +390,198 @@ func (b ResourceBounds) validate(ec *erc.Collector, path string, memSlotSize *re
 	}
 }
 
+// ScalingSchedule is the type that we deserialize from the "autoscaling.neon.tech/scaling-schedule"
+// annotation.
+//
+// During each window, the autoscaler-agent will not scale the VM below the window's minimum
+// resources (but still within the VM's bounds). Outside of all windows, the schedule has no effect.
+type ScalingSchedule struct {
+	Windows []ScheduledWindow `json:"windows"`
+}
+
+// ScheduledWindow is a single recurring window of time, in the style of a cron schedule.
+//
+// For example, business hours on weekdays could be given by:
+//
+//	{"days": ["mon", "tue", "wed", "thu", "fri"], "start": "09:00", "end": "17:00",
+//	 "timezone": "Europe/Berlin", "min": {"cpu": 1, "mem": "4Gi"}}
+type ScheduledWindow struct {
+	// Days gives the days of the week on which the window starts, as lowercase three-letter names
+	// (e.g. "mon"). If empty, the window starts every day.
+	Days []string `json:"days,omitempty"`
+	// Start gives the time of day that the window starts, in 24-hour "HH:MM" format.
+	Start string `json:"start"`
+	// End gives the time of day that the window ends, in 24-hour "HH:MM" format. If End is not
+	// after Start, the window continues past midnight into the following day.
+	End string `json:"end"`
+	// Timezone is the IANA name of the timezone that Start and End are in. Defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+	// Min gives the minimum resources that the VM must have during the window.
+	Min ResourceBounds `json:"min"`
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Validate checks that all of the windows in the ScalingSchedule are well-formed.
+func (s ScalingSchedule) Validate(memSlotSize *resource.Quantity) error {
+	ec := &erc.Collector{}
+
+	for i, w := range s.Windows {
+		path := fmt.Sprintf(".windows[%d]", i)
+		errAt := func(field string, err error) error {
+			return fmt.Errorf("error at %s%s: %w", path, field, err)
+		}
+
+		for j, day := range w.Days {
+			if _, ok := weekdayNames[day]; !ok {
+				ec.Add(errAt(fmt.Sprintf(".days[%d]", j), fmt.Errorf("unknown day %q", day)))
+			}
+		}
+		if _, err := parseTimeOfDay(w.Start); err != nil {
+			ec.Add(errAt(".start", err))
+		}
+		if _, err := parseTimeOfDay(w.End); err != nil {
+			ec.Add(errAt(".end", err))
+		}
+		if _, err := loadLocation(w.Timezone); err != nil {
+			ec.Add(errAt(".timezone", err))
+		}
+		w.Min.validate(ec, path+".min", memSlotSize)
+	}
+
+	return ec.Resolve()
+}
+
+// ActiveMinimum returns the minimum resources required by the windows that are active at the
+// given time, alongside the earliest time that any of those windows ends.
+//
+// If no windows are active, ActiveMinimum returns nil.
+func (s ScalingSchedule) ActiveMinimum(now time.Time) (*Resources, time.Time) {
+	var result *Resources
+	var until time.Time
+
+	for _, w := range s.Windows {
+		end, active := w.activeAt(now)
+		if !active {
+			continue
+		}
+
+		windowMin := Resources{
+			VCPU: vmv1.MilliCPUFromResourceQuantity(w.Min.CPU),
+			Mem:  BytesFromResourceQuantity(w.Min.Mem),
+		}
+		if result == nil {
+			result = &windowMin
+			until = end
+		} else {
+			result = lo.ToPtr(result.Max(windowMin))
+			until = lo.Ternary(end.Before(until), end, until)
+		}
+	}
+
+	return result, until
+}
+
+// locations caches the timezones loaded by loadLocation, as a map of name to *time.Location.
+var locations sync.Map
+
+// loadLocation is time.LoadLocation, but caching the result -- time.LoadLocation reads and parses
+// the timezone database on every call, and we check schedules on every tick.
+//
+// NOTE: Binaries using ScalingSchedule should import "time/tzdata", in case the timezone database
+// isn't available on the host.
+func loadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, err
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// activeAt returns whether the window is active at the given time, and if so, when it ends.
+//
+// Assumes that the window has already been validated.
+func (w ScheduledWindow) activeAt(now time.Time) (end time.Time, active bool) {
+	loc, err := loadLocation(w.Timezone)
+	if err != nil {
+		return time.Time{}, false
+	}
+	startOffset, err1 := parseTimeOfDay(w.Start)
+	endOffset, err2 := parseTimeOfDay(w.End)
+	if err1 != nil || err2 != nil {
+		return time.Time{}, false
+	}
+
+	now = now.In(loc)
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+
+	// Because windows may cross midnight, check both the window starting today and the one that
+	// started yesterday.
+	for _, dayStart := range []time.Time{midnight, midnight.AddDate(0, 0, -1)} {
+		if !w.startsOn(dayStart.Weekday()) {
+			continue
+		}
+
+		// Use wall-clock times, so that windows are unaffected by DST transitions.
+		atOffset := func(day time.Time, offset time.Duration) time.Time {
+			hours, minutes := int(offset/time.Hour), int(offset%time.Hour/time.Minute)
+			return time.Date(day.Year(), day.Month(), day.Day(), hours, minutes, 0, 0, loc)
+		}
+		start := atOffset(dayStart, startOffset)
+		end := atOffset(dayStart, endOffset)
+		if endOffset <= startOffset {
+			end = atOffset(dayStart.AddDate(0, 0, 1), endOffset)
+		}
+
+		if !now.Before(start) && now.Before(end) {
+			return end, true
+		}
+	}
+
+	return time.Time{}, false
+}
+
+func (w ScheduledWindow) startsOn(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekdayNames[name] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTimeOfDay parses a "HH:MM" string into the offset from midnight
+func parseTimeOfDay(s string) (time.Duration, error) {
+	hh, mm, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("expected time in HH:MM format, got %q", s)
+	}
+	hours, err := strconv.Atoi(hh)
+	if err != nil || hours < 0 || hours > 23 {
+		return 0, fmt.Errorf("invalid hour in %q", s)
+	}
+	minutes, err := strconv.Atoi(mm)
+	if err != nil || minutes < 0 || minutes > 59 {
+		return 0, fmt.Errorf("invalid minute in %q", s)
+	}
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute, nil
+}
+
 // ScalingConfig provides bits of configuration for how the autoscaler-agent makes scaling decisions
 type ScalingConfig struct {
 	// LoadAverageFractionTarget sets the desired fraction of current CPU that the load average
@@ -437,6 +650,68 @@ type ScalingConfig struct {
 	// means that stable zone will be from 0.75*load5 to 1.25*load5, and mixed zone will be
 	// from 0.6*load5 to 0.75*load5, and from 1.25*load5 to 1.4*load5.
 	CPUMixedZoneRatio *float64 `json:"cpuMixedZoneRatio,omitempty"`
+
+	// EnableCPUForecast, if true, enables projecting the VM's recent load average forward in time,
+	// using a linear trend fitted over the last CPUForecastWindowSeconds of samples. The projected
+	// load is then used as an additional component of the goal CU, so that VMs with steadily
+	// increasing load can be upscaled before the load actually arrives.
+	//
+	// This field is optional, and defaults to false. If enabled, CPUForecastWindowSeconds and
+	// CPUForecastHorizonSeconds must also be set.
+	EnableCPUForecast *bool `json:"enableCPUForecast,omitempty"`
+
+	// CPUForecastWindowSeconds gives the duration of load average history that we fit the trend
+	// to, when EnableCPUForecast is true.
+	CPUForecastWindowSeconds *int `json:"cpuForecastWindowSeconds,omitempty"`
+
+	// CPUForecastHorizonSeconds gives how far past the most recent sample the load average is
+	// projected, when EnableCPUForecast is true.
+	CPUForecastHorizonSeconds *int `json:"cpuForecastHorizonSeconds,omitempty"`
+
+	// EnablePSIScaling, if true, enables an additional component of the goal CU based on pressure
+	// stall information (PSI) from the VM: if the share of time that tasks were stalled waiting on
+	// CPU or memory over the last 10 seconds exceeds PSIThresholdPercent, the goal CU is set to one
+	// more than the VM's current CU. While the pressure remains, further steps are only taken once
+	// the VM has reached the previous one and it's had time to take effect.
+	//
+	// This catches contention that's not visible in the load average, e.g. from CPU steal.
+	//
+	// This field is optional, and defaults to false. If enabled, PSIThresholdPercent must also be
+	// set. VMs that don't report PSI metrics are unaffected.
+	EnablePSIScaling *bool `json:"enablePSIScaling,omitempty"`
+
+	// PSIThresholdPercent gives the 10-second "some" pressure (from 0 to 100) above which we
+	// upscale, when EnablePSIScaling is true.
+	PSIThresholdPercent *float64 `json:"psiThresholdPercent,omitempty"`
+
+	// PSIStepCooldownSeconds gives the minimum time between successive increases of the goal CU
+	// from PSI, when EnablePSIScaling is true. Pressure is reported as a 10-second average, so it
+	// takes a while after upscaling before it drops.
+	//
+	// This field is optional, and defaults to 30 seconds.
+	PSIStepCooldownSeconds *int `json:"psiStepCooldownSeconds,omitempty"`
+
+	// DownscaleStabilizationSeconds, if set and greater than zero, requires that the goal CU stays
+	// below the VM's current CU for this many seconds before we downscale. When the window has
+	// passed, we only downscale to the highest goal CU seen during it.
+	//
+	// The window restarts whenever the VM's current CU changes, so this also acts as a cooldown
+	// between successive downscaling (or after upscaling).
+	//
+	// This field is optional. If unset, downscaling happens as soon as the goal CU is lower.
+	DownscaleStabilizationSeconds *int `json:"downscaleStabilizationSeconds,omitempty"`
+
+	// DownscaleMaxStepCU, if set and greater than zero, limits the number of compute units that we
+	// will downscale by in a single decision.
+	//
+	// This field is optional. If unset, there is no limit. Upscaling is never limited.
+	DownscaleMaxStepCU *int `json:"downscaleMaxStepCU,omitempty"`
+
+	// GoalPolicy selects, by name, the policy that the autoscaler-agent uses to determine the goal
+	// CU from the VM's metrics. Custom policies must be registered with the autoscaler-agent.
+	//
+	// This field is optional. If unset, the built-in "default" policy is used.
+	GoalPolicy *string `json:"goalPolicy,omitempty"`
 }
 
 // WithOverrides returns a new copy of defaults, where fields set in overrides replace the ones in
@@ -480,6 +755,37 @@ func (defaults ScalingConfig) WithOverrides(overrides *ScalingConfig) ScalingCon
 		defaults.CPUMixedZoneRatio = lo.ToPtr(*overrides.CPUMixedZoneRatio)
 	}
 
+	if overrides.EnableCPUForecast != nil {
+		defaults.EnableCPUForecast = lo.ToPtr(*overrides.EnableCPUForecast)
+	}
+	if overrides.CPUForecastWindowSeconds != nil {
+		defaults.CPUForecastWindowSeconds = lo.ToPtr(*overrides.CPUForecastWindowSeconds)
+	}
+	if overrides.CPUForecastHorizonSeconds != nil {
+		defaults.CPUForecastHorizonSeconds = lo.ToPtr(*overrides.CPUForecastHorizonSeconds)
+	}
+
+	if overrides.EnablePSIScaling != nil {
+		defaults.EnablePSIScaling = lo.ToPtr(*overrides.EnablePSIScaling)
+	}
+	if overrides.PSIThresholdPercent != nil {
+		defaults.PSIThresholdPercent = lo.ToPtr(*overrides.PSIThresholdPercent)
+	}
+	if overrides.PSIStepCooldownSeconds != nil {
+		defaults.PSIStepCooldownSeconds = lo.ToPtr(*overrides.PSIStepCooldownSeconds)
+	}
+
+	if overrides.DownscaleStabilizationSeconds != nil {
+		defaults.DownscaleStabilizationSeconds = lo.ToPtr(*overrides.DownscaleStabilizationSeconds)
+	}
+	if overrides.DownscaleMaxStepCU != nil {
+		defaults.DownscaleMaxStepCU = lo.ToPtr(*overrides.DownscaleMaxStepCU)
+	}
+
+	if overrides.GoalPolicy != nil {
+		defaults.GoalPolicy = lo.ToPtr(*overrides.GoalPolicy)
+	}
+
 	return defaults
 }
 
@@ -536,6 +842,38 @@ func (c *ScalingConfig) validate(requireAll bool) error {
 		erc.Whenf(ec, c.CPUMixedZoneRatio == nil, "%s is a required field", ".cpuMixedZoneRatio")
 	}
 
+	if c.CPUForecastWindowSeconds != nil {
+		erc.Whenf(ec, *c.CPUForecastWindowSeconds <= 0, "%s must be set to value > 0", ".cpuForecastWindowSeconds")
+	}
+	if c.CPUForecastHorizonSeconds != nil {
+		erc.Whenf(ec, *c.CPUForecastHorizonSeconds <= 0, "%s must be set to value > 0", ".cpuForecastHorizonSeconds")
+	}
+	if c.PSIThresholdPercent != nil {
+		erc.Whenf(ec, *c.PSIThresholdPercent <= 0.0, "%s must be set to value > 0", ".psiThresholdPercent")
+		erc.Whenf(ec, *c.PSIThresholdPercent >= 100.0, "%s must be set to value < 100", ".psiThresholdPercent")
+	}
+	if c.PSIStepCooldownSeconds != nil {
+		erc.Whenf(ec, *c.PSIStepCooldownSeconds < 0, "%s must be set to value >= 0", ".psiStepCooldownSeconds")
+	}
+	if c.DownscaleStabilizationSeconds != nil {
+		erc.Whenf(ec, *c.DownscaleStabilizationSeconds < 0, "%s must be set to value >= 0", ".downscaleStabilizationSeconds")
+	}
+	if c.DownscaleMaxStepCU != nil {
+		erc.Whenf(ec, *c.DownscaleMaxStepCU < 0, "%s must be set to value >= 0", ".downscaleMaxStepCU")
+	}
+	if c.GoalPolicy != nil {
+		erc.Whenf(ec, *c.GoalPolicy == "", "%s must not be empty if set", ".goalPolicy")
+	}
+
+	// If forecasting or PSI scaling is enabled in the defaults, it must be usable without any overrides.
+	if requireAll && lo.FromPtr(c.EnableCPUForecast) {
+		erc.Whenf(ec, c.CPUForecastWindowSeconds == nil, "%s is required when %s is true", ".cpuForecastWindowSeconds", ".enableCPUForecast")
+		erc.Whenf(ec, c.CPUForecastHorizonSeconds == nil, "%s is required when %s is true", ".cpuForecastHorizonSeconds", ".enableCPUForecast")
+	}
+	if requireAll && lo.FromPtr(c.EnablePSIScaling) {
+		erc.Whenf(ec, c.PSIThresholdPercent == nil, "%s is required when %s is true", ".psiThresholdPercent", ".enablePSIScaling")
+	}
+
 	// heads-up! some functions elsewhere depend on the concrete return type of this function.
 	return ec.Resolve()
 }
@@ -0,0 +1,229 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func TestScalingScheduleValidate(t *testing.T) {
+	memSlotSize := resource.MustParse("1Gi")
+
+	window := func(modify func(w *ScheduledWindow)) ScalingSchedule {
+		w := ScheduledWindow{
+			Days:     []string{"mon", "fri"},
+			Start:    "09:00",
+			End:      "17:30",
+			Timezone: "Europe/Berlin",
+			Min: ResourceBounds{
+				CPU: resource.MustParse("1"),
+				Mem: resource.MustParse("4Gi"),
+			},
+		}
+		modify(&w)
+		return ScalingSchedule{Windows: []ScheduledWindow{w}}
+	}
+
+	cases := []struct {
+		name     string
+		schedule ScalingSchedule
+		errors   []string
+	}{
+		{
+			name:     "valid",
+			schedule: window(func(w *ScheduledWindow) {}),
+			errors:   nil,
+		},
+		{
+			name:     "default-timezone",
+			schedule: window(func(w *ScheduledWindow) { w.Timezone = "" }),
+			errors:   nil,
+		},
+		{
+			name:     "bad-day",
+			schedule: window(func(w *ScheduledWindow) { w.Days = []string{"mon", "Tuesday"} }),
+			errors:   []string{`error at .windows[0].days[1]: unknown day "Tuesday"`},
+		},
+		{
+			name: "bad-times",
+			schedule: window(func(w *ScheduledWindow) {
+				w.Start = "9am"
+				w.End = "24:00"
+			}),
+			errors: []string{
+				`error at .windows[0].start: expected time in HH:MM format, got "9am"`,
+				`error at .windows[0].end: invalid hour in "24:00"`,
+			},
+		},
+		{
+			name:     "bad-timezone",
+			schedule: window(func(w *ScheduledWindow) { w.Timezone = "Mars/Olympus_Mons" }),
+			errors:   []string{"error at .windows[0].timezone: unknown time zone Mars/Olympus_Mons"},
+		},
+		{
+			name:     "bad-min",
+			schedule: window(func(w *ScheduledWindow) { w.Min.Mem = resource.MustParse("3.5Gi") }),
+			errors:   []string{"error at .windows[0].min.mem: must be divisible by VM memory slot size 1Gi"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.schedule.Validate(&memSlotSize)
+			if len(c.errors) == 0 {
+				assert.NoError(t, err)
+				return
+			}
+			require.Error(t, err)
+			for _, e := range c.errors {
+				assert.ErrorContains(t, err, e)
+			}
+		})
+	}
+}
+
+func TestScheduledWindowActiveAt(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	cases := []struct {
+		name   string
+		window ScheduledWindow
+		now    time.Time
+		active bool
+		end    time.Time
+	}{
+		{
+			name:   "utc-active",
+			window: ScheduledWindow{Days: nil, Start: "09:00", End: "17:00", Timezone: "", Min: ResourceBounds{}},
+			now:    time.Date(2026, time.June, 3, 12, 0, 0, 0, time.UTC),
+			active: true,
+			end:    time.Date(2026, time.June, 3, 17, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "utc-end-is-exclusive",
+			window: ScheduledWindow{Days: nil, Start: "09:00", End: "17:00", Timezone: "", Min: ResourceBounds{}},
+			now:    time.Date(2026, time.June, 3, 17, 0, 0, 0, time.UTC),
+			active: false,
+			end:    time.Time{},
+		},
+		{
+			// 2026-06-03 is a wednesday
+			name:   "wrong-day",
+			window: ScheduledWindow{Days: []string{"mon"}, Start: "09:00", End: "17:00", Timezone: "", Min: ResourceBounds{}},
+			now:    time.Date(2026, time.June, 3, 12, 0, 0, 0, time.UTC),
+			active: false,
+			end:    time.Time{},
+		},
+		{
+			// 2026-06-06 is a saturday; the window started on friday.
+			name:   "past-midnight",
+			window: ScheduledWindow{Days: []string{"fri"}, Start: "22:00", End: "06:00", Timezone: "", Min: ResourceBounds{}},
+			now:    time.Date(2026, time.June, 6, 3, 0, 0, 0, time.UTC),
+			active: true,
+			end:    time.Date(2026, time.June, 6, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:   "past-midnight-next-day",
+			window: ScheduledWindow{Days: []string{"fri"}, Start: "22:00", End: "06:00", Timezone: "", Min: ResourceBounds{}},
+			now:    time.Date(2026, time.June, 7, 3, 0, 0, 0, time.UTC),
+			active: false,
+			end:    time.Time{},
+		},
+		{
+			// 12:00 in Berlin is 10:00 UTC during summer time.
+			name:   "timezone",
+			window: ScheduledWindow{Days: nil, Start: "09:00", End: "11:00", Timezone: "Europe/Berlin", Min: ResourceBounds{}},
+			now:    time.Date(2026, time.June, 3, 10, 0, 0, 0, time.UTC),
+			active: false,
+			end:    time.Time{},
+		},
+		{
+			// On 2026-03-29, Berlin's clocks go from 02:00 to 03:00, so the window is only 2 hours
+			// long. 01:30 UTC is 03:30 CEST.
+			name:   "dst-start",
+			window: ScheduledWindow{Days: nil, Start: "01:00", End: "04:00", Timezone: "Europe/Berlin", Min: ResourceBounds{}},
+			now:    time.Date(2026, time.March, 29, 1, 30, 0, 0, time.UTC),
+			active: true,
+			end:    time.Date(2026, time.March, 29, 4, 0, 0, 0, berlin),
+		},
+		{
+			// 23:30 UTC on the day before is 00:30 CET, before the window starts.
+			name:   "dst-start-before",
+			window: ScheduledWindow{Days: nil, Start: "01:00", End: "04:00", Timezone: "Europe/Berlin", Min: ResourceBounds{}},
+			now:    time.Date(2026, time.March, 28, 23, 30, 0, 0, time.UTC),
+			active: false,
+			end:    time.Time{},
+		},
+		{
+			// On 2026-10-25, Berlin's clocks go from 03:00 back to 02:00, so the window is 4 hours
+			// long. 02:30 UTC is 03:30 CET, after the transition.
+			name:   "dst-end",
+			window: ScheduledWindow{Days: nil, Start: "01:00", End: "04:00", Timezone: "Europe/Berlin", Min: ResourceBounds{}},
+			now:    time.Date(2026, time.October, 25, 2, 30, 0, 0, time.UTC),
+			active: true,
+			end:    time.Date(2026, time.October, 25, 3, 0, 0, 0, time.UTC),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			end, active := c.window.activeAt(c.now)
+			assert.Equal(t, c.active, active)
+			assert.True(t, c.end.Equal(end), "expected end %v, got %v", c.end, end)
+		})
+	}
+}
+
+func TestScalingScheduleActiveMinimum(t *testing.T) {
+	schedule := ScalingSchedule{
+		Windows: []ScheduledWindow{
+			{
+				Days:     nil,
+				Start:    "08:00",
+				End:      "18:00",
+				Timezone: "",
+				Min:      ResourceBounds{CPU: resource.MustParse("2"), Mem: resource.MustParse("4Gi")},
+			},
+			{
+				Days:     nil,
+				Start:    "12:00",
+				End:      "14:00",
+				Timezone: "",
+				Min:      ResourceBounds{CPU: resource.MustParse("1"), Mem: resource.MustParse("8Gi")},
+			},
+		},
+	}
+
+	day := func(hour int) time.Time {
+		return time.Date(2026, time.June, 3, hour, 0, 0, 0, time.UTC)
+	}
+
+	minimum, until := schedule.ActiveMinimum(day(6))
+	assert.Nil(t, minimum)
+	assert.Equal(t, time.Time{}, until)
+
+	minimum, until = schedule.ActiveMinimum(day(9))
+	assert.Equal(t, &Resources{VCPU: 2000, Mem: 4 * 1024 * 1024 * 1024}, minimum)
+	assert.Equal(t, day(18), until)
+
+	// Both windows are active: take the larger of each resource, until the first one ends.
+	minimum, until = schedule.ActiveMinimum(day(13))
+	assert.Equal(t, &Resources{VCPU: 2000, Mem: 8 * 1024 * 1024 * 1024}, minimum)
+	assert.Equal(t, day(14), until)
+}
+
+func TestLoadLocationCached(t *testing.T) {
+	first, err := loadLocation("America/New_York")
+	require.NoError(t, err)
+	second, err := loadLocation("America/New_York")
+	require.NoError(t, err)
+	assert.Same(t, first, second)
+
+	_, err = loadLocation("not a timezone")
+	assert.Error(t, err)
+}
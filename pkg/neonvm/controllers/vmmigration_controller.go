@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"slices"
 	"time"
 
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -716,6 +717,17 @@ func (r *VirtualMachineMigrationReconciler) targetPodForVirtualMachine(
 	// TODO: make it false or empty after the migration is done to enable correct readiness probe
 	pod.Spec.Containers[0].Env = append(pod.Spec.Containers[0].Env, corev1.EnvVar{Name: "RECEIVE_MIGRATION", Value: "true"})
 
+	// add the migration's node affinity (e.g. a target node hint from the scheduler), in addition to
+	// the VM's own affinity
+	if migration.Spec.NodeAffinity != nil {
+		// copy, so that we don't modify the VM's affinity
+		pod.Spec.Affinity = pod.Spec.Affinity.DeepCopy()
+		if pod.Spec.Affinity == nil {
+			pod.Spec.Affinity = &corev1.Affinity{}
+		}
+		pod.Spec.Affinity.NodeAffinity = mergeNodeAffinity(pod.Spec.Affinity.NodeAffinity, migration.Spec.NodeAffinity)
+	}
+
 	// add podAntiAffinity to schedule target pod to another k8s node
 	if migration.Spec.PreventMigrationToSameHost {
 		if pod.Spec.Affinity == nil {
@@ -748,6 +760,44 @@ func (r *VirtualMachineMigrationReconciler) targetPodForVirtualMachine(
 	return pod, nil
 }
 
+// mergeNodeAffinity returns the node affinity that requires both a and b, and prefers the
+// preferences of each.
+func mergeNodeAffinity(a, b *corev1.NodeAffinity) *corev1.NodeAffinity {
+	if a == nil {
+		return b.DeepCopy()
+	}
+
+	merged := a.DeepCopy()
+	merged.PreferredDuringSchedulingIgnoredDuringExecution = append(
+		merged.PreferredDuringSchedulingIgnoredDuringExecution,
+		b.PreferredDuringSchedulingIgnoredDuringExecution...,
+	)
+
+	aReq := a.RequiredDuringSchedulingIgnoredDuringExecution
+	bReq := b.RequiredDuringSchedulingIgnoredDuringExecution
+	switch {
+	case bReq == nil || len(bReq.NodeSelectorTerms) == 0:
+		// nothing more to require
+	case aReq == nil || len(aReq.NodeSelectorTerms) == 0:
+		merged.RequiredDuringSchedulingIgnoredDuringExecution = bReq.DeepCopy()
+	default:
+		// NodeSelectorTerms are ORed, and the requirements within each term are ANDed -- so to
+		// require both, we need every combination of terms from each.
+		var terms []corev1.NodeSelectorTerm
+		for _, ta := range aReq.NodeSelectorTerms {
+			for _, tb := range bReq.NodeSelectorTerms {
+				terms = append(terms, corev1.NodeSelectorTerm{
+					MatchExpressions: slices.Concat(ta.MatchExpressions, tb.MatchExpressions),
+					MatchFields:      slices.Concat(ta.MatchFields, tb.MatchFields),
+				})
+			}
+		}
+		merged.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{NodeSelectorTerms: terms}
+	}
+
+	return merged
+}
+
 type blockDeviceMigrationError struct {
 	message string
 }
@@ -337,3 +337,52 @@ func Test_VMM_FailsWhenBlockDeviceNotRWX(t *testing.T) {
 	params.refetchVM(vm)
 	require.Equal(t, vmv1.VmRunning, vm.Status.Phase)
 }
+
+func Test_mergeNodeAffinity(t *testing.T) {
+	term := func(key string) corev1.NodeSelectorTerm {
+		//nolint:exhaustruct // this is a test
+		return corev1.NodeSelectorTerm{
+			MatchExpressions: []corev1.NodeSelectorRequirement{
+				{Key: key, Operator: corev1.NodeSelectorOpExists},
+			},
+		}
+	}
+	preferred := func(key string) corev1.PreferredSchedulingTerm {
+		return corev1.PreferredSchedulingTerm{Weight: 100, Preference: term(key)}
+	}
+
+	a := &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{term("a1"), term("a2")},
+		},
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{preferred("a")},
+	}
+	b := &corev1.NodeAffinity{
+		RequiredDuringSchedulingIgnoredDuringExecution: &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{term("b")},
+		},
+		PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{preferred("b")},
+	}
+
+	merged := mergeNodeAffinity(a, b)
+
+	// Each of a's terms must also satisfy b's term
+	require.Equal(t, []corev1.NodeSelectorTerm{
+		//nolint:exhaustruct // this is a test
+		{MatchExpressions: append(term("a1").MatchExpressions, term("b").MatchExpressions...)},
+		//nolint:exhaustruct // this is a test
+		{MatchExpressions: append(term("a2").MatchExpressions, term("b").MatchExpressions...)},
+	}, merged.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms)
+	require.Equal(
+		t,
+		[]corev1.PreferredSchedulingTerm{preferred("a"), preferred("b")},
+		merged.PreferredDuringSchedulingIgnoredDuringExecution,
+	)
+
+	// The inputs are not modified
+	require.Len(t, a.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms, 2)
+	require.Len(t, a.PreferredDuringSchedulingIgnoredDuringExecution, 1)
+
+	// With nothing to merge with, the result is a copy
+	require.Equal(t, b, mergeNodeAffinity(nil, b))
+}
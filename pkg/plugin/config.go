@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"slices"
+	"time"
 )
 
 //////////////////
@@ -53,6 +54,17 @@ type Config struct {
 	// successive patch operations on a VirtualMachine object.
 	PatchRetryWaitSeconds int `json:"patchRetryWaitSeconds"`
 
+	// ApprovalWaitMillis sets the maximum duration, in milliseconds, that we'll wait for more
+	// resources to be approved before responding to an autoscaler-agent request with whatever is
+	// currently approved.
+	//
+	// If zero or unset, defaults to DefaultApprovalWaitMillis.
+	ApprovalWaitMillis int `json:"approvalWaitMillis,omitempty"`
+
+	// PartialPermits, if not nil, enables explaining partially approved requests to
+	// autoscaler-agents that support it, with an estimate of when to retry.
+	PartialPermits *PartialPermitsConfig `json:"partialPermits,omitempty"`
+
 	// NodeMetricLabels gives additional labels to annotate node metrics with.
 	// The map is keyed by the metric name, and gives the kubernetes label that should be used to
 	// populate it.
@@ -65,6 +77,26 @@ type Config struct {
 	//   }
 	NodeMetricLabels map[string]string `json:"nodeMetricLabels"`
 
+	// MigrationLimits, if not nil, limits the live migrations triggered by the scheduler plugin.
+	MigrationLimits *MigrationLimitsConfig `json:"migrationLimits,omitempty"`
+
+	// GrowthReserve, if not nil, makes Filter and Score take into account the expected growth of
+	// the autoscaling VMs on each node, so that we avoid packing nodes where likely upscaling would
+	// immediately push the reserved resources above the watermark.
+	//
+	// Filter only rejects autoscaling VM pods for this; other pods can still be placed on the node.
+	GrowthReserve *GrowthReserveConfig `json:"growthReserve,omitempty"`
+
+	// Drain, if not nil, enables draining nodes that are marked with the DrainKey annotation or
+	// taint, by live migrating all VMs away from them.
+	Drain *DrainConfig `json:"drain,omitempty"`
+
+	// ZoneLabel, if provided, gives the node label that determines each node's availability zone,
+	// e.g. "topology.kubernetes.io/zone".
+	//
+	// When provided, VMs will only be live migrated to nodes in the same zone.
+	ZoneLabel string `json:"zoneLabel,omitempty"`
+
 	// IgnoredNamespaces, if provided, gives a list of namespaces that the plugin should completely
 	// ignore, as if pods from those namespaces do not exist.
 	//
@@ -103,6 +135,77 @@ type ScoringConfig struct {
 	// Randomize, if true, will cause the scheduler to score a node with a random number in the
 	// range [minScore + 1, trueScore], instead of the trueScore.
 	Randomize bool
+
+	// Strategies, if not empty, gives the strategies used to score nodes. The final score is the
+	// weighted average of the score from each strategy.
+	//
+	// If empty, nodes are scored only with the usage curve defined by MinUsageScore,
+	// MaxUsageScore, and ScorePeak (i.e. ScoringUsageCurve).
+	Strategies []ScoringStrategyConfig `json:"strategies,omitempty"`
+}
+
+// ScoringStrategyConfig selects one of the strategies for scoring nodes.
+type ScoringStrategyConfig struct {
+	// Name is the name of the strategy, e.g. ScoringLeastAllocated. Refer to the ScoringUsageCurve
+	// group of constants for the full list.
+	Name string `json:"name"`
+	// Weight is the relative weight of this strategy's score.
+	Weight float64 `json:"weight"`
+}
+
+// PartialPermitsConfig configures the estimated retry times included in responses to
+// autoscaler-agent requests that were only partially approved.
+type PartialPermitsConfig struct {
+	// MigrationRetryAfterSeconds gives the estimated time, in seconds, for ongoing live migrations
+	// to free up resources on the node. It's used when more resources can't be approved until
+	// migrations have finished, or if the VM itself is being migrated.
+	MigrationRetryAfterSeconds int `json:"migrationRetryAfterSeconds"`
+	// NodeFullRetryAfterSeconds gives the time, in seconds, that autoscaler-agents should wait
+	// before retrying when the node is full and no migrations are in progress.
+	NodeFullRetryAfterSeconds int `json:"nodeFullRetryAfterSeconds"`
+}
+
+// MigrationLimitsConfig limits the live migrations triggered by the scheduler plugin, so that we
+// don't disrupt too many VMs (or put too much load on the cluster) at once.
+//
+// Each namespace may additionally limit the number of its VMs being migrated at a time with the
+// AnnotationMigrationBudget annotation, regardless of this config.
+type MigrationLimitsConfig struct {
+	// MaxConcurrentPerNode, if not zero, sets the maximum number of live migrations away from each
+	// node that may be ongoing at a time.
+	MaxConcurrentPerNode int `json:"maxConcurrentPerNode"`
+	// MaxConcurrentTotal, if not zero, sets the maximum number of live migrations that may be
+	// ongoing at a time across the whole cluster.
+	MaxConcurrentTotal int `json:"maxConcurrentTotal"`
+	// MinIntervalSeconds, if not zero, sets the minimum duration, in seconds, between triggering
+	// live migrations of the same VM.
+	MinIntervalSeconds int `json:"minIntervalSeconds"`
+}
+
+// GrowthReserveConfig configures how the expected growth of VMs on a node is estimated.
+//
+// Refer to (*state.Node).ExpectedGrowth for more.
+type GrowthReserveConfig struct {
+	// Quantile selects, from the distribution of pending upscaling across the VMs on a node (as a
+	// fraction of how much each VM may still grow), the fraction that each VM is expected to grow
+	// by. It must be between 0 and 1, inclusive.
+	//
+	// For example, with a quantile of 0.9, each VM is expected to grow about as much as the 10% of
+	// VMs on the node that are growing the most.
+	Quantile float64 `json:"quantile"`
+	// MinFraction sets the minimum fraction of how much each VM may still grow (i.e., Max -
+	// Reserved) that it's expected to grow by. It must be between 0 and 1, inclusive.
+	MinFraction float64 `json:"minFraction"`
+}
+
+// DrainConfig configures how nodes are drained. See DrainKey for more.
+type DrainConfig struct {
+	// MaxConcurrentMigrations sets the maximum number of live migrations away from each draining
+	// node that may be ongoing at a time.
+	//
+	// This replaces MigrationLimitsConfig.MaxConcurrentPerNode for draining nodes. The cluster-wide
+	// limit and namespaces' migration budgets still apply.
+	MaxConcurrentMigrations int `json:"maxConcurrentMigrations"`
 }
 
 ///////////////////////
@@ -139,6 +242,34 @@ func (c *Config) validate() (string, error) {
 		return "patchRetryWaitSeconds", errors.New("value must be > 0")
 	}
 
+	if c.ApprovalWaitMillis < 0 {
+		return "approvalWaitMillis", errors.New("value must be >= 0")
+	}
+
+	if c.PartialPermits != nil {
+		if path, err := c.PartialPermits.validate(); err != nil {
+			return fmt.Sprintf("partialPermits.%s", path), err
+		}
+	}
+
+	if c.MigrationLimits != nil {
+		if path, err := c.MigrationLimits.validate(); err != nil {
+			return fmt.Sprintf("migrationLimits.%s", path), err
+		}
+	}
+
+	if c.GrowthReserve != nil {
+		if path, err := c.GrowthReserve.validate(); err != nil {
+			return fmt.Sprintf("growthReserve.%s", path), err
+		}
+	}
+
+	if c.Drain != nil {
+		if path, err := c.Drain.validate(); err != nil {
+			return fmt.Sprintf("drain.%s", path), err
+		}
+	}
+
 	if c.Watermark <= 0.0 {
 		return "watermark", errors.New("value must be > 0")
 	} else if c.Watermark > 1.0 {
@@ -157,6 +288,54 @@ func (c *ScoringConfig) validate() (string, error) {
 		return "scorePeak", errors.New("value must be between 0 and 1, inclusive")
 	}
 
+	for i, s := range c.Strategies {
+		if _, ok := scoringStrategies[s.Name]; !ok {
+			return fmt.Sprintf("strategies[%d].name", i), fmt.Errorf("unknown scoring strategy %q", s.Name)
+		} else if s.Weight <= 0 {
+			return fmt.Sprintf("strategies[%d].weight", i), errors.New("value must be > 0")
+		}
+	}
+
+	return "", nil
+}
+
+func (c *PartialPermitsConfig) validate() (string, error) {
+	if c.MigrationRetryAfterSeconds <= 0 {
+		return "migrationRetryAfterSeconds", errors.New("value must be > 0")
+	} else if c.NodeFullRetryAfterSeconds <= 0 {
+		return "nodeFullRetryAfterSeconds", errors.New("value must be > 0")
+	}
+
+	return "", nil
+}
+
+func (c *MigrationLimitsConfig) validate() (string, error) {
+	if c.MaxConcurrentPerNode < 0 {
+		return "maxConcurrentPerNode", errors.New("value must be >= 0")
+	} else if c.MaxConcurrentTotal < 0 {
+		return "maxConcurrentTotal", errors.New("value must be >= 0")
+	} else if c.MinIntervalSeconds < 0 {
+		return "minIntervalSeconds", errors.New("value must be >= 0")
+	}
+
+	return "", nil
+}
+
+func (c *GrowthReserveConfig) validate() (string, error) {
+	if c.Quantile < 0 || c.Quantile > 1 {
+		return "quantile", errors.New("value must be between 0 and 1, inclusive")
+	} else if c.MinFraction < 0 || c.MinFraction > 1 {
+		return "minFraction", errors.New("value must be between 0 and 1, inclusive")
+	}
+
+	return "", nil
+}
+
+func (c *DrainConfig) validate() (string, error) {
+	if c.MaxConcurrentMigrations <= 0 {
+		return "maxConcurrentMigrations", errors.New("value must be > 0")
+	}
+
 	return "", nil
 }
 
@@ -166,6 +345,9 @@ func (c *ScoringConfig) validate() (string, error) {
 
 const DefaultConfigPath = "/etc/scheduler-plugin-config/autoscale-enforcer-config.json"
 
+// DefaultApprovalWaitMillis is the value used for Config.ApprovalWaitMillis if it's not set.
+const DefaultApprovalWaitMillis = 1000
+
 func ReadConfig(path string) (*Config, error) {
 	file, err := os.Open(path)
 	if err != nil {
@@ -194,3 +376,11 @@ func ReadConfig(path string) (*Config, error) {
 func (c Config) ignoredNamespace(namespace string) bool {
 	return slices.Contains(c.IgnoredNamespaces, namespace)
 }
+
+func (c Config) approvalWait() time.Duration {
+	millis := c.ApprovalWaitMillis
+	if millis == 0 {
+		millis = DefaultApprovalWaitMillis
+	}
+	return time.Millisecond * time.Duration(millis)
+}
@@ -0,0 +1,69 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadConfigApprovalWait(t *testing.T) {
+	// A minimal config, from before approvalWaitMillis was added.
+	const baseConfig = `{
+		"watermark": 0.9,
+		"scoring": {"minUsageScore": 0.5, "maxUsageScore": 0, "scorePeak": 0.8},
+		"schedulerName": "autoscale-scheduler",
+		"reconcileWorkers": 16,
+		"logSuccessiveFailuresThreshold": 10,
+		"startupEventHandlingTimeoutSeconds": 15,
+		"patchRetryWaitSeconds": 1,
+		"k8sCRUDTimeoutSeconds": 1,
+		"nodeMetricLabels": {},
+		"ignoredNamespaces": []%s
+	}`
+
+	cases := []struct {
+		name     string
+		extra    string
+		expected time.Duration
+		err      string
+	}{
+		{
+			name:     "unset",
+			extra:    "",
+			expected: time.Second,
+			err:      "",
+		},
+		{
+			name:     "set",
+			extra:    `, "approvalWaitMillis": 250`,
+			expected: 250 * time.Millisecond,
+			err:      "",
+		},
+		{
+			name:     "negative",
+			extra:    `, "approvalWaitMillis": -1`,
+			expected: 0,
+			err:      "invalid config at approvalWaitMillis: value must be >= 0",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			require.NoError(t, os.WriteFile(path, fmt.Appendf(nil, baseConfig, c.extra), 0o644))
+
+			cfg, err := ReadConfig(path)
+			if c.err != "" {
+				assert.EqualError(t, err, c.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, c.expected, cfg.approvalWait())
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package plugin
+
+// Draining nodes by live migrating all VMs away from them.
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/samber/lo"
+	"go.uber.org/zap"
+
+	corev1 "k8s.io/api/core/v1"
+
+	"github.com/neondatabase/autoscaling/pkg/plugin/state"
+)
+
+// DrainKey is the annotation (with value "true") or taint (with any value or effect) that marks a
+// node to be drained, if enabled by the scheduler plugin's config.
+//
+// While a node is draining, no new pods are scheduled onto it, and the scheduler plugin live
+// migrates all VMs away from it.
+const DrainKey = "autoscaling.neon.tech/drain"
+
+// AnnotationDrainStatus is set by the scheduler plugin on draining nodes, with the JSON-encoded
+// DrainStatus.
+const AnnotationDrainStatus = "autoscaling.neon.tech/drain-status"
+
+// DrainStatus is the progress of draining a node
+type DrainStatus struct {
+	// Remaining is the number of VMs still on the node.
+	Remaining int `json:"remaining"`
+	// Migrating is the number of remaining VMs that are being migrated, or about to be.
+	Migrating int `json:"migrating"`
+	// Unmigratable is the number of remaining VMs that can't be live migrated. They must be
+	// removed from the node some other way.
+	Unmigratable int `json:"unmigratable"`
+	// Drained is true once there are no VMs remaining on the node.
+	Drained bool `json:"drained"`
+}
+
+// nodeDrainRequested returns whether the node has been marked to be drained
+func nodeDrainRequested(node *corev1.Node) bool {
+	if node.Annotations[DrainKey] == "true" {
+		return true
+	}
+	return slices.ContainsFunc(node.Spec.Taints, func(t corev1.Taint) bool {
+		return t.Key == DrainKey
+	})
+}
+
+// drainNode requests migrations for VMs on the draining node, up to the configured concurrency,
+// and returns a function to update the node's drain status, if it changed.
+//
+// NOTE: this function expects that the caller has acquired s.mu.
+func (s *PluginState) drainNode(logger *zap.Logger, ns *nodeState, nodeObj *corev1.Node) func() error {
+	now := time.Now()
+
+	// Draining uses its own per-node concurrency, but is still subject to the cluster-wide limit
+	// and namespaces' migration budgets. We don't enforce the minimum interval between migrations,
+	// because the VM can't stay here anyways.
+	limiter := s.newMigrationLimiter(logger, ns, now)
+	limiter.limits.MaxConcurrentPerNode = s.config.Drain.MaxConcurrentMigrations
+	limiter.limits.MinIntervalSeconds = 0
+
+	targets := s.migrationTargets(ns)
+
+	var status DrainStatus
+	var candidates []state.Pod
+	for uid, pod := range ns.node.Pods() {
+		if lo.IsEmpty(pod.VirtualMachine) {
+			continue
+		}
+
+		status.Remaining += 1
+		_, requested := ns.requestedMigrations[uid]
+		switch {
+		case pod.Migrating || requested:
+			status.Migrating += 1
+		case !pod.Migratable:
+			status.Unmigratable += 1
+		default:
+			candidates = append(candidates, pod)
+		}
+	}
+	status.Drained = status.Remaining == 0
+
+	slices.SortFunc(candidates, func(cx, cy state.Pod) int {
+		return cx.BetterMigrationTargetThan(cy)
+	})
+	for _, pod := range candidates {
+		podLogger := logger.With(zap.Object("Pod", pod))
+
+		if reason := limiter.limitReached(); reason != "" {
+			limiter.onThrottled(reason)
+			break
+		}
+		if reason := limiter.podThrottled(pod); reason != "" {
+			podLogger.Info("Not draining Pod yet because of migration limits", zap.String("Limit", reason))
+			limiter.onThrottled(reason)
+			continue
+		}
+
+		// Prefer a node with room for the VM, but migrate it anyways if there isn't one.
+		var targetNode string
+		if target := bestMigrationTarget(ns.node, targets, pod); target != nil {
+			targetNode = target.nodeName
+			target.cpuRoom -= pod.CPU.Reserved
+			target.memRoom -= pod.Mem.Reserved
+		}
+
+		podLogger.Info("Internally triggering migration to drain node", zap.String("TargetNode", targetNode))
+		if err := s.requeuePod(pod.UID); err != nil {
+			podLogger.Error("Failed to requeue reconciling of Pod to drain", zap.Error(err))
+			continue
+		}
+		ns.requestedMigrations[pod.UID] = targetNode
+		s.recordMigration(pod.VirtualMachine, now)
+		limiter.add(pod)
+		status.Migrating += 1
+	}
+
+	return s.updateDrainStatus(logger, ns, nodeObj, &status)
+}
+
+// updateDrainStatus returns a function to set the node's drain status annotation to the status, or
+// remove it if status is nil. If the annotation is already up-to-date, this returns nil.
+//
+// NOTE: this function expects that the caller has acquired s.mu.
+func (s *PluginState) updateDrainStatus(
+	logger *zap.Logger,
+	ns *nodeState,
+	nodeObj *corev1.Node,
+	status *DrainStatus,
+) func() error {
+	var value *string
+	if status != nil {
+		encoded, err := json.Marshal(status)
+		if err != nil {
+			panic(fmt.Errorf("could not marshal drain status: %w", err))
+		}
+		value = lo.ToPtr(string(encoded))
+	}
+
+	if lo.FromPtr(value) == ns.drainStatus {
+		return nil
+	}
+
+	var event func()
+	switch {
+	case status == nil:
+		// no longer draining; no event needed
+	case ns.drainStatus == "":
+		event = func() {
+			s.recordDrainEvent(nodeObj, corev1.EventTypeNormal, "DrainStarted", "Live migrating all VMs away from node")
+		}
+	case status.Drained:
+		event = func() {
+			s.recordDrainEvent(nodeObj, corev1.EventTypeNormal, "Drained", "All VMs have been removed from node")
+		}
+	}
+
+	logger.Info("Updating node drain status", zap.Any("DrainStatus", status))
+
+	return func() error {
+		if err := s.setNodeAnnotation(nodeObj.Name, AnnotationDrainStatus, value); err != nil {
+			return fmt.Errorf("could not update node drain status: %w", err)
+		}
+		if event != nil {
+			event()
+		}
+		return nil
+	}
+}
@@ -0,0 +1,196 @@
+package plugin
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/neondatabase/autoscaling/pkg/plugin/metrics"
+	"github.com/neondatabase/autoscaling/pkg/plugin/state"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+func TestDrainNode(t *testing.T) {
+	migrating := migratablePod("migrating", 4*time.Hour, 1*cpu, 4*gib)
+	migrating.Migrating = true
+	unmigratable := migratablePod("unmigratable", 3*time.Hour, 1*cpu, 4*gib)
+	unmigratable.Migratable = false
+	small := migratablePod("small", 2*time.Hour, 1*cpu, 4*gib)
+	large := migratablePod("large", 1*time.Hour, 4*cpu, 16*gib)
+
+	newNodeState := func(name string, draining bool, pods ...state.Pod) *nodeState {
+		node := state.NodeStateFromParams(name, 10*cpu, 40*gib, 0.8, nil)
+		for _, p := range pods {
+			node.AddPod(p)
+		}
+		return &nodeState{
+			node:                node,
+			requestedMigrations: make(map[types.UID]string),
+			podsVMPatchedAt:     make(map[types.UID]time.Time),
+			draining:            draining,
+			unschedulable:       false,
+			drainStatus:         "",
+		}
+	}
+
+	type annotationPatch struct {
+		node  string
+		value *string
+	}
+
+	cases := []struct {
+		name              string
+		pods              []state.Pod
+		maxConcurrent     int
+		previousStatus    string
+		expectedRequested map[types.UID]string
+		expectedPatch     *annotationPatch
+	}{
+		{
+			// "migrating" takes up one of the two slots, so we can only start one more. "small" is
+			// the better migration target, and fits on "target"; "large" doesn't fit, but would
+			// be migrated anyways if there were room for another migration.
+			name:              "start-draining",
+			pods:              []state.Pod{migrating, unmigratable, small, large},
+			maxConcurrent:     2,
+			previousStatus:    "",
+			expectedRequested: map[types.UID]string{small.UID: "target"},
+			expectedPatch: &annotationPatch{
+				node:  "source",
+				value: lo.ToPtr(`{"remaining":4,"migrating":2,"unmigratable":1,"drained":false}`),
+			},
+		},
+		{
+			name:              "no-target",
+			pods:              []state.Pod{large},
+			maxConcurrent:     2,
+			previousStatus:    "",
+			expectedRequested: map[types.UID]string{large.UID: ""},
+			expectedPatch: &annotationPatch{
+				node:  "source",
+				value: lo.ToPtr(`{"remaining":1,"migrating":1,"unmigratable":0,"drained":false}`),
+			},
+		},
+		{
+			name:              "unchanged-status",
+			pods:              []state.Pod{unmigratable},
+			maxConcurrent:     2,
+			previousStatus:    `{"remaining":1,"migrating":0,"unmigratable":1,"drained":false}`,
+			expectedRequested: map[types.UID]string{},
+			expectedPatch:     nil,
+		},
+		{
+			name:              "drained",
+			pods:              nil,
+			maxConcurrent:     2,
+			previousStatus:    `{"remaining":1,"migrating":1,"unmigratable":0,"drained":false}`,
+			expectedRequested: map[types.UID]string{},
+			expectedPatch: &annotationPatch{
+				node:  "source",
+				value: lo.ToPtr(`{"remaining":0,"migrating":0,"unmigratable":0,"drained":true}`),
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			source := newNodeState("source", true, c.pods...)
+			source.drainStatus = c.previousStatus
+
+			var patched *annotationPatch
+
+			//nolint:exhaustruct // this is a test
+			s := &PluginState{
+				config: Config{
+					Drain: &DrainConfig{MaxConcurrentMigrations: c.maxConcurrent},
+				},
+				nodes: map[string]*nodeState{
+					"source": source,
+					"target": newNodeState("target", false),
+					// "other" has room for "large", but is also draining, so must not be used.
+					"other": newNodeState("other", true),
+				},
+				lastMigrationAt: make(map[util.NamespacedName]time.Time),
+				metrics:         metrics.BuildPluginMetrics(prometheus.NewRegistry(), nil),
+				getNamespace: func(string) (*corev1.Namespace, bool) {
+					return nil, false
+				},
+				requeuePod: func(types.UID) error { return nil },
+				setNodeAnnotation: func(nodeName string, key string, value *string) error {
+					assert.Equal(t, AnnotationDrainStatus, key)
+					patched = &annotationPatch{node: nodeName, value: value}
+					return nil
+				},
+				recordDrainEvent: func(*corev1.Node, string, string, string) {},
+			}
+			// Make "target" only have room for "small".
+			s.nodes["target"].node.AddPod(migratablePod("existing", time.Hour, 6*cpu, 24*gib))
+
+			//nolint:exhaustruct // this is a test
+			nodeObj := &corev1.Node{ObjectMeta: metav1.ObjectMeta{Name: "source"}}
+
+			afterUnlock := s.drainNode(zap.NewNop(), source, nodeObj)
+			assert.Equal(t, c.expectedRequested, source.requestedMigrations)
+
+			if c.expectedPatch == nil {
+				assert.Nil(t, afterUnlock)
+				return
+			}
+			require.NotNil(t, afterUnlock)
+			require.NoError(t, afterUnlock())
+			assert.Equal(t, c.expectedPatch, patched)
+		})
+	}
+}
+
+func TestNodeDrainRequested(t *testing.T) {
+	cases := []struct {
+		name     string
+		node     corev1.Node
+		expected bool
+	}{
+		{
+			name:     "none",
+			node:     corev1.Node{},
+			expected: false,
+		},
+		{
+			name: "annotation",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DrainKey: "true"}},
+			},
+			expected: true,
+		},
+		{
+			name: "annotation-false",
+			node: corev1.Node{
+				ObjectMeta: metav1.ObjectMeta{Annotations: map[string]string{DrainKey: "false"}},
+			},
+			expected: false,
+		},
+		{
+			name: "taint",
+			node: corev1.Node{
+				Spec: corev1.NodeSpec{
+					Taints: []corev1.Taint{{Key: DrainKey, Effect: corev1.TaintEffectNoSchedule}},
+				},
+			},
+			expected: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			assert.Equal(t, c.expected, nodeDrainRequested(&c.node))
+		})
+	}
+}
@@ -110,6 +110,11 @@ func NewAutoscaleEnforcerPlugin(
 		return nil, fmt.Errorf("could not start watch on Pod events: %w", err)
 	}
 
+	namespaceStore, err := watchNamespaceEvents(ctx, logger, handle.ClientSet(), watchMetrics)
+	if err != nil {
+		return nil, fmt.Errorf("could not start watch on Namespace events: %w", err)
+	}
+
 	// we make these handlers with nil instead of initEvents so that we're not blocking plugin setup
 	// on the migration objects being handled.
 	vmmHandlers := watchHandlers[*vmv1.VirtualMachineMigration](reconcileQueue, nil)
@@ -117,7 +122,7 @@ func NewAutoscaleEnforcerPlugin(
 		return nil, fmt.Errorf("could not start watch on VirtualMachineMigration events: %w", err)
 	}
 
-	pluginState = NewPluginState(*config, vmClient, pluginMetrics, podStore, nodeStore)
+	pluginState = NewPluginState(*config, handle.ClientSet(), vmClient, handle.EventRecorder(), pluginMetrics, podStore, nodeStore, namespaceStore)
 
 	// Start the workers for the queue. We can't do these earlier because our handlers depend on the
 	// PluginState that only exists now.
@@ -141,6 +146,10 @@ func NewAutoscaleEnforcerPlugin(
 	if err != nil {
 		return nil, fmt.Errorf("could not start agent request handler: %w", err)
 	}
+	err = pluginState.startPermitStreamServer(ctx, logger.Named("agent-stream"), getPod, podStore.Listen)
+	if err != nil {
+		return nil, fmt.Errorf("could not start agent permit stream server: %w", err)
+	}
 
 	// The reconciles are ongoing -- we need to wait until they're finished.
 	timeout := time.Second * time.Duration(config.StartupEventHandlingTimeoutSeconds)
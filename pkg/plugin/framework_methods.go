@@ -12,6 +12,8 @@ import (
 	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
 	"github.com/neondatabase/autoscaling/pkg/plugin/metrics"
 	"github.com/neondatabase/autoscaling/pkg/plugin/reconcile"
 	"github.com/neondatabase/autoscaling/pkg/plugin/state"
@@ -155,6 +157,11 @@ func (e *AutoscaleEnforcer) Filter(
 		return framework.NewStatus(framework.Error, msg)
 	}
 
+	if ns.draining {
+		logger.Info("Rejecting Pod because Node is being drained")
+		return framework.NewStatus(framework.Unschedulable, "Node is being drained")
+	}
+
 	var approve bool
 	ns.node.Speculatively(func(n *state.Node) (commit bool) {
 		approve = e.filterCheck(logger, ns.node, n, podState, proposedPods)
@@ -243,6 +250,19 @@ func (e *AutoscaleEnforcer) filterCheck(
 		n.AddPod(filterPod)
 		canAddToNode = !n.OverBudget()
 
+		// If enabled, also check that the VMs' expected growth would fit below the watermark.
+		//
+		// This only applies to autoscaling VMs: other pods can't grow, so there's no need to
+		// reserve room for them, and rejecting them wouldn't make room for the existing VMs.
+		var cpuGrowth vmv1.MilliCPU
+		var memGrowth api.Bytes
+		if e.state.config.GrowthReserve != nil && filterPod.Autoscaling {
+			cpuGrowth, memGrowth = e.state.expectedGrowth(n)
+			withinWatermark := n.CPU.Reserved+cpuGrowth <= n.CPU.Watermark &&
+				n.Mem.Reserved+memGrowth <= n.Mem.Watermark
+			canAddToNode = canAddToNode && withinWatermark
+		}
+
 		var msg string
 		if canAddToNode {
 			msg = "Allowing Pod placement onto this Node"
@@ -255,6 +275,8 @@ func (e *AutoscaleEnforcer) filterCheck(
 			zap.Object("FilterNode", tmpNode),
 			zap.Object("FilterNodeWithPod", n),
 			zap.Object("Pod", filterPod),
+			zap.Uint32("ExpectedCPUGrowth", uint32(cpuGrowth)),
+			zap.Uint64("ExpectedMemGrowth", uint64(memGrowth)),
 			zap.Any("LocalPodsNotInFilterState", localNotInProposed),
 			zap.Any("FilterPodsNotInLocalState", proposedNotInLocalState),
 		)
@@ -335,10 +357,14 @@ func (e *AutoscaleEnforcer) Score(
 				zap.Object("NodeWithPod", tmp),
 			)
 		} else {
-			cfg := e.state.config.Scoring
-			cpuScore := calculateScore(cfg, tmp.CPU.Reserved, tmp.CPU.Total, e.state.maxNodeCPU)
-			memScore := calculateScore(cfg, tmp.Mem.Reserved, tmp.Mem.Total, e.state.maxNodeMem)
-			scoreFraction := min(cpuScore, memScore)
+			cpuGrowth, memGrowth := e.state.expectedGrowth(tmp)
+			scoreFraction, strategyScores := scoreNode(e.state.config.Scoring.strategies(), scoringInput{
+				node:       tmp,
+				cpuGrowth:  cpuGrowth,
+				memGrowth:  memGrowth,
+				maxNodeCPU: e.state.maxNodeCPU,
+				maxNodeMem: e.state.maxNodeMem,
+			})
 
 			scoreLen := framework.MaxNodeScore - framework.MinNodeScore
 			score = framework.MinNodeScore + int64(float64(scoreLen)*scoreFraction)
@@ -346,8 +372,7 @@ func (e *AutoscaleEnforcer) Score(
 			logger.Info(
 				"Scored Pod placement for Node",
 				zap.Int64("Score", score),
-				zap.Float64("CPUFraction", cpuScore),
-				zap.Float64("MemFraction", memScore),
+				strategyScores,
 				zap.Object("NodeWithPod", tmp),
 			)
 		}
@@ -358,6 +383,15 @@ func (e *AutoscaleEnforcer) Score(
 	return score, nil
 }
 
+// expectedGrowth returns the expected growth of the VMs on the node, or zero if the growth reserve
+// is not enabled.
+func (s *PluginState) expectedGrowth(node *state.Node) (vmv1.MilliCPU, api.Bytes) {
+	if s.config.GrowthReserve == nil {
+		return 0, 0
+	}
+	return node.ExpectedGrowth(s.config.GrowthReserve.Quantile, s.config.GrowthReserve.MinFraction)
+}
+
 type floatable interface {
 	AsFloat64() float64
 }
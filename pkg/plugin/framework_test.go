@@ -3,15 +3,22 @@ package plugin
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"go.uber.org/zap"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/kubernetes/pkg/scheduler/framework"
 
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
 	"github.com/neondatabase/autoscaling/pkg/plugin/metrics"
+	"github.com/neondatabase/autoscaling/pkg/plugin/state"
 )
 
 // TestNormalizeScoreRandomization tests that the NormalizeScore function randomizes scores
@@ -162,3 +169,211 @@ func TestMultipleNormalizeScoreRuns(t *testing.T) {
 		t.Errorf("Expected multiple different random values, but got only: %v", results)
 	}
 }
+
+func TestScoringStrategies(t *testing.T) {
+	// vmPod returns a pod with the reserved resources, that may scale up to the max.
+	vmPod := func(name string, reservedCPU, maxCPU vmv1.MilliCPU, reservedMem, maxMem api.Bytes) state.Pod {
+		pod := migratablePod(name, time.Hour, reservedCPU, reservedMem)
+		pod.CPU.Max = maxCPU
+		pod.Mem.Max = maxMem
+		return pod
+	}
+
+	cfg := ScoringConfig{
+		MinUsageScore: 0.5,
+		MaxUsageScore: 0,
+		ScorePeak:     0.8,
+		Randomize:     false,
+		Strategies:    nil,
+	}
+
+	cases := []struct {
+		name     string
+		pods     []state.Pod
+		expected map[string]float64
+	}{
+		{
+			// 4/10 CPU and 16/40 GiB reserved, no room needed for upscaling.
+			name: "balanced-usage",
+			pods: []state.Pod{vmPod("a", 4*cpu, 4*cpu, 16*gib, 16*gib)},
+			expected: map[string]float64{
+				// 0.5 + (1 - 0.5) / 0.8 * 0.4
+				ScoringUsageCurve:      0.75,
+				ScoringLeastAllocated:  0.6,
+				ScoringMostAllocated:   0.4,
+				ScoringBalanced:        1,
+				ScoringUpscaleHeadroom: 1,
+			},
+		},
+		{
+			// 8/10 CPU and 8/40 GiB reserved. Pods may scale up by 4 CPU and 8 GiB; only half of the
+			// CPU upscaling would fit.
+			name: "unbalanced-usage",
+			pods: []state.Pod{
+				vmPod("a", 6*cpu, 8*cpu, 6*gib, 10*gib),
+				vmPod("b", 2*cpu, 4*cpu, 2*gib, 6*gib),
+			},
+			expected: map[string]float64{
+				// min(score(0.8), score(0.2)) = min(1, 0.5 + (1 - 0.5) / 0.8 * 0.2)
+				ScoringUsageCurve:      0.625,
+				ScoringLeastAllocated:  0.5,
+				ScoringMostAllocated:   0.5,
+				ScoringBalanced:        0.4,
+				ScoringUpscaleHeadroom: 0.5,
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			node := state.NodeStateFromParams("node", 10*cpu, 40*gib, 0.8, nil)
+			for _, p := range c.pods {
+				node.AddPod(p)
+			}
+			input := scoringInput{
+				node:       node,
+				cpuGrowth:  0,
+				memGrowth:  0,
+				maxNodeCPU: 10 * cpu,
+				maxNodeMem: 40 * gib,
+			}
+
+			for name, expected := range c.expected {
+				strategy := scoringStrategies[name](cfg)
+				assert.InDelta(t, expected, strategy.score(input), 1e-9, "strategy %q", name)
+			}
+		})
+	}
+}
+
+func TestScoreNodeWeighting(t *testing.T) {
+	fixed := func(score float64) scoringStrategy {
+		return scoringStrategyFunc(func(scoringInput) float64 { return score })
+	}
+
+	//nolint:exhaustruct // this is a test
+	input := scoringInput{}
+
+	score, _ := scoreNode([]weightedScoringStrategy{
+		{name: "x", weight: 3, strategy: fixed(1)},
+		{name: "y", weight: 1, strategy: fixed(0)},
+	}, input)
+	assert.InDelta(t, 0.75, score, 1e-9)
+
+	// Scores outside of [0, 1] are clamped
+	score, _ = scoreNode([]weightedScoringStrategy{
+		{name: "x", weight: 1, strategy: fixed(2)},
+		{name: "y", weight: 1, strategy: fixed(-1)},
+	}, input)
+	assert.InDelta(t, 0.5, score, 1e-9)
+
+	// With no strategies configured, only the usage curve is used.
+	//nolint:exhaustruct // this is a test
+	strategies := ScoringConfig{}.strategies()
+	require.Len(t, strategies, 1)
+	assert.Equal(t, ScoringUsageCurve, strategies[0].name)
+}
+
+func TestScoringConfigValidation(t *testing.T) {
+	cases := []struct {
+		name         string
+		strategies   []ScoringStrategyConfig
+		expectedPath string
+	}{
+		{
+			name: "valid",
+			strategies: []ScoringStrategyConfig{
+				{Name: ScoringMostAllocated, Weight: 2},
+				{Name: ScoringUpscaleHeadroom, Weight: 1},
+			},
+			expectedPath: "",
+		},
+		{
+			name:         "unknown",
+			strategies:   []ScoringStrategyConfig{{Name: "random", Weight: 1}},
+			expectedPath: "strategies[0].name",
+		},
+		{
+			name: "zero-weight",
+			strategies: []ScoringStrategyConfig{
+				{Name: ScoringBalanced, Weight: 1},
+				{Name: ScoringLeastAllocated, Weight: 0},
+			},
+			expectedPath: "strategies[1].weight",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			//nolint:exhaustruct // this is a test
+			cfg := ScoringConfig{Strategies: c.strategies}
+			path, err := cfg.validate()
+			assert.Equal(t, c.expectedPath, path)
+			assert.Equal(t, c.expectedPath != "", err != nil)
+		})
+	}
+}
+
+func TestFilterGrowthReserve(t *testing.T) {
+	// A pod that may grow by 4 CPU, hasn't requested anything more yet.
+	pod := migratablePod("pod", time.Hour, 5*cpu, 4*gib)
+	pod.CPU.Max = 9 * cpu
+
+	cases := []struct {
+		name          string
+		growthReserve *GrowthReserveConfig
+		autoscaling   bool
+		expected      bool
+	}{
+		{
+			name:          "disabled",
+			growthReserve: nil,
+			autoscaling:   true,
+			expected:      true,
+		},
+		{
+			// Expected to grow by 2 CPU, to 7 CPU, below the watermark of 8.
+			name:          "fits-below-watermark",
+			growthReserve: &GrowthReserveConfig{Quantile: 0.9, MinFraction: 0.5},
+			autoscaling:   true,
+			expected:      true,
+		},
+		{
+			// Expected to grow by 4 CPU, to 9 CPU, above the watermark of 8.
+			name:          "above-watermark",
+			growthReserve: &GrowthReserveConfig{Quantile: 0.9, MinFraction: 1},
+			autoscaling:   true,
+			expected:      false,
+		},
+		{
+			// Same as above, but the growth reserve doesn't apply to pods that can't grow.
+			name:          "above-watermark-not-autoscaling",
+			growthReserve: &GrowthReserveConfig{Quantile: 0.9, MinFraction: 1},
+			autoscaling:   false,
+			expected:      true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			//nolint:exhaustruct // Only initializing fields needed for the test
+			enforcer := &AutoscaleEnforcer{
+				logger: zap.NewNop(),
+				state: &PluginState{
+					config: Config{GrowthReserve: c.growthReserve},
+				},
+			}
+
+			pod := pod
+			pod.Autoscaling = c.autoscaling
+
+			node := state.NodeStateFromParams("node", 10*cpu, 40*gib, 0.8, nil)
+			var ok bool
+			node.Speculatively(func(tmp *state.Node) bool {
+				ok = enforcer.filterCheck(zap.NewNop(), node, tmp, pod, map[types.UID]*framework.PodInfo{})
+				return false
+			})
+			assert.Equal(t, c.expected, ok)
+		})
+	}
+}
@@ -16,6 +16,8 @@ import (
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
+	coreclient "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/events"
 
 	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
 	vmclient "github.com/neondatabase/autoscaling/neonvm/client/clientset/versioned"
@@ -52,41 +54,72 @@ type PluginState struct {
 	// We use this when scoring pod placements.
 	maxNodeMem api.Bytes
 
+	// lastMigrationAt stores the last time we triggered live migration of each VM, if
+	// MigrationLimitsConfig.MinIntervalSeconds is set.
+	//
+	// Entries are removed once they are older than the interval.
+	lastMigrationAt map[util.NamespacedName]time.Time
+
 	metrics *metrics.Plugin
 
+	getNamespace func(name string) (*corev1.Namespace, bool)
+
 	requeuePod      func(uid types.UID) error
 	requeueNode     func(nodeName string) error
 	createMigration func(*zap.Logger, *vmv1.VirtualMachineMigration) error
 	deleteMigration func(*zap.Logger, *vmv1.VirtualMachineMigration) error
 	patchVM         func(util.NamespacedName, []patch.Operation) error
+
+	// setNodeAnnotation sets the annotation on the node to the value, or removes it if the value is
+	// nil.
+	setNodeAnnotation func(nodeName string, key string, value *string) error
+	// recordDrainEvent emits a k8s event about the progress of draining the node.
+	recordDrainEvent func(node *corev1.Node, eventType string, reason string, note string)
 }
 
 type nodeState struct {
 	node *state.Node
 
-	// requestedMigrations stores the set of pods that we've decided we should migrate.
+	// requestedMigrations stores the set of pods that we've decided we should migrate, with the
+	// name of the node we chose to migrate each one to.
 	//
 	// When they are reconciled, we will (a) double-check that we should still migrate them, and (b)
 	// if so, create a VirtualMachineMigration object to handle it.
-	requestedMigrations map[types.UID]struct{}
+	requestedMigrations map[types.UID]string
 
 	// podsVMPatchedAt stores the last time that the VirtualMachine object for a Pod was patched, so
 	// that we can avoid spamming patch requests if the Pod is just slightly out of date.
 	//
 	// The map is keyed by the *Pod* UID, even though it stores when we patched the *VM*.
 	podsVMPatchedAt map[types.UID]time.Time
+
+	// draining is true if the node is marked to be drained, and draining is enabled.
+	//
+	// Draining nodes are not considered for scheduling or as migration targets.
+	draining bool
+	// unschedulable is true if the node is cordoned, i.e. has .spec.unschedulable set.
+	//
+	// Unschedulable nodes are not considered as migration targets.
+	unschedulable bool
+	// drainStatus is the current value of the node's AnnotationDrainStatus, or empty if it's not
+	// set.
+	drainStatus string
 }
 
 func NewPluginState(
 	config Config,
+	kubeClient coreclient.Interface,
 	vmClient vmclient.Interface,
+	eventRecorder events.EventRecorder,
 	metrics *metrics.Plugin,
 	podWatchStore *watch.Store[corev1.Pod],
 	nodeWatchStore *watch.Store[corev1.Node],
+	namespaceWatchStore *watch.Store[corev1.Namespace],
 ) *PluginState {
 	crudTimeout := time.Second * time.Duration(config.K8sCRUDTimeoutSeconds)
 
 	indexedNodeStore := watch.NewIndexedStore(nodeWatchStore, watch.NewFlatNameIndex[corev1.Node]())
+	indexedNamespaceStore := watch.NewIndexedStore(namespaceWatchStore, watch.NewFlatNameIndex[corev1.Namespace]())
 
 	return &PluginState{
 		mu: sync.Mutex{},
@@ -103,7 +136,16 @@ func NewPluginState(
 		maxNodeCPU: 0,
 		maxNodeMem: 0,
 
+		lastMigrationAt: make(map[util.NamespacedName]time.Time),
+
 		metrics: metrics,
+		getNamespace: func(name string) (*corev1.Namespace, bool) {
+			return indexedNamespaceStore.GetIndexed(
+				func(i *watch.FlatNameIndex[corev1.Namespace]) (*corev1.Namespace, bool) {
+					return i.Get(name)
+				},
+			)
+		},
 		requeuePod: func(uid types.UID) error {
 			ok := podWatchStore.NopUpdate(uid)
 			if !ok {
@@ -169,5 +211,30 @@ func NewPluginState(
 			metrics.RecordK8sOp("Patch", "VirtualMachine", vm.Name, err)
 			return err
 		},
+		setNodeAnnotation: func(nodeName string, key string, value *string) error {
+			// Use a merge patch, so that we don't need to handle the annotations map not existing.
+			patchPayload, err := json.Marshal(map[string]any{
+				"metadata": map[string]any{
+					"annotations": map[string]*string{key: value},
+				},
+			})
+			if err != nil {
+				panic(fmt.Errorf("could not marshal merge patch: %w", err))
+			}
+
+			ctx, cancel := context.WithTimeout(context.TODO(), crudTimeout)
+			defer cancel()
+
+			_, err = kubeClient.CoreV1().Nodes().
+				Patch(ctx, nodeName, types.MergePatchType, patchPayload, metav1.PatchOptions{})
+			metrics.RecordK8sOp("Patch", "Node", nodeName, err)
+			return err
+		},
+		recordDrainEvent: func(node *corev1.Node, eventType string, reason string, note string) {
+			if eventRecorder == nil {
+				return
+			}
+			eventRecorder.Eventf(node, nil, eventType, reason, "Drain", note)
+		},
 	}
 }
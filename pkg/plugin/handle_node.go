@@ -4,6 +4,7 @@ package plugin
 
 import (
 	"fmt"
+	"slices"
 	"time"
 
 	"go.uber.org/zap"
@@ -20,7 +21,14 @@ func (s *PluginState) HandleNodeEvent(logger *zap.Logger, kind reconcile.EventKi
 
 	switch kind {
 	case reconcile.EventKindAdded, reconcile.EventKindModified:
-		return s.updateNode(logger, node, expectExists)
+		afterUnlock, err := s.updateNode(logger, node, expectExists)
+		if err != nil {
+			return err
+		}
+		if afterUnlock != nil {
+			return afterUnlock()
+		}
+		return nil
 	case reconcile.EventKindDeleted, reconcile.EventKindEphemeral:
 		return s.deleteNode(logger, node, expectExists)
 	default:
@@ -28,10 +36,19 @@ func (s *PluginState) HandleNodeEvent(logger *zap.Logger, kind reconcile.EventKi
 	}
 }
 
-func (s *PluginState) updateNode(logger *zap.Logger, node *corev1.Node, expectExists bool) error {
-	newNode, err := state.NodeStateFromK8sObj(node, s.config.Watermark, s.metrics.Nodes.InheritedLabels)
+func (s *PluginState) updateNode(
+	logger *zap.Logger,
+	node *corev1.Node,
+	expectExists bool,
+) (afterUnlock func() error, _ error) {
+	keepLabels := s.metrics.Nodes.InheritedLabels
+	if s.config.ZoneLabel != "" {
+		keepLabels = append(slices.Clip(keepLabels), s.config.ZoneLabel)
+	}
+
+	newNode, err := state.NodeStateFromK8sObj(node, s.config.Watermark, keepLabels)
 	if err != nil {
-		return fmt.Errorf("could not get state from Node object: %w", err)
+		return nil, fmt.Errorf("could not get state from Node object: %w", err)
 	}
 
 	s.mu.Lock()
@@ -50,8 +67,11 @@ func (s *PluginState) updateNode(logger *zap.Logger, node *corev1.Node, expectEx
 
 		entry := &nodeState{
 			node:                newNode,
-			requestedMigrations: make(map[types.UID]struct{}),
+			requestedMigrations: make(map[types.UID]string),
 			podsVMPatchedAt:     make(map[types.UID]time.Time),
+			draining:            false,
+			unschedulable:       false,
+			drainStatus:         "",
 		}
 
 		logger.Info("Adding base node state", zap.Object("Node", entry.node))
@@ -74,7 +94,17 @@ func (s *PluginState) updateNode(logger *zap.Logger, node *corev1.Node, expectEx
 		updated = oldNS
 	}
 
-	return s.reconcileNode(logger, updated)
+	draining := s.config.Drain != nil && nodeDrainRequested(node)
+	if draining && !updated.draining {
+		logger.Info("Node is marked to be drained")
+	} else if !draining && updated.draining {
+		logger.Info("Node is no longer marked to be drained")
+	}
+	updated.draining = draining
+	updated.unschedulable = node.Spec.Unschedulable
+	updated.drainStatus = node.Annotations[AnnotationDrainStatus]
+
+	return s.reconcileNode(logger, updated, node)
 }
 
 func (s *PluginState) deleteNode(logger *zap.Logger, node *corev1.Node, expectExists bool) error {
@@ -98,19 +128,30 @@ func (s *PluginState) deleteNode(logger *zap.Logger, node *corev1.Node, expectEx
 // reconcileNode makes any updates necessary given the current state of the node.
 // In particular, this method:
 //
-// 1. Triggers live migration if reserved resources are above the watermark; and
-// 2. Updates the prometheus metrics we expose about the node
+// 1. Triggers live migration if reserved resources are above the watermark, or of all VMs if the
+// node is being drained;
+// 2. Updates the node's drain status annotation (via the returned afterUnlock); and
+// 3. Updates the prometheus metrics we expose about the node
 //
 // NOTE: this function expects that the caller has acquired s.mu.
-func (s *PluginState) reconcileNode(logger *zap.Logger, ns *nodeState) error {
+func (s *PluginState) reconcileNode(
+	logger *zap.Logger,
+	ns *nodeState,
+	nodeObj *corev1.Node,
+) (afterUnlock func() error, _ error) {
 	defer s.metrics.Nodes.Update(ns.node)
 
+	if ns.draining {
+		return s.drainNode(logger, ns, nodeObj), nil
+	}
+
 	err := s.balanceNode(logger, ns)
 	if err != nil {
-		return fmt.Errorf("could not trigger live migrations: %w", err)
+		return nil, fmt.Errorf("could not trigger live migrations: %w", err)
 	}
 
-	return nil
+	// Clear the drain status, if the node was previously draining.
+	return s.updateDrainStatus(logger, ns, nodeObj, nil), nil
 }
 
 // updateNodeMetricsAndRequeue updates the node's metrics and puts it back in the reconcile queue.
@@ -126,6 +167,8 @@ func (s *PluginState) updateNodeMetricsAndRequeue(logger *zap.Logger, ns *nodeSt
 
 func (s *PluginState) balanceNode(logger *zap.Logger, ns *nodeState) error {
 	var err error
+	now := time.Now()
+	targets := s.migrationTargets(ns)
 	// use Speculatively() to produce a temporary node that triggerMigrationsIfNecessary can use to
 	// evaluate what the state *will* look like after the migrations are running.
 	ns.node.Speculatively(func(tmpNode *state.Node) (commit bool) {
@@ -139,11 +182,18 @@ func (s *PluginState) balanceNode(logger *zap.Logger, ns *nodeState) error {
 			originalNode,
 			tmpNode,
 			requestedMigrations,
-			func(podUID types.UID) error {
+			targets,
+			func() *migrationLimiter {
+				return s.newMigrationLimiter(logger, ns, now)
+			},
+			func(podUID types.UID, targetNode string) error {
 				if err := s.requeuePod(podUID); err != nil {
 					return err
 				}
-				ns.requestedMigrations[podUID] = struct{}{}
+				ns.requestedMigrations[podUID] = targetNode
+				if pod, ok := ns.node.GetPod(podUID); ok {
+					s.recordMigration(pod.VirtualMachine, now)
+				}
 				return nil
 			},
 		)
@@ -176,7 +176,7 @@ func (s *PluginState) updatePod(
 		return nil, nil
 	}
 
-	if _, ok := ns.requestedMigrations[newPod.UID]; ok {
+	if targetNode, ok := ns.requestedMigrations[newPod.UID]; ok {
 		// If the pod is already migrating, remove it from requestedMigrations.
 		if newPod.Migrating {
 			delete(ns.requestedMigrations, newPod.UID)
@@ -186,12 +186,13 @@ func (s *PluginState) updatePod(
 		} else {
 			// Otherwise: the pod is not migrating, but *is* migratable. Let's trigger migration.
 			logger.Info("Creating migration for Pod")
+			zone := s.nodeZone(ns.node)
 			return &podUpdateResult{
 				needsMoreResources: false,
 				// we need to release the lock to trigger the migration, otherwise we may slow down
 				// processing due to API delays.
 				afterUnlock: func() error {
-					if err := s.createMigrationForPod(logger, newPod); err != nil {
+					if err := s.createMigrationForPod(logger, newPod, targetNode, zone); err != nil {
 						return fmt.Errorf("could not create migration for Pod: %w", err)
 					}
 					return nil
@@ -209,11 +210,55 @@ func (s *PluginState) updatePod(
 	return nil, nil
 }
 
-func (s *PluginState) createMigrationForPod(logger *zap.Logger, pod state.Pod) error {
-	vmm := &vmv1.VirtualMachineMigration{
+// createMigrationForPod creates a VirtualMachineMigration for the pod, hinting that it should be
+// migrated to targetNode, if it's not empty.
+//
+// If zone is not empty, the migration is required to stay within that zone.
+func (s *PluginState) createMigrationForPod(logger *zap.Logger, pod state.Pod, targetNode string, zone string) error {
+	return s.createMigration(logger, migrationForPod(pod, targetNode, s.config.ZoneLabel, zone))
+}
+
+// migrationForPod returns the VirtualMachineMigration for createMigrationForPod
+func migrationForPod(pod state.Pod, targetNode string, zoneLabel string, zone string) *vmv1.VirtualMachineMigration {
+	var nodeAffinity *corev1.NodeAffinity
+	if targetNode != "" || zone != "" {
+		nodeAffinity = &corev1.NodeAffinity{}
+	}
+
+	// The VM's current zone is a hard requirement, because we only pick targets within the same
+	// zone (and moving a VM away from its storage would make it slower).
+	if zone != "" {
+		nodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution = &corev1.NodeSelector{
+			NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+				MatchExpressions: []corev1.NodeSelectorRequirement{{
+					Key:      zoneLabel,
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{zone},
+				}},
+			}},
+		}
+	}
+
+	// Hint that the VM should be migrated to the node we chose. This isn't required, because the
+	// state of the cluster may have changed by the time the target pod is scheduled.
+	if targetNode != "" {
+		nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = []corev1.PreferredSchedulingTerm{{
+			Weight: 100,
+			Preference: corev1.NodeSelectorTerm{
+				MatchFields: []corev1.NodeSelectorRequirement{{
+					Key:      "metadata.name",
+					Operator: corev1.NodeSelectorOpIn,
+					Values:   []string{targetNode},
+				}},
+			},
+		}}
+	}
+
+	return &vmv1.VirtualMachineMigration{
 		ObjectMeta: metadataForNewMigration(pod),
 		Spec: vmv1.VirtualMachineMigrationSpec{
-			VmName: pod.VirtualMachine.Name,
+			VmName:       pod.VirtualMachine.Name,
+			NodeAffinity: nodeAffinity,
 
 			// FIXME: NeonVM's VirtualMachineMigrationSpec has a bunch of boolean fields that aren't
 			// pointers, which means we need to explicitly set them when using the Go API.
@@ -225,8 +270,6 @@ func (s *PluginState) createMigrationForPod(logger *zap.Logger, pod state.Pod) e
 			AllowPostCopy:              false,
 		},
 	}
-
-	return s.createMigration(logger, vmm)
 }
 
 func (s *PluginState) reconcilePodResources(
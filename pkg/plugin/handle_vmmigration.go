@@ -34,6 +34,7 @@ func (s *PluginState) HandleMigrationEvent(
 		// Migration was deleted. Nothing to do.
 		return nil
 	case reconcile.EventKindAdded, reconcile.EventKindModified:
+		s.recordExistingMigration(vmm)
 		return s.deleteMigrationIfNeeded(logger, vmm)
 	default:
 		panic("unreachable")
@@ -53,6 +54,16 @@ func metadataForNewMigration(pod state.Pod) metav1.ObjectMeta {
 	}
 }
 
+// recordExistingMigration records when the migration was created, so that we don't migrate the
+// same VM again too soon after -- even if the scheduler restarted since then.
+func (s *PluginState) recordExistingMigration(vmm *vmv1.VirtualMachineMigration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	vm := util.NamespacedName{Namespace: vmm.Namespace, Name: vmm.Spec.VmName}
+	s.recordMigration(vm, vmm.CreationTimestamp.Time)
+}
+
 // deleteMigrationIfNeeded deletes the migration object if it was created by the scheduler plugin
 // and has reached a terminal state (succeeded or failed).
 //
@@ -26,6 +26,8 @@ type Plugin struct {
 	ResourceRequests      *prometheus.CounterVec
 	ValidResourceRequests *prometheus.CounterVec
 
+	ThrottledMigrations *prometheus.CounterVec
+
 	K8sOps *prometheus.CounterVec
 }
 
@@ -53,6 +55,14 @@ func BuildPluginMetrics(reg prometheus.Registerer, nodeMetricLabels map[string]s
 			[]string{"code", "node"},
 		)),
 
+		ThrottledMigrations: util.RegisterMetric(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "autoscaling_plugin_throttled_migrations_total",
+				Help: "Number of times live migration was not triggered because of migration limits",
+			},
+			[]string{"reason"},
+		)),
+
 		K8sOps: util.RegisterMetric(reg, prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "autoscaling_plugin_k8s_ops_total",
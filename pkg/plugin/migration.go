@@ -3,25 +3,265 @@ package plugin
 // Decision-making for live migrations.
 
 import (
+	"cmp"
 	"fmt"
 	"slices"
+	"strconv"
+	"time"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 
 	"k8s.io/apimachinery/pkg/types"
 
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
 	"github.com/neondatabase/autoscaling/pkg/plugin/state"
+	"github.com/neondatabase/autoscaling/pkg/util"
 )
 
+// AnnotationMigrationBudget can be set on a Namespace to limit the number of VMs in it that may be
+// live migrated at a time. For example, "0" prevents the scheduler plugin from migrating any VMs in
+// the namespace.
+const AnnotationMigrationBudget = "autoscaling.neon.tech/migration-budget"
+
+// Reasons that a migration may be throttled, for the ThrottledMigrations metric
+const (
+	throttledByNodeLimit    = "node"
+	throttledByClusterLimit = "cluster"
+	throttledByNamespace    = "namespace"
+	throttledByInterval     = "interval"
+)
+
+// migrationLimiter enforces the limits on triggering new live migrations, from
+// MigrationLimitsConfig and each namespace's AnnotationMigrationBudget.
+type migrationLimiter struct {
+	limits MigrationLimitsConfig
+	now    time.Time
+
+	// nodeCount, totalCount, and namespaceCounts give the number of migrations ongoing or already
+	// requested from the node, in total, and for each namespace.
+	nodeCount       int
+	totalCount      int
+	namespaceCounts map[string]int
+
+	namespaceBudget func(namespace string) (int, bool)
+	lastMigrationAt func(vm util.NamespacedName) (time.Time, bool)
+	onThrottled     func(reason string)
+}
+
+// newMigrationLimiter returns the migrationLimiter for triggering migrations away from the node.
+//
+// NOTE: this function expects that the caller has acquired s.mu.
+func (s *PluginState) newMigrationLimiter(logger *zap.Logger, source *nodeState, now time.Time) *migrationLimiter {
+	var limits MigrationLimitsConfig
+	if s.config.MigrationLimits != nil {
+		limits = *s.config.MigrationLimits
+	}
+
+	l := &migrationLimiter{
+		limits:          limits,
+		now:             now,
+		nodeCount:       0,
+		totalCount:      0,
+		namespaceCounts: make(map[string]int),
+		namespaceBudget: func(namespace string) (int, bool) {
+			ns, ok := s.getNamespace(namespace)
+			if !ok {
+				return 0, false
+			}
+			value, ok := ns.Annotations[AnnotationMigrationBudget]
+			if !ok {
+				return 0, false
+			}
+			budget, err := strconv.Atoi(value)
+			if err != nil || budget < 0 {
+				logger.Warn(
+					"Ignoring invalid migration budget annotation on Namespace",
+					zap.String("Namespace", namespace),
+					zap.String("Value", value),
+				)
+				return 0, false
+			}
+			return budget, true
+		},
+		lastMigrationAt: func(vm util.NamespacedName) (time.Time, bool) {
+			t, ok := s.lastMigrationAt[vm]
+			return t, ok
+		},
+		onThrottled: func(reason string) {
+			s.metrics.ThrottledMigrations.WithLabelValues(reason).Inc()
+		},
+	}
+
+	for _, ns := range s.nodes {
+		count := func(pod state.Pod) {
+			if ns == source {
+				l.nodeCount += 1
+			}
+			l.totalCount += 1
+			l.namespaceCounts[pod.Namespace] += 1
+		}
+
+		for uid, pod := range ns.node.MigratablePods() {
+			if _, requested := ns.requestedMigrations[uid]; pod.Migrating || requested {
+				count(pod)
+			}
+		}
+		// Normally pods with requested migrations are migratable (otherwise the request is
+		// canceled), but we might not have processed the change yet.
+		for uid := range ns.requestedMigrations {
+			if pod, ok := ns.node.GetPod(uid); ok && !pod.Migratable {
+				count(pod)
+			}
+		}
+	}
+
+	return l
+}
+
+// limitReached returns the reason that no more migrations may be triggered from the node, or the
+// empty string if more are allowed.
+func (l *migrationLimiter) limitReached() string {
+	if l.limits.MaxConcurrentPerNode != 0 && l.nodeCount >= l.limits.MaxConcurrentPerNode {
+		return throttledByNodeLimit
+	} else if l.limits.MaxConcurrentTotal != 0 && l.totalCount >= l.limits.MaxConcurrentTotal {
+		return throttledByClusterLimit
+	}
+	return ""
+}
+
+// podThrottled returns the reason that the pod may not be migrated right now, or the empty string
+// if it may be.
+func (l *migrationLimiter) podThrottled(pod state.Pod) string {
+	if budget, ok := l.namespaceBudget(pod.Namespace); ok && l.namespaceCounts[pod.Namespace] >= budget {
+		return throttledByNamespace
+	}
+	if l.limits.MinIntervalSeconds != 0 {
+		interval := time.Second * time.Duration(l.limits.MinIntervalSeconds)
+		if last, ok := l.lastMigrationAt(pod.VirtualMachine); ok && l.now.Sub(last) < interval {
+			return throttledByInterval
+		}
+	}
+	return ""
+}
+
+// add records that we've triggered migration of the pod
+func (l *migrationLimiter) add(pod state.Pod) {
+	l.nodeCount += 1
+	l.totalCount += 1
+	l.namespaceCounts[pod.Namespace] += 1
+}
+
+// recordMigration records that migration of the VM was triggered at the given time, for enforcing
+// MigrationLimitsConfig.MinIntervalSeconds.
+//
+// NOTE: this function expects that the caller has acquired s.mu.
+func (s *PluginState) recordMigration(vm util.NamespacedName, at time.Time) {
+	limits := s.config.MigrationLimits
+	if limits == nil || limits.MinIntervalSeconds == 0 {
+		return
+	}
+
+	if last, ok := s.lastMigrationAt[vm]; !ok || at.After(last) {
+		s.lastMigrationAt[vm] = at
+	}
+
+	// Clean up entries that no longer matter, so that we don't leak memory.
+	now := time.Now()
+	interval := time.Second * time.Duration(limits.MinIntervalSeconds)
+	for vm, last := range s.lastMigrationAt {
+		if now.Sub(last) >= interval {
+			delete(s.lastMigrationAt, vm)
+		}
+	}
+}
+
+// migrationTarget is a node that VMs may be migrated to, with the amount of room it has below its
+// watermark.
+type migrationTarget struct {
+	nodeName string
+	cpuRoom  vmv1.MilliCPU
+	memRoom  api.Bytes
+}
+
+func (t migrationTarget) fits(pod state.Pod) bool {
+	return pod.CPU.Reserved <= t.cpuRoom && pod.Mem.Reserved <= t.memRoom
+}
+
+// migrationTargets returns the nodes that VMs on the source node may be migrated to, in the same
+// zone, not draining or cordoned, and with room below their watermark.
+//
+// Room already claimed by other requested migrations is not included.
+//
+// NOTE: this function expects that the caller has acquired s.mu.
+func (s *PluginState) migrationTargets(source *nodeState) []*migrationTarget {
+	zone := s.nodeZone(source.node)
+
+	targets := make(map[string]*migrationTarget)
+	for name, ns := range s.nodes {
+		if ns == source || ns.draining || ns.unschedulable || s.nodeZone(ns.node) != zone {
+			continue
+		}
+		targets[name] = &migrationTarget{
+			nodeName: name,
+			cpuRoom:  util.SaturatingSub(ns.node.CPU.Watermark, ns.node.CPU.Reserved),
+			memRoom:  util.SaturatingSub(ns.node.Mem.Watermark, ns.node.Mem.Reserved),
+		}
+	}
+
+	// Subtract the room that migrations we've already requested are expected to take up.
+	for _, ns := range s.nodes {
+		for uid, targetNode := range ns.requestedMigrations {
+			t, ok := targets[targetNode]
+			if !ok {
+				continue
+			}
+			if pod, ok := ns.node.GetPod(uid); ok {
+				t.cpuRoom = util.SaturatingSub(t.cpuRoom, pod.CPU.Reserved)
+				t.memRoom = util.SaturatingSub(t.memRoom, pod.Mem.Reserved)
+			}
+		}
+	}
+
+	var result []*migrationTarget
+	for _, t := range targets {
+		if t.cpuRoom > 0 && t.memRoom > 0 {
+			result = append(result, t)
+		}
+	}
+	// sort, so that we make the same decisions given the same state
+	slices.SortFunc(result, func(x, y *migrationTarget) int {
+		return cmp.Compare(x.nodeName, y.nodeName)
+	})
+	return result
+}
+
+// nodeZone returns the zone of the node, or the empty string if zones are not configured.
+func (s *PluginState) nodeZone(node *state.Node) string {
+	if s.config.ZoneLabel == "" {
+		return ""
+	}
+	zone, _ := node.Labels.Get(s.config.ZoneLabel)
+	return zone
+}
+
 // triggerMigrationsIfNecessary uses the state of the temporary node to request any migrations that
 // may be ncessary to reduce the reserved resources below the watermark.
+//
+// VMs are only migrated if there is a target node with room for them below its watermark. The
+// room on each target is reduced by the VMs we decide to migrate there.
+//
+// Migrations are also subject to the limits enforced by the migrationLimiter, which is only
+// created if we need to migrate anything.
 func triggerMigrationsIfNecessary(
 	logger *zap.Logger,
 	originalNode *state.Node,
 	tmpNode *state.Node,
 	requestedMigrations []types.UID,
-	requestMigrationAndRequeue func(podUID types.UID) error,
+	targets []*migrationTarget,
+	newLimiter func() *migrationLimiter,
+	requestMigrationAndRequeue func(podUID types.UID, targetNode string) error,
 ) error {
 	// To get an accurate count of the amount that's migrating, mark all the pods in
 	// requestedMigrations as if they're already migrating.
@@ -72,31 +312,42 @@ func triggerMigrationsIfNecessary(
 		candidates = append(candidates, pod)
 	}
 
-	// Ok, we have some migration candidates. Let's sort them and keep triggering migrations
-	// until it'll be enough to get below the watermark.
-	slices.SortFunc(candidates, func(cx, cy state.Pod) int {
-		return cx.BetterMigrationTargetThan(cy)
-	})
-	for _, pod := range candidates {
-		podLogger := logger.With(zap.Any("CandidatePod", pod))
-
-		// If we find a pod that is singularly above the watermark, don't migrate it! We'll
-		// likely just end up above the watermark on the new node.
-		// NOTE that this is NOT true in heterogeneous clusters (i.e., where the nodes are
-		// different sizes), but scheduling is much more complex there, and we'd have to be
-		// careful to only migrate when there's candidate nodes *with room* where the VM could
-		// fit without going over the watermark.
-		//
-		// That's all quite complicated -- hence why we're taking the easy way out.
-		tooBig := pod.CPU.Reserved > tmpNode.CPU.Watermark || pod.Mem.Reserved > tmpNode.Mem.Watermark
-		if tooBig {
-			podLogger.Warn("Skipping potential migration of candidate Pod because it's too big")
-			continue
+	limiter := newLimiter()
+
+	// Ok, we have some migration candidates. Let's keep triggering migrations until it'll be
+	// enough to get below the watermark.
+	for len(candidates) != 0 && (cpuAbove > 0 || memAbove > 0) {
+		if reason := limiter.limitReached(); reason != "" {
+			logger.Info("Not triggering more migrations because the concurrency limit was reached", zap.String("Limit", reason))
+			limiter.onThrottled(reason)
+			break
+		}
+		candidates = slices.DeleteFunc(candidates, func(pod state.Pod) bool {
+			reason := limiter.podThrottled(pod)
+			if reason != "" {
+				logger.Info("Skipping potential migration of candidate Pod because of migration limits", zap.Any("CandidatePod", pod), zap.String("Limit", reason))
+				limiter.onThrottled(reason)
+			}
+			return reason != ""
+		})
+
+		i, target, ok := chooseMigration(tmpNode, candidates, cpuAbove, memAbove, targets)
+		if !ok {
+			logger.Warn(
+				"No remaining candidate Pods fit on any migration target below its watermark",
+				zap.Int("Candidates", len(candidates)),
+				zap.Int("Targets", len(targets)),
+			)
+			break
 		}
+		pod := candidates[i]
+		candidates = slices.Delete(candidates, i, i+1)
+
+		podLogger := logger.With(zap.Any("CandidatePod", pod), zap.String("TargetNode", target.nodeName))
 
 		// Trigger migration of this pod!
 		podLogger.Info("Internally triggering migration for candidate Pod")
-		if err := requestMigrationAndRequeue(pod.UID); err != nil {
+		if err := requestMigrationAndRequeue(pod.UID, target.nodeName); err != nil {
 			podLogger.Error("Failed to requeue reconciling of candidate Pod")
 			return fmt.Errorf("could not requeue pod %v with UID %s: %w", pod.NamespacedName, pod.UID, err)
 		}
@@ -104,16 +355,15 @@ func triggerMigrationsIfNecessary(
 		newPod := pod
 		newPod.Migrating = true
 		tmpNode.UpdatePod(pod, newPod)
+		limiter.add(pod)
 
-		// ... and then check if we need to keep migrating more ...
+		// ... and the room left on the target ...
+		target.cpuRoom -= pod.CPU.Reserved
+		target.memRoom -= pod.Mem.Reserved
+
+		// ... and then check if we need to keep migrating more.
 		cpuAbove = tmpNode.CPU.UnmigratedAboveWatermark()
 		memAbove = tmpNode.Mem.UnmigratedAboveWatermark()
-
-		if cpuAbove <= 0 && memAbove <= 0 {
-			// We've triggered enough migrations that it should get us below the watermark.
-			// We're done for now.
-			break
-		}
 	}
 
 	if cpuAbove > 0 || memAbove > 0 {
@@ -125,3 +375,77 @@ func triggerMigrationsIfNecessary(
 
 	return nil
 }
+
+// chooseMigration picks which of the candidate pods to migrate next, and where to, returning false
+// if none of them fit on any of the targets.
+//
+// We prefer the smallest pod that would get the node below its watermark -- or if there isn't one,
+// the largest pod -- so that we migrate as little as possible while still getting the node below
+// its watermark. Ties are broken by (state.Pod).BetterMigrationTargetThan.
+//
+// Each pod is sent to the target with the least room that it still fits on, so that larger VMs can
+// use the targets with more room.
+func chooseMigration(
+	node *state.Node,
+	candidates []state.Pod,
+	cpuAbove vmv1.MilliCPU,
+	memAbove api.Bytes,
+	targets []*migrationTarget,
+) (index int, _ *migrationTarget, ok bool) {
+	enough := func(pod state.Pod) bool {
+		return pod.CPU.Reserved >= cpuAbove && pod.Mem.Reserved >= memAbove
+	}
+	// better returns whether x should be migrated instead of y
+	better := func(x, y state.Pod) bool {
+		if enough(x) != enough(y) {
+			return enough(x)
+		}
+		sx := nodeFraction(node, x.CPU.Reserved, x.Mem.Reserved)
+		sy := nodeFraction(node, y.CPU.Reserved, y.Mem.Reserved)
+		if sx != sy {
+			// smallest if it's enough, otherwise largest
+			return (sx < sy) == enough(x)
+		}
+		return x.BetterMigrationTargetThan(y) < 0
+	}
+
+	var best *migrationTarget
+	for i, pod := range candidates {
+		target := bestMigrationTarget(node, targets, pod)
+		if target == nil {
+			continue
+		}
+
+		if best == nil || better(pod, candidates[index]) {
+			index, best = i, target
+		}
+	}
+
+	return index, best, best != nil
+}
+
+// bestMigrationTarget returns the target with the least room that the pod still fits on, or nil if
+// it doesn't fit on any of them.
+func bestMigrationTarget(node *state.Node, targets []*migrationTarget, pod state.Pod) *migrationTarget {
+	var best *migrationTarget
+	var bestRemaining float64
+	for _, t := range targets {
+		if !t.fits(pod) {
+			continue
+		}
+		remaining := nodeFraction(node, t.cpuRoom-pod.CPU.Reserved, t.memRoom-pod.Mem.Reserved)
+		if best == nil || remaining < bestRemaining {
+			best, bestRemaining = t, remaining
+		}
+	}
+	return best
+}
+
+// nodeFraction gives the fraction of the node's total resources represented by the amounts of CPU
+// and memory, taking whichever resource is larger.
+func nodeFraction(node *state.Node, cpu vmv1.MilliCPU, mem api.Bytes) float64 {
+	return max(
+		float64(cpu)/float64(max(node.CPU.Total, 1)),
+		float64(mem)/float64(max(node.Mem.Total, 1)),
+	)
+}
@@ -0,0 +1,293 @@
+package plugin
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/types"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/plugin/state"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+const (
+	cpu = vmv1.MilliCPU(1000)
+	gib = api.Bytes(1024 * 1024 * 1024)
+)
+
+func migratablePod(name string, age time.Duration, podCPU vmv1.MilliCPU, podMem api.Bytes) state.Pod {
+	return state.Pod{
+		NamespacedName: util.NamespacedName{Namespace: "test-namespace", Name: name},
+		UID:            types.UID(fmt.Sprintf("%s-uid", name)),
+		CreatedAt:      time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC).Add(-age),
+		VirtualMachine: util.NamespacedName{Namespace: "test-namespace", Name: name},
+		Migratable:     true,
+		AlwaysMigrate:  false,
+		Migrating:      false,
+		Autoscaling:    true,
+		CPU: state.PodResources[vmv1.MilliCPU]{
+			Reserved:   podCPU,
+			Requested:  podCPU,
+			Max:        podCPU,
+			Factor:     250,
+			Overcommit: lo.ToPtr(resource.MustParse("1000m")),
+		},
+		Mem: state.PodResources[api.Bytes]{
+			Reserved:   podMem,
+			Requested:  podMem,
+			Max:        podMem,
+			Factor:     gib,
+			Overcommit: lo.ToPtr(resource.MustParse("1000m")),
+		},
+	}
+}
+
+func TestTriggerMigrations(t *testing.T) {
+	pods := []state.Pod{
+		migratablePod("a", 4*time.Hour, 2*cpu, 8*gib),
+		migratablePod("b", 3*time.Hour, 4*cpu, 16*gib),
+		migratablePod("c", 2*time.Hour, 1*cpu, 4*gib),
+		migratablePod("d", 1*time.Hour, 3*cpu, 12*gib),
+	}
+
+	cases := []struct {
+		name      string
+		watermark float64
+		limits    MigrationLimitsConfig
+		// ongoingElsewhere is the number of migrations already ongoing from other nodes
+		ongoingElsewhere int
+		// budget, if not nil, is the migration budget for the pods' namespace
+		budget *int
+		// recentlyMigrated are the VMs that were migrated 1 minute ago
+		recentlyMigrated []string
+		expected         map[string]string
+	}{
+		{
+			// 2 CPU / 8 GiB above the watermark: "a" and "d" are both big enough, but "a" is
+			// smaller. It doesn't fit on "small", so goes to "medium" instead of "large".
+			name:      "smallest-sufficient",
+			watermark: 0.8,
+			expected:  map[string]string{"a-uid": "medium"},
+		},
+		{
+			// 5 CPU / 20 GiB above the watermark: No single VM is big enough, so we start with the
+			// largest ("b", which only fits on "large"), followed by the smallest that covers the
+			// rest ("c", which exactly fits on "small").
+			name:      "largest-first",
+			watermark: 0.5,
+			expected:  map[string]string{"b-uid": "large", "c-uid": "small"},
+		},
+		{
+			name:      "node-limit",
+			watermark: 0.5,
+			limits:    MigrationLimitsConfig{MaxConcurrentPerNode: 1, MaxConcurrentTotal: 0, MinIntervalSeconds: 0},
+			expected:  map[string]string{"b-uid": "large"},
+		},
+		{
+			name:             "cluster-limit",
+			watermark:        0.5,
+			limits:           MigrationLimitsConfig{MaxConcurrentPerNode: 0, MaxConcurrentTotal: 5, MinIntervalSeconds: 0},
+			ongoingElsewhere: 4,
+			expected:         map[string]string{"b-uid": "large"},
+		},
+		{
+			name:      "namespace-budget",
+			watermark: 0.5,
+			budget:    lo.ToPtr(0),
+			expected:  map[string]string{},
+		},
+		{
+			// "a" would be chosen, but was migrated too recently. "d" is the next best.
+			name:             "min-interval",
+			watermark:        0.8,
+			limits:           MigrationLimitsConfig{MaxConcurrentPerNode: 0, MaxConcurrentTotal: 0, MinIntervalSeconds: 600},
+			recentlyMigrated: []string{"a"},
+			expected:         map[string]string{"d-uid": "medium"},
+		},
+	}
+
+	now := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			node := state.NodeStateFromParams("source", 10*cpu, 40*gib, c.watermark, nil)
+			for _, p := range pods {
+				node.AddPod(p)
+			}
+
+			targets := []*migrationTarget{
+				{nodeName: "large", cpuRoom: 6 * cpu, memRoom: 24 * gib},
+				{nodeName: "medium", cpuRoom: 3 * cpu, memRoom: 12 * gib},
+				{nodeName: "small", cpuRoom: 1 * cpu, memRoom: 4 * gib},
+			}
+
+			newLimiter := func() *migrationLimiter {
+				return &migrationLimiter{
+					limits:          c.limits,
+					now:             now,
+					nodeCount:       0,
+					totalCount:      c.ongoingElsewhere,
+					namespaceCounts: make(map[string]int),
+					namespaceBudget: func(string) (int, bool) {
+						return lo.FromPtr(c.budget), c.budget != nil
+					},
+					lastMigrationAt: func(vm util.NamespacedName) (time.Time, bool) {
+						if slices.Contains(c.recentlyMigrated, vm.Name) {
+							return now.Add(-time.Minute), true
+						}
+						return time.Time{}, false
+					},
+					onThrottled: func(string) {},
+				}
+			}
+
+			requested := make(map[string]string)
+			node.Speculatively(func(tmpNode *state.Node) bool {
+				err := triggerMigrationsIfNecessary(
+					zap.NewNop(),
+					node,
+					tmpNode,
+					nil,
+					targets,
+					newLimiter,
+					func(podUID types.UID, targetNode string) error {
+						requested[string(podUID)] = targetNode
+						return nil
+					},
+				)
+				require.NoError(t, err)
+				return false
+			})
+
+			assert.Equal(t, c.expected, requested)
+		})
+	}
+}
+
+func TestMigrationTargets(t *testing.T) {
+	const zoneLabel = "topology.kubernetes.io/zone"
+
+	newNodeState := func(name string, zone string, reserved ...state.Pod) *nodeState {
+		node := state.NodeStateFromParams(name, 10*cpu, 40*gib, 0.8, map[string]string{zoneLabel: zone})
+		for _, p := range reserved {
+			node.AddPod(p)
+		}
+		return &nodeState{
+			node:                node,
+			requestedMigrations: make(map[types.UID]string),
+			podsVMPatchedAt:     make(map[types.UID]time.Time),
+			draining:            false,
+			unschedulable:       false,
+			drainStatus:         "",
+		}
+	}
+
+	migrating := migratablePod("migrating", time.Hour, 2*cpu, 8*gib)
+
+	source := newNodeState("source", "zone-a", migrating)
+	source.requestedMigrations[migrating.UID] = "same-zone"
+
+	cordoned := newNodeState("cordoned", "zone-a")
+	cordoned.unschedulable = true
+
+	//nolint:exhaustruct // this is a test
+	s := &PluginState{
+		config: Config{ZoneLabel: zoneLabel},
+		nodes: map[string]*nodeState{
+			"source":     source,
+			"same-zone":  newNodeState("same-zone", "zone-a", migratablePod("x", time.Hour, 1*cpu, 4*gib)),
+			"other-zone": newNodeState("other-zone", "zone-b"),
+			"full":       newNodeState("full", "zone-a", migratablePod("y", time.Hour, 8*cpu, 32*gib)),
+			"cordoned":   cordoned,
+		},
+	}
+
+	// Only "same-zone" is a valid target, and it has less room because of the migration we've
+	// already requested.
+	assert.Equal(t, []*migrationTarget{
+		{nodeName: "same-zone", cpuRoom: 5 * cpu, memRoom: 20 * gib},
+	}, s.migrationTargets(source))
+}
+
+func TestMigrationForPod(t *testing.T) {
+	const zoneLabel = "topology.kubernetes.io/zone"
+	pod := migratablePod("vm", time.Hour, 1*cpu, 4*gib)
+
+	targetTerm := corev1.PreferredSchedulingTerm{
+		Weight: 100,
+		Preference: corev1.NodeSelectorTerm{
+			MatchFields: []corev1.NodeSelectorRequirement{{
+				Key:      "metadata.name",
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{"target"},
+			}},
+		},
+	}
+	zoneSelector := &corev1.NodeSelector{
+		NodeSelectorTerms: []corev1.NodeSelectorTerm{{
+			MatchExpressions: []corev1.NodeSelectorRequirement{{
+				Key:      zoneLabel,
+				Operator: corev1.NodeSelectorOpIn,
+				Values:   []string{"zone-a"},
+			}},
+		}},
+	}
+
+	cases := []struct {
+		name       string
+		targetNode string
+		zone       string
+		expected   *corev1.NodeAffinity
+	}{
+		{
+			name:       "none",
+			targetNode: "",
+			zone:       "",
+			expected:   nil,
+		},
+		{
+			name:       "target-only",
+			targetNode: "target",
+			zone:       "",
+			expected: &corev1.NodeAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{targetTerm},
+			},
+		},
+		{
+			name:       "zone-only",
+			targetNode: "",
+			zone:       "zone-a",
+			expected: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution: zoneSelector,
+			},
+		},
+		{
+			name:       "target-and-zone",
+			targetNode: "target",
+			zone:       "zone-a",
+			expected: &corev1.NodeAffinity{
+				RequiredDuringSchedulingIgnoredDuringExecution:  zoneSelector,
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.PreferredSchedulingTerm{targetTerm},
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			vmm := migrationForPod(pod, c.targetNode, zoneLabel, c.zone)
+			assert.Equal(t, pod.VirtualMachine.Name, vmm.Spec.VmName)
+			assert.Equal(t, c.expected, vmm.Spec.NodeAffinity)
+		})
+	}
+}
@@ -0,0 +1,356 @@
+package plugin
+
+// Handling for permit streams from autoscaler-agents, the streaming alternative to the HTTP handler
+// in run.go. See package permitstream for more.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/tychoish/fun/srv"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/api/permitstream"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+// PermitStreamPort is the port that the permit stream gRPC server listens on.
+const PermitStreamPort = 10301
+
+type permitStreamServer struct {
+	state  *PluginState
+	logger *zap.Logger
+
+	getPod         func(util.NamespacedName) (*corev1.Pod, bool)
+	listenerForPod func(types.UID) (util.BroadcastReceiver, bool)
+}
+
+// startPermitStreamServer runs the gRPC server for handling permit streams from autoscaler-agents
+func (s *PluginState) startPermitStreamServer(
+	ctx context.Context,
+	logger *zap.Logger,
+	getPod func(util.NamespacedName) (*corev1.Pod, bool),
+	listenerForPod func(types.UID) (util.BroadcastReceiver, bool),
+) error {
+	server := grpc.NewServer()
+	permitstream.RegisterServer(server, &permitStreamServer{
+		state:          s,
+		logger:         logger,
+		getPod:         getPod,
+		listenerForPod: listenerForPod,
+	})
+
+	listener, err := net.Listen("tcp", fmt.Sprintf("0.0.0.0:%d", PermitStreamPort))
+	if err != nil {
+		return fmt.Errorf("error listening on port %d: %w", PermitStreamPort, err)
+	}
+
+	orca := srv.GetOrchestrator(ctx)
+
+	logger.Info("Starting permit stream server")
+	service := &srv.Service{
+		Name: "permit-stream",
+		Run: func(context.Context) error {
+			return server.Serve(listener)
+		},
+		Shutdown: func() error {
+			// note: Stop instead of GracefulStop, because the streams are long-lived and would
+			// otherwise block shutdown.
+			server.Stop()
+			return nil
+		},
+	}
+	if err := service.Start(ctx); err != nil {
+		return fmt.Errorf("error starting permit stream server: %w", err)
+	}
+
+	if err := orca.Add(service); err != nil {
+		return fmt.Errorf("error adding permit stream server to orchestrator: %w", err)
+	}
+	return nil
+}
+
+// permitStreamState is the state of a single permit stream
+type permitStreamState struct {
+	// latest is the most recent request received on the stream, or nil if there hasn't been one.
+	latest *permitstream.Request
+	// nodeName is the node of the pod for the stream, as of the latest request.
+	nodeName string
+	// updates receives notifications each time the pod is updated. It is only valid if latest is
+	// not nil.
+	updates util.BroadcastReceiver
+	// lastSent is the permit most recently sent to the agent, or the LastPermit from the latest
+	// request if it hasn't yet been responded to.
+	lastSent *api.Resources
+	// pending is true if the latest request hasn't yet been responded to.
+	pending bool
+	// timeout, if not nil, fires once we've waited long enough for the pending request to be
+	// approved.
+	timeout *time.Timer
+}
+
+// Stream implements permitstream.Server
+func (p *permitStreamServer) Stream(stream permitstream.ServerStream) error {
+	ctx := stream.Context()
+
+	// Receive requests separately, so that we can wait for them at the same time as updates to the
+	// pod.
+	requests := make(chan *permitstream.Request)
+	recvErr := make(chan error, 1)
+	go func() {
+		for {
+			req, err := stream.Recv()
+			if err != nil {
+				recvErr <- err
+				return
+			}
+			select {
+			case requests <- req:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	var state permitStreamState
+	defer func() {
+		if state.timeout != nil {
+			state.timeout.Stop()
+		}
+	}()
+
+	for {
+		var updates <-chan struct{}
+		var timeout <-chan time.Time
+		if state.latest != nil {
+			updates = state.updates.Wait()
+		}
+		if state.timeout != nil {
+			timeout = state.timeout.C
+		}
+
+		timedOut := false
+
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case err := <-recvErr:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case req := <-requests:
+			if err := p.handleRequest(stream, &state, req); err != nil {
+				return err
+			}
+		case <-updates:
+			state.updates.Awake()
+		case <-timeout:
+			timedOut = true
+			state.timeout = nil
+		}
+
+		if state.latest != nil {
+			if err := p.checkPod(stream, &state, timedOut); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// handleRequest handles a new request on the stream, responding immediately if possible
+func (p *permitStreamServer) handleRequest(
+	stream permitstream.ServerStream,
+	state *permitStreamState,
+	req *permitstream.Request,
+) (err error) {
+	logger := p.logger.With(zap.Object("pod", req.Request.Pod), zap.Any("request", req.Request), zap.Uint64("seq", req.Seq))
+
+	nodeName := "<none>" // override this later if we have a node name
+	statusCode := 200
+
+	defer func() {
+		if err != nil {
+			logFunc := logger.Warn
+			if statusCode >= 500 {
+				logFunc = logger.Error
+			}
+			logFunc("Responding to autoscaler-agent stream request with error", zap.Int("status", statusCode), zap.Error(err))
+			p.recordResult(statusCode, nodeName)
+		}
+	}()
+
+	if state.latest != nil {
+		if req.Request.Pod != state.latest.Request.Pod {
+			statusCode = 400
+			return status.Errorf(codes.InvalidArgument, "pod changed within stream, from %v to %v", state.latest.Request.Pod, req.Request.Pod)
+		} else if req.Seq <= state.latest.Seq {
+			statusCode = 400
+			return status.Errorf(codes.InvalidArgument, "seq %d is not greater than previous %d", req.Seq, state.latest.Seq)
+		}
+	}
+	if !req.Request.ProtoVersion.SupportsPermitStreaming() {
+		statusCode = 400
+		return status.Errorf(codes.InvalidArgument, "protocol version %v does not support permit streaming", req.Request.ProtoVersion)
+	}
+
+	podObj, vmName, statusCode, err := validateAgentRequest(logger, req.Request, p.getPod)
+	if err != nil {
+		return status.Error(grpcCodeForStatus(statusCode), err.Error())
+	}
+	nodeName = podObj.Spec.NodeName
+
+	patches, changed := vmPatchForAgentRequest(podObj, req.Request)
+
+	// Start listening *before* we update the VM.
+	if state.latest == nil {
+		updates, ok := p.listenerForPod(podObj.UID)
+		if !ok {
+			statusCode = 404
+			return status.Error(codes.NotFound, "pod not found")
+		}
+		state.updates = updates
+	}
+
+	if changed {
+		if err := p.state.patchVM(vmName, patches); err != nil {
+			logger.Error("Failed to patch VM object", zap.Error(err))
+			statusCode = 500
+			return status.Error(codes.Internal, "failed to patch VM object")
+		}
+		logger.Info("Patched VirtualMachine for agent request", zap.Any("patches", patches))
+	}
+
+	state.latest = req
+	state.nodeName = nodeName
+	state.lastSent = req.Request.LastPermit
+	if state.timeout != nil {
+		state.timeout.Stop()
+		state.timeout = nil
+	}
+
+	// If we should be able to instantly approve the request, don't bother waiting to observe it.
+	if req.Request.LastPermit != nil && !req.Request.Resources.HasFieldGreaterThan(*req.Request.LastPermit) {
+		state.pending = false
+		return p.respond(logger, stream, state, req.Seq, req.Request.Resources, nil)
+	}
+
+	state.pending = true
+	state.timeout = time.NewTimer(p.state.config.approvalWait())
+	return nil
+}
+
+// checkPod responds to the pending request, or pushes an updated permit to the agent, if the
+// resources approved for the pod have changed.
+func (p *permitStreamServer) checkPod(stream permitstream.ServerStream, state *permitStreamState, timedOut bool) error {
+	req := state.latest
+	logger := p.logger.With(zap.Object("pod", req.Request.Pod), zap.Uint64("seq", req.Seq))
+
+	podObj, ok := p.getPod(req.Request.Pod)
+	if !ok {
+		logger.Warn("Pod for stream no longer exists")
+		return status.Error(codes.NotFound, "pod not found")
+	}
+
+	approved, requested, err := podPermitState(podObj)
+	if err != nil {
+		logger.Error("Failed to extract Pod state from Pod object for agent stream", zap.Error(err))
+		return status.Error(codes.Internal, "failed to extract state from pod")
+	}
+
+	// If our updates to the VM haven't been reflected on the pod yet, the approved resources may
+	// be for a previous request.
+	if requested != req.Request.Resources {
+		if state.pending && timedOut {
+			if partial := p.state.pendingPermit(req.Request); partial != nil {
+				logger.Warn("Timed out while waiting for updates, responding with previous permit")
+				state.pending = false
+				return p.respond(logger, stream, state, req.Seq, *req.Request.LastPermit, partial)
+			}
+			logger.Error("Timed out while waiting for updates without suitable response to agent request")
+			p.recordResult(500, state.nodeName)
+			return status.Error(codes.Internal, "timed out waiting for updates to be processed")
+		}
+		return nil
+	}
+
+	if !permitIncreased(podObj, approved, state.lastSent) && !(state.pending && timedOut) {
+		return nil
+	}
+
+	seq := uint64(0)
+	if state.pending {
+		seq = req.Seq
+		state.pending = false
+		if state.timeout != nil {
+			state.timeout.Stop()
+			state.timeout = nil
+		}
+		if timedOut {
+			logger.Warn("Timed out while waiting for updates to respond to agent request")
+		}
+	}
+	return p.respond(logger, stream, state, seq, approved, p.state.partialPermit(req.Request, podObj, approved))
+}
+
+// respond sends a permit to the agent. If seq is zero, the permit is pushed as an update to the
+// latest request.
+func (p *permitStreamServer) respond(
+	logger *zap.Logger,
+	stream permitstream.ServerStream,
+	state *permitStreamState,
+	seq uint64,
+	permit api.Resources,
+	partial *api.PartialPermit,
+) error {
+	resp := permitstream.Response{
+		Seq: seq,
+		Response: api.PluginResponse{
+			Permit:  permit,
+			Migrate: nil,
+			Partial: partial,
+		},
+	}
+	if err := stream.Send(&resp); err != nil {
+		return err
+	}
+	state.lastSent = &permit
+
+	if seq != 0 {
+		p.recordResult(200, state.nodeName)
+		logger.Info("Handled agent request", zap.Int("status", 200), zap.Any("response", resp.Response))
+	} else {
+		logger.Info("Pushed updated permit to agent", zap.Any("response", resp.Response))
+	}
+	return nil
+}
+
+func (p *permitStreamServer) recordResult(statusCode int, nodeName string) {
+	p.state.metrics.ResourceRequests.WithLabelValues(strconv.Itoa(statusCode)).Inc()
+	p.state.metrics.ValidResourceRequests.WithLabelValues(strconv.Itoa(statusCode), nodeName).Inc()
+}
+
+// grpcCodeForStatus returns the gRPC status code equivalent to the HTTP status returned by
+// validateAgentRequest
+func grpcCodeForStatus(statusCode int) codes.Code {
+	switch statusCode {
+	case 400:
+		return codes.InvalidArgument
+	case 404:
+		return codes.NotFound
+	default:
+		return codes.Internal
+	}
+}
@@ -0,0 +1,389 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/api/permitstream"
+	"github.com/neondatabase/autoscaling/pkg/plugin/metrics"
+	"github.com/neondatabase/autoscaling/pkg/util"
+	"github.com/neondatabase/autoscaling/pkg/util/patch"
+)
+
+// fakeServerStream is a permitstream.ServerStream that passes messages over channels
+type fakeServerStream struct {
+	// embedded so that we implement the rest of the interface; calling its methods will panic.
+	grpc.ServerStream
+
+	ctx       context.Context
+	requests  chan *permitstream.Request
+	responses chan *permitstream.Response
+}
+
+func (s *fakeServerStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *fakeServerStream) Recv() (*permitstream.Request, error) {
+	select {
+	case req, ok := <-s.requests:
+		if !ok {
+			return nil, io.EOF
+		}
+		return req, nil
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	}
+}
+
+func (s *fakeServerStream) Send(resp *permitstream.Response) error {
+	select {
+	case s.responses <- resp:
+		return nil
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// permitStreamTest runs a permitStreamServer for a single VM pod, with helpers to update the pod
+// and interact with the stream.
+type permitStreamTest struct {
+	t      *testing.T
+	server *permitStreamServer
+	stream *fakeServerStream
+	result chan error
+
+	mu          sync.Mutex
+	pod         *corev1.Pod
+	broadcaster *util.Broadcaster
+	patches     [][]patch.Operation
+}
+
+var (
+	testStreamPod    = util.NamespacedName{Namespace: "default", Name: "runner"}
+	testStreamPodUID = types.UID("runner-uid")
+)
+
+func startPermitStreamTest(t *testing.T, config Config) *permitStreamTest {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	resourcesJSON, err := json.Marshal(vmv1.VirtualMachineResources{
+		CPUs:           vmv1.CPUs{Min: 1 * cpu, Max: 4 * cpu, Use: 1 * cpu, Limit: 1 * cpu},
+		MemorySlots:    vmv1.MemorySlots{Min: 1, Max: 16, Use: 4},
+		MemorySlotSize: resource.MustParse("1Gi"),
+	})
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // this is a test
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testStreamPod.Name,
+			Namespace: testStreamPod.Namespace,
+			UID:       testStreamPodUID,
+			Labels:    map[string]string{api.LabelEnableAutoscaling: "true"},
+			Annotations: map[string]string{
+				vmv1.VirtualMachineResourcesAnnotation: string(resourcesJSON),
+			},
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: vmv1.SchemeGroupVersion.String(),
+				Kind:       "VirtualMachine",
+				Name:       "vm",
+			}},
+		},
+		Spec: corev1.PodSpec{NodeName: "node"},
+	}
+
+	test := &permitStreamTest{
+		t:      t,
+		server: nil, // set below
+		stream: &fakeServerStream{
+			ServerStream: nil,
+			ctx:          ctx,
+			requests:     make(chan *permitstream.Request),
+			responses:    make(chan *permitstream.Response),
+		},
+		result:      make(chan error, 1),
+		mu:          sync.Mutex{},
+		pod:         pod,
+		broadcaster: util.NewBroadcaster(),
+		patches:     nil,
+	}
+
+	//nolint:exhaustruct // this is a test
+	state := &PluginState{
+		config:  config,
+		nodes:   make(map[string]*nodeState),
+		metrics: metrics.BuildPluginMetrics(prometheus.NewRegistry(), nil),
+		patchVM: func(_ util.NamespacedName, patches []patch.Operation) error {
+			test.mu.Lock()
+			defer test.mu.Unlock()
+			test.patches = append(test.patches, patches)
+			return nil
+		},
+	}
+
+	test.server = &permitStreamServer{
+		state:  state,
+		logger: zap.NewNop(),
+		getPod: func(name util.NamespacedName) (*corev1.Pod, bool) {
+			test.mu.Lock()
+			defer test.mu.Unlock()
+			if name != testStreamPod {
+				return nil, false
+			}
+			return test.pod.DeepCopy(), true
+		},
+		listenerForPod: func(uid types.UID) (util.BroadcastReceiver, bool) {
+			if uid != testStreamPodUID {
+				return util.BroadcastReceiver{}, false
+			}
+			return test.broadcaster.NewReceiver(), true
+		},
+	}
+
+	go func() {
+		test.result <- test.server.Stream(test.stream)
+	}()
+
+	return test
+}
+
+// setPod updates the requested and approved resources on the pod, as if the VM's changes were
+// propagated to it.
+func (p *permitStreamTest) setPod(requested, approved api.Resources) {
+	requestedJSON, err := json.Marshal(requested)
+	require.NoError(p.t, err)
+	approvedJSON, err := json.Marshal(approved)
+	require.NoError(p.t, err)
+
+	p.mu.Lock()
+	p.pod.Annotations[api.AnnotationAutoscalingUnit] = `{"vCPUs":250,"mem":1073741824}`
+	p.pod.Annotations[api.InternalAnnotationResourcesRequested] = string(requestedJSON)
+	p.pod.Annotations[api.InternalAnnotationResourcesApproved] = string(approvedJSON)
+	p.mu.Unlock()
+
+	p.broadcaster.Broadcast()
+}
+
+func (p *permitStreamTest) send(seq uint64, pod util.NamespacedName, resources api.Resources, lastPermit *api.Resources) {
+	req := &permitstream.Request{
+		Seq: seq,
+		Request: api.AgentRequest{
+			ProtoVersion: api.PluginProtoV6_1,
+			Pod:          pod,
+			ComputeUnit:  api.Resources{VCPU: 250, Mem: 1 * gib},
+			Resources:    resources,
+			LastPermit:   lastPermit,
+			Metrics:      nil,
+		},
+	}
+	select {
+	case p.stream.requests <- req:
+	case <-time.After(time.Second):
+		p.t.Fatal("timed out sending request")
+	}
+}
+
+func (p *permitStreamTest) receive() *permitstream.Response {
+	select {
+	case resp := <-p.stream.responses:
+		return resp
+	case err := <-p.result:
+		p.t.Fatalf("stream ended before response: %v", err)
+	case <-time.After(time.Second):
+		p.t.Fatal("timed out waiting for response")
+	}
+	return nil
+}
+
+func (p *permitStreamTest) assertNoResponse() {
+	select {
+	case resp := <-p.stream.responses:
+		p.t.Fatalf("unexpected response %+v", resp)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (p *permitStreamTest) streamError() error {
+	select {
+	case err := <-p.result:
+		return err
+	case <-time.After(time.Second):
+		p.t.Fatal("timed out waiting for stream to end")
+	}
+	return nil
+}
+
+func res(vcpu vmv1.MilliCPU, mem api.Bytes) api.Resources {
+	return api.Resources{VCPU: vcpu, Mem: mem}
+}
+
+func TestPermitStreamImmediateApproval(t *testing.T) {
+	//nolint:exhaustruct // this is a test
+	p := startPermitStreamTest(t, Config{ApprovalWaitMillis: 10000})
+	p.setPod(res(1*cpu, 4*gib), res(1*cpu, 4*gib))
+
+	// Downscaling doesn't need to wait for the pod to be updated.
+	p.send(1, testStreamPod, res(cpu/2, 2*gib), &api.Resources{VCPU: 1 * cpu, Mem: 4 * gib})
+	resp := p.receive()
+	assert.Equal(t, uint64(1), resp.Seq)
+	assert.Equal(t, res(cpu/2, 2*gib), resp.Response.Permit)
+	assert.Nil(t, resp.Response.Partial)
+
+	p.mu.Lock()
+	assert.Len(t, p.patches, 1)
+	p.mu.Unlock()
+
+	close(p.stream.requests)
+	assert.NoError(t, p.streamError())
+}
+
+func TestPermitStreamPendingThenPush(t *testing.T) {
+	//nolint:exhaustruct // this is a test
+	p := startPermitStreamTest(t, Config{ApprovalWaitMillis: 10000})
+	p.setPod(res(1*cpu, 4*gib), res(1*cpu, 4*gib))
+
+	p.send(1, testStreamPod, res(2*cpu, 8*gib), &api.Resources{VCPU: 1 * cpu, Mem: 4 * gib})
+	p.assertNoResponse()
+
+	// Pod updates for a previous request shouldn't be used to respond.
+	p.setPod(res(1*cpu, 4*gib), res(1*cpu, 4*gib))
+	p.assertNoResponse()
+
+	// Once the request is reflected on the pod, we respond with whatever was approved.
+	p.setPod(res(2*cpu, 8*gib), res(3*cpu/2, 6*gib))
+	resp := p.receive()
+	assert.Equal(t, uint64(1), resp.Seq)
+	assert.Equal(t, res(3*cpu/2, 6*gib), resp.Response.Permit)
+
+	// ... and then push updates with seq 0 when more is approved.
+	p.setPod(res(2*cpu, 8*gib), res(2*cpu, 8*gib))
+	resp = p.receive()
+	assert.Equal(t, uint64(0), resp.Seq)
+	assert.Equal(t, res(2*cpu, 8*gib), resp.Response.Permit)
+
+	// Nothing is pushed if the permit hasn't increased.
+	p.setPod(res(2*cpu, 8*gib), res(2*cpu, 8*gib))
+	p.assertNoResponse()
+}
+
+func TestPermitStreamTimeoutPartialPermit(t *testing.T) {
+	//nolint:exhaustruct // this is a test
+	p := startPermitStreamTest(t, Config{
+		ApprovalWaitMillis: 20,
+		PartialPermits:     &PartialPermitsConfig{MigrationRetryAfterSeconds: 10, NodeFullRetryAfterSeconds: 30},
+	})
+	p.setPod(res(1*cpu, 4*gib), res(1*cpu, 4*gib))
+
+	// The pod is never updated, so we should time out and respond with the previous permit.
+	p.send(1, testStreamPod, res(2*cpu, 8*gib), &api.Resources{VCPU: 1 * cpu, Mem: 4 * gib})
+	resp := p.receive()
+	assert.Equal(t, uint64(1), resp.Seq)
+	assert.Equal(t, res(1*cpu, 4*gib), resp.Response.Permit)
+	require.NotNil(t, resp.Response.Partial)
+	assert.Equal(t, api.PartialPermitReasonPending, resp.Response.Partial.Reason)
+}
+
+// TestAgentRequestTimeoutPartialPermit checks that requests over HTTP also get partial permits, if
+// the autoscaler-agent's protocol version supports them.
+func TestAgentRequestTimeoutPartialPermit(t *testing.T) {
+	cases := []struct {
+		name    string
+		version api.PluginProtoVersion
+		partial bool
+	}{
+		{name: "v5.0", version: api.PluginProtoV5_0, partial: false},
+		{name: "v6.1", version: api.PluginProtoV6_1, partial: true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			//nolint:exhaustruct // this is a test
+			p := startPermitStreamTest(t, Config{
+				ApprovalWaitMillis: 20,
+				PartialPermits:     &PartialPermitsConfig{MigrationRetryAfterSeconds: 10, NodeFullRetryAfterSeconds: 30},
+			})
+			p.setPod(res(1*cpu, 4*gib), res(1*cpu, 4*gib))
+
+			// The pod is never updated, so we should time out.
+			req := api.AgentRequest{
+				ProtoVersion: c.version,
+				Pod:          testStreamPod,
+				ComputeUnit:  api.Resources{VCPU: 250, Mem: 1 * gib},
+				Resources:    res(2*cpu, 8*gib),
+				LastPermit:   &api.Resources{VCPU: 1 * cpu, Mem: 4 * gib},
+				Metrics:      nil,
+			}
+			resp, status, err := p.server.state.handleAgentRequest(zap.NewNop(), req, p.server.getPod, p.server.listenerForPod)
+
+			if !c.partial {
+				assert.Equal(t, 500, status)
+				assert.EqualError(t, err, "timed out waiting for updates to be processed")
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 200, status)
+			assert.Equal(t, res(1*cpu, 4*gib), resp.Permit)
+			require.NotNil(t, resp.Partial)
+			assert.Equal(t, api.PartialPermitReasonPending, resp.Partial.Reason)
+		})
+	}
+}
+
+func TestPermitStreamRejectsInvalidRequests(t *testing.T) {
+	cases := []struct {
+		name    string
+		seq     uint64
+		pod     util.NamespacedName
+		message string
+	}{
+		{
+			name:    "pod-changed",
+			seq:     2,
+			pod:     util.NamespacedName{Namespace: "default", Name: "other"},
+			message: "pod changed within stream, from default/runner to default/other",
+		},
+		{
+			name:    "seq-not-increasing",
+			seq:     1,
+			pod:     testStreamPod,
+			message: "seq 1 is not greater than previous 1",
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			//nolint:exhaustruct // this is a test
+			p := startPermitStreamTest(t, Config{ApprovalWaitMillis: 10000})
+			p.setPod(res(1*cpu, 4*gib), res(1*cpu, 4*gib))
+
+			p.send(1, testStreamPod, res(1*cpu, 4*gib), &api.Resources{VCPU: 1 * cpu, Mem: 4 * gib})
+			assert.Equal(t, uint64(1), p.receive().Seq)
+
+			p.send(c.seq, c.pod, res(1*cpu, 4*gib), &api.Resources{VCPU: 1 * cpu, Mem: 4 * gib})
+			err := p.streamError()
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+			assert.Equal(t, c.message, status.Convert(err).Message())
+		})
+	}
+}
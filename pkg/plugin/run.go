@@ -31,7 +31,7 @@ const (
 
 const (
 	MinPluginProtocolVersion api.PluginProtoVersion = api.PluginProtoV5_0
-	MaxPluginProtocolVersion api.PluginProtoVersion = api.PluginProtoV5_0
+	MaxPluginProtocolVersion api.PluginProtoVersion = api.PluginProtoV6_1
 )
 
 // startPermitHandler runs the server for handling each resourceRequest from a pod
@@ -143,48 +143,13 @@ func (s *PluginState) handleAgentRequest(
 			Inc()
 	}()
 
-	// Before doing anything, check that the version is within the range we're expecting.
-	expectedProtoRange := api.VersionRange[api.PluginProtoVersion]{
-		Min: MinPluginProtocolVersion,
-		Max: MaxPluginProtocolVersion,
-	}
-
-	if !req.ProtoVersion.IsValid() {
-		return nil, 400, fmt.Errorf("invalid protocol version %v", req.ProtoVersion)
-	}
-	reqProtoRange := req.ProtocolRange()
-	if _, ok := expectedProtoRange.LatestSharedVersion(reqProtoRange); !ok {
-		return nil, 400, fmt.Errorf(
-			"protocol version mismatch: need %v but got %v", expectedProtoRange, reqProtoRange,
-		)
-	}
-
-	// check that req.ComputeUnit has no zeros
-	if err := req.ComputeUnit.ValidateNonZero(); err != nil {
-		return nil, 400, fmt.Errorf("computeUnit fields must be non-zero: %w", err)
-	}
-
-	podObj, ok := getPod(req.Pod)
-	if !ok {
-		logger.Warn("Received request for Pod we don't know") // pod already in the logger's context
-		return nil, 404, errors.New("pod not found")
-	} else if podObj.Spec.NodeName == "" {
-		logger.Warn("Received request for Pod we don't know where it was scheduled")
-		return nil, 404, errors.New("pod's node is unknown")
+	podObj, vmName, status, err := validateAgentRequest(logger, req, getPod)
+	if err != nil {
+		return nil, status, err
 	}
 
 	nodeName = podObj.Spec.NodeName // set nodeName for deferred metrics
 
-	vmRef, ok := vmv1.VirtualMachineOwnerForPod(podObj)
-	if !ok {
-		logger.Error("Received request for non-VM Pod")
-		return nil, 400, errors.New("pod is not associated with a VM")
-	}
-	vmName := util.NamespacedName{
-		Namespace: podObj.Namespace,
-		Name:      vmRef.Name,
-	}
-
 	// From this point, we'll:
 	//
 	// 1. Update the annotations on the VirtualMachine object, if this request should change them;
@@ -213,6 +178,7 @@ func (s *PluginState) handleAgentRequest(
 		resp := api.PluginResponse{
 			Permit:  req.Resources,
 			Migrate: nil,
+			Partial: nil,
 		}
 		status = 200
 		logger.Info("Handled agent request", zap.Int("status", status), zap.Any("response", resp))
@@ -225,8 +191,7 @@ func (s *PluginState) handleAgentRequest(
 		return nil, 404, errors.New("pod not found")
 	}
 
-	// FIXME: make the timeout configurable.
-	updateTimeout := time.NewTimer(time.Second)
+	updateTimeout := time.NewTimer(s.config.approvalWait())
 	defer updateTimeout.Stop()
 
 	for {
@@ -249,7 +214,7 @@ func (s *PluginState) handleAgentRequest(
 			return nil, 404, errors.New("pod not found")
 		}
 
-		podState, err := state.PodStateFromK8sObj(podObj)
+		approved, requested, err := podPermitState(podObj)
 		if err != nil {
 			logger.Error("Failed to extract Pod state from Pod object for agent request")
 			return nil, 500, errors.New("failed to extract state from pod")
@@ -261,23 +226,8 @@ func (s *PluginState) handleAgentRequest(
 		// So, we should keep waiting until the approved resources have increased from the
 		// LastPermit in the request.
 
-		approved := api.Resources{
-			VCPU: podState.CPU.Reserved,
-			Mem:  podState.Mem.Reserved,
-		}
-		requested := api.Resources{
-			VCPU: podState.CPU.Requested,
-			Mem:  podState.Mem.Requested,
-		}
-
 		canReturn := requested == req.Resources
-		var shouldReturn bool
-		if req.LastPermit == nil {
-			_, hasApproved := podObj.Annotations[api.InternalAnnotationResourcesApproved]
-			shouldReturn = canReturn && hasApproved
-		} else {
-			shouldReturn = canReturn && approved.HasFieldGreaterThan(*req.LastPermit)
-		}
+		shouldReturn := canReturn && permitIncreased(podObj, approved, req.LastPermit)
 
 		// Return if we have results, or if we've timed out and it's good enough.
 		if shouldReturn || (timedOut && canReturn) {
@@ -287,6 +237,7 @@ func (s *PluginState) handleAgentRequest(
 			resp := api.PluginResponse{
 				Permit:  approved,
 				Migrate: nil,
+				Partial: s.partialPermit(req, podObj, approved),
 			}
 			status = 200
 			logger.Info("Handled agent request", zap.Int("status", status), zap.Any("response", resp))
@@ -294,8 +245,20 @@ func (s *PluginState) handleAgentRequest(
 		}
 
 		// ... otherwise, if we timed out and our updates to the VM *haven't* yet been reflected on
-		// the pod, we don't have anything we can return, so we should return an error.
+		// the pod, we can only return the previous permit -- if the agent supports it.
 		if timedOut {
+			if partial := s.pendingPermit(req); partial != nil {
+				logger.Warn("Timed out while waiting for updates, responding with previous permit")
+				resp := api.PluginResponse{
+					Permit:  *req.LastPermit,
+					Migrate: nil,
+					Partial: partial,
+				}
+				status = 200
+				logger.Info("Handled agent request", zap.Int("status", status), zap.Any("response", resp))
+				return &resp, status, nil
+			}
+
 			logger.Error("Timed out while waiting for updates without suitable response to agent request")
 			return nil, 500, errors.New("timed out waiting for updates to be processed")
 		}
@@ -305,6 +268,86 @@ func (s *PluginState) handleAgentRequest(
 	}
 }
 
+// validateAgentRequest checks that the request is valid and refers to a VM pod we know about,
+// returning the pod and its VM.
+//
+// If the request is invalid, the returned error and status code describe why.
+func validateAgentRequest(
+	logger *zap.Logger,
+	req api.AgentRequest,
+	getPod func(util.NamespacedName) (*corev1.Pod, bool),
+) (_ *corev1.Pod, _ util.NamespacedName, status int, _ error) {
+	// Before doing anything, check that the version is within the range we're expecting.
+	expectedProtoRange := api.VersionRange[api.PluginProtoVersion]{
+		Min: MinPluginProtocolVersion,
+		Max: MaxPluginProtocolVersion,
+	}
+
+	if !req.ProtoVersion.IsValid() {
+		return nil, util.NamespacedName{}, 400, fmt.Errorf("invalid protocol version %v", req.ProtoVersion)
+	}
+	reqProtoRange := req.ProtocolRange()
+	if _, ok := expectedProtoRange.LatestSharedVersion(reqProtoRange); !ok {
+		return nil, util.NamespacedName{}, 400, fmt.Errorf(
+			"protocol version mismatch: need %v but got %v", expectedProtoRange, reqProtoRange,
+		)
+	}
+
+	// check that req.ComputeUnit has no zeros
+	if err := req.ComputeUnit.ValidateNonZero(); err != nil {
+		return nil, util.NamespacedName{}, 400, fmt.Errorf("computeUnit fields must be non-zero: %w", err)
+	}
+
+	podObj, ok := getPod(req.Pod)
+	if !ok {
+		logger.Warn("Received request for Pod we don't know") // pod already in the logger's context
+		return nil, util.NamespacedName{}, 404, errors.New("pod not found")
+	} else if podObj.Spec.NodeName == "" {
+		logger.Warn("Received request for Pod we don't know where it was scheduled")
+		return nil, util.NamespacedName{}, 404, errors.New("pod's node is unknown")
+	}
+
+	vmRef, ok := vmv1.VirtualMachineOwnerForPod(podObj)
+	if !ok {
+		logger.Error("Received request for non-VM Pod")
+		return nil, util.NamespacedName{}, 400, errors.New("pod is not associated with a VM")
+	}
+	vmName := util.NamespacedName{
+		Namespace: podObj.Namespace,
+		Name:      vmRef.Name,
+	}
+
+	return podObj, vmName, 200, nil
+}
+
+// podPermitState returns the resources approved and requested for the pod, from its annotations.
+func podPermitState(pod *corev1.Pod) (approved api.Resources, requested api.Resources, _ error) {
+	podState, err := state.PodStateFromK8sObj(pod)
+	if err != nil {
+		return api.Resources{}, api.Resources{}, err
+	}
+
+	approved = api.Resources{
+		VCPU: podState.CPU.Reserved,
+		Mem:  podState.Mem.Reserved,
+	}
+	requested = api.Resources{
+		VCPU: podState.CPU.Requested,
+		Mem:  podState.Mem.Requested,
+	}
+	return approved, requested, nil
+}
+
+// permitIncreased returns whether the approved resources for the pod have increased from
+// lastPermit. If lastPermit is nil, this is whether the pod has any approved resources at all.
+func permitIncreased(pod *corev1.Pod, approved api.Resources, lastPermit *api.Resources) bool {
+	if lastPermit == nil {
+		_, hasApproved := pod.Annotations[api.InternalAnnotationResourcesApproved]
+		return hasApproved
+	}
+	return approved.HasFieldGreaterThan(*lastPermit)
+}
+
 func vmPatchForAgentRequest(pod *corev1.Pod, req api.AgentRequest) (_ []patch.Operation, changed bool) {
 	marshalJSON := func(value any) string {
 		bs, err := json.Marshal(value)
@@ -361,3 +404,63 @@ func vmPatchForAgentRequest(pod *corev1.Pod, req api.AgentRequest) (_ []patch.Op
 
 	return patches, changed
 }
+
+// partialPermit returns the explanation for why the request was only partially approved, or nil if
+// it was fully approved or the agent doesn't support partial permits.
+func (s *PluginState) partialPermit(req api.AgentRequest, pod *corev1.Pod, permit api.Resources) *api.PartialPermit {
+	cfg := s.config.PartialPermits
+	if cfg == nil || !req.ProtoVersion.IncludesPartialPermits() || !req.Resources.HasFieldGreaterThan(permit) {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ns, ok := s.nodes[pod.Spec.NodeName]
+	if !ok {
+		return nil
+	}
+	node := ns.node
+
+	if p, ok := node.GetPod(pod.UID); ok && p.Migrating {
+		return &api.PartialPermit{
+			Reason:            api.PartialPermitReasonPodMigrating,
+			Message:           "VM is being migrated",
+			RetryAfterSeconds: uint(cfg.MigrationRetryAfterSeconds),
+		}
+	}
+
+	message := fmt.Sprintf(
+		"node %s has %d/%d CPU and %d/%d memory reserved (watermarks %d, %d), with %d CPU and %d memory migrating",
+		node.Name,
+		node.CPU.Reserved, node.CPU.Total, node.Mem.Reserved, node.Mem.Total,
+		node.CPU.Watermark, node.Mem.Watermark, node.CPU.Migrating, node.Mem.Migrating,
+	)
+
+	if node.CPU.Migrating > 0 || node.Mem.Migrating > 0 || len(ns.requestedMigrations) != 0 {
+		return &api.PartialPermit{
+			Reason:            api.PartialPermitReasonMigrationInProgress,
+			Message:           message,
+			RetryAfterSeconds: uint(cfg.MigrationRetryAfterSeconds),
+		}
+	}
+
+	return &api.PartialPermit{
+		Reason:            api.PartialPermitReasonNodeFull,
+		Message:           message,
+		RetryAfterSeconds: uint(cfg.NodeFullRetryAfterSeconds),
+	}
+}
+
+// pendingPermit returns the explanation to give when we timed out before the request was processed,
+// or nil if we can't respond with the request's LastPermit instead of an error.
+func (s *PluginState) pendingPermit(req api.AgentRequest) *api.PartialPermit {
+	if s.config.PartialPermits == nil || !req.ProtoVersion.IncludesPartialPermits() || req.LastPermit == nil {
+		return nil
+	}
+	return &api.PartialPermit{
+		Reason:            api.PartialPermitReasonPending,
+		Message:           "timed out waiting for request to be processed",
+		RetryAfterSeconds: 0,
+	}
+}
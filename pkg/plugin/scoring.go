@@ -0,0 +1,182 @@
+package plugin
+
+// Strategies for scoring where Pods should be placed.
+
+import (
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/plugin/state"
+)
+
+// Names of the built-in scoring strategies, for use in ScoringStrategyConfig.
+const (
+	// ScoringUsageCurve scores nodes with the curve given by ScoringConfig's MinUsageScore,
+	// MaxUsageScore, and ScorePeak, scaled by the size of the node relative to the largest one.
+	//
+	// This is the default when no strategies are configured.
+	ScoringUsageCurve = "usageCurve"
+	// ScoringLeastAllocated prefers the nodes with the most unreserved resources, to spread pods
+	// out across the cluster.
+	ScoringLeastAllocated = "leastAllocated"
+	// ScoringMostAllocated prefers the nodes with the least unreserved resources, to pack pods onto
+	// fewer nodes so that cluster-autoscaler can remove the rest.
+	ScoringMostAllocated = "mostAllocated"
+	// ScoringBalanced prefers the nodes where the fractions of CPU and memory reserved would be
+	// closest to each other, so that neither resource is left stranded.
+	ScoringBalanced = "balanced"
+	// ScoringUpscaleHeadroom prefers the nodes with enough unreserved resources for the VMs on them
+	// to scale up to their maximum size.
+	ScoringUpscaleHeadroom = "upscaleHeadroom"
+)
+
+// scoringStrategy gives the score of a node for a pod, from 0 (worst) to 1 (best).
+type scoringStrategy interface {
+	score(input scoringInput) float64
+}
+
+// scoringInput is the information available to a scoringStrategy
+type scoringInput struct {
+	// node is the state of the node, with the pod added
+	node *state.Node
+	// cpuGrowth and memGrowth are the expected growth of the VMs on the node, which should be
+	// treated as already reserved. They are zero if GrowthReserveConfig is not set.
+	cpuGrowth vmv1.MilliCPU
+	memGrowth api.Bytes
+	// maxNodeCPU and maxNodeMem are the largest amount of each resource we've seen on a node.
+	maxNodeCPU vmv1.MilliCPU
+	maxNodeMem api.Bytes
+}
+
+// scoringStrategyFunc is a scoringStrategy from a plain function
+type scoringStrategyFunc func(input scoringInput) float64
+
+func (f scoringStrategyFunc) score(input scoringInput) float64 {
+	return f(input)
+}
+
+// scoringStrategies maps the name of each built-in strategy to a function creating it from the
+// config.
+var scoringStrategies = map[string]func(cfg ScoringConfig) scoringStrategy{
+	ScoringUsageCurve: func(cfg ScoringConfig) scoringStrategy {
+		return scoringStrategyFunc(func(input scoringInput) float64 {
+			node := input.node
+			cpuScore := calculateScore(cfg, node.CPU.Reserved+input.cpuGrowth, node.CPU.Total, input.maxNodeCPU)
+			memScore := calculateScore(cfg, node.Mem.Reserved+input.memGrowth, node.Mem.Total, input.maxNodeMem)
+			return min(cpuScore, memScore)
+		})
+	},
+	ScoringLeastAllocated: func(ScoringConfig) scoringStrategy {
+		return scoringStrategyFunc(func(input scoringInput) float64 {
+			cpu, mem := usageFractions(input)
+			return ((1 - cpu) + (1 - mem)) / 2
+		})
+	},
+	ScoringMostAllocated: func(ScoringConfig) scoringStrategy {
+		return scoringStrategyFunc(func(input scoringInput) float64 {
+			cpu, mem := usageFractions(input)
+			return (cpu + mem) / 2
+		})
+	},
+	ScoringBalanced: func(ScoringConfig) scoringStrategy {
+		return scoringStrategyFunc(func(input scoringInput) float64 {
+			cpu, mem := usageFractions(input)
+			return 1 - max(cpu-mem, mem-cpu)
+		})
+	},
+	ScoringUpscaleHeadroom: func(ScoringConfig) scoringStrategy {
+		return scoringStrategyFunc(upscaleHeadroomScore)
+	},
+}
+
+// weightedScoringStrategy is a scoringStrategy from the config, with its weight
+type weightedScoringStrategy struct {
+	name     string
+	weight   float64
+	strategy scoringStrategy
+}
+
+// strategies returns the configured scoring strategies, or only ScoringUsageCurve if there are
+// none.
+//
+// The config must have already been validated, so that all the strategies exist.
+func (c ScoringConfig) strategies() []weightedScoringStrategy {
+	configured := c.Strategies
+	if len(configured) == 0 {
+		configured = []ScoringStrategyConfig{{Name: ScoringUsageCurve, Weight: 1}}
+	}
+
+	var strategies []weightedScoringStrategy
+	for _, s := range configured {
+		strategies = append(strategies, weightedScoringStrategy{
+			name:     s.Name,
+			weight:   s.Weight,
+			strategy: scoringStrategies[s.Name](c),
+		})
+	}
+	return strategies
+}
+
+// strategyScores stores the score from each strategy, for logging
+type strategyScores map[string]float64
+
+func (s strategyScores) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for name, score := range s {
+		enc.AddFloat64(name, score)
+	}
+	return nil
+}
+
+// scoreNode returns the weighted average of the scores from each strategy, from 0 to 1, alongside
+// the individual scores.
+func scoreNode(strategies []weightedScoringStrategy, input scoringInput) (float64, zap.Field) {
+	scores := make(strategyScores)
+	var total, totalWeight float64
+	for _, s := range strategies {
+		score := min(max(s.strategy.score(input), 0), 1)
+		scores[s.name] = score
+		total += s.weight * score
+		totalWeight += s.weight
+	}
+
+	if totalWeight == 0 {
+		return 0, zap.Object("StrategyScores", scores)
+	}
+	return total / totalWeight, zap.Object("StrategyScores", scores)
+}
+
+// usageFractions returns the fraction of the node's CPU and memory that are reserved, including
+// expected growth
+func usageFractions(input scoringInput) (cpu float64, mem float64) {
+	node := input.node
+	cpu = (node.CPU.Reserved + input.cpuGrowth).AsFloat64() / max(node.CPU.Total.AsFloat64(), 1)
+	mem = (node.Mem.Reserved + input.memGrowth).AsFloat64() / max(node.Mem.Total.AsFloat64(), 1)
+	return min(cpu, 1), min(mem, 1)
+}
+
+// upscaleHeadroomScore returns the fraction of the potential upscaling from VMs on the node (i.e.,
+// the sum of Max - Reserved) that fits in the node's unreserved resources, taking whichever
+// resource has less.
+//
+// Nodes where all VMs could scale to their maximum size get a score of 1.
+func upscaleHeadroomScore(input scoringInput) float64 {
+	var cpuUpscale vmv1.MilliCPU
+	var memUpscale api.Bytes
+	for _, pod := range input.node.Pods() {
+		cpuUpscale += pod.CPU.Max - min(pod.CPU.Max, pod.CPU.Reserved)
+		memUpscale += pod.Mem.Max - min(pod.Mem.Max, pod.Mem.Reserved)
+	}
+
+	fits := func(free, upscale float64) float64 {
+		if upscale == 0 {
+			return 1
+		}
+		return min(max(free, 0)/upscale, 1)
+	}
+
+	cpuFree := input.node.CPU.Total.AsFloat64() - input.node.CPU.Reserved.AsFloat64()
+	memFree := input.node.Mem.Total.AsFloat64() - input.node.Mem.Reserved.AsFloat64()
+	return min(fits(cpuFree, cpuUpscale.AsFloat64()), fits(memFree, memUpscale.AsFloat64()))
+}
@@ -0,0 +1,68 @@
+package state
+
+import (
+	"math"
+	"slices"
+
+	"golang.org/x/exp/constraints"
+
+	vmv1 "github.com/neondatabase/autoscaling/neonvm/apis/neonvm/v1"
+	"github.com/neondatabase/autoscaling/pkg/api"
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+// ExpectedGrowth estimates how much the resources reserved on the node will increase as the VMs on
+// it scale up, after applying overcommit factors.
+//
+// Each pod's "headroom" is the amount it may still grow by (Max - Reserved), and its pending growth
+// is the amount it's requested but not yet been given (Requested - Reserved). We take the given
+// quantile of pending growth as a fraction of headroom across all pods on the node (or minFraction,
+// if that's larger), and expect each pod to grow by that fraction of its headroom -- or by its own
+// pending growth, if that's larger.
+//
+// Pods that are migrating are not included, because they are expected to leave the node.
+func (n *Node) ExpectedGrowth(quantile float64, minFraction float64) (vmv1.MilliCPU, api.Bytes) {
+	var cpu []PodResources[vmv1.MilliCPU]
+	var mem []PodResources[api.Bytes]
+	for _, pod := range n.pods.Entries() {
+		if pod.Migrating {
+			continue
+		}
+		cpu = append(cpu, pod.CPU)
+		mem = append(mem, pod.Mem)
+	}
+
+	return expectedGrowth(cpu, quantile, minFraction), expectedGrowth(mem, quantile, minFraction)
+}
+
+func expectedGrowth[T constraints.Unsigned](pods []PodResources[T], quantile float64, minFraction float64) T {
+	// headroom and pending return the pod's (Max - Reserved) and (Requested - Reserved), with
+	// pending bounded by headroom.
+	headroom := func(p PodResources[T]) T {
+		return util.SaturatingSub(p.Max, p.Reserved)
+	}
+	pending := func(p PodResources[T]) T {
+		return min(util.SaturatingSub(p.Requested, p.Reserved), headroom(p))
+	}
+
+	var fractions []float64
+	for _, p := range pods {
+		if h := headroom(p); h != 0 {
+			fractions = append(fractions, float64(pending(p))/float64(h))
+		}
+	}
+	if len(fractions) == 0 {
+		return 0
+	}
+
+	slices.Sort(fractions)
+	i := int(math.Ceil(quantile*float64(len(fractions)))) - 1
+	fraction := max(minFraction, fractions[min(max(i, 0), len(fractions)-1)])
+
+	var total T
+	for _, p := range pods {
+		growth := max(pending(p), T(float64(headroom(p))*fraction))
+		total += applyOvercommit(growth, p.Overcommit)
+	}
+	return total
+}
@@ -40,15 +40,18 @@ func fixedPod(id int, cpu vmv1.MilliCPU, mem api.Bytes) state.Pod {
 		Migratable:     false,
 		AlwaysMigrate:  false,
 		Migrating:      false,
+		Autoscaling:    false,
 		CPU: state.PodResources[vmv1.MilliCPU]{
 			Reserved:   cpu,
 			Requested:  cpu,
+			Max:        cpu,
 			Factor:     0,
 			Overcommit: lo.ToPtr(resource.MustParse("1000m")), // 1000m = 1.0 = "no overcommit"
 		},
 		Mem: state.PodResources[api.Bytes]{
 			Reserved:   mem,
 			Requested:  mem,
+			Max:        mem,
 			Factor:     0,
 			Overcommit: lo.ToPtr(resource.MustParse("1000m")), // 1000m = 1.0 = "no overcommit"
 		},
@@ -232,15 +235,18 @@ func TestPodReconciling(t *testing.T) {
 			Migratable:    false,
 			AlwaysMigrate: false,
 			Migrating:     false,
+			Autoscaling:   true,
 			CPU: state.PodResources[vmv1.MilliCPU]{
 				Reserved:   p.cpu.reserved,
 				Requested:  p.cpu.requested,
+				Max:        16 * factorCPU,
 				Factor:     factorCPU,
 				Overcommit: overcommitFactors.cpu,
 			},
 			Mem: state.PodResources[api.Bytes]{
 				Reserved:   p.mem.reserved,
 				Requested:  p.mem.requested,
+				Max:        16 * factorMem,
 				Factor:     factorMem,
 				Overcommit: overcommitFactors.mem,
 			},
@@ -805,3 +811,77 @@ func TestNodeLabelUpdates(t *testing.T) {
 		})
 	}
 }
+
+func TestExpectedGrowth(t *testing.T) {
+	cpu := vmv1.MilliCPU(1000)
+	gib := api.Bytes(1024 * 1024 * 1024)
+
+	vmPod := func(id int, reserved, requested, maxCPU vmv1.MilliCPU) state.Pod {
+		pod := fixedPod(id, reserved, gib)
+		pod.VirtualMachine = util.NamespacedName{Name: fmt.Sprintf("vm-%d", id), Namespace: "test-namespace"}
+		pod.CPU.Requested = requested
+		pod.CPU.Max = maxCPU
+		return pod
+	}
+
+	// Of the pods with room to grow, one has requested half of its remaining room, and one hasn't
+	// requested anything.
+	pods := []state.Pod{
+		vmPod(1, 2*cpu, 4*cpu, 6*cpu),
+		vmPod(2, 1*cpu, 1*cpu, 3*cpu),
+		vmPod(3, 2*cpu, 2*cpu, 2*cpu), // already at max
+		fixedPod(4, 1*cpu, gib),       // not a VM
+	}
+	migrating := vmPod(5, 1*cpu, 3*cpu, 5*cpu)
+	migrating.Migrating = true
+	pods = append(pods, migrating)
+
+	cases := []struct {
+		name        string
+		quantile    float64
+		minFraction float64
+		expected    vmv1.MilliCPU
+	}{
+		{
+			// Everyone grows by half of their remaining room: 2 + 1
+			name:        "max-quantile",
+			quantile:    1,
+			minFraction: 0,
+			expected:    3 * cpu,
+		},
+		{
+			// Only the pending growth is expected
+			name:        "median",
+			quantile:    0.5,
+			minFraction: 0,
+			expected:    2 * cpu,
+		},
+		{
+			// Everyone grows to their max: 4 + 2
+			name:        "min-fraction-full",
+			quantile:    0,
+			minFraction: 1,
+			expected:    6 * cpu,
+		},
+		{
+			// The first pod's pending growth is larger than the minimum: 2 + 0.5
+			name:        "min-fraction-partial",
+			quantile:    0.5,
+			minFraction: 0.25,
+			expected:    2500,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			node := state.NodeStateFromParams("node", 20*cpu, 20*gib, defaultWatermarkFraction, nil)
+			for _, p := range pods {
+				node.AddPod(p)
+			}
+
+			cpuGrowth, memGrowth := node.ExpectedGrowth(c.quantile, c.minFraction)
+			assert.Equal(t, c.expected, cpuGrowth)
+			assert.Equal(t, api.Bytes(0), memGrowth)
+		})
+	}
+}
@@ -40,6 +40,9 @@ type Pod struct {
 	// Migrating is true iff there is a VirtualMachineMigration with this pod as the source.
 	Migrating bool
 
+	// Autoscaling is true if this Pod is owned by a VirtualMachine with autoscaling enabled.
+	Autoscaling bool
+
 	CPU PodResources[vmv1.MilliCPU]
 	Mem PodResources[api.Bytes]
 }
@@ -57,6 +60,7 @@ func (p Pod) MarshalLogObject(enc zapcore.ObjectEncoder) error {
 		enc.AddBool("Migratable", p.Migratable)
 		enc.AddBool("AlwaysMigrate", p.AlwaysMigrate)
 		enc.AddBool("Migrating", p.Migrating)
+		enc.AddBool("Autoscaling", p.Autoscaling)
 	}
 	if err := enc.AddReflected("CPU", p.CPU); err != nil {
 		return err
@@ -87,6 +91,12 @@ type PodResources[T constraints.Unsigned] struct {
 	// Reserved -- in effect, it's been given back resources that it previously set aside.
 	Requested T
 
+	// Max is the maximum amount of T that the Pod may be expected to scale up to.
+	//
+	// For a regular Pod, or a VM without autoscaling enabled, this is equal to Reserved. For
+	// autoscaling VMs, this is the VM's maximum resources (or Reserved, if that's larger).
+	Max T
+
 	// Factor is the smallest incremental change in T that can be allocated to the pod.
 	//
 	// For pods that aren't VMs, this should be set to zero, as it has no impact.
@@ -135,16 +145,19 @@ func podStateForNormalPod(pod *corev1.Pod) Pod {
 		Migratable:     false,
 		AlwaysMigrate:  false,
 		Migrating:      false,
+		Autoscaling:    false,
 
 		CPU: PodResources[vmv1.MilliCPU]{
 			Reserved:   cpu,
 			Requested:  cpu,
+			Max:        cpu,
 			Factor:     0,
 			Overcommit: resource.NewMilliQuantity(1000, resource.DecimalSI), // 1000m = 1.0 = "no overcommit"
 		},
 		Mem: PodResources[api.Bytes]{
 			Reserved:   mem,
 			Requested:  mem,
+			Max:        mem,
 			Factor:     0,
 			Overcommit: resource.NewMilliQuantity(1000, resource.DecimalSI), // 1000m = 1.0 = "no overcommit"
 		},
@@ -183,12 +196,18 @@ func podStateForVMRunner(pod *corev1.Pod, vmRef metav1.OwnerReference) (Pod, err
 		return lo.Empty[Pod](), err
 	}
 
-	var scalingUnit, requested, approved *api.Resources
+	var scalingUnit, requested, approved, maxResources *api.Resources
 
 	if !autoscalable {
 		approved = actualResources
 		requested = actualResources
+		maxResources = actualResources
 	} else {
+		maxResources = &api.Resources{
+			VCPU: res.CPUs.Max,
+			Mem:  api.BytesFromResourceQuantity(res.MemorySlotSize) * api.Bytes(res.MemorySlots.Max),
+		}
+
 		scalingUnit, err = api.ExtractScalingUnit(pod)
 		if err != nil {
 			return lo.Empty[Pod](), err
@@ -231,16 +250,19 @@ func podStateForVMRunner(pod *corev1.Pod, vmRef metav1.OwnerReference) (Pod, err
 		Migratable:     migratable,
 		AlwaysMigrate:  alwaysMigrate,
 		Migrating:      migrating,
+		Autoscaling:    autoscalable,
 
 		CPU: PodResources[vmv1.MilliCPU]{
 			Reserved:   approved.VCPU,
 			Requested:  requested.VCPU,
+			Max:        max(maxResources.VCPU, approved.VCPU),
 			Factor:     scalingUnit.VCPU,
 			Overcommit: overcommitFromOptionalQuantity(lo.FromPtr(overcommit).CPU),
 		},
 		Mem: PodResources[api.Bytes]{
 			Reserved:   approved.Mem,
 			Requested:  requested.Mem,
+			Max:        max(maxResources.Mem, approved.Mem),
 			Factor:     scalingUnit.Mem,
 			Overcommit: overcommitFromOptionalQuantity(lo.FromPtr(overcommit).Memory),
 		},
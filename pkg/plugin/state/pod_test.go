@@ -43,11 +43,13 @@ func TestPodStateExtraction(t *testing.T) {
 	}
 
 	type extractedPod struct {
-		vm    *util.NamespacedName
-		flags *flags
+		vm          *util.NamespacedName
+		flags       *flags
+		autoscaling bool
 
 		reserved   resources
 		requested  *resources
+		max        *resources
 		factor     *resources
 		overcommit overcommitFactors
 	}
@@ -84,13 +86,15 @@ func TestPodStateExtraction(t *testing.T) {
 				},
 			},
 			extracted: extractedPod{
-				vm:    nil,
-				flags: nil,
+				vm:          nil,
+				flags:       nil,
+				autoscaling: false,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(750),
 					mem: api.Bytes(1280 * mib),
 				},
 				requested:  nil,
+				max:        nil,
 				factor:     nil,
 				overcommit: defaultOvercommit,
 			},
@@ -121,12 +125,14 @@ func TestPodStateExtraction(t *testing.T) {
 					Name:      "vm-name",
 					Namespace: "test-namespace",
 				},
-				flags: nil,
+				flags:       nil,
+				autoscaling: false,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(1000),
 					mem: api.Bytes(2048 * mib),
 				},
 				requested:  nil,
+				max:        nil,
 				factor:     nil,
 				overcommit: defaultOvercommit,
 			},
@@ -167,7 +173,8 @@ func TestPodStateExtraction(t *testing.T) {
 					Name:      "vm-name",
 					Namespace: "test-namespace",
 				},
-				flags: nil,
+				flags:       nil,
+				autoscaling: true,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(1000),
 					mem: api.Bytes(2048 * mib),
@@ -176,6 +183,10 @@ func TestPodStateExtraction(t *testing.T) {
 					cpu: vmv1.MilliCPU(1500),
 					mem: api.Bytes(3072 * mib),
 				},
+				max: &resources{
+					cpu: vmv1.MilliCPU(1500),
+					mem: api.Bytes(3072 * mib),
+				},
 				factor: &resources{
 					cpu: vmv1.MilliCPU(500),
 					mem: api.Bytes(1024 * mib),
@@ -223,7 +234,8 @@ func TestPodStateExtraction(t *testing.T) {
 					Name:      "vm-name",
 					Namespace: "test-namespace",
 				},
-				flags: nil,
+				flags:       nil,
+				autoscaling: true,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(2000),
 					mem: api.Bytes(4096 * mib),
@@ -232,6 +244,7 @@ func TestPodStateExtraction(t *testing.T) {
 					cpu: vmv1.MilliCPU(1000),
 					mem: api.Bytes(2048 * mib),
 				},
+				max: nil,
 				factor: &resources{
 					cpu: vmv1.MilliCPU(500),
 					mem: api.Bytes(1024 * mib),
@@ -285,6 +298,7 @@ func TestPodStateExtraction(t *testing.T) {
 					alwaysMigrate: false,
 					migrating:     false,
 				},
+				autoscaling: true,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(2000),
 					mem: api.Bytes(4096 * mib),
@@ -293,6 +307,7 @@ func TestPodStateExtraction(t *testing.T) {
 					cpu: vmv1.MilliCPU(1000),
 					mem: api.Bytes(2048 * mib),
 				},
+				max: nil,
 				factor: &resources{
 					cpu: vmv1.MilliCPU(500),
 					mem: api.Bytes(1024 * mib),
@@ -358,6 +373,7 @@ func TestPodStateExtraction(t *testing.T) {
 					alwaysMigrate: false,
 					migrating:     true,
 				},
+				autoscaling: true,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(2000),
 					mem: api.Bytes(4096 * mib),
@@ -366,6 +382,7 @@ func TestPodStateExtraction(t *testing.T) {
 					cpu: vmv1.MilliCPU(1000),
 					mem: api.Bytes(2048 * mib),
 				},
+				max: nil,
 				factor: &resources{
 					cpu: vmv1.MilliCPU(500),
 					mem: api.Bytes(1024 * mib),
@@ -435,6 +452,7 @@ func TestPodStateExtraction(t *testing.T) {
 					// end up.
 					migrating: false,
 				},
+				autoscaling: true,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(2000),
 					mem: api.Bytes(4096 * mib),
@@ -443,6 +461,7 @@ func TestPodStateExtraction(t *testing.T) {
 					cpu: vmv1.MilliCPU(1000),
 					mem: api.Bytes(2048 * mib),
 				},
+				max: nil,
 				factor: &resources{
 					cpu: vmv1.MilliCPU(500),
 					mem: api.Bytes(1024 * mib),
@@ -511,6 +530,7 @@ func TestPodStateExtraction(t *testing.T) {
 					// due to the migration being complete.
 					migrating: true,
 				},
+				autoscaling: true,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(2000),
 					mem: api.Bytes(4096 * mib),
@@ -519,6 +539,7 @@ func TestPodStateExtraction(t *testing.T) {
 					cpu: vmv1.MilliCPU(1000),
 					mem: api.Bytes(2048 * mib),
 				},
+				max: nil,
 				factor: &resources{
 					cpu: vmv1.MilliCPU(500),
 					mem: api.Bytes(1024 * mib),
@@ -556,12 +577,14 @@ func TestPodStateExtraction(t *testing.T) {
 					Name:      "vm-name",
 					Namespace: "test-namespace",
 				},
-				flags: nil,
+				flags:       nil,
+				autoscaling: false,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(1000),
 					mem: api.Bytes(2048 * mib),
 				},
 				requested: nil,
+				max:       nil,
 				factor:    nil,
 				overcommit: overcommitFactors{
 					cpu: lo.ToPtr(resource.MustParse("2500m")),
@@ -598,12 +621,14 @@ func TestPodStateExtraction(t *testing.T) {
 					Name:      "vm-name",
 					Namespace: "test-namespace",
 				},
-				flags: nil,
+				flags:       nil,
+				autoscaling: false,
 				reserved: resources{
 					cpu: vmv1.MilliCPU(1000),
 					mem: api.Bytes(2048 * mib),
 				},
 				requested: nil,
+				max:       nil,
 				factor:    nil,
 				overcommit: overcommitFactors{
 					cpu: lo.ToPtr(resource.MustParse("2500m")),
@@ -657,15 +682,18 @@ func TestPodStateExtraction(t *testing.T) {
 				Migratable:     lo.FromPtr(c.extracted.flags).migratable,
 				AlwaysMigrate:  lo.FromPtr(c.extracted.flags).alwaysMigrate,
 				Migrating:      lo.FromPtr(c.extracted.flags).migrating,
+				Autoscaling:    c.extracted.autoscaling,
 				CPU: state.PodResources[vmv1.MilliCPU]{
 					Reserved:   c.extracted.reserved.cpu,
 					Requested:  lo.FromPtrOr(c.extracted.requested, c.extracted.reserved).cpu,
+					Max:        lo.FromPtrOr(c.extracted.max, c.extracted.reserved).cpu,
 					Factor:     lo.FromPtr(c.extracted.factor).cpu,
 					Overcommit: c.extracted.overcommit.cpu,
 				},
 				Mem: state.PodResources[api.Bytes]{
 					Reserved:   c.extracted.reserved.mem,
 					Requested:  lo.FromPtrOr(c.extracted.requested, c.extracted.reserved).mem,
+					Max:        lo.FromPtrOr(c.extracted.max, c.extracted.reserved).mem,
 					Factor:     lo.FromPtr(c.extracted.factor).mem,
 					Overcommit: c.extracted.overcommit.mem,
 				},
@@ -111,6 +111,28 @@ func watchPodEvents(
 	)
 }
 
+func watchNamespaceEvents(
+	ctx context.Context,
+	parentLogger *zap.Logger,
+	client coreclient.Interface,
+	metrics watch.Metrics,
+) (*watch.Store[corev1.Namespace], error) {
+	return watch.Watch(
+		ctx,
+		parentLogger.Named("watch-namespaces"),
+		client.CoreV1().Namespaces(),
+		watchConfig[corev1.Namespace](metrics),
+		watch.Accessors[*corev1.NamespaceList, corev1.Namespace]{
+			Items: func(list *corev1.NamespaceList) []corev1.Namespace { return list.Items },
+		},
+		watch.InitModeSync,
+		metav1.ListOptions{},
+		// We only need the namespaces for their annotations, which we fetch from the store as
+		// needed.
+		watch.HandlerFuncs[*corev1.Namespace]{},
+	)
+}
+
 func watchMigrationEvents(
 	ctx context.Context,
 	parentLogger *zap.Logger,
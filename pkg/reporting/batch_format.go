@@ -0,0 +1,62 @@
+package reporting
+
+import (
+	"fmt"
+)
+
+// BatchFormat is the serialization format for batches of events, configurable per client with
+// BaseClientConfig.Format.
+type BatchFormat string
+
+const (
+	// BatchFormatJSON is the default format, using JSON lines or a JSON array depending on the
+	// client.
+	BatchFormatJSON BatchFormat = "json"
+	// BatchFormatParquet uses ParquetBuilder
+	BatchFormatParquet BatchFormat = "parquet"
+	// BatchFormatProtobuf uses ProtobufBuilder
+	BatchFormatProtobuf BatchFormat = "protobuf"
+)
+
+// Validate returns an error if the format is not one of the known values. The empty string is
+// valid, and treated as BatchFormatJSON.
+func (f BatchFormat) Validate() error {
+	switch f {
+	case "", BatchFormatJSON, BatchFormatParquet, BatchFormatProtobuf:
+		return nil
+	default:
+		return fmt.Errorf("unknown batch format %q", f)
+	}
+}
+
+// IsJSON returns whether the format is BatchFormatJSON, including when it's unset.
+func (f BatchFormat) IsJSON() bool {
+	return f == "" || f == BatchFormatJSON
+}
+
+// NewBlobBatchBuilder returns the BatchBuilder generator to use for clients that store each batch
+// as a separate object (e.g. S3, Azure Blob Storage, GCS, local files), along with the file
+// extension that object keys should use.
+//
+// JSON and protobuf batches are gzipped; Parquet batches are not.
+func NewBlobBatchBuilder[E any](
+	format BatchFormat,
+	schema *Schema[E],
+) (_ func() BatchBuilder[E], extension string) {
+	switch format {
+	case "", BatchFormatJSON:
+		return func() BatchBuilder[E] {
+			return NewJSONLinesBuilder[E](NewGZIPBuffer())
+		}, ".ndjson.gz"
+	case BatchFormatParquet:
+		return func() BatchBuilder[E] {
+			return NewParquetBuilder(NewByteBuffer(), schema)
+		}, ".parquet"
+	case BatchFormatProtobuf:
+		return func() BatchBuilder[E] {
+			return NewProtobufBuilder(NewGZIPBuffer(), schema)
+		}, ".pb.gz"
+	default:
+		panic(fmt.Sprintf("unknown batch format %q", format))
+	}
+}
@@ -0,0 +1,127 @@
+package reporting
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+var _ BatchBuilder[int] = (*ParquetBuilder[int])(nil)
+
+// ParquetBuilder is a BatchBuilder where the batch is serialized as a single Parquet file, with one
+// column for each field in the Schema.
+//
+// Files are written with a single row group and zstd compression, using dictionary encoding for
+// string columns. The name and version of the schema are included in the file's key-value metadata
+// as "schema_name" and "schema_version".
+//
+// Map fields are stored as a JSON-encoded string column, rather than Parquet's nested MAP type.
+//
+// Unlike the other BatchBuilders, Parquet is a columnar format, so rows are buffered by the writer
+// until the batch is finished. This means that the IOBuffer only receives the complete file, so
+// there's no benefit to using a GZIPBuffer.
+type ParquetBuilder[E any] struct {
+	buf    IOBuffer
+	schema *Schema[E]
+
+	writer *parquet.GenericWriter[any]
+	// columns gives the index of each field's column in the Parquet schema, which orders columns by
+	// name.
+	columns []int
+}
+
+func NewParquetBuilder[E any](buf IOBuffer, schema *Schema[E]) *ParquetBuilder[E] {
+	group := make(parquet.Group)
+	for _, f := range schema.Fields {
+		var node parquet.Node
+		switch f.Type {
+		case FieldTypeString:
+			node = parquet.Encoded(parquet.String(), &parquet.RLEDictionary)
+		case FieldTypeInt64:
+			node = parquet.Int(64)
+		case FieldTypeDouble:
+			node = parquet.Leaf(parquet.DoubleType)
+		case FieldTypeTimestamp:
+			node = parquet.Timestamp(parquet.Microsecond)
+		case FieldTypeStringMap:
+			node = parquet.Encoded(parquet.JSON(), &parquet.RLEDictionary)
+		default:
+			panic(fmt.Sprintf("unknown field type %d", f.Type))
+		}
+		if f.Optional {
+			node = parquet.Optional(node)
+		}
+		group[f.Name] = node
+	}
+	pqSchema := parquet.NewSchema(schema.Name, group)
+
+	columns := make([]int, len(schema.Fields))
+	for i, f := range schema.Fields {
+		leaf, _ := pqSchema.Lookup(f.Name)
+		columns[i] = leaf.ColumnIndex
+	}
+
+	return &ParquetBuilder[E]{
+		buf:    buf,
+		schema: schema,
+		writer: parquet.NewGenericWriter[any](
+			buf,
+			pqSchema,
+			parquet.Compression(&parquet.Zstd),
+			parquet.KeyValueMetadata("schema_name", schema.Name),
+			parquet.KeyValueMetadata("schema_version", strconv.FormatUint(uint64(schema.Version), 10)),
+		),
+		columns: columns,
+	}
+}
+
+func (b *ParquetBuilder[E]) Add(event E) {
+	row := make(parquet.Row, len(b.schema.Fields))
+	for i, f := range b.schema.Fields {
+		v := f.value(event)
+		col := b.columns[i]
+
+		if v.absent {
+			row[col] = parquet.NullValue().Level(0, 0, col)
+			continue
+		}
+
+		var value parquet.Value
+		switch f.Type {
+		case FieldTypeString:
+			value = parquet.ByteArrayValue([]byte(v.str))
+		case FieldTypeInt64, FieldTypeTimestamp:
+			value = parquet.Int64Value(v.int)
+		case FieldTypeDouble:
+			value = parquet.DoubleValue(v.double)
+		case FieldTypeStringMap:
+			// note: json.Marshal sorts map keys, so the encoding is deterministic.
+			encoded, err := json.Marshal(v.strMap)
+			if err != nil {
+				panic(fmt.Sprintf("failed to marshal %q: %s", f.Name, err))
+			}
+			value = parquet.ByteArrayValue(encoded)
+		default:
+			panic(fmt.Sprintf("unknown field type %d", f.Type))
+		}
+
+		definitionLevel := 0
+		if f.Optional {
+			definitionLevel = 1
+		}
+		row[col] = value.Level(0, definitionLevel, col)
+	}
+
+	if _, err := b.writer.WriteRows([]parquet.Row{row}); err != nil {
+		panic(fmt.Sprintf("failed to write: %s", err))
+	}
+}
+
+func (b *ParquetBuilder[E]) Finish() []byte {
+	if err := b.writer.Close(); err != nil {
+		panic(fmt.Sprintf("failed to write: %s", err))
+	}
+	return b.buf.Collect()
+}
@@ -0,0 +1,91 @@
+package reporting_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+	"github.com/parquet-go/parquet-go/format"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/neondatabase/autoscaling/pkg/reporting"
+)
+
+func TestParquetBuilder(t *testing.T) {
+	builder := reporting.NewParquetBuilder(reporting.NewByteBuffer(), schemaTestSchema)
+	events := schemaTestEvents()
+	for _, e := range events {
+		builder.Add(e)
+	}
+	data := builder.Finish()
+
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(len(events)), file.NumRows())
+	name, ok := file.Lookup("schema_name")
+	assert.True(t, ok)
+	assert.Equal(t, "test.Event", name)
+	version, ok := file.Lookup("schema_version")
+	assert.True(t, ok)
+	assert.Equal(t, "3", version)
+
+	type column struct {
+		Name     string
+		Optional bool
+		Type     string
+	}
+	var columns []column
+	for _, f := range file.Schema().Fields() {
+		columns = append(columns, column{Name: f.Name(), Optional: f.Optional(), Type: f.Type().String()})
+	}
+	// Columns are ordered by name
+	assert.Equal(t, []column{
+		{Name: "count", Optional: false, Type: "INT(64,true)"},
+		{Name: "labels", Optional: true, Type: "JSON"},
+		{Name: "name", Optional: false, Type: "STRING"},
+		{Name: "ratio", Optional: true, Type: "DOUBLE"},
+		{Name: "time", Optional: false, Type: "TIMESTAMP(isAdjustedToUTC=true,unit=MICROS)"},
+	}, columns)
+
+	require.Len(t, file.Metadata().RowGroups, 1)
+	for _, c := range file.Metadata().RowGroups[0].Columns {
+		assert.Equal(t, format.Zstd, c.MetaData.Codec, "column %v", c.MetaData.PathInSchema)
+	}
+
+	type row struct {
+		Name   string    `parquet:"name"`
+		Count  int64     `parquet:"count"`
+		Time   time.Time `parquet:"time,timestamp(microsecond)"`
+		Ratio  *float64  `parquet:"ratio,optional"`
+		Labels *string   `parquet:"labels,optional"`
+	}
+	reader := parquet.NewGenericReader[row](file)
+	defer reader.Close()
+	rows := make([]row, len(events)+1)
+	n, err := reader.Read(rows)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, len(events), n)
+
+	for i, e := range events {
+		r := rows[i]
+		assert.Equal(t, e.Name, r.Name)
+		assert.Equal(t, e.Count, r.Count)
+		assert.True(t, e.Time.Equal(r.Time), "expected time %v, got %v", e.Time, r.Time)
+		assert.Equal(t, e.Ratio, r.Ratio)
+
+		// Empty maps are stored as null
+		if len(e.Labels) == 0 {
+			assert.Nil(t, r.Labels)
+		} else {
+			require.NotNil(t, r.Labels)
+			var labels map[string]string
+			require.NoError(t, json.Unmarshal([]byte(*r.Labels), &labels))
+			assert.Equal(t, e.Labels, labels)
+		}
+	}
+}
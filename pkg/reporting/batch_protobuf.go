@@ -0,0 +1,162 @@
+package reporting
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+
+	"github.com/neondatabase/autoscaling/pkg/reporting/reportingpb"
+)
+
+var _ BatchBuilder[int] = (*ProtobufBuilder[int])(nil)
+
+// ProtobufBuilder is a BatchBuilder where each event is serialized as a length-delimited protobuf
+// message, using the Schema's ToProto.
+//
+// Each message is prefixed with its size as a varint (the same format as Java's
+// writeDelimitedTo). The first message in every batch is a BatchHeader, giving the name and version
+// of the schema; see batch.proto.
+type ProtobufBuilder[E any] struct {
+	buf    IOBuffer
+	schema *Schema[E]
+}
+
+// protobufMarshalOptions sorts map entries, so that the encoding of each event is deterministic.
+var protobufMarshalOptions = protodelim.MarshalOptions{
+	MarshalOptions: proto.MarshalOptions{
+		Deterministic: true,
+	},
+}
+
+func NewProtobufBuilder[E any](buf IOBuffer, schema *Schema[E]) *ProtobufBuilder[E] {
+	b := &ProtobufBuilder[E]{
+		buf:    buf,
+		schema: schema,
+	}
+
+	b.writeDelimited(&reportingpb.BatchHeader{
+		SchemaName:    schema.Name,
+		SchemaVersion: schema.Version,
+	})
+
+	return b
+}
+
+func (b *ProtobufBuilder[E]) Add(event E) {
+	b.writeDelimited(b.schema.ToProto(event))
+}
+
+func (b *ProtobufBuilder[E]) writeDelimited(msg proto.Message) {
+	if _, err := protobufMarshalOptions.MarshalTo(b.buf, msg); err != nil {
+		panic(fmt.Sprintf("failed to write: %s", err))
+	}
+}
+
+func (b *ProtobufBuilder[E]) Finish() []byte {
+	return b.buf.Collect()
+}
+
+// DecodeProtobufBatch decodes a batch written by ProtobufBuilder (after decompression), returning
+// each event as a map from field name to value.
+//
+// The events are decoded with the generated protobuf message named by the schema, which must be
+// linked into the binary.
+//
+// Values are string, int64, float64, time.Time (in UTC), or map[string]string, depending on the
+// type of the field. Absent optional fields are not included. Fields that aren't in the schema are
+// ignored, so that batches written with a newer version of the schema can still be read.
+func DecodeProtobufBatch[E any](schema *Schema[E], data []byte) ([]map[string]any, error) {
+	r := bytes.NewReader(data)
+	// MaxSize is disabled because the messages were written by us, and batches are already bounded.
+	opts := protodelim.UnmarshalOptions{MaxSize: -1}
+
+	var header reportingpb.BatchHeader
+	if err := opts.UnmarshalFrom(r, &header); err != nil {
+		return nil, fmt.Errorf("could not decode header: %w", err)
+	} else if header.SchemaName != schema.Name {
+		return nil, fmt.Errorf("batch has schema %q, expected %q", header.SchemaName, schema.Name)
+	}
+
+	msgType, err := protoregistry.GlobalTypes.FindMessageByName(protoreflect.FullName(schema.Name))
+	if err != nil {
+		return nil, fmt.Errorf("could not find protobuf message for schema %q: %w", schema.Name, err)
+	}
+
+	fields := make([]protoreflect.FieldDescriptor, len(schema.Fields))
+	for i, f := range schema.Fields {
+		fields[i] = msgType.Descriptor().Fields().ByName(protoreflect.Name(f.Name))
+		if fields[i] == nil {
+			return nil, fmt.Errorf("protobuf message %q has no field %q", schema.Name, f.Name)
+		} else if !protobufFieldMatches(f.Type, fields[i]) {
+			return nil, fmt.Errorf("protobuf field %q has the wrong type", f.Name)
+		}
+	}
+
+	var events []map[string]any
+	for {
+		msg := msgType.New()
+		if err := opts.UnmarshalFrom(r, msg.Interface()); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("could not decode event %d: %w", len(events), err)
+		}
+
+		event := make(map[string]any)
+		for i, f := range schema.Fields {
+			if f.Optional && !msg.Has(fields[i]) {
+				continue
+			}
+			event[f.Name] = protobufFieldValue(f.Type, msg.Get(fields[i]))
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// protobufFieldMatches returns whether the protobuf field has the type expected for the FieldType
+func protobufFieldMatches(t FieldType, fd protoreflect.FieldDescriptor) bool {
+	switch t {
+	case FieldTypeString:
+		return fd.Kind() == protoreflect.StringKind && !fd.IsList()
+	case FieldTypeInt64, FieldTypeTimestamp:
+		return fd.Kind() == protoreflect.Int64Kind && !fd.IsList()
+	case FieldTypeDouble:
+		return fd.Kind() == protoreflect.DoubleKind && !fd.IsList()
+	case FieldTypeStringMap:
+		return fd.IsMap() &&
+			fd.MapKey().Kind() == protoreflect.StringKind &&
+			fd.MapValue().Kind() == protoreflect.StringKind
+	default:
+		panic(fmt.Sprintf("unknown field type %d", t))
+	}
+}
+
+func protobufFieldValue(t FieldType, v protoreflect.Value) any {
+	switch t {
+	case FieldTypeString:
+		return v.String()
+	case FieldTypeInt64:
+		return v.Int()
+	case FieldTypeTimestamp:
+		return time.UnixMicro(v.Int()).UTC()
+	case FieldTypeDouble:
+		return v.Float()
+	case FieldTypeStringMap:
+		m := make(map[string]string)
+		v.Map().Range(func(key protoreflect.MapKey, value protoreflect.Value) bool {
+			m[key.String()] = value.String()
+			return true
+		})
+		return m
+	default:
+		panic(fmt.Sprintf("unknown field type %d", t))
+	}
+}
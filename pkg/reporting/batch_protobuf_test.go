@@ -0,0 +1,117 @@
+package reporting_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/samber/lo"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protodelim"
+	"google.golang.org/protobuf/proto"
+
+	"github.com/neondatabase/autoscaling/pkg/reporting"
+	"github.com/neondatabase/autoscaling/pkg/reporting/internal/testpb"
+	"github.com/neondatabase/autoscaling/pkg/reporting/reportingpb"
+)
+
+type schemaTestEvent struct {
+	Name   string
+	Count  int64
+	Time   time.Time
+	Ratio  *float64
+	Labels map[string]string
+}
+
+var schemaTestSchema = &reporting.Schema[schemaTestEvent]{
+	Name:    "test.Event",
+	Version: 3,
+	Fields: []reporting.Field[schemaTestEvent]{
+		reporting.StringField("name", func(e schemaTestEvent) string { return e.Name }),
+		reporting.Int64Field("count", func(e schemaTestEvent) int64 { return e.Count }),
+		reporting.TimestampField("time", func(e schemaTestEvent) time.Time { return e.Time }),
+		reporting.OptionalDoubleField("ratio", func(e schemaTestEvent) *float64 { return e.Ratio }),
+		reporting.StringMapField("labels", func(e schemaTestEvent) map[string]string { return e.Labels }),
+	},
+	ToProto: func(e schemaTestEvent) proto.Message {
+		return &testpb.Event{
+			Name:   e.Name,
+			Count:  e.Count,
+			Time:   e.Time.UnixMicro(),
+			Ratio:  e.Ratio,
+			Labels: e.Labels,
+		}
+	},
+}
+
+func schemaTestEvents() []schemaTestEvent {
+	ratio := 0.25
+	return []schemaTestEvent{
+		{Name: "foo", Count: 1, Time: time.UnixMicro(1_700_000_000_000_000), Ratio: &ratio, Labels: nil},
+		{Name: "", Count: -5, Time: time.UnixMicro(1_700_000_001_000_000), Ratio: nil, Labels: map[string]string{"team": "a", "project": "b"}},
+		{Name: "bar", Count: 1 << 40, Time: time.UnixMicro(1_700_000_002_000_000), Ratio: nil, Labels: map[string]string{}},
+	}
+}
+
+func TestProtobufBuilder(t *testing.T) {
+	builder := reporting.NewProtobufBuilder(reporting.NewByteBuffer(), schemaTestSchema)
+	for _, e := range schemaTestEvents() {
+		builder.Add(e)
+	}
+	r := bytes.NewReader(builder.Finish())
+
+	var header reportingpb.BatchHeader
+	require.NoError(t, protodelim.UnmarshalFrom(r, &header))
+	assert.Equal(t, "test.Event", header.SchemaName)
+	assert.Equal(t, uint32(3), header.SchemaVersion)
+
+	expected := []*testpb.Event{
+		{Name: "foo", Count: 1, Time: 1_700_000_000_000_000, Ratio: lo.ToPtr(0.25), Labels: nil},
+		{Name: "", Count: -5, Time: 1_700_000_001_000_000, Ratio: nil, Labels: map[string]string{"team": "a", "project": "b"}},
+		{Name: "bar", Count: 1 << 40, Time: 1_700_000_002_000_000, Ratio: nil, Labels: nil},
+	}
+	for i, e := range expected {
+		var event testpb.Event
+		require.NoError(t, protodelim.UnmarshalFrom(r, &event))
+		assert.True(t, proto.Equal(e, &event), "event %d: expected %v, got %v", i, e, &event)
+	}
+	assert.Zero(t, r.Len())
+}
+
+func TestDecodeProtobufBatch(t *testing.T) {
+	builder := reporting.NewProtobufBuilder(reporting.NewByteBuffer(), schemaTestSchema)
+	for _, e := range schemaTestEvents() {
+		builder.Add(e)
+	}
+	data := builder.Finish()
+
+	events, err := reporting.DecodeProtobufBatch(schemaTestSchema, data)
+	require.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{"name": "foo", "count": int64(1), "time": time.UnixMicro(1_700_000_000_000_000).UTC(), "ratio": 0.25},
+		{"name": "", "count": int64(-5), "time": time.UnixMicro(1_700_000_001_000_000).UTC(), "labels": map[string]string{"team": "a", "project": "b"}},
+		{"name": "bar", "count": int64(1 << 40), "time": time.UnixMicro(1_700_000_002_000_000).UTC()},
+	}, events)
+
+	// Batches for a different schema are rejected.
+	otherSchema := &reporting.Schema[schemaTestEvent]{
+		Name:    "test.Other",
+		Version: 1,
+		Fields:  schemaTestSchema.Fields,
+		ToProto: schemaTestSchema.ToProto,
+	}
+	_, err = reporting.DecodeProtobufBatch(otherSchema, data)
+	assert.EqualError(t, err, `batch has schema "test.Event", expected "test.Other"`)
+
+	// Fields that aren't in the schema are ignored.
+	oldSchema := &reporting.Schema[schemaTestEvent]{
+		Name:    schemaTestSchema.Name,
+		Version: 1,
+		Fields:  schemaTestSchema.Fields[:2],
+		ToProto: schemaTestSchema.ToProto,
+	}
+	events, err = reporting.DecodeProtobufBatch(oldSchema, data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]any{"name": "foo", "count": int64(1)}, events[0])
+}
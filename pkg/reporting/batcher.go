@@ -2,6 +2,7 @@ package reporting
 
 import (
 	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -30,21 +31,39 @@ type eventBatcher[E any] struct {
 	onComplete    func()
 	completedSize int
 
+	// spool, if not nil, stores completed batches on disk until they're dropped.
+	spool *spool
+
 	sizeGauge prometheus.Gauge
 }
 
 type batch[E any] struct {
 	serialized []byte
 	count      int
+	// spoolPath is the path to the batch in the spool, or "" if it's not stored there.
+	spoolPath string
+
+	// createdAt is the time the batch was completed, or restored from the spool.
+	createdAt time.Time
+	// attempts is the number of failed attempts to send the batch.
+	attempts uint
+	// retryAfter is the earliest time that the batch should be sent again, after a failed attempt.
+	retryAfter time.Time
 }
 
+// newEventBatcher creates a new eventBatcher
+//
+// If spool is not nil, completed batches will be stored in it, and restored will be treated as
+// already completed batches.
 func newEventBatcher[E any](
 	targetBatchSize int,
 	newBatch func() BatchBuilder[E],
 	notifyCompletedBatch func(),
 	sizeGauge prometheus.Gauge,
+	spool *spool,
+	restored []spooledBatch,
 ) *eventBatcher[E] {
-	return &eventBatcher[E]{
+	b := &eventBatcher[E]{
 		mu: sync.Mutex{},
 
 		targetBatchSize: targetBatchSize,
@@ -57,8 +76,29 @@ func newEventBatcher[E any](
 		onComplete:    notifyCompletedBatch,
 		completedSize: 0,
 
+		spool: spool,
+
 		sizeGauge: sizeGauge,
 	}
+
+	now := time.Now()
+	for _, r := range restored {
+		b.completed = append(b.completed, batch[E]{
+			serialized: r.serialized,
+			count:      r.count,
+			spoolPath:  r.path,
+			createdAt:  now,
+			attempts:   0,
+			retryAfter: time.Time{},
+		})
+		b.completedSize += r.count
+	}
+	if len(restored) != 0 {
+		b.updateGauge()
+		b.onComplete()
+	}
+
+	return b
 }
 
 // enqueue adds an event to the current in-progress batch.
@@ -114,6 +154,19 @@ func (b *eventBatcher[E]) peekLatestCompleted() batch[E] {
 	return b.completed[0]
 }
 
+// markLatestFailed records a failed attempt to send the most recently completed batch, returning
+// the updated batch.
+//
+// This method will panic if (*eventBatcher[E]).completedCount() is zero.
+func (b *eventBatcher[E]) markLatestFailed(retryAfter time.Time) batch[E] {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.completed[0].attempts += 1
+	b.completed[0].retryAfter = retryAfter
+	return b.completed[0]
+}
+
 // dropLatestCompleted drops the most recently completed batch from internal storage.
 //
 // This method will panic if (*eventBatcher[E]).completedCount() is zero.
@@ -125,6 +178,10 @@ func (b *eventBatcher[e]) dropLatestCompleted() {
 	b.completed = b.completed[1:]
 	b.completedSize -= batch.count
 
+	if batch.spoolPath != "" {
+		b.spool.remove(batch.spoolPath, len(batch.serialized))
+	}
+
 	b.updateGauge()
 }
 
@@ -135,9 +192,20 @@ func (b *eventBatcher[E]) updateGauge() {
 
 // NB: must hold mu
 func (b *eventBatcher[E]) finishCurrentBatch() {
+	serialized := b.ongoing.Finish()
+
+	var spoolPath string
+	if b.spool != nil {
+		spoolPath = b.spool.write(serialized, b.ongoingSize)
+	}
+
 	b.completed = append(b.completed, batch[E]{
-		serialized: b.ongoing.Finish(),
+		serialized: serialized,
 		count:      b.ongoingSize,
+		spoolPath:  spoolPath,
+		createdAt:  time.Now(),
+		attempts:   0,
+		retryAfter: time.Time{},
 	})
 
 	b.completedSize += b.ongoingSize
@@ -3,6 +3,7 @@ package reporting
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/assert"
@@ -25,6 +26,14 @@ func (b *csvBatchBuilder) Finish() []byte {
 	return b.buf.Bytes()
 }
 
+// peekIgnoringTime returns the latest completed batch, with its creation time removed so that it can
+// be compared directly.
+func peekIgnoringTime[E any](b *eventBatcher[E]) batch[E] {
+	batch := b.peekLatestCompleted()
+	batch.createdAt = time.Time{}
+	return batch
+}
+
 func TestEventBatching(t *testing.T) {
 	targetBatchSize := 3
 
@@ -41,7 +50,7 @@ func TestEventBatching(t *testing.T) {
 		}
 	}
 
-	batcher := newEventBatcher(targetBatchSize, newBatch, notify, gauge)
+	batcher := newEventBatcher(targetBatchSize, newBatch, notify, gauge, nil, nil)
 
 	// First batch:
 	// Add a small number of items to the batch, and then explicitly request early completion.
@@ -56,8 +65,8 @@ func TestEventBatching(t *testing.T) {
 	assert.Equal(t, true, notified)
 	assert.Equal(t, 1, batcher.completedCount())
 	assert.Equal(t,
-		batch[string]{count: 2, serialized: []byte("b1-1,b1-2")},
-		batcher.peekLatestCompleted())
+		batch[string]{count: 2, serialized: []byte("b1-1,b1-2"), spoolPath: "", createdAt: time.Time{}, attempts: 0, retryAfter: time.Time{}},
+		peekIgnoringTime(batcher))
 	// clear the current batch:
 	notified = false
 	batcher.dropLatestCompleted()
@@ -80,8 +89,8 @@ func TestEventBatching(t *testing.T) {
 	// check that the batches so far match what we expect:
 	assert.Equal(t, 2, batcher.completedCount())
 	assert.Equal(t,
-		batch[string]{count: 3, serialized: []byte("b2-1,b2-2,b2-3")},
-		batcher.peekLatestCompleted())
+		batch[string]{count: 3, serialized: []byte("b2-1,b2-2,b2-3"), spoolPath: "", createdAt: time.Time{}, attempts: 0, retryAfter: time.Time{}},
+		peekIgnoringTime(batcher))
 	// add the last batch:
 	batcher.enqueue("b4-1")
 	batcher.enqueue("b4-2")
@@ -91,22 +100,22 @@ func TestEventBatching(t *testing.T) {
 	// Check that the final batches are what we expect
 	assert.Equal(t, 3, batcher.completedCount())
 	assert.Equal(t,
-		batch[string]{count: 3, serialized: []byte("b2-1,b2-2,b2-3")},
-		batcher.peekLatestCompleted())
+		batch[string]{count: 3, serialized: []byte("b2-1,b2-2,b2-3"), spoolPath: "", createdAt: time.Time{}, attempts: 0, retryAfter: time.Time{}},
+		peekIgnoringTime(batcher))
 	// Consume one batch:
 	batcher.dropLatestCompleted()
 	// and now, it should just be b3 and b4:
 	assert.Equal(t, 2, batcher.completedCount())
 	assert.Equal(t,
-		batch[string]{count: 3, serialized: []byte("b3-1,b3-2,b3-3")},
-		batcher.peekLatestCompleted())
+		batch[string]{count: 3, serialized: []byte("b3-1,b3-2,b3-3"), spoolPath: "", createdAt: time.Time{}, attempts: 0, retryAfter: time.Time{}},
+		peekIgnoringTime(batcher))
 	// consume b3:
 	batcher.dropLatestCompleted()
 	// ... so it should just be b4:
 	assert.Equal(t, 1, batcher.completedCount())
 	assert.Equal(t,
-		batch[string]{count: 3, serialized: []byte("b4-1,b4-2,b4-3")},
-		batcher.peekLatestCompleted())
+		batch[string]{count: 3, serialized: []byte("b4-1,b4-2,b4-3"), spoolPath: "", createdAt: time.Time{}, attempts: 0, retryAfter: time.Time{}},
+		peekIgnoringTime(batcher))
 	// and after consuming the last one...
 	batcher.dropLatestCompleted()
 	// ... there should be nothing left:
@@ -11,6 +11,20 @@ type Client[E any] struct {
 	Base            BaseClient
 	BaseConfig      BaseClientConfig
 	NewBatchBuilder func() BatchBuilder[E]
+
+	// Extension is the file extension matching how batches from NewBatchBuilder are serialized,
+	// e.g. ".ndjson.gz". For clients that store each batch as a separate object, this must be the
+	// extension used in their keys.
+	//
+	// This is used so that batches sent to the dead-letter client keep an extension that matches
+	// their contents.
+	Extension string
+
+	// DeadLetter, if not nil, is the client that batches are sent to once they've exceeded the
+	// limits in BaseConfig.Retry.
+	//
+	// This is typically set by ResolveDeadLetters.
+	DeadLetter *Client[E]
 }
 
 // BaseClient is the shared lower-level interface to send the processed data somewhere.
@@ -18,7 +32,7 @@ type Client[E any] struct {
 // It's split into the client itself, intended to be used as a kind of persistent object, and a
 // separate ClientRequest object, intended to be used only for the lifetime of a single request.
 //
-// See S3Client, AzureBlobClient, and HTTPClient.
+// See S3Client, AzureBlobClient, GCSClient, HTTPClient, KafkaClient, and LocalFileClient.
 type BaseClient interface {
 	NewRequest() ClientRequest
 }
@@ -27,6 +41,25 @@ var (
 	_ BaseClient = (*S3Client)(nil)
 	_ BaseClient = (*AzureClient)(nil)
 	_ BaseClient = (*HTTPClient)(nil)
+	_ BaseClient = (*KafkaClient)(nil)
+	_ BaseClient = (*LocalFileClient)(nil)
+	_ BaseClient = (*GCSClient)(nil)
+)
+
+// ObjectClient is a BaseClient that stores each batch as a separate object, named by a generated
+// key.
+type ObjectClient interface {
+	BaseClient
+
+	// NewRequestWithKey is like NewRequest, but passes the generated key through mapKey.
+	NewRequestWithKey(mapKey func(key string) string) ClientRequest
+}
+
+var (
+	_ ObjectClient = (*S3Client)(nil)
+	_ ObjectClient = (*AzureClient)(nil)
+	_ ObjectClient = (*LocalFileClient)(nil)
+	_ ObjectClient = (*GCSClient)(nil)
 )
 
 // ClientRequest is the abstract interface for a single request to send a batch of processed data.
@@ -42,6 +75,18 @@ type BaseClientConfig struct {
 	PushEverySeconds          uint `json:"pushEverySeconds"`
 	PushRequestTimeoutSeconds uint `json:"pushRequestTimeoutSeconds"`
 	MaxBatchSize              uint `json:"maxBatchSize"`
+
+	// Format, if not empty, sets the serialization format for batches. Non-JSON formats are only
+	// supported by clients that store each batch as a separate object.
+	Format BatchFormat `json:"format,omitempty"`
+
+	// Spool, if not nil, enables storing completed batches on disk until they're sent, so that
+	// they are not lost if the process restarts.
+	Spool *SpoolConfig `json:"spool,omitempty"`
+
+	// Retry, if not nil, sets the policy for retrying failed batches. If nil, failed batches are
+	// retried on every push, with no limit.
+	Retry *RetryConfig `json:"retry,omitempty"`
 }
 
 // SimplifiableError is an extension of the standard 'error' interface that provides a
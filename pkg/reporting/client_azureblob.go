@@ -83,9 +83,14 @@ func NewAzureBlobStorageClientWithBaseClient(
 
 // NewRequest implements BaseClient
 func (c AzureClient) NewRequest() ClientRequest {
+	return c.NewRequestWithKey(func(key string) string { return key })
+}
+
+// NewRequestWithKey implements ObjectClient
+func (c AzureClient) NewRequestWithKey(mapKey func(key string) string) ClientRequest {
 	return &azureRequest{
 		AzureClient: c,
-		key:         c.generateKey(),
+		key:         mapKey(c.generateKey()),
 	}
 }
 
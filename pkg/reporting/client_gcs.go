@@ -0,0 +1,225 @@
+package reporting
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+// GCSClient is a BaseClient for Google Cloud Storage, or any other object store implementing the
+// GCS JSON API (e.g., fake-gcs-server for testing).
+//
+// Objects are uploaded with a single "simple upload" request, which is suitable for the size of
+// batches we send.
+type GCSClient struct {
+	cfg    GCSClientConfig
+	client *http.Client
+
+	generateKey func() string
+
+	// token is the access token used to authenticate with GCS.
+	token *gcsTokenSource
+}
+
+type GCSClientConfig struct {
+	Bucket string `json:"bucket"`
+	// Endpoint, if not empty, overrides the default GCS endpoint of "https://storage.googleapis.com".
+	Endpoint string `json:"endpoint"`
+	// TokenPath, if not empty, is the path to a file containing the OAuth2 access token to use for
+	// requests. The file is re-read for every request, so that it can be periodically refreshed.
+	//
+	// If TokenPath is empty, access tokens are fetched from the GCE metadata server, which is
+	// available when running on GKE with Workload Identity.
+	TokenPath string `json:"tokenPath"`
+}
+
+const (
+	gcsDefaultEndpoint  = "https://storage.googleapis.com"
+	gcsMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+)
+
+type gcsRequestError struct {
+	err error
+}
+
+func (e gcsRequestError) Error() string {
+	return fmt.Sprintf("Error making GCS request: %s", e.err.Error())
+}
+
+func (e gcsRequestError) Unwrap() error {
+	return e.err
+}
+
+func (e gcsRequestError) Simplified() string {
+	return util.RootError(e.err).Error()
+}
+
+type gcsUnexpectedStatusCodeError struct {
+	statusCode int
+	body       string
+}
+
+func (e gcsUnexpectedStatusCodeError) Error() string {
+	return fmt.Sprintf("Unexpected GCS status code %d: %s", e.statusCode, e.body)
+}
+
+func (e gcsUnexpectedStatusCodeError) Simplified() string {
+	return fmt.Sprintf("GCS HTTP code %d", e.statusCode)
+}
+
+func NewGCSClient(client *http.Client, cfg GCSClientConfig, generateKey func() string) (*GCSClient, error) {
+	if cfg.Bucket == "" {
+		return nil, errors.New("no bucket configured")
+	}
+
+	return &GCSClient{
+		cfg:         cfg,
+		client:      client,
+		generateKey: generateKey,
+		token: &gcsTokenSource{
+			client:    client,
+			tokenPath: cfg.TokenPath,
+			mu:        sync.Mutex{},
+			cached:    "",
+			expiresAt: time.Time{},
+		},
+	}, nil
+}
+
+// NewRequest implements BaseClient
+func (c *GCSClient) NewRequest() ClientRequest {
+	return c.NewRequestWithKey(func(key string) string { return key })
+}
+
+// NewRequestWithKey implements ObjectClient
+func (c *GCSClient) NewRequestWithKey(mapKey func(key string) string) ClientRequest {
+	return &gcsRequest{
+		GCSClient: c,
+		key:       mapKey(c.generateKey()),
+	}
+}
+
+// gcsRequest is the implementation of ClientRequest used by GCSClient
+type gcsRequest struct {
+	*GCSClient
+	key string
+}
+
+// LogFields implements ClientRequest
+func (r *gcsRequest) LogFields() zap.Field {
+	return zap.Inline(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("bucket", r.cfg.Bucket)
+		enc.AddString("key", r.key)
+		enc.AddString("endpoint", r.cfg.Endpoint)
+		return nil
+	}))
+}
+
+// Send implements ClientRequest
+func (r *gcsRequest) Send(ctx context.Context, payload []byte) SimplifiableError {
+	token, err := r.token.get(ctx)
+	if err != nil {
+		return gcsRequestError{err: fmt.Errorf("could not get access token: %w", err)}
+	}
+
+	endpoint := r.cfg.Endpoint
+	if endpoint == "" {
+		endpoint = gcsDefaultEndpoint
+	}
+	reqURL := fmt.Sprintf(
+		"%s/upload/storage/v1/b/%s/o?uploadType=media&name=%s",
+		strings.TrimRight(endpoint, "/"),
+		url.PathEscape(r.cfg.Bucket),
+		url.QueryEscape(r.key),
+	)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, bytes.NewReader(payload))
+	if err != nil {
+		return gcsRequestError{err: err}
+	}
+	req.Header.Set("content-type", "application/octet-stream")
+	req.Header.Set("authorization", fmt.Sprintf("Bearer %s", token))
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return gcsRequestError{err: err}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return gcsUnexpectedStatusCodeError{statusCode: resp.StatusCode, body: string(body)}
+	}
+
+	return nil
+}
+
+// gcsTokenSource provides the OAuth2 access tokens for GCSClient
+type gcsTokenSource struct {
+	client    *http.Client
+	tokenPath string
+
+	mu        sync.Mutex
+	cached    string
+	expiresAt time.Time
+}
+
+func (s *gcsTokenSource) get(ctx context.Context) (string, error) {
+	if s.tokenPath != "" {
+		content, err := os.ReadFile(s.tokenPath)
+		if err != nil {
+			return "", err
+		}
+		return strings.TrimSpace(string(content)), nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Refresh a little early, so that the token doesn't expire while the request is in flight.
+	if s.cached != "" && time.Now().Add(time.Minute).Before(s.expiresAt) {
+		return s.cached, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcsMetadataTokenURL, http.NoBody)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("metadata-flavor", "Google")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned status code %d", resp.StatusCode)
+	}
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("could not decode metadata server response: %w", err)
+	}
+
+	s.cached = body.AccessToken
+	s.expiresAt = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	return s.cached, nil
+}
@@ -0,0 +1,56 @@
+package reporting
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGCSClientSend(t *testing.T) {
+	uploaded := make(map[string]string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("authorization") != "Bearer test-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost || r.URL.Path != "/upload/storage/v1/b/test-bucket/o" ||
+			r.URL.Query().Get("uploadType") != "media" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		uploaded[r.URL.Query().Get("name")] = string(body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenPath, []byte("test-token\n"), 0o600))
+
+	cfg := GCSClientConfig{
+		Bucket:    "test-bucket",
+		Endpoint:  server.URL,
+		TokenPath: tokenPath,
+	}
+	client, err := NewGCSClient(server.Client(), cfg, func() string { return "prefix/key 1.ndjson.gz" })
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.Nil(t, client.NewRequest().Send(ctx, []byte("payload")))
+	assert.Equal(t, map[string]string{"prefix/key 1.ndjson.gz": "payload"}, uploaded)
+
+	// Errors from the server are reported by status code
+	require.NoError(t, os.WriteFile(tokenPath, []byte("expired-token\n"), 0o600))
+	sendErr := client.NewRequest().Send(ctx, []byte("payload"))
+	require.NotNil(t, sendErr)
+	assert.Equal(t, "GCS HTTP code 401", sendErr.Simplified())
+}
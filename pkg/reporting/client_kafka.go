@@ -0,0 +1,265 @@
+package reporting
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/lithammer/shortuuid"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/sasl"
+	"github.com/twmb/franz-go/pkg/sasl/plain"
+	"github.com/twmb/franz-go/pkg/sasl/scram"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+// KafkaClient is a BaseClient that produces each line of a batch as a separate message to a Kafka
+// topic (or to any other message queue implementing the Kafka protocol).
+//
+// Batches MUST be uncompressed JSON lines, i.e. from a JSONLinesBuilder with a ByteBuffer.
+//
+// Connections to the brokers are kept open between requests.
+type KafkaClient struct {
+	cfg    KafkaClientConfig
+	client *kgo.Client
+}
+
+type KafkaClientConfig struct {
+	// Brokers are the "host:port" addresses of the brokers used to fetch the cluster metadata.
+	Brokers []string `json:"brokers"`
+	// Topic is the topic to produce messages to.
+	Topic string `json:"topic"`
+	// ClientID is sent to the brokers to identify the client.
+	ClientID string `json:"clientID"`
+	// RequireAllAcks, if true, waits for all in-sync replicas to acknowledge each batch, rather than
+	// just the partition leader.
+	RequireAllAcks bool `json:"requireAllAcks"`
+	// TLS, if not nil, enables TLS for connections to the brokers.
+	TLS *KafkaTLSConfig `json:"tls,omitempty"`
+	// SASL, if not nil, enables SASL authentication with the brokers.
+	SASL *KafkaSASLConfig `json:"sasl,omitempty"`
+}
+
+type KafkaTLSConfig struct {
+	// CAPath, if not empty, is the path to a PEM file with the CA certificates used to verify the
+	// brokers. If empty, the system's root certificates are used.
+	CAPath string `json:"caPath,omitempty"`
+	// ServerName, if not empty, overrides the hostname used to verify the brokers' certificates.
+	ServerName string `json:"serverName,omitempty"`
+}
+
+// Supported values of KafkaSASLConfig.Mechanism
+const (
+	KafkaSASLPlain       = "PLAIN"
+	KafkaSASLScramSHA256 = "SCRAM-SHA-256"
+	KafkaSASLScramSHA512 = "SCRAM-SHA-512"
+)
+
+type KafkaSASLConfig struct {
+	// Mechanism is the SASL mechanism to use. One of "PLAIN", "SCRAM-SHA-256", or "SCRAM-SHA-512".
+	Mechanism string `json:"mechanism"`
+	// Username is the user to authenticate as.
+	Username string `json:"username"`
+	// PasswordPath is the path to a file containing the password. The file is re-read each time
+	// we authenticate, so that it can be rotated.
+	PasswordPath string `json:"passwordPath"`
+}
+
+// kafkaRecordRetries is the number of times a failing produce request is retried by the client
+// before we give up on the batch.
+//
+// Batches are retried anyways on the next push, so there's no need to keep retrying until the
+// request times out -- and giving up earlier means we can report the actual error.
+const kafkaRecordRetries = 3
+
+// kafkaNetworkError is returned by KafkaClient for failures to connect or communicate with brokers.
+type kafkaNetworkError struct {
+	err error
+}
+
+func (e kafkaNetworkError) Error() string {
+	return fmt.Sprintf("Error communicating with Kafka broker: %s", e.err.Error())
+}
+
+func (e kafkaNetworkError) Unwrap() error {
+	return e.err
+}
+
+func (e kafkaNetworkError) Simplified() string {
+	return util.RootError(e.err).Error()
+}
+
+// kafkaBrokerError is returned by KafkaClient when a broker responds with an error code.
+type kafkaBrokerError struct {
+	err *kerr.Error
+}
+
+func (e kafkaBrokerError) Error() string {
+	return fmt.Sprintf("Kafka broker returned error code %d (%s) when producing messages", e.err.Code, e.err.Message)
+}
+
+func (e kafkaBrokerError) Unwrap() error {
+	return e.err
+}
+
+func (e kafkaBrokerError) Simplified() string {
+	return fmt.Sprintf("Kafka %s", e.err.Message)
+}
+
+func NewKafkaClient(cfg KafkaClientConfig) (*KafkaClient, error) {
+	return newKafkaClient(cfg)
+}
+
+// newKafkaClient is NewKafkaClient, but with extra options for the underlying client, for use in
+// tests.
+func newKafkaClient(cfg KafkaClientConfig, extraOpts ...kgo.Opt) (*KafkaClient, error) {
+	if len(cfg.Brokers) == 0 {
+		return nil, errors.New("no brokers configured")
+	} else if cfg.Topic == "" {
+		return nil, errors.New("no topic configured")
+	}
+
+	opts := []kgo.Opt{
+		kgo.SeedBrokers(cfg.Brokers...),
+		kgo.DefaultProduceTopic(cfg.Topic),
+		kgo.RecordRetries(kafkaRecordRetries),
+	}
+	if cfg.ClientID != "" {
+		opts = append(opts, kgo.ClientID(cfg.ClientID))
+	}
+	if cfg.RequireAllAcks {
+		opts = append(opts, kgo.RequiredAcks(kgo.AllISRAcks()))
+	} else {
+		// Idempotent writes require acks from all in-sync replicas.
+		opts = append(opts, kgo.RequiredAcks(kgo.LeaderAck()), kgo.DisableIdempotentWrite())
+	}
+
+	if cfg.TLS != nil {
+		tlsConfig, err := cfg.TLS.build()
+		if err != nil {
+			return nil, fmt.Errorf("invalid TLS config: %w", err)
+		}
+		opts = append(opts, kgo.DialTLSConfig(tlsConfig))
+	}
+	if cfg.SASL != nil {
+		mechanism, err := cfg.SASL.build()
+		if err != nil {
+			return nil, fmt.Errorf("invalid SASL config: %w", err)
+		}
+		opts = append(opts, kgo.SASL(mechanism))
+	}
+
+	client, err := kgo.NewClient(append(opts, extraOpts...)...)
+	if err != nil {
+		return nil, fmt.Errorf("could not create Kafka client: %w", err)
+	}
+
+	return &KafkaClient{
+		cfg:    cfg,
+		client: client,
+	}, nil
+}
+
+func (c *KafkaTLSConfig) build() (*tls.Config, error) {
+	//nolint:exhaustruct // there's lots of fields; defaults are fine for the rest.
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+	if c.CAPath != "" {
+		pem, err := os.ReadFile(c.CAPath)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", c.CAPath)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+func (c *KafkaSASLConfig) build() (sasl.Mechanism, error) {
+	readPassword := func() (string, error) {
+		content, err := os.ReadFile(c.PasswordPath)
+		if err != nil {
+			return "", fmt.Errorf("could not read SASL password: %w", err)
+		}
+		return strings.TrimSpace(string(content)), nil
+	}
+	scramAuth := func(context.Context) (scram.Auth, error) {
+		password, err := readPassword()
+		//nolint:exhaustruct // other fields are optional
+		return scram.Auth{User: c.Username, Pass: password}, err
+	}
+
+	switch c.Mechanism {
+	case KafkaSASLPlain:
+		return plain.Plain(func(context.Context) (plain.Auth, error) {
+			password, err := readPassword()
+			//nolint:exhaustruct // other fields are optional
+			return plain.Auth{User: c.Username, Pass: password}, err
+		}), nil
+	case KafkaSASLScramSHA256:
+		return scram.Sha256(scramAuth), nil
+	case KafkaSASLScramSHA512:
+		return scram.Sha512(scramAuth), nil
+	default:
+		return nil, fmt.Errorf("unknown SASL mechanism %q", c.Mechanism)
+	}
+}
+
+// NewRequest implements BaseClient
+func (c *KafkaClient) NewRequest() ClientRequest {
+	return &kafkaRequest{
+		KafkaClient: c,
+		traceID:     shortuuid.New(),
+	}
+}
+
+// kafkaRequest is the implementation of ClientRequest used by KafkaClient
+type kafkaRequest struct {
+	*KafkaClient
+	traceID string
+}
+
+// LogFields implements ClientRequest
+func (r *kafkaRequest) LogFields() zap.Field {
+	return zap.Inline(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("topic", r.cfg.Topic)
+		enc.AddString("traceID", r.traceID)
+		return nil
+	}))
+}
+
+// Send implements ClientRequest
+func (r *kafkaRequest) Send(ctx context.Context, payload []byte) SimplifiableError {
+	var records []*kgo.Record
+	for _, line := range bytes.Split(payload, []byte{'\n'}) {
+		if len(line) != 0 {
+			records = append(records, kgo.SliceRecord(line))
+		}
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	if err := r.client.ProduceSync(ctx, records...).FirstErr(); err != nil {
+		var brokerErr *kerr.Error
+		if errors.As(err, &brokerErr) {
+			return kafkaBrokerError{err: brokerErr}
+		}
+		return kafkaNetworkError{err: err}
+	}
+	return nil
+}
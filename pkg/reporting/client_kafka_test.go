@@ -0,0 +1,298 @@
+package reporting
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/orlangure/gnomock"
+	kafkapreset "github.com/orlangure/gnomock/preset/kafka"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/twmb/franz-go/pkg/kerr"
+	"github.com/twmb/franz-go/pkg/kfake"
+	"github.com/twmb/franz-go/pkg/kgo"
+	"github.com/twmb/franz-go/pkg/kmsg"
+)
+
+// countingListener is a net.Listener that counts the number of accepted connections
+type countingListener struct {
+	net.Listener
+	accepted *atomic.Int32
+}
+
+func (l countingListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err == nil {
+		l.accepted.Add(1)
+	}
+	return conn, err
+}
+
+// consumeKafkaMessages reads n messages from the topic, across all partitions, returning them
+// sorted.
+func consumeKafkaMessages(t *testing.T, brokers []string, topic string, n int) []string {
+	consumer, err := kgo.NewClient(
+		kgo.SeedBrokers(brokers...),
+		kgo.ConsumeTopics(topic),
+		kgo.ConsumeResetOffset(kgo.NewOffset().AtStart()),
+	)
+	require.NoError(t, err)
+	defer consumer.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var messages []string
+	for len(messages) < n {
+		fetches := consumer.PollFetches(ctx)
+		require.NoError(t, ctx.Err())
+		fetches.EachRecord(func(r *kgo.Record) {
+			messages = append(messages, string(r.Value))
+		})
+	}
+	sort.Strings(messages)
+	return messages
+}
+
+func TestKafkaClientSend(t *testing.T) {
+	var accepted atomic.Int32
+	cluster, err := kfake.NewCluster(
+		kfake.NumBrokers(1),
+		kfake.SeedTopics(2, "events"),
+		kfake.ListenFn(func(network, address string) (net.Listener, error) {
+			l, err := net.Listen(network, address)
+			return countingListener{Listener: l, accepted: &accepted}, err
+		}),
+	)
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	client, err := NewKafkaClient(KafkaClientConfig{
+		// first broker isn't listening, so we should fall back to the second.
+		Brokers:        append([]string{"127.0.0.1:1"}, cluster.ListenAddrs()...),
+		Topic:          "events",
+		ClientID:       "test-client",
+		RequireAllAcks: true,
+		TLS:            nil,
+		SASL:           nil,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	require.Nil(t, client.NewRequest().Send(ctx, []byte("{\"a\":1}\n{\"b\":2}\n")))
+	connections := accepted.Load()
+
+	// Connections should be reused between requests.
+	require.Nil(t, client.NewRequest().Send(ctx, []byte("{\"c\":3}\n")))
+	require.Nil(t, client.NewRequest().Send(ctx, []byte("\n")))
+	assert.Equal(t, connections, accepted.Load())
+
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`, `{"c":3}`}, consumeKafkaMessages(t, cluster.ListenAddrs(), "events", 3))
+}
+
+func TestKafkaClientErrors(t *testing.T) {
+	cluster, err := kfake.NewCluster(kfake.NumBrokers(1), kfake.SeedTopics(1, "events"))
+	require.NoError(t, err)
+	defer cluster.Close()
+
+	cluster.ControlKey(int16(kmsg.Produce), func(req kmsg.Request) (kmsg.Response, error, bool) {
+		cluster.KeepControl()
+		produceReq := req.(*kmsg.ProduceRequest)
+		resp := produceReq.ResponseKind().(*kmsg.ProduceResponse)
+		for _, topic := range produceReq.Topics {
+			respTopic := kmsg.NewProduceResponseTopic()
+			respTopic.Topic = topic.Topic
+			for _, partition := range topic.Partitions {
+				respPartition := kmsg.NewProduceResponseTopicPartition()
+				respPartition.Partition = partition.Partition
+				respPartition.ErrorCode = kerr.NotEnoughReplicas.Code
+				respTopic.Partitions = append(respTopic.Partitions, respPartition)
+			}
+			resp.Topics = append(resp.Topics, respTopic)
+		}
+		return resp, nil, true
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	// Failed requests wait for a metadata refresh before they're retried, which by default can only
+	// happen every 5 seconds.
+	fastRetries := kgo.MetadataMinAge(10 * time.Millisecond)
+
+	client, err := newKafkaClient(KafkaClientConfig{
+		Brokers:        cluster.ListenAddrs(),
+		Topic:          "events",
+		ClientID:       "",
+		RequireAllAcks: false,
+		TLS:            nil,
+		SASL:           nil,
+	}, fastRetries)
+	require.NoError(t, err)
+
+	sendErr := client.NewRequest().Send(ctx, []byte("{}\n"))
+	require.NotNil(t, sendErr)
+	assert.Equal(t, "Kafka NOT_ENOUGH_REPLICAS", sendErr.Simplified())
+
+	client, err = newKafkaClient(KafkaClientConfig{
+		Brokers:        cluster.ListenAddrs(),
+		Topic:          "other-topic",
+		ClientID:       "",
+		RequireAllAcks: false,
+		TLS:            nil,
+		SASL:           nil,
+	}, fastRetries)
+	require.NoError(t, err)
+	sendErr = client.NewRequest().Send(ctx, []byte("{}\n"))
+	require.NotNil(t, sendErr)
+	assert.Equal(t, "Kafka UNKNOWN_TOPIC_OR_PARTITION", sendErr.Simplified())
+}
+
+// writeTestCertificate generates a self-signed certificate for 127.0.0.1, writing it to a file in
+// dir and returning both.
+func writeTestCertificate(t *testing.T, dir string) (tls.Certificate, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	//nolint:exhaustruct // this is a test
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "ca.pem")
+	//nolint:exhaustruct // this is a test
+	pemBytes := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	require.NoError(t, os.WriteFile(path, pemBytes, 0o644))
+
+	//nolint:exhaustruct // this is a test
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, path
+}
+
+func TestKafkaClientTLSAndSASL(t *testing.T) {
+	dir := t.TempDir()
+	cert, caPath := writeTestCertificate(t, dir)
+
+	passwordPath := filepath.Join(dir, "password")
+	require.NoError(t, os.WriteFile(passwordPath, []byte("hunter2\n"), 0o600))
+
+	for _, mechanism := range []string{KafkaSASLPlain, KafkaSASLScramSHA256, KafkaSASLScramSHA512} {
+		t.Run(mechanism, func(t *testing.T) {
+			cluster, err := kfake.NewCluster(
+				kfake.NumBrokers(1),
+				kfake.SeedTopics(1, "events"),
+				//nolint:exhaustruct // this is a test
+				kfake.TLS(&tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}),
+				kfake.EnableSASL(),
+				kfake.Superuser(mechanism, "agent", "hunter2"),
+			)
+			require.NoError(t, err)
+			defer cluster.Close()
+
+			newClient := func(username string) *KafkaClient {
+				client, err := NewKafkaClient(KafkaClientConfig{
+					Brokers:        cluster.ListenAddrs(),
+					Topic:          "events",
+					ClientID:       "",
+					RequireAllAcks: true,
+					TLS:            &KafkaTLSConfig{CAPath: caPath, ServerName: ""},
+					SASL: &KafkaSASLConfig{
+						Mechanism:    mechanism,
+						Username:     username,
+						PasswordPath: passwordPath,
+					},
+				})
+				require.NoError(t, err)
+				return client
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			require.Nil(t, newClient("agent").NewRequest().Send(ctx, []byte("{}\n")))
+
+			// Authentication failures are retried until the request times out.
+			ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			assert.NotNil(t, newClient("someone-else").NewRequest().Send(ctx, []byte("{}\n")))
+		})
+	}
+}
+
+func TestKafkaClientInvalidConfig(t *testing.T) {
+	_, err := NewKafkaClient(KafkaClientConfig{
+		Brokers:        []string{"127.0.0.1:9092"},
+		Topic:          "events",
+		ClientID:       "",
+		RequireAllAcks: false,
+		TLS:            &KafkaTLSConfig{CAPath: filepath.Join(t.TempDir(), "missing.pem"), ServerName: ""},
+		SASL:           nil,
+	})
+	assert.ErrorContains(t, err, "could not read CA file")
+
+	_, err = NewKafkaClient(KafkaClientConfig{
+		Brokers:        []string{"127.0.0.1:9092"},
+		Topic:          "events",
+		ClientID:       "",
+		RequireAllAcks: false,
+		TLS:            nil,
+		SASL:           &KafkaSASLConfig{Mechanism: "GSSAPI", Username: "", PasswordPath: ""},
+	})
+	assert.ErrorContains(t, err, `unknown SASL mechanism "GSSAPI"`)
+}
+
+// Checks that KafkaClient works with a real Kafka broker, running in docker.
+func TestKafkaClientRealBroker(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skip long-running test in short mode")
+	}
+
+	container, err := gnomock.Start(
+		kafkapreset.Preset(kafkapreset.WithTopics("events")),
+		gnomock.WithTimeout(3*time.Minute),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = gnomock.Stop(container) })
+
+	brokers := []string{container.Address(kafkapreset.BrokerPort)}
+
+	client, err := NewKafkaClient(KafkaClientConfig{
+		Brokers:        brokers,
+		Topic:          "events",
+		ClientID:       "test-client",
+		RequireAllAcks: true,
+		TLS:            nil,
+		SASL:           nil,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	require.Nil(t, client.NewRequest().Send(ctx, []byte("{\"a\":1}\n{\"b\":2}\n")))
+	require.Nil(t, client.NewRequest().Send(ctx, []byte("{\"c\":3}\n")))
+
+	assert.Equal(t, []string{`{"a":1}`, `{"b":2}`, `{"c":3}`}, consumeKafkaMessages(t, brokers, "events", 3))
+}
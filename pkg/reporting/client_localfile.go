@@ -0,0 +1,190 @@
+package reporting
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"github.com/neondatabase/autoscaling/pkg/util"
+)
+
+// LocalFileClient is a BaseClient that writes each batch to its own file in a local directory,
+// removing the oldest files once there are too many.
+//
+// The files are named by the key generator, in the same way as the keys for blob storage, so they
+// can later be uploaded as-is.
+//
+// Other files in the directory are left alone: on startup, the client only picks up files for
+// which isKey (given to NewLocalFileClient) returns true.
+type LocalFileClient struct {
+	cfg LocalFileClientConfig
+
+	generateKey func() string
+
+	mu sync.Mutex
+	// files are the paths of all the files written by the client, oldest first. This includes
+	// files written before the process was restarted.
+	files []string
+}
+
+type LocalFileClientConfig struct {
+	// Directory is the directory that files are written into.
+	Directory string `json:"directory"`
+	// MaxFiles, if non-zero, is the maximum number of files to keep in the directory. When a new
+	// file would exceed this limit, the oldest files are removed.
+	MaxFiles uint `json:"maxFiles"`
+}
+
+type LocalFileError struct {
+	Err error
+}
+
+func (e LocalFileError) Error() string {
+	return fmt.Sprintf("Error writing local file: %s", e.Err.Error())
+}
+
+func (e LocalFileError) Unwrap() error {
+	return e.Err
+}
+
+func (e LocalFileError) Simplified() string {
+	return util.RootError(e.Err).Error()
+}
+
+// localFileTmpSuffix is appended to files while they're being written, so that a partially written
+// file is never mistaken for a complete one.
+const localFileTmpSuffix = ".tmp"
+
+// NewLocalFileClient creates a new LocalFileClient, writing files named by generateKey.
+//
+// isKey must return whether a path, relative to the directory and slash-separated, could have been
+// produced by generateKey. Only those files (and their leftover temporary files) are included in
+// rotation or removed.
+func NewLocalFileClient(
+	cfg LocalFileClientConfig,
+	generateKey func() string,
+	isKey func(key string) bool,
+) (*LocalFileClient, error) {
+	if err := os.MkdirAll(cfg.Directory, 0o755); err != nil {
+		return nil, fmt.Errorf("could not create directory: %w", err)
+	}
+
+	// Find the files left from previous runs, so that they're included in rotation.
+	type existingFile struct {
+		path    string
+		modTime int64
+	}
+	var existing []existingFile
+	err := filepath.WalkDir(cfg.Directory, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		} else if d.IsDir() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(cfg.Directory, path)
+		if err != nil {
+			return err
+		}
+		key, isTmp := strings.CutSuffix(filepath.ToSlash(rel), localFileTmpSuffix)
+		if !isKey(key) {
+			return nil
+		} else if isTmp {
+			return os.Remove(path)
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		existing = append(existing, existingFile{path: path, modTime: info.ModTime().UnixNano()})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("could not read existing files: %w", err)
+	}
+
+	slices.SortFunc(existing, func(x, y existingFile) int {
+		return cmp.Or(cmp.Compare(x.modTime, y.modTime), strings.Compare(x.path, y.path))
+	})
+
+	var files []string
+	for _, f := range existing {
+		files = append(files, f.path)
+	}
+
+	return &LocalFileClient{
+		cfg:         cfg,
+		generateKey: generateKey,
+		mu:          sync.Mutex{},
+		files:       files,
+	}, nil
+}
+
+// NewRequest implements BaseClient
+func (c *LocalFileClient) NewRequest() ClientRequest {
+	return c.NewRequestWithKey(func(key string) string { return key })
+}
+
+// NewRequestWithKey implements ObjectClient
+func (c *LocalFileClient) NewRequestWithKey(mapKey func(key string) string) ClientRequest {
+	return &localFileRequest{
+		LocalFileClient: c,
+		path:            filepath.Join(c.cfg.Directory, mapKey(c.generateKey())),
+	}
+}
+
+// localFileRequest is the implementation of ClientRequest used by LocalFileClient
+type localFileRequest struct {
+	*LocalFileClient
+	path string
+}
+
+// LogFields implements ClientRequest
+func (r *localFileRequest) LogFields() zap.Field {
+	return zap.Inline(zapcore.ObjectMarshalerFunc(func(enc zapcore.ObjectEncoder) error {
+		enc.AddString("path", r.path)
+		return nil
+	}))
+}
+
+// Send implements ClientRequest
+func (r *localFileRequest) Send(ctx context.Context, payload []byte) SimplifiableError {
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return LocalFileError{Err: err}
+	}
+
+	tmpPath := r.path + localFileTmpSuffix
+	if err := os.WriteFile(tmpPath, payload, 0o644); err != nil {
+		_ = os.Remove(tmpPath)
+		return LocalFileError{Err: err}
+	}
+	if err := os.Rename(tmpPath, r.path); err != nil {
+		_ = os.Remove(tmpPath)
+		return LocalFileError{Err: err}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.files = append(r.files, r.path)
+	if r.cfg.MaxFiles != 0 {
+		for uint(len(r.files)) > r.cfg.MaxFiles {
+			// Failing to remove old files shouldn't cause the batch to be re-sent, so we ignore any
+			// errors here. The file will be left behind, but we won't try to remove it again.
+			_ = os.Remove(r.files[0])
+			r.files = r.files[1:]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package reporting
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalFileClientRotation(t *testing.T) {
+	dir := t.TempDir()
+	cfg := LocalFileClientConfig{Directory: dir, MaxFiles: 2}
+
+	var n int
+	generateKey := func() string {
+		n += 1
+		return fmt.Sprintf("prefix/%d.ndjson", n)
+	}
+
+	isKey := regexp.MustCompile(`^prefix/\d+\.ndjson$`).MatchString
+
+	// Files that weren't written by the client must be left alone.
+	unrelated := []string{
+		filepath.Join(dir, "other.tmp"),
+		filepath.Join(dir, "other/1.ndjson"),
+		filepath.Join(dir, "prefix/notes.txt"+localFileTmpSuffix),
+	}
+	for _, path := range unrelated {
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte("unrelated"), 0o644))
+	}
+
+	client, err := NewLocalFileClient(cfg, generateKey, isKey)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	for i := 1; i <= 3; i++ {
+		require.Nil(t, client.NewRequest().Send(ctx, []byte(fmt.Sprintf("batch %d\n", i))))
+	}
+
+	// The first file should have been removed, to stay within MaxFiles.
+	assert.NoFileExists(t, filepath.Join(dir, "prefix/1.ndjson"))
+	content, err := os.ReadFile(filepath.Join(dir, "prefix/3.ndjson"))
+	require.NoError(t, err)
+	assert.Equal(t, "batch 3\n", string(content))
+
+	// After "restarting", existing files should still count towards the limit, and leftover
+	// temporary files should be removed.
+	tmpPath := filepath.Join(dir, "prefix/9.ndjson"+localFileTmpSuffix)
+	require.NoError(t, os.WriteFile(tmpPath, []byte("partial"), 0o644))
+
+	client, err = NewLocalFileClient(cfg, generateKey, isKey)
+	require.NoError(t, err)
+	assert.NoFileExists(t, tmpPath)
+
+	require.Nil(t, client.NewRequest().Send(ctx, []byte("batch 4\n")))
+	assert.NoFileExists(t, filepath.Join(dir, "prefix/2.ndjson"))
+	assert.FileExists(t, filepath.Join(dir, "prefix/3.ndjson"))
+	assert.FileExists(t, filepath.Join(dir, "prefix/4.ndjson"))
+
+	for _, path := range unrelated {
+		assert.FileExists(t, path)
+	}
+}
@@ -73,9 +73,14 @@ func NewS3Client(
 
 // NewRequest implements BaseClient
 func (c *S3Client) NewRequest() ClientRequest {
+	return c.NewRequestWithKey(func(key string) string { return key })
+}
+
+// NewRequestWithKey implements ObjectClient
+func (c *S3Client) NewRequestWithKey(mapKey func(key string) string) ClientRequest {
 	return &s3Request{
 		S3Client: c,
-		key:      c.generateKey(),
+		key:      mapKey(c.generateKey()),
 	}
 }
 
@@ -0,0 +1,169 @@
+// Protobuf definition for the events used in the tests of ProtobufBuilder.
+//
+// This must be kept in sync with schemaTestSchema in batch_protobuf_test.go.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: pkg/reporting/internal/testpb/test.proto
+
+package testpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type Event struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Name          string                 `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Count         int64                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	Time          int64                  `protobuf:"varint,3,opt,name=time,proto3" json:"time,omitempty"`
+	Ratio         *float64               `protobuf:"fixed64,5,opt,name=ratio,proto3,oneof" json:"ratio,omitempty"`
+	Labels        map[string]string      `protobuf:"bytes,6,rep,name=labels,proto3" json:"labels,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Event) Reset() {
+	*x = Event{}
+	mi := &file_pkg_reporting_internal_testpb_test_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Event) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Event) ProtoMessage() {}
+
+func (x *Event) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_reporting_internal_testpb_test_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Event.ProtoReflect.Descriptor instead.
+func (*Event) Descriptor() ([]byte, []int) {
+	return file_pkg_reporting_internal_testpb_test_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Event) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Event) GetCount() int64 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *Event) GetTime() int64 {
+	if x != nil {
+		return x.Time
+	}
+	return 0
+}
+
+func (x *Event) GetRatio() float64 {
+	if x != nil && x.Ratio != nil {
+		return *x.Ratio
+	}
+	return 0
+}
+
+func (x *Event) GetLabels() map[string]string {
+	if x != nil {
+		return x.Labels
+	}
+	return nil
+}
+
+var File_pkg_reporting_internal_testpb_test_proto protoreflect.FileDescriptor
+
+const file_pkg_reporting_internal_testpb_test_proto_rawDesc = "" +
+	"\n" +
+	"(pkg/reporting/internal/testpb/test.proto\x12\x04test\"\xd6\x01\n" +
+	"\x05Event\x12\x12\n" +
+	"\x04name\x18\x01 \x01(\tR\x04name\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x03R\x05count\x12\x12\n" +
+	"\x04time\x18\x03 \x01(\x03R\x04time\x12\x19\n" +
+	"\x05ratio\x18\x05 \x01(\x01H\x00R\x05ratio\x88\x01\x01\x12/\n" +
+	"\x06labels\x18\x06 \x03(\v2\x17.test.Event.LabelsEntryR\x06labels\x1a9\n" +
+	"\vLabelsEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01B\b\n" +
+	"\x06_ratioBCZAgithub.com/neondatabase/autoscaling/pkg/reporting/internal/testpbb\x06proto3"
+
+var (
+	file_pkg_reporting_internal_testpb_test_proto_rawDescOnce sync.Once
+	file_pkg_reporting_internal_testpb_test_proto_rawDescData []byte
+)
+
+func file_pkg_reporting_internal_testpb_test_proto_rawDescGZIP() []byte {
+	file_pkg_reporting_internal_testpb_test_proto_rawDescOnce.Do(func() {
+		file_pkg_reporting_internal_testpb_test_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_reporting_internal_testpb_test_proto_rawDesc), len(file_pkg_reporting_internal_testpb_test_proto_rawDesc)))
+	})
+	return file_pkg_reporting_internal_testpb_test_proto_rawDescData
+}
+
+var file_pkg_reporting_internal_testpb_test_proto_msgTypes = make([]protoimpl.MessageInfo, 2)
+var file_pkg_reporting_internal_testpb_test_proto_goTypes = []any{
+	(*Event)(nil), // 0: test.Event
+	nil,           // 1: test.Event.LabelsEntry
+}
+var file_pkg_reporting_internal_testpb_test_proto_depIdxs = []int32{
+	1, // 0: test.Event.labels:type_name -> test.Event.LabelsEntry
+	1, // [1:1] is the sub-list for method output_type
+	1, // [1:1] is the sub-list for method input_type
+	1, // [1:1] is the sub-list for extension type_name
+	1, // [1:1] is the sub-list for extension extendee
+	0, // [0:1] is the sub-list for field type_name
+}
+
+func init() { file_pkg_reporting_internal_testpb_test_proto_init() }
+func file_pkg_reporting_internal_testpb_test_proto_init() {
+	if File_pkg_reporting_internal_testpb_test_proto != nil {
+		return
+	}
+	file_pkg_reporting_internal_testpb_test_proto_msgTypes[0].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_reporting_internal_testpb_test_proto_rawDesc), len(file_pkg_reporting_internal_testpb_test_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   2,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_pkg_reporting_internal_testpb_test_proto_goTypes,
+		DependencyIndexes: file_pkg_reporting_internal_testpb_test_proto_depIdxs,
+		MessageInfos:      file_pkg_reporting_internal_testpb_test_proto_msgTypes,
+	}.Build()
+	File_pkg_reporting_internal_testpb_test_proto = out.File
+	file_pkg_reporting_internal_testpb_test_proto_goTypes = nil
+	file_pkg_reporting_internal_testpb_test_proto_depIdxs = nil
+}
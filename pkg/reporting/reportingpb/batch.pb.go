@@ -0,0 +1,139 @@
+// Protobuf definitions shared by all batches written by ProtobufBuilder.
+//
+// Each batch is a sequence of messages, each prefixed by its size as a varint. The first message is
+// always a BatchHeader, and the rest are events of the type given by the header.
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.8
+// 	protoc        (unknown)
+// source: pkg/reporting/batch.proto
+
+package reportingpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type BatchHeader struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// schema_name is the name of the event message type, e.g. "billing.IncrementalEvent"
+	SchemaName string `protobuf:"bytes,1,opt,name=schema_name,json=schemaName,proto3" json:"schema_name,omitempty"`
+	// schema_version is incremented every time fields are added to or removed from the event type.
+	SchemaVersion uint32 `protobuf:"varint,2,opt,name=schema_version,json=schemaVersion,proto3" json:"schema_version,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *BatchHeader) Reset() {
+	*x = BatchHeader{}
+	mi := &file_pkg_reporting_batch_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *BatchHeader) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*BatchHeader) ProtoMessage() {}
+
+func (x *BatchHeader) ProtoReflect() protoreflect.Message {
+	mi := &file_pkg_reporting_batch_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use BatchHeader.ProtoReflect.Descriptor instead.
+func (*BatchHeader) Descriptor() ([]byte, []int) {
+	return file_pkg_reporting_batch_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *BatchHeader) GetSchemaName() string {
+	if x != nil {
+		return x.SchemaName
+	}
+	return ""
+}
+
+func (x *BatchHeader) GetSchemaVersion() uint32 {
+	if x != nil {
+		return x.SchemaVersion
+	}
+	return 0
+}
+
+var File_pkg_reporting_batch_proto protoreflect.FileDescriptor
+
+const file_pkg_reporting_batch_proto_rawDesc = "" +
+	"\n" +
+	"\x19pkg/reporting/batch.proto\x12\treporting\"U\n" +
+	"\vBatchHeader\x12\x1f\n" +
+	"\vschema_name\x18\x01 \x01(\tR\n" +
+	"schemaName\x12%\n" +
+	"\x0eschema_version\x18\x02 \x01(\rR\rschemaVersionB?Z=github.com/neondatabase/autoscaling/pkg/reporting/reportingpbb\x06proto3"
+
+var (
+	file_pkg_reporting_batch_proto_rawDescOnce sync.Once
+	file_pkg_reporting_batch_proto_rawDescData []byte
+)
+
+func file_pkg_reporting_batch_proto_rawDescGZIP() []byte {
+	file_pkg_reporting_batch_proto_rawDescOnce.Do(func() {
+		file_pkg_reporting_batch_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pkg_reporting_batch_proto_rawDesc), len(file_pkg_reporting_batch_proto_rawDesc)))
+	})
+	return file_pkg_reporting_batch_proto_rawDescData
+}
+
+var file_pkg_reporting_batch_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_pkg_reporting_batch_proto_goTypes = []any{
+	(*BatchHeader)(nil), // 0: reporting.BatchHeader
+}
+var file_pkg_reporting_batch_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_pkg_reporting_batch_proto_init() }
+func file_pkg_reporting_batch_proto_init() {
+	if File_pkg_reporting_batch_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pkg_reporting_batch_proto_rawDesc), len(file_pkg_reporting_batch_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_pkg_reporting_batch_proto_goTypes,
+		DependencyIndexes: file_pkg_reporting_batch_proto_depIdxs,
+		MessageInfos:      file_pkg_reporting_batch_proto_msgTypes,
+	}.Build()
+	File_pkg_reporting_batch_proto = out.File
+	file_pkg_reporting_batch_proto_goTypes = nil
+	file_pkg_reporting_batch_proto_depIdxs = nil
+}
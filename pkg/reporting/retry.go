@@ -0,0 +1,105 @@
+package reporting
+
+// Retry policies for failed batches, and dead-letter handling for batches that exceed them
+
+import (
+	"fmt"
+	"time"
+)
+
+type RetryConfig struct {
+	// InitialBackoffSeconds is the minimum time, in seconds, to wait before retrying a batch after
+	// its first failed attempt. The backoff doubles with each subsequent failure.
+	//
+	// Batches are only sent on each push, so backoff durations shorter than the time between
+	// pushes have no effect.
+	InitialBackoffSeconds uint `json:"initialBackoffSeconds"`
+	// MaxBackoffSeconds is the upper limit, in seconds, for the backoff between attempts.
+	MaxBackoffSeconds uint `json:"maxBackoffSeconds"`
+
+	// MaxAttempts, if non-zero, is the maximum number of attempts to send a batch before it is
+	// given up on.
+	MaxAttempts uint `json:"maxAttempts"`
+	// MaxAgeSeconds, if non-zero, is the maximum time, in seconds, after a batch was created that
+	// we will keep trying to send it.
+	MaxAgeSeconds uint `json:"maxAgeSeconds"`
+
+	// DeadLetter, if not empty, is the name of another client that batches are sent to once they
+	// exceed MaxAttempts or MaxAgeSeconds. If empty, those batches are dropped.
+	//
+	// See ResolveDeadLetters for more.
+	DeadLetter string `json:"deadLetter"`
+}
+
+// backoff returns the time to wait after the given number of failed attempts
+func (c *RetryConfig) backoff(attempts uint) time.Duration {
+	backoff := time.Second * time.Duration(c.InitialBackoffSeconds)
+	maxBackoff := time.Second * time.Duration(c.MaxBackoffSeconds)
+
+	for i := uint(1); i < attempts && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, maxBackoff)
+}
+
+// exceeded returns whether we should stop trying to send the batch
+func (c *RetryConfig) exceeded(attempts uint, createdAt time.Time, now time.Time) bool {
+	if c.MaxAttempts != 0 && attempts >= c.MaxAttempts {
+		return true
+	}
+	maxAge := time.Second * time.Duration(c.MaxAgeSeconds)
+	return c.MaxAgeSeconds != 0 && now.Sub(createdAt) >= maxAge
+}
+
+// ResolveDeadLetters sets the DeadLetter for each client with a dead-letter destination in its
+// RetryConfig, returning the clients that should receive events.
+//
+// Clients that are used as a dead-letter destination are only used for that, and are not included
+// in the returned list. Batches are sent to them exactly as they were serialized for the original
+// client, so both clients must be configured with the same format. Dead-letter clients that store
+// each batch as an object name it with the original client's Extension, so that the key matches
+// the contents (e.g., batches from the Kafka client are not gzipped).
+func ResolveDeadLetters[E any](clients []Client[E]) ([]Client[E], error) {
+	byName := make(map[string]*Client[E])
+	for i := range clients {
+		byName[clients[i].Name] = &clients[i]
+	}
+
+	isDeadLetter := make(map[string]bool)
+	for _, c := range clients {
+		if c.BaseConfig.Retry == nil || c.BaseConfig.Retry.DeadLetter == "" {
+			continue
+		}
+
+		name := c.BaseConfig.Retry.DeadLetter
+		target, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("client %q has unknown dead-letter client %q", c.Name, name)
+		} else if name == c.Name {
+			return nil, fmt.Errorf("client %q cannot be its own dead-letter client", c.Name)
+		} else if target.BaseConfig.Retry != nil && target.BaseConfig.Retry.DeadLetter != "" {
+			return nil, fmt.Errorf("dead-letter client %q cannot have its own dead-letter client", name)
+		} else if !sameFormat(target.BaseConfig.Format, c.BaseConfig.Format) {
+			return nil, fmt.Errorf("dead-letter client %q must use the same format as client %q", name, c.Name)
+		}
+		isDeadLetter[name] = true
+	}
+
+	var result []Client[E]
+	for _, c := range clients {
+		if isDeadLetter[c.Name] {
+			continue
+		}
+		if c.BaseConfig.Retry != nil && c.BaseConfig.Retry.DeadLetter != "" {
+			target := *byName[c.BaseConfig.Retry.DeadLetter]
+			c.DeadLetter = &target
+		}
+		result = append(result, c)
+	}
+
+	return result, nil
+}
+
+func sameFormat(a, b BatchFormat) bool {
+	return a == b || (a.IsJSON() && b.IsJSON())
+}
@@ -0,0 +1,143 @@
+package reporting
+
+// Schemas describing the fields of events, for the non-JSON batch formats
+
+import (
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Schema describes the fields of an event type E, for use by ParquetBuilder and ProtobufBuilder.
+//
+// Each schema has a corresponding protobuf message, with Go code generated by protoc-gen-go (see
+// 'make generate-proto'). The message must have a field with the same name and type for each of
+// the schema's fields.
+//
+// The Name and Version are included in each batch, so that downstream consumers can tell which
+// fields to expect. When changing the fields of a schema:
+//
+//   - Adding a new field is backwards-compatible, and should increment Version;
+//   - Removing or changing the type of a field is not backwards-compatible, and should increment
+//     Version *and* never reuse the protobuf field number.
+type Schema[E any] struct {
+	// Name is the full name of the protobuf message, e.g. "billing.IncrementalEvent".
+	Name    string
+	Version uint32
+	Fields  []Field[E]
+
+	// ToProto converts an event to its generated protobuf message, for ProtobufBuilder.
+	ToProto func(E) proto.Message
+}
+
+// FieldType is the type of a single field in a Schema
+type FieldType int
+
+const (
+	FieldTypeString FieldType = iota
+	FieldTypeInt64
+	FieldTypeDouble
+	// FieldTypeTimestamp is stored as an int64 number of microseconds since the unix epoch.
+	FieldTypeTimestamp
+	// FieldTypeStringMap is stored as a map<string, string> in protobuf, and as a JSON object in
+	// Parquet.
+	FieldTypeStringMap
+)
+
+// Field is a single field of an event, with a function to extract its value.
+//
+// Fields are constructed with StringField, Int64Field, DoubleField, OptionalDoubleField,
+// TimestampField, and StringMapField.
+type Field[E any] struct {
+	// Name is the name of the field, used as the Parquet column name. It must be the same as the
+	// name of the protobuf field.
+	Name string
+	Type FieldType
+	// Optional is true if the field may be absent. Only optional fields may return absent values.
+	Optional bool
+
+	value func(E) fieldValue
+}
+
+// fieldValue is the value of a single field for a single event. Only the member corresponding to
+// the field's type is set.
+type fieldValue struct {
+	absent bool
+	str    string
+	int    int64
+	double float64
+	strMap map[string]string
+}
+
+func StringField[E any](name string, get func(E) string) Field[E] {
+	return Field[E]{
+		Name:     name,
+		Type:     FieldTypeString,
+		Optional: false,
+		value: func(e E) fieldValue {
+			return fieldValue{absent: false, str: get(e), int: 0, double: 0, strMap: nil}
+		},
+	}
+}
+
+func Int64Field[E any](name string, get func(E) int64) Field[E] {
+	return Field[E]{
+		Name:     name,
+		Type:     FieldTypeInt64,
+		Optional: false,
+		value: func(e E) fieldValue {
+			return fieldValue{absent: false, str: "", int: get(e), double: 0, strMap: nil}
+		},
+	}
+}
+
+func DoubleField[E any](name string, get func(E) float64) Field[E] {
+	return Field[E]{
+		Name:     name,
+		Type:     FieldTypeDouble,
+		Optional: false,
+		value: func(e E) fieldValue {
+			return fieldValue{absent: false, str: "", int: 0, double: get(e), strMap: nil}
+		},
+	}
+}
+
+// OptionalDoubleField is like DoubleField, but the value is absent when get returns nil.
+func OptionalDoubleField[E any](name string, get func(E) *float64) Field[E] {
+	return Field[E]{
+		Name:     name,
+		Type:     FieldTypeDouble,
+		Optional: true,
+		value: func(e E) fieldValue {
+			v := get(e)
+			if v == nil {
+				return fieldValue{absent: true, str: "", int: 0, double: 0, strMap: nil}
+			}
+			return fieldValue{absent: false, str: "", int: 0, double: *v, strMap: nil}
+		},
+	}
+}
+
+func TimestampField[E any](name string, get func(E) time.Time) Field[E] {
+	return Field[E]{
+		Name:     name,
+		Type:     FieldTypeTimestamp,
+		Optional: false,
+		value: func(e E) fieldValue {
+			return fieldValue{absent: false, str: "", int: get(e).UnixMicro(), double: 0, strMap: nil}
+		},
+	}
+}
+
+// StringMapField is a field with string keys and values. The value is absent when the map is empty.
+func StringMapField[E any](name string, get func(E) map[string]string) Field[E] {
+	return Field[E]{
+		Name:     name,
+		Type:     FieldTypeStringMap,
+		Optional: true,
+		value: func(e E) fieldValue {
+			m := get(e)
+			return fieldValue{absent: len(m) == 0, str: "", int: 0, double: 0, strMap: m}
+		},
+	}
+}
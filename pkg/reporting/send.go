@@ -2,6 +2,7 @@ package reporting
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
@@ -80,7 +81,9 @@ func (s eventSender[E]) senderLoop(ctx context.Context, logger *zap.Logger) {
 		// push the events that have been accumulated so far.
 		timer.Reset(heartbeat)
 
-		s.sendAllCompletedBatches(logger)
+		// When we're about to exit, this is the last chance to send anything, so we ignore any
+		// backoff from previously failed attempts.
+		s.sendAllCompletedBatches(logger, final)
 
 		if final {
 			logger.Info("Ending events sender loop")
@@ -89,7 +92,7 @@ func (s eventSender[E]) senderLoop(ctx context.Context, logger *zap.Logger) {
 	}
 }
 
-func (s eventSender[E]) sendAllCompletedBatches(logger *zap.Logger) {
+func (s eventSender[E]) sendAllCompletedBatches(logger *zap.Logger, ignoreBackoff bool) {
 	logger.Info("Pushing all available event batches")
 
 	if s.queue.completedCount() == 0 {
@@ -132,6 +135,19 @@ func (s eventSender[E]) sendAllCompletedBatches(logger *zap.Logger) {
 
 		batch := s.queue.peekLatestCompleted()
 
+		retry := s.client.BaseConfig.Retry
+		if retry != nil && !ignoreBackoff && time.Now().Before(batch.retryAfter) {
+			logger.Info(
+				"Waiting before retrying events batch",
+				zap.Int("count", batch.count),
+				zap.Uint("attempts", batch.attempts),
+				zap.Time("retryAfter", batch.retryAfter),
+			)
+
+			// note: lastSendDuration is left alone here; it's still flagging the failed attempt.
+			return
+		}
+
 		req := s.client.Base.NewRequest()
 
 		logger.Info(
@@ -153,8 +169,8 @@ func (s eventSender[E]) sendAllCompletedBatches(logger *zap.Logger) {
 		reqDuration := time.Since(reqStart)
 
 		if err != nil {
-			// Something went wrong and we're going to abandon attempting to push any further
-			// events.
+			// Something went wrong and, unless we give up on this batch below, we're going to
+			// abandon attempting to push any further events.
 			logger.Error(
 				"Failed to push billing events",
 				zap.Int("count", batch.count),
@@ -169,9 +185,22 @@ func (s eventSender[E]) sendAllCompletedBatches(logger *zap.Logger) {
 			rootErr := err.Simplified()
 			s.metrics.sendErrorsTotal.WithLabelValues(s.client.Name, rootErr).Inc()
 
-			s.lastSendDuration = 0
-			s.metrics.lastSendDuration.WithLabelValues(s.client.Name).Set(0.0) // use 0 as a flag that something went wrong; there's no valid time here.
-			return
+			now := time.Now()
+			var retryAfter time.Time
+			if retry != nil {
+				retryAfter = now.Add(retry.backoff(batch.attempts + 1))
+			}
+			batch = s.queue.markLatestFailed(retryAfter)
+
+			// If we haven't yet given up on this batch, we'll try again later. Otherwise, try to
+			// get it out of the way so that later batches can be sent.
+			if retry == nil || !retry.exceeded(batch.attempts, batch.createdAt, now) || !s.giveUp(logger, batch) {
+				s.lastSendDuration = 0
+				s.metrics.lastSendDuration.WithLabelValues(s.client.Name).Set(0.0) // use 0 as a flag that something went wrong; there's no valid time here.
+				return
+			}
+
+			continue
 		}
 
 		s.queue.dropLatestCompleted() // mark this batch as complete
@@ -195,3 +224,71 @@ func (s eventSender[E]) sendAllCompletedBatches(logger *zap.Logger) {
 		}
 	}
 }
+
+// giveUp handles a batch that has exceeded the retry policy, either by sending it to the dead-letter
+// client or, if there isn't one, dropping it.
+//
+// Returns whether the batch was removed from the queue.
+func (s eventSender[E]) giveUp(logger *zap.Logger, batch batch[E]) bool {
+	if s.client.DeadLetter == nil {
+		logger.Error(
+			"Dropping events batch after exceeding retry policy",
+			zap.Int("count", batch.count),
+			zap.Uint("attempts", batch.attempts),
+			zap.Time("createdAt", batch.createdAt),
+		)
+		s.metrics.deadLetterBatchesTotal.WithLabelValues(s.client.Name, "dropped").Inc()
+		s.queue.dropLatestCompleted()
+		return true
+	}
+
+	deadLetter := s.client.DeadLetter
+	var req ClientRequest
+	if objClient, ok := deadLetter.Base.(ObjectClient); ok && deadLetter.Extension != s.client.Extension {
+		// The batch is sent as it was serialized for this client, so the key should have this
+		// client's extension, rather than the dead-letter client's.
+		req = objClient.NewRequestWithKey(func(key string) string {
+			return strings.TrimSuffix(key, deadLetter.Extension) + s.client.Extension
+		})
+	} else {
+		req = deadLetter.Base.NewRequest()
+	}
+
+	reqStart := time.Now()
+	err := func() SimplifiableError {
+		reqCtx, cancel := context.WithTimeout(
+			context.TODO(),
+			time.Second*time.Duration(deadLetter.BaseConfig.PushRequestTimeoutSeconds),
+		)
+		defer cancel()
+
+		return req.Send(reqCtx, batch.serialized)
+	}()
+	reqDuration := time.Since(reqStart)
+
+	if err != nil {
+		logger.Error(
+			"Failed to send events batch to dead-letter client",
+			zap.String("deadLetter", deadLetter.Name),
+			zap.Int("count", batch.count),
+			zap.Uint("attempts", batch.attempts),
+			zap.Duration("after", reqDuration),
+			req.LogFields(),
+			zap.Error(err),
+		)
+		s.metrics.deadLetterBatchesTotal.WithLabelValues(s.client.Name, "failed").Inc()
+		return false
+	}
+
+	logger.Warn(
+		"Sent events batch to dead-letter client after exceeding retry policy",
+		zap.String("deadLetter", deadLetter.Name),
+		zap.Int("count", batch.count),
+		zap.Uint("attempts", batch.attempts),
+		zap.Duration("after", reqDuration),
+		req.LogFields(),
+	)
+	s.metrics.deadLetterBatchesTotal.WithLabelValues(s.client.Name, "sent").Inc()
+	s.queue.dropLatestCompleted()
+	return true
+}
@@ -0,0 +1,271 @@
+package reporting
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+// fakeClient is a BaseClient that records the payloads it was sent, optionally failing on payloads
+// that match fail.
+type fakeClient struct {
+	fail func(payload []byte) bool
+	sent [][]byte
+}
+
+type fakeClientError struct{}
+
+func (fakeClientError) Error() string      { return "fake error" }
+func (fakeClientError) Simplified() string { return "fake error" }
+
+func (c *fakeClient) NewRequest() ClientRequest {
+	return fakeRequest{c}
+}
+
+type fakeRequest struct {
+	*fakeClient
+}
+
+func (r fakeRequest) LogFields() zap.Field {
+	return zap.Skip()
+}
+
+func (r fakeRequest) Send(ctx context.Context, payload []byte) SimplifiableError {
+	if r.fail(payload) {
+		return fakeClientError{}
+	}
+	r.sent = append(r.sent, payload)
+	return nil
+}
+
+func newTestSender(client Client[string], metrics *EventSinkMetrics) eventSender[string] {
+	newBatch := func() BatchBuilder[string] {
+		return &csvBatchBuilder{buf: bytes.Buffer{}, started: false}
+	}
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{})
+
+	return eventSender[string]{
+		client:           client,
+		metrics:          metrics,
+		queue:            newEventBatcher(1, newBatch, func() {}, gauge, nil, nil),
+		batchComplete:    nil,
+		lastSendDuration: 0,
+	}
+}
+
+func TestSenderDeadLetter(t *testing.T) {
+	primary := &fakeClient{
+		fail: func(payload []byte) bool { return string(payload) == "poison" },
+		sent: nil,
+	}
+	deadLetter := &fakeClient{
+		fail: func([]byte) bool { return false },
+		sent: nil,
+	}
+
+	clients, err := ResolveDeadLetters([]Client[string]{
+		{
+			Name: "primary",
+			Base: primary,
+			BaseConfig: BaseClientConfig{ //nolint:exhaustruct // this is a test
+				PushRequestTimeoutSeconds: 1,
+				Retry: &RetryConfig{
+					InitialBackoffSeconds: 0,
+					MaxBackoffSeconds:     0,
+					MaxAttempts:           3,
+					MaxAgeSeconds:         0,
+					DeadLetter:            "dead-letter",
+				},
+			},
+			NewBatchBuilder: nil,
+			Extension:       "",
+			DeadLetter:      nil,
+		},
+		{
+			Name:            "dead-letter",
+			Base:            deadLetter,
+			BaseConfig:      BaseClientConfig{PushRequestTimeoutSeconds: 1}, //nolint:exhaustruct // this is a test
+			NewBatchBuilder: nil,
+			Extension:       "",
+			DeadLetter:      nil,
+		},
+	})
+	require.NoError(t, err)
+	// The dead-letter client shouldn't receive events itself.
+	require.Len(t, clients, 1)
+
+	metrics := NewEventSinkMetrics("test", prometheus.NewRegistry())
+	sender := newTestSender(clients[0], metrics)
+
+	sender.queue.enqueue("poison")
+	sender.queue.enqueue("ok")
+
+	// The first couple attempts fail, and block the queue:
+	for range 2 {
+		sender.sendAllCompletedBatches(zap.NewNop(), false)
+		assert.Equal(t, 2, sender.queue.completedCount())
+	}
+	assert.Empty(t, primary.sent)
+	assert.Empty(t, deadLetter.sent)
+
+	// ... until the last attempt, after which it gets moved to the dead-letter client, unblocking
+	// the queue.
+	sender.sendAllCompletedBatches(zap.NewNop(), false)
+	assert.Equal(t, 0, sender.queue.completedCount())
+	assert.Equal(t, [][]byte{[]byte("ok")}, primary.sent)
+	assert.Equal(t, [][]byte{[]byte("poison")}, deadLetter.sent)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.deadLetterBatchesTotal.WithLabelValues("primary", "sent")))
+	assert.Equal(t, 3.0, testutil.ToFloat64(metrics.sendErrorsTotal.WithLabelValues("primary", "fake error")))
+}
+
+func TestSenderDeadLetterExtension(t *testing.T) {
+	primary := &fakeClient{
+		fail: func([]byte) bool { return true },
+		sent: nil,
+	}
+	dir := t.TempDir()
+	deadLetter, err := NewLocalFileClient(
+		LocalFileClientConfig{Directory: dir, MaxFiles: 0},
+		func() string { return "batch.ndjson.gz" },
+		func(string) bool { return false },
+	)
+	require.NoError(t, err)
+
+	clients, err := ResolveDeadLetters([]Client[string]{
+		{
+			Name: "primary",
+			Base: primary,
+			BaseConfig: BaseClientConfig{ //nolint:exhaustruct // this is a test
+				PushRequestTimeoutSeconds: 1,
+				Retry: &RetryConfig{
+					InitialBackoffSeconds: 0,
+					MaxBackoffSeconds:     0,
+					MaxAttempts:           1,
+					MaxAgeSeconds:         0,
+					DeadLetter:            "dead-letter",
+				},
+			},
+			NewBatchBuilder: nil,
+			Extension:       ".ndjson",
+			DeadLetter:      nil,
+		},
+		{
+			Name:            "dead-letter",
+			Base:            deadLetter,
+			BaseConfig:      BaseClientConfig{PushRequestTimeoutSeconds: 1}, //nolint:exhaustruct // this is a test
+			NewBatchBuilder: nil,
+			Extension:       ".ndjson.gz",
+			DeadLetter:      nil,
+		},
+	})
+	require.NoError(t, err)
+
+	sender := newTestSender(clients[0], NewEventSinkMetrics("test", prometheus.NewRegistry()))
+	sender.queue.enqueue("event")
+	sender.sendAllCompletedBatches(zap.NewNop(), false)
+	assert.Equal(t, 0, sender.queue.completedCount())
+
+	// The batch wasn't gzipped by the primary client, so it shouldn't be named as if it was.
+	content, err := os.ReadFile(filepath.Join(dir, "batch.ndjson"))
+	require.NoError(t, err)
+	assert.Equal(t, "event", string(content))
+	assert.NoFileExists(t, filepath.Join(dir, "batch.ndjson.gz"))
+}
+
+func TestSenderBackoff(t *testing.T) {
+	fail := true
+	client := &fakeClient{
+		fail: func([]byte) bool { return fail },
+		sent: nil,
+	}
+
+	metrics := NewEventSinkMetrics("test", prometheus.NewRegistry())
+	sender := newTestSender(Client[string]{
+		Name: "client",
+		Base: client,
+		BaseConfig: BaseClientConfig{ //nolint:exhaustruct // this is a test
+			PushRequestTimeoutSeconds: 1,
+			Retry: &RetryConfig{
+				InitialBackoffSeconds: 60,
+				MaxBackoffSeconds:     600,
+				MaxAttempts:           0,
+				MaxAgeSeconds:         0,
+				DeadLetter:            "",
+			},
+		},
+		NewBatchBuilder: nil,
+		Extension:       "",
+		DeadLetter:      nil,
+	}, metrics)
+
+	sender.queue.enqueue("event")
+	sender.sendAllCompletedBatches(zap.NewNop(), false)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.sendErrorsTotal.WithLabelValues("client", "fake error")))
+
+	// Within the backoff, we shouldn't try again, even if it would succeed.
+	fail = false
+	sender.sendAllCompletedBatches(zap.NewNop(), false)
+	assert.Empty(t, client.sent)
+	assert.Equal(t, 1, sender.queue.completedCount())
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.sendErrorsTotal.WithLabelValues("client", "fake error")))
+	// ... and the last send should still be flagged as failed.
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.lastSendDuration.WithLabelValues("client")))
+
+	// ... unless we're ignoring backoff, e.g. because we're shutting down.
+	sender.sendAllCompletedBatches(zap.NewNop(), true)
+	assert.Equal(t, [][]byte{[]byte("event")}, client.sent)
+	assert.Equal(t, 0, sender.queue.completedCount())
+}
+
+func TestRetryBackoff(t *testing.T) {
+	cfg := RetryConfig{
+		InitialBackoffSeconds: 10,
+		MaxBackoffSeconds:     60,
+		MaxAttempts:           0,
+		MaxAgeSeconds:         0,
+		DeadLetter:            "",
+	}
+
+	var got []float64
+	for attempts := uint(1); attempts <= 5; attempts++ {
+		got = append(got, cfg.backoff(attempts).Seconds())
+	}
+	assert.Equal(t, []float64{10, 20, 40, 60, 60}, got)
+}
+
+func TestResolveDeadLettersErrors(t *testing.T) {
+	client := func(name, deadLetter string) Client[string] {
+		return Client[string]{
+			Name: name,
+			Base: nil,
+			BaseConfig: BaseClientConfig{ //nolint:exhaustruct // this is a test
+				Retry: &RetryConfig{ //nolint:exhaustruct // this is a test
+					DeadLetter: deadLetter,
+				},
+			},
+			NewBatchBuilder: nil,
+			Extension:       "",
+			DeadLetter:      nil,
+		}
+	}
+
+	_, err := ResolveDeadLetters([]Client[string]{client("a", "missing")})
+	assert.Error(t, err)
+	_, err = ResolveDeadLetters([]Client[string]{client("a", "a")})
+	assert.Error(t, err)
+	_, err = ResolveDeadLetters([]Client[string]{client("a", "b"), client("b", "c"), client("c", "")})
+	assert.Error(t, err)
+
+	parquet := client("b", "")
+	parquet.BaseConfig.Format = BatchFormatParquet
+	_, err = ResolveDeadLetters([]Client[string]{client("a", "b"), parquet})
+	assert.Error(t, err)
+}
@@ -39,7 +39,33 @@ func NewEventSink[E any](logger *zap.Logger, metrics *EventSinkMetrics, clients
 
 		sizeGauge := metrics.queueSizeCurrent.WithLabelValues(c.Name)
 
-		batcher := newEventBatcher[E](int(c.BaseConfig.MaxBatchSize), c.NewBatchBuilder, notifyComplete, sizeGauge)
+		var spool *spool
+		var restored []spooledBatch
+		if c.BaseConfig.Spool != nil {
+			var err error
+			spool, restored, err = openSpool(logger.With(zap.String("client", c.Name)), *c.BaseConfig.Spool, spoolMetrics{
+				batches:  metrics.spoolBatchesCurrent.WithLabelValues(c.Name),
+				bytes:    metrics.spoolBytesCurrent.WithLabelValues(c.Name),
+				overflow: metrics.spoolOverflowTotal.WithLabelValues(c.Name),
+			})
+			if err != nil {
+				// Continue without the spool. Events can still be sent; they just won't survive
+				// restarts.
+				logger.Error("Failed to open spool, continuing without it", zap.String("client", c.Name), zap.Error(err))
+				spool = nil
+			} else if len(restored) != 0 {
+				logger.Info("Restored batches from spool", zap.String("client", c.Name), zap.Int("batches", len(restored)))
+			}
+		}
+
+		batcher := newEventBatcher[E](
+			int(c.BaseConfig.MaxBatchSize),
+			c.NewBatchBuilder,
+			notifyComplete,
+			sizeGauge,
+			spool,
+			restored,
+		)
 		queueWriters = append(queueWriters, batcher)
 
 		// Create the sender -- we'll save starting it for the call to Run()
@@ -105,6 +131,12 @@ type EventSinkMetrics struct {
 	queueSizeCurrent *prometheus.GaugeVec
 	lastSendDuration *prometheus.GaugeVec
 	sendErrorsTotal  *prometheus.CounterVec
+
+	spoolBatchesCurrent *prometheus.GaugeVec
+	spoolBytesCurrent   *prometheus.GaugeVec
+	spoolOverflowTotal  *prometheus.CounterVec
+
+	deadLetterBatchesTotal *prometheus.CounterVec
 }
 
 func NewEventSinkMetrics(prefix string, reg prometheus.Registerer) *EventSinkMetrics {
@@ -130,5 +162,33 @@ func NewEventSinkMetrics(prefix string, reg prometheus.Registerer) *EventSinkMet
 			},
 			[]string{"client", "cause"},
 		)),
+		spoolBatchesCurrent: util.RegisterMetric(reg, prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: fmt.Sprintf("%s_spool_batches", prefix),
+				Help: "Number of unsent batches stored in the on-disk spool",
+			},
+			[]string{"client"},
+		)),
+		spoolBytesCurrent: util.RegisterMetric(reg, prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: fmt.Sprintf("%s_spool_size_bytes", prefix),
+				Help: "Total size, in bytes, of the unsent batches stored in the on-disk spool",
+			},
+			[]string{"client"},
+		)),
+		spoolOverflowTotal: util.RegisterMetric(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: fmt.Sprintf("%s_spool_overflow_total", prefix),
+				Help: "Total batches that were not stored in the on-disk spool because it was full",
+			},
+			[]string{"client"},
+		)),
+		deadLetterBatchesTotal: util.RegisterMetric(reg, prometheus.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: fmt.Sprintf("%s_dead_letter_batches_total", prefix),
+				Help: "Total batches that exceeded the retry policy, by what happened to them",
+			},
+			[]string{"client", "outcome"},
+		)),
 	}
 }
@@ -0,0 +1,213 @@
+package reporting
+
+// Write-ahead spool of completed batches, so that they aren't lost if the process restarts before
+// they're sent.
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.uber.org/zap"
+)
+
+type SpoolConfig struct {
+	// Directory is the directory that completed batches are written to until they are sent.
+	//
+	// Each client MUST have its own directory.
+	Directory string `json:"directory"`
+	// MaxSizeMB is the maximum total size, in megabytes, of the batches in the spool. Batches that
+	// would go beyond this limit are kept only in memory.
+	MaxSizeMB uint `json:"maxSizeMB"`
+}
+
+const (
+	spoolFileSuffix = ".batch"
+	spoolTmpSuffix  = ".tmp"
+)
+
+// spool stores completed batches on disk.
+//
+// Each batch is stored in its own file, named "<seq>-<count>.batch", where seq orders the batches
+// and count is the number of events in the batch. Because the batches are stored exactly as they
+// will be sent, the idempotency keys of any events are preserved across restarts.
+//
+// spool is not safe for concurrent use; the eventBatcher only uses it while holding its lock.
+type spool struct {
+	logger *zap.Logger
+
+	dir     string
+	maxSize int64
+
+	size    int64
+	nextSeq uint64
+
+	metrics spoolMetrics
+}
+
+type spoolMetrics struct {
+	batches  prometheus.Gauge
+	bytes    prometheus.Gauge
+	overflow prometheus.Counter
+}
+
+// spooledBatch is a batch that was restored from the spool on startup
+type spooledBatch struct {
+	path       string
+	serialized []byte
+	count      int
+}
+
+// openSpool opens (or creates) the spool in the configured directory, returning any batches that
+// were left from a previous run, oldest first.
+func openSpool(logger *zap.Logger, cfg SpoolConfig, metrics spoolMetrics) (*spool, []spooledBatch, error) {
+	if err := os.MkdirAll(cfg.Directory, 0o755); err != nil {
+		return nil, nil, fmt.Errorf("could not create spool directory: %w", err)
+	}
+
+	entries, err := os.ReadDir(cfg.Directory)
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not read spool directory: %w", err)
+	}
+
+	s := &spool{
+		logger:  logger,
+		dir:     cfg.Directory,
+		maxSize: int64(cfg.MaxSizeMB) << 20,
+		size:    0,
+		nextSeq: 0,
+		metrics: metrics,
+	}
+
+	var restored []spooledBatch
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(s.dir, name)
+
+		if strings.HasSuffix(name, spoolTmpSuffix) {
+			// Partially written batch from a previous run. It was never acknowledged as spooled,
+			// so the events were still in memory, and it's safe to remove.
+			if err := os.Remove(path); err != nil {
+				logger.Warn("Failed to remove temporary spool file", zap.String("path", path), zap.Error(err))
+			}
+			continue
+		} else if !strings.HasSuffix(name, spoolFileSuffix) || entry.IsDir() {
+			continue
+		}
+
+		var seq uint64
+		var count int
+		if _, err := fmt.Sscanf(strings.TrimSuffix(name, spoolFileSuffix), "%d-%d", &seq, &count); err != nil {
+			logger.Warn("Ignoring spool file with unexpected name", zap.String("path", path))
+			continue
+		}
+
+		serialized, err := os.ReadFile(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("could not read spooled batch %q: %w", path, err)
+		}
+
+		restored = append(restored, spooledBatch{path: path, serialized: serialized, count: count})
+		s.size += int64(len(serialized))
+		s.nextSeq = max(s.nextSeq, seq+1)
+	}
+
+	// Files names are zero-padded, so lexicographic ordering is the same as ordering by seq.
+	slices.SortFunc(restored, func(x, y spooledBatch) int {
+		return strings.Compare(x.path, y.path)
+	})
+
+	s.metrics.batches.Set(float64(len(restored)))
+	s.metrics.bytes.Set(float64(s.size))
+
+	return s, restored, nil
+}
+
+// write stores the batch in the spool, returning the path to it, or "" if the batch could not be
+// stored.
+func (s *spool) write(serialized []byte, count int) string {
+	if s.size+int64(len(serialized)) > s.maxSize {
+		s.logger.Warn(
+			"Spool is full, keeping batch only in memory",
+			zap.Int64("spoolSize", s.size),
+			zap.Int("batchSize", len(serialized)),
+		)
+		s.metrics.overflow.Inc()
+		return ""
+	}
+
+	path := filepath.Join(s.dir, fmt.Sprintf("%020d-%d%s", s.nextSeq, count, spoolFileSuffix))
+	s.nextSeq += 1
+
+	// Write to a temporary file first, so that we never restore a partially written batch.
+	tmpPath := path + spoolTmpSuffix
+	err := func() error {
+		if err := writeFileSync(tmpPath, serialized); err != nil {
+			return err
+		}
+		if err := os.Rename(tmpPath, path); err != nil {
+			return err
+		}
+		// Sync the directory as well, so that the rename is persisted.
+		return syncDir(s.dir)
+	}()
+	if err != nil {
+		s.logger.Error("Failed to write batch to spool", zap.String("path", path), zap.Error(err))
+		_ = os.Remove(tmpPath)
+		_ = os.Remove(path)
+		return ""
+	}
+
+	s.size += int64(len(serialized))
+	s.metrics.batches.Inc()
+	s.metrics.bytes.Set(float64(s.size))
+	return path
+}
+
+// remove deletes a batch from the spool, after it's been sent.
+func (s *spool) remove(path string, size int) {
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		// If this fails, the batch will be sent again after restarting. That's ok, because the
+		// idempotency keys are preserved.
+		s.logger.Error("Failed to remove batch from spool", zap.String("path", path), zap.Error(err))
+	}
+
+	s.size -= int64(size)
+	s.metrics.batches.Dec()
+	s.metrics.bytes.Set(float64(s.size))
+}
+
+// writeFileSync is like os.WriteFile, but also syncs the file before closing it, so that the
+// contents are persisted before it's renamed.
+func writeFileSync(path string, data []byte) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// syncDir syncs the directory, so that changes to its entries are persisted.
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	if err := dir.Sync(); err != nil {
+		_ = dir.Close()
+		return err
+	}
+	return dir.Close()
+}
@@ -0,0 +1,107 @@
+package reporting
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+)
+
+func newTestSpoolMetrics() spoolMetrics {
+	return spoolMetrics{
+		batches:  prometheus.NewGauge(prometheus.GaugeOpts{}),
+		bytes:    prometheus.NewGauge(prometheus.GaugeOpts{}),
+		overflow: prometheus.NewCounter(prometheus.CounterOpts{}),
+	}
+}
+
+func TestSpoolRestoresBatches(t *testing.T) {
+	dir := t.TempDir()
+	cfg := SpoolConfig{Directory: dir, MaxSizeMB: 1}
+
+	newBatch := func() BatchBuilder[string] {
+		return &csvBatchBuilder{buf: bytes.Buffer{}, started: false}
+	}
+	gauge := prometheus.NewGauge(prometheus.GaugeOpts{})
+
+	// First "run": create two batches, and send only the first.
+	spool, restored, err := openSpool(zap.NewNop(), cfg, newTestSpoolMetrics())
+	require.NoError(t, err)
+	assert.Empty(t, restored)
+
+	batcher := newEventBatcher(2, newBatch, func() {}, gauge, spool, restored)
+	batcher.enqueue("b1-1")
+	batcher.enqueue("b1-2")
+	batcher.enqueue("b2-1")
+	batcher.finishOngoing()
+	require.Equal(t, 2, batcher.completedCount())
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	batcher.dropLatestCompleted()
+
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	// Leave a partially written batch behind, which should be ignored and cleaned up.
+	tmpPath := filepath.Join(dir, "00000000000000000099-1.batch.tmp")
+	require.NoError(t, os.WriteFile(tmpPath, []byte("partial"), 0o644))
+
+	// Second "run": the unsent batch should be restored.
+	metrics := newTestSpoolMetrics()
+	spool, restored, err = openSpool(zap.NewNop(), cfg, metrics)
+	require.NoError(t, err)
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.batches))
+	assert.NoFileExists(t, tmpPath)
+
+	var notified bool
+	batcher = newEventBatcher(2, newBatch, func() { notified = true }, gauge, spool, restored)
+	assert.True(t, notified)
+	require.Equal(t, 1, batcher.completedCount())
+	b := batcher.peekLatestCompleted()
+	assert.Equal(t, []byte("b2-1"), b.serialized)
+	assert.Equal(t, 1, b.count)
+	assert.Equal(t, 1.0, testutil.ToFloat64(gauge))
+
+	// New batches should be ordered after the restored ones.
+	batcher.enqueue("b3-1")
+	batcher.finishOngoing()
+	batcher.dropLatestCompleted()
+	assert.Equal(t, []byte("b3-1"), batcher.peekLatestCompleted().serialized)
+	batcher.dropLatestCompleted()
+
+	entries, err = os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.batches))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.bytes))
+}
+
+func TestSpoolSizeLimit(t *testing.T) {
+	dir := t.TempDir()
+	metrics := newTestSpoolMetrics()
+	spool, _, err := openSpool(zap.NewNop(), SpoolConfig{Directory: dir, MaxSizeMB: 1}, metrics)
+	require.NoError(t, err)
+
+	big := bytes.Repeat([]byte{'x'}, 600<<10)
+
+	path := spool.write(big, 1)
+	assert.NotEmpty(t, path)
+	// Second write would exceed 1 MiB:
+	assert.Empty(t, spool.write(big, 1))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.overflow))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.batches))
+
+	// After removing the first, there's room again.
+	spool.remove(path, len(big))
+	assert.NotEmpty(t, spool.write(big, 1))
+}